@@ -0,0 +1,88 @@
+package compressedtrie
+
+import (
+	"strings"
+	"sync"
+)
+
+// An Allocator provides the memory for the nodes and labels a tree creates as
+// words are inserted and deleted, and as it is decoded by DeserializeInto. The
+// tree never gives memory back, it is all garbage once the tree is, so an
+// Allocator that reuses memory must know when the tree is no longer used.
+//
+// AllocLabel returns a copy of label. Labels hold no pointers, so the copy may
+// be in memory outside the Go heap, such as a region from syscall.Mmap, made
+// into a string with unsafe.String. This keeps the bulk of a dictionary out of
+// the heap the garbage collector paces itself by. AllocNode returns a pointer
+// to a zero Node. Nodes hold pointers that the garbage collector has to see, so
+// they must be in the Go heap, but can be handed out from large slices to cut
+// the number of allocations and objects it tracks.
+//
+// Nodes the tree copies, such as those of revisions kept by WithHistory, and
+// trees built from t by other functions use the Go heap.
+type Allocator interface {
+	AllocNode() *Node
+	AllocLabel(label string) string
+}
+
+// WithAllocator makes the tree take the memory for its nodes and labels from
+// a, see Allocator.
+func WithAllocator(a Allocator) Option {
+	return func(t *Tree) { t.alloc = a }
+}
+
+// newNode returns a zero node from t's allocator.
+func (t *Tree) newNode() *Node {
+	if t.alloc == nil {
+		return &Node{}
+	}
+	return t.alloc.AllocNode()
+}
+
+// WithCopyOnInsert makes Insert and InsertSortedNext copy each word they are
+// given before keeping any of it. Without it the label of a new leaf, among
+// other things, shares the memory of the word passed in, so a word sliced from
+// a large buffer, such as a file read whole or data made into strings with
+// unsafe.String, keeps all of the buffer alive, or dangling if it is reused or
+// unmapped. The prefixes kept by WithMissCache are always copied. The
+// wrappers that take Options, such as LRUTree, copy the words they keep
+// alongside the tree as well.
+func WithCopyOnInsert() Option {
+	return func(t *Tree) { t.copyStrings = true }
+}
+
+// copied returns s, or a copy of it if t was created with WithCopyOnInsert.
+func (t *Tree) copied(s string) string {
+	if !t.copyStrings {
+		return s
+	}
+	return strings.Clone(s)
+}
+
+// newLabel returns label in memory from t's allocator. Without one label is
+// returned as it is, which for a new leaf keeps the word passed to Insert.
+func (t *Tree) newLabel(label string) string {
+	if t.alloc == nil {
+		return label
+	}
+	return t.alloc.AllocLabel(label)
+}
+
+// lockedAllocator is an Allocator shared by goroutines, such as the workers of
+// a parallel decode, that takes a lock around each call to a.
+type lockedAllocator struct {
+	mu sync.Mutex
+	a  Allocator
+}
+
+func (l *lockedAllocator) AllocNode() *Node {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.a.AllocNode()
+}
+
+func (l *lockedAllocator) AllocLabel(label string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.a.AllocLabel(label)
+}
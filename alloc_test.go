@@ -0,0 +1,147 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+// slabAllocator hands out nodes from slices and copies labels into large byte
+// slices, as an allocator that keeps labels off the heap would.
+type slabAllocator struct {
+	nodes  []Node
+	labels []byte
+	slabs  [][]byte
+	owned  map[*Node]bool
+}
+
+func (a *slabAllocator) AllocNode() *Node {
+	if len(a.nodes) == 0 {
+		a.nodes = make([]Node, 64)
+	}
+	node := &a.nodes[0]
+	a.nodes = a.nodes[1:]
+	a.owned[node] = true
+	return node
+}
+
+func (a *slabAllocator) AllocLabel(label string) string {
+	if label == "" {
+		return ""
+	}
+	if len(label) > cap(a.labels)-len(a.labels) {
+		a.labels = make([]byte, 0, max(4096, len(label)))
+		a.slabs = append(a.slabs, a.labels)
+	}
+	start := len(a.labels)
+	a.labels = append(a.labels, label...)
+	return unsafe.String(&a.labels[start], len(label))
+}
+
+// holds reports whether the bytes of s are in one of a's slabs.
+func (a *slabAllocator) holds(s string) bool {
+	p := uintptr(unsafe.Pointer(unsafe.StringData(s)))
+	for _, slab := range a.slabs {
+		start := uintptr(unsafe.Pointer(unsafe.SliceData(slab)))
+		if p >= start && p+uintptr(len(s)) <= start+uintptr(cap(slab)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAllocator(t *testing.T) {
+	a := &slabAllocator{owned: make(map[*Node]bool)}
+	tree := NewTree(WithAllocator(a))
+	words := perfWords(2000)
+	for _, word := range words {
+		tree.Insert(word)
+	}
+	for _, word := range words[:500] {
+		tree.Delete(word)
+	}
+	expected := perfTree(2000)
+	for _, word := range words[:500] {
+		expected.Delete(word)
+	}
+	if !sameTrees(tree, expected) {
+		t.Fatalf("Tree built with an allocator differs")
+	}
+
+	check := func(what string) {
+		t.Helper()
+		for path, node := range tree.Nodes() {
+			if node == tree.root {
+				continue
+			}
+			if !a.owned[node] || !a.holds(node.label) {
+				t.Fatalf("%s: node %q not from the allocator", what, path)
+			}
+		}
+	}
+	check("Insert and Delete")
+
+	// Nodes beyond those of the old tree come from the allocator too
+	bigger := perfTree(3000)
+	if err := tree.DeserializeInto(bytes.NewReader(bigger.Freeze())); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tree.FindWordsWithPrefix(""), bigger.FindWordsWithPrefix("")) {
+		t.Errorf("DeserializeInto with an allocator lost words")
+	}
+	for path, node := range tree.Nodes() {
+		if path != "" && !a.holds(node.label) {
+			t.Fatalf("DeserializeInto: label %q not from the allocator", path)
+		}
+	}
+}
+
+func TestCopyOnInsert(t *testing.T) {
+	// Words made straight from a buffer that is then reused
+	buf := []byte("alpha alphabet beta")
+	word := func(start, end int) string { return unsafe.String(&buf[start], end-start) }
+	words := []string{word(0, 5), word(6, 14), word(15, 19)}
+
+	tree := NewTree(WithCopyOnInsert(), WithMissCache(4), WithDuplicateReport(4))
+	for _, word := range words {
+		tree.Insert(word)
+	}
+	tree.Insert(words[0])
+	sorted := NewTree(WithCopyOnInsert())
+	for _, word := range words {
+		sorted.InsertSortedNext(word)
+	}
+	lru := NewLRUTree(10, 0, WithCopyOnInsert())
+	for _, word := range words {
+		lru.Insert(word)
+	}
+	tree.Contains(word(0, 3) + "x")
+	miss := word(15, 17)
+	tree.FindWordsWithPrefix("c")
+	tree.FindWordsWithPrefix(miss + "y")
+
+	copy(buf, "xxxxxxxxxxxxxxxxxxx")
+	expected := []string{"alpha", "alphabet", "beta"}
+	if got := tree.FindWordsWithPrefix(""); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if got := sorted.FindWordsWithPrefix(""); !slices.Equal(got, expected) {
+		t.Errorf("InsertSortedNext: expected %v, got %v", expected, got)
+	}
+	if got := lru.FindWordsWithPrefix(""); !slices.Equal(got, expected) {
+		t.Errorf("LRUTree: expected %v, got %v", expected, got)
+	}
+	lru.Insert("alpha")
+	if n := lru.Len(); n != 3 {
+		t.Errorf("LRUTree: expected alpha to be found in the index, got %d words", n)
+	}
+	if report, _ := tree.Duplicates(); !slices.Equal(report.Examples, []string{"alpha"}) {
+		t.Errorf("Expected the duplicate alpha, got %v", report.Examples)
+	}
+	for _, miss := range []string{"alpx", "bey"} {
+		if !tree.misses.contains(miss) {
+			t.Errorf("Expected the miss %q to be cached", miss)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package compressedtrie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// Anonymize returns a copy of t with the same shape whose words are keyed
+// pseudonyms of those of t, for sharing the structure of a dictionary, with
+// its node and label lengths and how its words branch, without the words
+// themselves. Each byte of a word is replaced by one of the same class,
+// lower case letters by lower case letters, upper case by upper case, digits
+// by digits, other printable ASCII, other ASCII and non-ASCII bytes among
+// themselves, chosen by HMAC-SHA256 under key of the bytes before it. Words
+// that share a prefix therefore share its pseudonym, and the bytes that
+// follow a prefix stay distinct, so the copy has exactly the nodes of t.
+// Text in UTF-8 may not be valid UTF-8 once anonymized.
+//
+// The pseudonyms can't be reversed without key, but each byte depends only on
+// the bytes before it, so common words may be guessed from how many words
+// start with them. The copy has no values.
+func (t *Tree) Anonymize(key []byte) *Tree {
+	a := newAnonymizer(key)
+	anon := NewTree()
+	anon.root.isWord = t.root.isWord
+	anon.root.lenLo, anon.root.lenHi = t.root.lenLo, t.root.lenHi
+	anon.root.children = a.children(t.root, a.state(nil, 0))
+	anon.nodes, anon.words = t.nodes, t.words
+	return anon
+}
+
+// byteClasses are the classes of bytes Anonymize maps among themselves.
+var byteClasses = func() [][]byte {
+	var lower, upper, digits, punct, controls, high []byte
+	for b := range 256 {
+		c := byte(b)
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = append(lower, c)
+		case c >= 'A' && c <= 'Z':
+			upper = append(upper, c)
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c >= ' ' && c <= '~':
+			punct = append(punct, c)
+		case c < 0x80:
+			controls = append(controls, c)
+		default:
+			high = append(high, c)
+		}
+	}
+	return [][]byte{lower, upper, digits, punct, controls, high}
+}()
+
+// byteClass holds the class of each byte and its position in the class.
+var byteClass = func() (classes [256]struct{ class, pos uint8 }) {
+	for i, class := range byteClasses {
+		for j, c := range class {
+			classes[c].class, classes[c].pos = uint8(i), uint8(j)
+		}
+	}
+	return classes
+}()
+
+// An anonymizer maps the labels of a tree to pseudonyms. Its state for a path
+// is the HMAC of the state of the path without its last byte and that byte,
+// so the pseudonym of each byte depends on all the bytes before it.
+type anonymizer struct {
+	mac hash.Hash
+	buf []byte
+}
+
+func newAnonymizer(key []byte) *anonymizer {
+	return &anonymizer{mac: hmac.New(sha256.New, key)}
+}
+
+// state returns the state that follows prev on byte c. The state of the root
+// is state(nil, 0).
+func (a *anonymizer) state(prev []byte, c byte) []byte {
+	a.mac.Reset()
+	a.mac.Write(prev)
+	a.mac.Write([]byte{c})
+	return a.mac.Sum(nil)
+}
+
+// pseudonym returns the byte that replaces c after the path with state s.
+func pseudonym(s []byte, c byte) byte {
+	class := byteClasses[byteClass[c].class]
+	shift := binary.BigEndian.Uint64(s) % uint64(len(class))
+	return class[(uint64(byteClass[c].pos)+shift)%uint64(len(class))]
+}
+
+// children returns the anonymized children of node, whose path has state s.
+func (a *anonymizer) children(node *Node, s []byte) map[byte]*Node {
+	children := make(map[byte]*Node, len(node.children))
+	for _, child := range node.children {
+		a.buf = a.buf[:0]
+		cs := s
+		for i := 0; i < len(child.label); i++ {
+			a.buf = append(a.buf, pseudonym(cs, child.label[i]))
+			cs = a.state(cs, child.label[i])
+		}
+		c := &Node{label: string(a.buf), isWord: child.isWord, lenLo: child.lenLo, lenHi: child.lenHi}
+		c.children = a.children(child, cs)
+		children[c.label[0]] = c
+	}
+	return children
+}
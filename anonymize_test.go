@@ -0,0 +1,80 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	words := append(perfWords(2000), "", "Acme-42", "Acme-7", "ACME", "café", "cafés", "tab\there")
+	tree := NewTree()
+	for _, word := range words {
+		tree.Insert(word)
+	}
+	key := []byte("secret")
+	anon := tree.Anonymize(key)
+
+	// Splits are a count of past inserts rather than of the shape
+	expected := tree.Stats()
+	expected.Splits = 0
+	if anon.Stats() != expected {
+		t.Errorf("Expected the same shape, got %+v for %+v", anon.Stats(), expected)
+	}
+	if anon.DOT() != tree.Anonymize(key).DOT() {
+		t.Errorf("Expected the same pseudonyms for the same key")
+	}
+	if anon.DOT() == tree.Anonymize([]byte("other")).DOT() {
+		t.Errorf("Expected different pseudonyms for a different key")
+	}
+
+	// Each word maps to a pseudonym of the same byte classes, and words
+	// share as much of their pseudonyms as of themselves
+	a := newAnonymizer(key)
+	original := make(map[string]string)
+	for _, word := range tree.FindWordsWithPrefix("") {
+		p := a.word(word)
+		if !anon.Contains(p) {
+			t.Fatalf("%q: pseudonym %q missing", word, p)
+		}
+		for i := range len(word) {
+			if byteClass[word[i]].class != byteClass[p[i]].class {
+				t.Errorf("%q: pseudonym %q changes the class of byte %d", word, p, i)
+			}
+		}
+		original[p] = word
+	}
+	pseudonyms := slices.Sorted(maps.Keys(original))
+	for i := 1; i < len(pseudonyms); i++ {
+		p, q := pseudonyms[i-1], pseudonyms[i]
+		if commonPrefixLen(p, q) != commonPrefixLen(original[p], original[q]) {
+			t.Errorf("%q and %q share a different prefix to %q and %q", p, q, original[p], original[q])
+		}
+	}
+	if a.word("Acme-42") == "Acme-42" || a.word("cafés") == "cafés" {
+		t.Errorf("Expected pseudonyms to differ")
+	}
+
+	var direct, exported bytes.Buffer
+	if err := anon.Serialize(&direct); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.SerializeWithOptions(&exported, SerializeOptions{AnonymizeKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(direct.Bytes(), exported.Bytes()) {
+		t.Errorf("AnonymizeKey doesn't write the anonymized tree")
+	}
+}
+
+// word returns the pseudonym of word, one byte at a time.
+func (a *anonymizer) word(word string) string {
+	var p []byte
+	s := a.state(nil, 0)
+	for i := range len(word) {
+		p = append(p, pseudonym(s, word[i]))
+		s = a.state(s, word[i])
+	}
+	return string(p)
+}
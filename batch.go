@@ -0,0 +1,97 @@
+package compressedtrie
+
+import "fmt"
+
+// A Batch stages inserts and deletes for a tree so that they can be applied
+// together with Commit, or dropped with Discard. Either every change in the
+// batch is made or none is, so a dictionary update that fails part way through
+// doesn't leave the tree half changed. Create one with Tree.Batch.
+//
+// Nothing is applied to the tree until Commit, so queries see it as it was
+// before the batch. As with Insert, Commit must not overlap with queries.
+type Batch struct {
+	t   *Tree
+	ops []batchOp
+}
+
+type batchOp struct {
+	word   string
+	delete bool
+	value  any // the value of a deleted word, for undoing the delete
+}
+
+// Batch returns an empty batch of changes to t.
+func (t *Tree) Batch() *Batch {
+	return &Batch{t: t}
+}
+
+// Insert stages the insertion of word. It returns ErrWordTooLong straight away
+// if word is longer than the tree allows, without staging it. Other limits can
+// only be checked by Commit.
+func (b *Batch) Insert(word string) error {
+	if b.t.maxWordLen > 0 && len(word) > b.t.maxWordLen {
+		return ErrWordTooLong
+	}
+	b.ops = append(b.ops, batchOp{word: word})
+	return nil
+}
+
+// Delete stages the removal of word. Deleting a word that isn't in the tree,
+// once the changes staged before it are made, does nothing.
+func (b *Batch) Delete(word string) {
+	b.ops = append(b.ops, batchOp{word: word, delete: true})
+}
+
+// Len returns the number of changes staged in b.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Commit makes the staged changes to the tree in the order they were staged.
+// If one of them fails, for example an insert exceeds the tree's depth limit,
+// the changes already made are undone and the error is returned. Either way b
+// is empty afterwards and can be reused.
+func (b *Batch) Commit() error {
+	t := b.t
+	ops := b.ops
+	b.ops = nil
+
+	// Remember the changes that did something, and the values of the words
+	// deleted, as used by MultiMap and WeightedTree. The shape of the tree
+	// only depends on the words it holds, so undoing them in reverse restores
+	// it along with the values, and reinserting a deleted word can't exceed a
+	// limit.
+	var done []batchOp
+	for _, op := range ops {
+		if op.delete {
+			if node := t.nodeAt(op.word); node != nil && node.isWord {
+				op.value = node.value
+			}
+			if t.Delete(op.word) {
+				done = append(done, op)
+			}
+			continue
+		}
+		if t.Contains(op.word) {
+			continue
+		}
+		if err := t.Insert(op.word); err != nil {
+			for i := len(done) - 1; i >= 0; i-- {
+				if done[i].delete {
+					t.Insert(done[i].word)
+					t.nodeAt(done[i].word).value = done[i].value
+				} else {
+					t.Delete(done[i].word)
+				}
+			}
+			return fmt.Errorf("compressedtrie: inserting %q: %w", op.word, err)
+		}
+		done = append(done, op)
+	}
+	return nil
+}
+
+// Discard drops the staged changes, leaving the tree as it is.
+func (b *Batch) Discard() {
+	b.ops = nil
+}
@@ -0,0 +1,86 @@
+package compressedtrie
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	tree := NewTree(WithMaxDepth(2), WithMaxWordLength(10))
+	for _, word := range []string{"alpha", "alphabet", "elephant"} {
+		tree.Insert(word)
+	}
+	before := asDot(tree)
+
+	b := tree.Batch()
+	if err := b.Insert("elephantine"); !errors.Is(err, ErrWordTooLong) {
+		t.Errorf("Expected ErrWordTooLong, got %v", err)
+	}
+	b.Insert("alpine")
+	b.Delete("alpha")
+	b.Delete("zebra")
+	if b.Len() != 3 {
+		t.Errorf("Expected 3 staged changes, got %d", b.Len())
+	}
+	if !tree.Contains("alpha") || tree.Contains("alpine") {
+		t.Errorf("Staged changes are visible before Commit")
+	}
+	b.Discard()
+	if err := b.Commit(); err != nil || asDot(tree) != before {
+		t.Errorf("Discarded changes were applied")
+	}
+
+	// alphabets needs a third level, so none of the batch is applied
+	b.Insert("ant")
+	b.Delete("elephant")
+	b.Insert("alphabets")
+	b.Insert("bee")
+	if err := b.Commit(); !errors.Is(err, ErrTreeTooDeep) {
+		t.Errorf("Expected ErrTreeTooDeep, got %v", err)
+	}
+	if asDot(tree) != before || tree.NodeCount() != 4 || tree.WordCount() != 3 {
+		t.Errorf("Failed batch changed the tree")
+	}
+	checkLengths(t, tree)
+	if b.Len() != 0 {
+		t.Errorf("Batch not empty after Commit")
+	}
+
+	// Without alpha it fits
+	b.Delete("alpha")
+	b.Insert("alphabets")
+	b.Insert("bee")
+	b.Delete("bee")
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"alphabet", "alphabets", "elephant"}
+	if words := tree.FindWordsWithPrefix(""); !slices.Equal(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+}
+
+func TestBatchValues(t *testing.T) {
+	m := NewMultiMap[int](WithMaxDepth(2))
+	m.Append("alpha", 1)
+	m.Append("alpha", 2)
+	m.Append("beta", 3)
+
+	// The deleted keys get their values back when the batch fails
+	b := m.Tree().Batch()
+	b.Delete("alpha")
+	b.Delete("beta")
+	b.Insert("alp")
+	b.Insert("alpha")
+	b.Insert("alphabet")
+	if err := b.Commit(); !errors.Is(err, ErrTreeTooDeep) {
+		t.Fatalf("Expected ErrTreeTooDeep, got %v", err)
+	}
+	if values := m.Values("alpha"); !slices.Equal(values, []int{1, 2}) {
+		t.Errorf("Expected the values of alpha restored, got %v", values)
+	}
+	if values := m.Values("beta"); !slices.Equal(values, []int{3}) {
+		t.Errorf("Expected the values of beta restored, got %v", values)
+	}
+}
@@ -0,0 +1,59 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// CompleteToBoundary returns the distinct next segments of the words in t
+// that start with prefix, rather than the words: each word from just after
+// the last sep in prefix up to and including the first sep after prefix, or
+// to its end if there is none. For paths such as
+// "/usr/local/bin" and "/usr/lost+found/x", with sep '/',
+// CompleteToBoundary("/usr/lo", '/') returns "local/" and "lost+found/", which
+// is what a file path or breadcrumb UI offers for a partial segment. Only the
+// subtrees up to the next sep are visited, however many words lie below. The
+// segments are in the same order as FindWordsWithPrefix.
+func (t *Tree) CompleteToBoundary(prefix string, sep byte) []string {
+	var segments []string
+	node, path := t.descend(prefix)
+	if node == nil {
+		return segments
+	}
+	from := strings.LastIndexByte(prefix, sep) + 1
+	t.traverse(node, path, traversal{
+		enter: func(node *Node, path []byte, _ int) step {
+			// Only the bytes of the label after prefix can end the segment
+			start := max(len(prefix), len(path)-len(node.label))
+			if i := bytes.IndexByte(path[start:], sep); i >= 0 {
+				segments = append(segments, string(path[from:start+i+1]))
+				return stepOver
+			}
+			return stepInto
+		},
+		visit: func(word string, _ *Node, _ int) step {
+			segments = append(segments, word[from:])
+			return stepInto
+		},
+	})
+	return segments
+}
+
+// NextBytes returns the bytes that can follow prefix in the words of t, in
+// ascending order, for input widgets that only offer the keys that lead to a
+// word. Whether prefix is itself a word is up to Contains; a word that is
+// prefix ends there, and adds no byte. The bytes are those of the words, so
+// with multibyte UTF-8 they may be the first byte of a character.
+func (t *Tree) NextBytes(prefix string) []byte {
+	node, path := t.descend(prefix)
+	switch {
+	case node == nil:
+		return nil
+	case len(path) > len(prefix):
+		// prefix ends inside node's label
+		return []byte{path[len(prefix)]}
+	}
+	return slices.Sorted(maps.Keys(node.children))
+}
@@ -0,0 +1,71 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompleteToBoundary(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{
+		"/usr/local/bin/go", "/usr/local/bin/gofmt", "/usr/local/lib", "/usr/lost+found/x",
+		"/usr/lolcat", "/usr/lo", "/usr/bin", "/etc/hosts", "/etc/",
+	} {
+		tree.Insert(word)
+	}
+
+	for _, tc := range []struct {
+		Prefix   string
+		Expected []string
+	}{
+		{"/usr/lo", []string{"lo", "local/", "lolcat", "lost+found/"}},
+		{"/usr/loc", []string{"local/"}},
+		{"/usr/", []string{"bin", "lo", "local/", "lolcat", "lost+found/"}},
+		{"/usr/local/", []string{"bin/", "lib"}},
+		{"/usr/local/bin/go", []string{"go", "gofmt"}},
+		{"/usr", []string{"usr/"}},
+		{"/", []string{"etc/", "usr/"}},
+		{"/etc/", []string{"", "hosts"}},
+		{"", []string{"/"}},
+		{"/var", nil},
+	} {
+		if got := tree.CompleteToBoundary(tc.Prefix, '/'); !slices.Equal(got, tc.Expected) {
+			t.Errorf("CompleteToBoundary(%q): expected %q, got %q", tc.Prefix, tc.Expected, got)
+		}
+	}
+
+	// Words that split inside a segment, such as dotted names
+	tree = NewTree()
+	for _, word := range []string{"com.example.api", "com.example.www", "com.exam.ple", "org.go"} {
+		tree.Insert(word)
+	}
+	if got, expected := tree.CompleteToBoundary("com.ex", '.'), []string{"exam.", "example."}; !slices.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestNextBytes(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"cat", "cart", "care", "cab", "dog", "do"} {
+		tree.Insert(word)
+	}
+
+	for _, tc := range []struct {
+		Prefix   string
+		Expected string
+	}{
+		{"", "cd"},
+		{"c", "a"},
+		{"ca", "brt"},
+		{"car", "et"},
+		{"cat", ""},
+		{"d", "o"},
+		{"do", "g"},
+		{"x", ""},
+		{"cats", ""},
+	} {
+		if got := tree.NextBytes(tc.Prefix); string(got) != tc.Expected {
+			t.Errorf("NextBytes(%q): expected %q, got %q", tc.Prefix, tc.Expected, got)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package compressedtrie
+
+import "time"
+
+// QueryBudget bounds the work FindWordsWithPrefixBudget does, so that latency
+// sensitive callers degrade gracefully on prefixes with huge subtrees. The
+// zero value is no bound.
+type QueryBudget struct {
+	// Deadline is the time after which the walk stops. It is checked every
+	// deadlineCheck nodes rather than at each one, so it can be overrun by
+	// the time that takes. Ignored if zero.
+	Deadline time.Time
+
+	// MaxNodes is the largest number of nodes below the prefix that are
+	// visited. Ignored if <= 0.
+	MaxNodes int
+}
+
+// deadlineCheck is how many nodes are visited between reads of the clock.
+const deadlineCheck = 64
+
+// FindWordsWithPrefixBudget is FindWordsWithPrefix that stops once budget is
+// spent. It returns the words found so far, a prefix of the full result in the
+// same order, and whether they were truncated.
+func (t *Tree) FindWordsWithPrefixBudget(prefix string, budget QueryBudget) ([]string, bool) {
+	return t.Query(prefix, QueryOptions{Budget: budget})
+}
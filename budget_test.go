@@ -0,0 +1,42 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFindWordsWithPrefixBudget(t *testing.T) {
+	tree := perfTree(2000)
+	all := tree.FindWordsWithPrefix("")
+
+	words, truncated := tree.FindWordsWithPrefixBudget("", QueryBudget{})
+	if truncated || !slices.Equal(words, all) {
+		t.Errorf("Unbounded query returned %d words truncated %v, expected %d", len(words), truncated, len(all))
+	}
+	words, truncated = tree.FindWordsWithPrefixBudget("", QueryBudget{MaxNodes: tree.NodeCount()})
+	if truncated || !slices.Equal(words, all) {
+		t.Errorf("Budget of every node returned %d words truncated %v", len(words), truncated)
+	}
+
+	// Partial results are the start of the full result
+	for _, n := range []int{1, 10, 100, tree.NodeCount() - 1} {
+		words, truncated := tree.FindWordsWithPrefixBudget("", QueryBudget{MaxNodes: n})
+		if !truncated || len(words) > n || !slices.Equal(words, all[:len(words)]) {
+			t.Errorf("MaxNodes %d: got %d words truncated %v", n, len(words), truncated)
+		}
+	}
+
+	words, truncated = tree.FindWordsWithPrefixBudget("a", QueryBudget{Deadline: time.Now().Add(-time.Second)})
+	if !truncated || len(words) != 0 {
+		t.Errorf("Expired deadline returned %d words truncated %v", len(words), truncated)
+	}
+	words, truncated = tree.FindWordsWithPrefixBudget("a", QueryBudget{Deadline: time.Now().Add(time.Hour)})
+	if truncated || !slices.Equal(words, tree.FindWordsWithPrefix("a")) {
+		t.Errorf("Distant deadline returned %d words truncated %v", len(words), truncated)
+	}
+
+	if words, truncated := tree.FindWordsWithPrefixBudget("zzzzzz", QueryBudget{MaxNodes: 1}); words != nil || truncated {
+		t.Errorf("Missing prefix returned %v truncated %v", words, truncated)
+	}
+}
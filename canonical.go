@@ -0,0 +1,65 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// checkCanonical returns an error matching ErrNotCanonical if t has a node that
+// Insert would never create.
+func (t *Tree) checkCanonical() error {
+	if t.root.label != "" {
+		return fmt.Errorf("%w: root has label %q", ErrNotCanonical, t.root.label)
+	}
+	return checkCanonical(t.root, "")
+}
+
+// checkCanonical checks the children of node, whose path from the root is path.
+func checkCanonical(node *Node, path string) error {
+	for _, k := range slices.Sorted(maps.Keys(node.children)) {
+		child := node.children[k]
+		childPath := path + child.label
+		switch {
+		case child.label == "":
+			return fmt.Errorf("%w: node below %q has an empty label", ErrNotCanonical, path)
+		case child.label[0] != k:
+			return fmt.Errorf("%w: node %q has key %q", ErrNotCanonical, childPath, k)
+		case !child.isWord && len(child.children) == 0:
+			return fmt.Errorf("%w: node %q is an empty leaf", ErrNotCanonical, childPath)
+		case !child.isWord && len(child.children) == 1:
+			return fmt.Errorf("%w: node %q has a single child", ErrNotCanonical, childPath)
+		}
+		if err := checkCanonical(child, childPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isCanonical reports whether checkCanonical would find nothing wrong with t,
+// without building the paths for an error.
+func (t *Tree) isCanonical() bool {
+	var canonical func(node *Node) bool
+	canonical = func(node *Node) bool {
+		for k, child := range node.children {
+			if child.label == "" || child.label[0] != k || !child.isWord && len(child.children) < 2 || !canonical(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return t.root.label == "" && canonical(t.root)
+}
+
+// rebuild returns a canonical tree holding the same words, and values, as t.
+// Like every query it ignores the label of the root.
+func (t *Tree) rebuild() *Tree {
+	rebuilt := NewTree()
+	t.yieldWords(t.root, "", func(word string, node *Node) bool {
+		rebuilt.InsertSortedNext(word)
+		rebuilt.nodeAt(word).value = node.value
+		return true
+	})
+	return rebuilt
+}
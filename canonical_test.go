@@ -0,0 +1,152 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// v1Node is a node for building version 1 files by hand.
+type v1Node struct {
+	label    string
+	isWord   bool
+	keys     string
+	children []v1Node
+}
+
+func (n v1Node) write(buf *bytes.Buffer) {
+	binary.Write(buf, binary.BigEndian, uint16(len(n.label)))
+	buf.WriteString(n.label)
+	if n.isWord {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	buf.WriteByte(byte(len(n.children)))
+	for i, child := range n.children {
+		buf.WriteByte(n.keys[i])
+		child.write(buf)
+	}
+}
+
+func v1File(root v1Node, nodes int) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, SerializedTreeHeader{CtreeMagic, 1, uint32(nodes)})
+	root.write(buf)
+	return buf.Bytes()
+}
+
+func TestDeserializeSanitize(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Root     v1Node
+		Expected []string
+	}{
+		{"Single child", v1Node{"", false, "a", []v1Node{{"ab", false, "c", []v1Node{{"cd", true, "", nil}}}}}, []string{"abcd"}},
+		{"Wrong key", v1Node{"", false, "xb", []v1Node{{"ab", true, "", nil}, {"bc", true, "", nil}}}, []string{"ab", "bc"}},
+		{"Empty leaf", v1Node{"", false, "ab", []v1Node{{"ab", true, "", nil}, {"bc", false, "", nil}}}, []string{"ab"}},
+		{"Empty label", v1Node{"", false, "ab", []v1Node{{"", true, "c", []v1Node{{"cd", true, "", nil}}}, {"bc", true, "", nil}}}, []string{"", "bc", "cd"}},
+		{"Root label", v1Node{"r", true, "ab", []v1Node{{"ab", true, "", nil}, {"bc", true, "", nil}}}, []string{"", "ab", "bc"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			data := v1File(tc.Root, 4)
+
+			trusted, err := DeserializeTree(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Expected the tree to be accepted without sanitizing, got %v", err)
+			}
+			if trusted.isCanonical() {
+				t.Errorf("isCanonical accepted the tree")
+			}
+			_, err = DeserializeTreeWithOptions(bytes.NewReader(data), DeserializeOptions{Sanitize: SanitizeReject})
+			if !errors.Is(err, ErrNotCanonical) {
+				t.Errorf("Expected ErrNotCanonical, got %v", err)
+			}
+
+			tree, err := DeserializeTreeWithOptions(bytes.NewReader(data), DeserializeOptions{Sanitize: SanitizeRepair})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tree.checkCanonical(); err != nil {
+				t.Errorf("Repaired tree is not canonical: %v", err)
+			}
+			if actual := tree.FindWordsWithPrefix(""); !slices.Equal(actual, tc.Expected) {
+				t.Errorf("Expected %v, got %v", tc.Expected, actual)
+			}
+			if tree.WordCount() != len(tc.Expected) {
+				t.Errorf("Expected %d words, got %d", len(tc.Expected), tree.WordCount())
+			}
+
+			// The trusted tree is written in canonical form
+			var written, repaired bytes.Buffer
+			trusted.Serialize(&written)
+			tree.Serialize(&repaired)
+			if !bytes.Equal(written.Bytes(), repaired.Bytes()) {
+				t.Errorf("Expected the canonical encoding")
+			}
+		})
+	}
+
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	buf := &bytes.Buffer{}
+	tree.Serialize(buf)
+	decoded, err := DeserializeTreeWithOptions(buf, DeserializeOptions{Sanitize: SanitizeReject})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asDot(decoded) != asDot(tree) {
+		t.Errorf("Canonical tree changed by sanitizing")
+	}
+}
+
+func TestSerializeReproducible(t *testing.T) {
+	words := perfWords(3000)
+	extra := perfWords(4000)[3000:]
+	rng := rand.New(rand.NewSource(1219))
+	optionSets := []SerializeOptions{
+		{},
+		{LabelDictionary: true, LengthBounds: true, WordCounts: true},
+		{Layout: BreadthFirst, LabelDictionary: true},
+	}
+
+	var first [][]byte
+	for build := range 4 {
+		// Insert in a different order each time, with words that are deleted
+		// again mixed in, and a different child order
+		var opts []Option
+		if build == 3 {
+			opts = append(opts, WithChildOrder(func(a, b string) int { return strings.Compare(b, a) }))
+		}
+		tree := NewTree(opts...)
+		all := append(slices.Clone(words), extra...)
+		rng.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+		for _, word := range all {
+			tree.Insert(word)
+		}
+		rng.Shuffle(len(extra), func(i, j int) { extra[i], extra[j] = extra[j], extra[i] })
+		for _, word := range extra {
+			tree.Delete(word)
+		}
+
+		for i, opts := range optionSets {
+			var buf bytes.Buffer
+			if err := tree.SerializeWithOptions(&buf, opts); err != nil {
+				t.Fatal(err)
+			}
+			if build == 0 {
+				first = append(first, buf.Bytes())
+			} else if !bytes.Equal(buf.Bytes(), first[i]) {
+				t.Errorf("Build %d, options %+v: different bytes", build, opts)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package compressedtrie
+
+// A ByteSet is a set of bytes, for restricting the bytes a query accepts at
+// each position, see Classes.
+type ByteSet [4]uint64
+
+// NewByteSet returns the set of the bytes in chars.
+func NewByteSet(chars string) ByteSet {
+	var s ByteSet
+	for i := range len(chars) {
+		s.Add(chars[i])
+	}
+	return s
+}
+
+// ByteRange returns the set of the bytes from lo to hi inclusive.
+func ByteRange(lo, hi byte) ByteSet {
+	var s ByteSet
+	for c := int(lo); c <= int(hi); c++ {
+		s.Add(byte(c))
+	}
+	return s
+}
+
+// Add adds c to s.
+func (s *ByteSet) Add(c byte) {
+	s[c/64] |= 1 << (c % 64)
+}
+
+// Contains reports whether c is in s.
+func (s ByteSet) Contains(c byte) bool {
+	return s[c/64]&(1<<(c%64)) != 0
+}
+
+// Union returns the bytes that are in s or o.
+func (s ByteSet) Union(o ByteSet) ByteSet {
+	return ByteSet{s[0] | o[0], s[1] | o[1], s[2] | o[2], s[3] | o[3]}
+}
+
+// Classes returns a function for FindWordsWithPrefixFunc that accepts words
+// whose i'th byte is in sets[i], and that are no longer than sets. For example
+// a plate of two letters followed by three digits is
+//
+//	upper, digit := ByteRange('A', 'Z'), ByteRange('0', '9')
+//	tree.FindWordsWithPrefixFunc("", Classes(upper, upper, digit, digit, digit))
+func Classes(sets ...ByteSet) func(path string, c byte) bool {
+	return func(path string, c byte) bool {
+		return len(path) < len(sets) && sets[len(path)].Contains(c)
+	}
+}
+
+// FindWordsWithPrefixFunc returns the words in t that start with prefix and in
+// which every byte c is accepted by allow(path, c), where path is the part of
+// the word before c. The words are in the same order as FindWordsWithPrefix.
+// The search gives up on a branch of the tree at the first byte allow
+// rejects, so formats that constrain the later bytes of a word by the earlier
+// ones, such as only digits after a '.', are found without visiting the words
+// that don't fit.
+func (t *Tree) FindWordsWithPrefixFunc(prefix string, allow func(path string, c byte) bool) []string {
+	var words []string
+	node, path := t.descend(prefix)
+	if node == nil || !allowed(path, 0, allow) {
+		return words
+	}
+	t.traverse(node, path, traversal{
+		enter: func(node *Node, path []byte, _ int) step {
+			// The bytes before the node's label have been checked
+			if !allowed(string(path), len(path)-len(node.label), allow) {
+				return stepOver
+			}
+			return stepInto
+		},
+		visit: func(word string, _ *Node, _ int) step {
+			words = append(words, word)
+			return stepInto
+		},
+	})
+	return words
+}
+
+// allowed reports whether allow accepts the bytes of path from start onwards.
+func allowed(path string, start int, allow func(string, byte) bool) bool {
+	for i := start; i < len(path); i++ {
+		if !allow(path[:i], path[i]) {
+			return false
+		}
+	}
+	return true
+}
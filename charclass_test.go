@@ -0,0 +1,66 @@
+package compressedtrie
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestByteSet(t *testing.T) {
+	s := NewByteSet("az").Union(ByteRange(0xf0, 0xff))
+	for _, c := range []byte{'a', 'z', 0xf0, 0xff} {
+		if !s.Contains(c) {
+			t.Errorf("Expected %#x in the set", c)
+		}
+	}
+	for _, c := range []byte{0, 'b', 'y', 0xef} {
+		if s.Contains(c) {
+			t.Errorf("Expected %#x not in the set", c)
+		}
+	}
+}
+
+func TestFindWordsWithPrefixFunc(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"AB123", "AB12C", "ABC12", "AZ999", "A1234", "XY000", "AB1234", "v1.2", "v1.2b", "v10.11", "v1a.2"} {
+		tree.Insert(word)
+	}
+
+	upper, digit := ByteRange('A', 'Z'), ByteRange('0', '9')
+	plate := Classes(upper, upper, digit, digit, digit)
+	cases := []struct {
+		Prefix   string
+		Expected []string
+	}{
+		{"", []string{"AB123", "AZ999", "XY000"}},
+		{"A", []string{"AB123", "AZ999"}},
+		{"AB12", []string{"AB123"}},
+		{"A1", nil},
+		{"Q", nil},
+	}
+	for _, tc := range cases {
+		if got := tree.FindWordsWithPrefixFunc(tc.Prefix, plate); !slices.Equal(got, tc.Expected) {
+			t.Errorf("%q: expected %v, got %v", tc.Prefix, tc.Expected, got)
+		}
+	}
+
+	// Only digits after a point, anything before it
+	digitsAfterPoint := func(path string, c byte) bool {
+		return !strings.Contains(path, ".") || digit.Contains(c)
+	}
+	expected := []string{"v1.2", "v10.11", "v1a.2"}
+	if got := tree.FindWordsWithPrefixFunc("v", digitsAfterPoint); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Branches are abandoned at the first rejected byte, which is one call for
+	// each top level branch plus four for the rest of XY000
+	visited := 0
+	tree.FindWordsWithPrefixFunc("", func(path string, c byte) bool {
+		visited++
+		return c == 'X' || path != ""
+	})
+	if visited != 7 {
+		t.Errorf("Expected 7 calls, got %d", visited)
+	}
+}
@@ -0,0 +1,194 @@
+package compressedtrie
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+	"os"
+)
+
+// A checkpoint written by WriteCheckpoint is the u32 CtreeCheckpointMagic and
+// the uvarint offset of the Builder, followed by its tree as written by
+// Serialize.
+
+// 32-bit magic number for checkpoints written by WriteCheckpoint
+const CtreeCheckpointMagic uint32 = 'C'<<24 | 'T'<<16 | 'R'<<8 | 'K'
+
+// checkpointEvery is the default for CheckpointOptions.Every.
+const checkpointEvery = 1 << 20
+
+// A Builder builds a tree from a long stream of words and can save how far it
+// has got, so that a build from a corpus that takes hours can be resumed after
+// a crash rather than started again. The state saved is the tree so far and
+// the offset, the number of words taken from the stream, which a resumed build
+// skips. A Builder must not be used by more than one goroutine at a time.
+type Builder struct {
+	tree   *Tree
+	offset int64
+}
+
+// NewBuilder returns a Builder for a new tree, the options apply to the tree.
+func NewBuilder(opts ...Option) *Builder {
+	return &Builder{tree: NewTree(opts...)}
+}
+
+// Insert adds word to the tree, see Tree.Insert, and counts it towards the
+// offset whether or not it's added.
+func (b *Builder) Insert(word string) error {
+	b.offset++
+	return b.tree.Insert(word)
+}
+
+// Offset returns the number of words b has been given, including those of the
+// build it was resumed from.
+func (b *Builder) Offset() int64 {
+	return b.offset
+}
+
+// Tree returns the tree b is building.
+func (b *Builder) Tree() *Tree {
+	return b.tree
+}
+
+// WriteCheckpoint writes the tree and offset of b to w, see ReadCheckpoint.
+func (b *Builder) WriteCheckpoint(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	head := binary.BigEndian.AppendUint32(nil, CtreeCheckpointMagic)
+	head = binary.AppendUvarint(head, uint64(b.offset))
+	if _, err := buf.Write(head); err != nil {
+		return err
+	}
+	if err := b.tree.Serialize(buf); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// ReadCheckpoint returns a Builder resumed from a checkpoint written by
+// WriteCheckpoint, whose tree is created with opts. Returns a *FormatError if
+// r doesn't hold a valid checkpoint.
+func ReadCheckpoint(r io.Reader, opts ...Option) (*Builder, error) {
+	buf := bufio.NewReader(r)
+	var magic [4]byte
+	if _, err := io.ReadFull(buf, magic[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, &FormatError{Offset: 0, Node: -1, Reason: "reading checkpoint header", Err: err}
+	}
+	if m := binary.BigEndian.Uint32(magic[:]); m != CtreeCheckpointMagic {
+		return nil, formatError(0, -1, "magic number %#x", m)
+	}
+	offset, err := binary.ReadUvarint(buf)
+	if err != nil || offset > 1<<62 {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, &FormatError{Offset: 4, Node: -1, Reason: "reading checkpoint offset", Err: err}
+	}
+	b := &Builder{tree: NewTree(opts...), offset: int64(offset)}
+	if err := b.tree.DeserializeInto(buf); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// SaveCheckpoint writes a checkpoint of b to the file path, replacing it at
+// once so that a crash leaves either the old checkpoint or the new one. The
+// new one is written to path with ".tmp" appended first.
+func (b *Builder) SaveCheckpoint(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = b.WriteCheckpoint(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// LoadCheckpoint is ReadCheckpoint for the file path, as saved by
+// SaveCheckpoint. If there is no such file it returns a new Builder, so that
+// the first run of a build and the runs that resume it are the same.
+func LoadCheckpoint(path string, opts ...Option) (*Builder, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewBuilder(opts...), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCheckpoint(f, opts...)
+}
+
+// CheckpointOptions configures Builder.Build.
+type CheckpointOptions struct {
+	// Path is the file checkpoints are saved to, see SaveCheckpoint.
+	Path string
+
+	// Every is the number of words between checkpoints, 0 for 1<<20.
+	Every int
+}
+
+// Build inserts the words of src and saves a checkpoint to opts.Path every
+// opts.Every words and once src is done. The first Offset words of src are
+// skipped, they were inserted by the build b was resumed from, so src must
+// yield the same words in the same order each time it is iterated. Build stops
+// with the error if src yields one, a word is rejected by Insert, a checkpoint
+// can't be saved or ctx is cancelled, and the next Build of a Builder from
+// LoadCheckpoint starts from the last checkpoint saved:
+//
+//	b, err := compressedtrie.LoadCheckpoint("build.ckpt")
+//	if err != nil {
+//		return err
+//	}
+//	if err := b.Build(ctx, compressedtrie.Lines(corpus), compressedtrie.CheckpointOptions{Path: "build.ckpt"}); err != nil {
+//		return err
+//	}
+//	tree := b.Tree()
+func (b *Builder) Build(ctx context.Context, src iter.Seq2[string, error], opts CheckpointOptions) error {
+	every := int64(opts.Every)
+	if every <= 0 {
+		every = checkpointEvery
+	}
+	skip := b.offset
+	for word, err := range src {
+		if err != nil {
+			return err
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if err := b.Insert(word); err != nil {
+			return err
+		}
+		if b.offset%every == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := b.SaveCheckpoint(opts.Path); err != nil {
+				return err
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.SaveCheckpoint(opts.Path)
+}
@@ -0,0 +1,102 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBuilderCheckpoint(t *testing.T) {
+	words := perfWords(1000)
+	path := filepath.Join(t.TempDir(), "build.ckpt")
+	crash := errors.New("crash")
+	// source yields the words, failing after the first fail of them
+	source := func(fail int) func(yield func(string, error) bool) {
+		return func(yield func(string, error) bool) {
+			for i, word := range words {
+				if i == fail {
+					yield("", crash)
+					return
+				}
+				if !yield(word, nil) {
+					return
+				}
+			}
+		}
+	}
+	opts := CheckpointOptions{Path: path, Every: 100}
+
+	b, err := LoadCheckpoint(path)
+	if err != nil || b.Offset() != 0 || b.Tree().WordCount() != 0 {
+		t.Fatalf("Expected a new Builder, got %v", err)
+	}
+	if err := b.Build(context.Background(), source(450), opts); err != crash {
+		t.Fatalf("Expected the crash, got %v", err)
+	}
+	for _, run := range []struct{ fail, resume int }{{750, 400}, {-1, 700}} {
+		fail := run.fail
+		b, err = LoadCheckpoint(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Offset() != int64(run.resume) || b.Tree().WordCount() != run.resume {
+			t.Fatalf("Expected to resume at %d, got %d with %d words", run.resume, b.Offset(), b.Tree().WordCount())
+		}
+		err = b.Build(context.Background(), source(fail), opts)
+		if fail >= 0 && err != crash || fail < 0 && err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+	if b.Offset() != int64(len(words)) || !slices.Equal(b.Tree().FindWordsWithPrefix(""), slices.Sorted(slices.Values(words))) {
+		t.Errorf("Expected all the words, got %d", b.Tree().WordCount())
+	}
+	if _, err := os.Stat(path + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no temporary file, got %v", err)
+	}
+
+	// A finished build resumes with nothing to do
+	b, err = LoadCheckpoint(path)
+	if err != nil || b.Offset() != int64(len(words)) {
+		t.Fatalf("Resumed at %d, %v", b.Offset(), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewBuilder().Build(ctx, source(-1), CheckpointOptions{Path: path}); err != context.Canceled {
+		t.Errorf("Expected Canceled, got %v", err)
+	}
+}
+
+func TestReadCheckpoint(t *testing.T) {
+	b := NewBuilder(WithMaxWordLength(5))
+	for _, word := range []string{"alpha", "beta", "gamma"} {
+		b.Insert(word)
+	}
+	var buf bytes.Buffer
+	if err := b.WriteCheckpoint(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	resumed, err := ReadCheckpoint(bytes.NewReader(data), WithMaxWordLength(5))
+	if err != nil || resumed.Offset() != 3 || !slices.Equal(resumed.Tree().FindWordsWithPrefix(""), []string{"alpha", "beta", "gamma"}) {
+		t.Fatalf("Unexpected resumed builder, %v", err)
+	}
+	if err := resumed.Insert("epsilon"); !errors.Is(err, ErrWordTooLong) || resumed.Offset() != 4 {
+		t.Errorf("Expected the options to apply, got %v", err)
+	}
+
+	var fe *FormatError
+	for _, n := range []int{0, 3, 4, 6, len(data) - 1} {
+		if _, err := ReadCheckpoint(bytes.NewReader(data[:n])); !errors.As(err, &fe) {
+			t.Errorf("%d bytes: expected a FormatError, got %v", n, err)
+		}
+	}
+	if _, err := ReadCheckpoint(bytes.NewReader(append([]byte("CTRM"), data[4:]...))); !errors.As(err, &fe) {
+		t.Errorf("Expected a FormatError for the wrong magic, got %v", err)
+	}
+}
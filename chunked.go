@@ -0,0 +1,246 @@
+package compressedtrie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// 32-bit magic number for manifests written by Manifest.MarshalBinary
+const CtreeManifestMagic uint32 = 'C'<<24 | 'T'<<16 | 'R'<<8 | 'M'
+
+// A Manifest describes a serialized tree split into chunks by
+// SerializeChunked, so that a large dictionary can be stored as objects under
+// a CDN's size limit and fetched in parallel. The chunks are consecutive
+// pieces of the output of SerializeWithOptions.
+type Manifest struct {
+	Size   int64 // total size of the serialized tree
+	Chunks []Chunk
+}
+
+// A Chunk is one piece of a serialized tree.
+type Chunk struct {
+	Offset   int64  // offset of the chunk in the serialized tree
+	Size     int64  // length of the chunk
+	Checksum uint32 // CRC-32 of the chunk, IEEE polynomial
+}
+
+// SerializeChunked writes t with opts split into the fewest chunks of at most
+// maxChunk bytes, all the same size give or take a byte, and returns the
+// manifest describing them. create is called for each chunk in turn to get
+// the writer for it, which is closed once the chunk is written.
+func (t *Tree) SerializeChunked(opts SerializeOptions, maxChunk int64, create func(i int) (io.WriteCloser, error)) (*Manifest, error) {
+	if maxChunk <= 0 {
+		return nil, fmt.Errorf("compressedtrie: chunk size %d", maxChunk)
+	}
+	size, err := t.SerializedSizeWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Spread the bytes over n chunks, the first size%n get one extra
+	n := (size + maxChunk - 1) / maxChunk
+	m := &Manifest{Size: size, Chunks: make([]Chunk, n)}
+	var off int64
+	for i := range m.Chunks {
+		c := &m.Chunks[i]
+		c.Offset = off
+		c.Size = size / n
+		if int64(i) < size%n {
+			c.Size++
+		}
+		off += c.Size
+	}
+
+	w := &chunkWriter{manifest: m, create: create, crc: crc32.NewIEEE()}
+	err = t.SerializeWithOptions(w, opts)
+	if err == nil && w.i < len(m.Chunks) {
+		err = fmt.Errorf("compressedtrie: wrote %d bytes of %d", w.off, size)
+	}
+	if w.cur != nil {
+		err = errors.Join(err, w.cur.Close())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// chunkWriter splits what is written to it into the chunks of its manifest,
+// filling in their checksums.
+type chunkWriter struct {
+	manifest *Manifest
+	create   func(i int) (io.WriteCloser, error)
+	i        int            // index of the chunk being written
+	cur      io.WriteCloser // writer for chunk i, nil if not yet created
+	off      int64          // bytes written to chunk i
+	crc      hash.Hash32
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.i == len(w.manifest.Chunks) {
+			return written, fmt.Errorf("compressedtrie: tree is larger than its measured size")
+		}
+		c := &w.manifest.Chunks[w.i]
+		if w.cur == nil {
+			cur, err := w.create(w.i)
+			if err != nil {
+				return written, err
+			}
+			w.cur = cur
+		}
+		n, err := w.cur.Write(p[:min(int64(len(p)), c.Size-w.off)])
+		w.crc.Write(p[:n])
+		written += n
+		w.off += int64(n)
+		p = p[n:]
+		if err != nil {
+			return written, err
+		}
+		if w.off == c.Size {
+			c.Checksum = w.crc.Sum32()
+			err := w.cur.Close()
+			w.i, w.cur, w.off = w.i+1, nil, 0
+			w.crc.Reset()
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// DeserializeChunked reads the tree described by m, calling open for each
+// chunk in turn and closing the reader once the chunk is read. The chunks are
+// checked against the sizes and checksums in m as they are read, a mismatch
+// is reported as a *FormatError in the same way as DeserializeTree reports
+// corrupt files, which is also how other errors are reported.
+func DeserializeChunked(m *Manifest, open func(i int) (io.ReadCloser, error)) (*Tree, error) {
+	r := &chunkReader{manifest: m, open: open, crc: crc32.NewIEEE()}
+	defer r.close()
+	tree, err := DeserializeTree(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The decoder stops at the end of the tree, check what is left of the
+	// last chunk too
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, &FormatError{Offset: r.pos, Node: -1, Reason: "reading the end of the last chunk", Err: err}
+	}
+	return tree, nil
+}
+
+// chunkReader joins the chunks of its manifest into one stream, checking each
+// as it reaches the end of it.
+type chunkReader struct {
+	manifest *Manifest
+	open     func(i int) (io.ReadCloser, error)
+	i        int           // index of the chunk being read
+	cur      io.ReadCloser // reader for chunk i, nil if not yet opened
+	off      int64         // bytes read from chunk i
+	pos      int64         // bytes read from all chunks
+	crc      hash.Hash32
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.i == len(r.manifest.Chunks) {
+			return 0, io.EOF
+		}
+		c := r.manifest.Chunks[r.i]
+		if r.cur == nil {
+			cur, err := r.open(r.i)
+			if err != nil {
+				return 0, err
+			}
+			r.cur = cur
+		}
+		n, err := r.cur.Read(p)
+		r.crc.Write(p[:n])
+		r.off += int64(n)
+		r.pos += int64(n)
+		if r.off > c.Size {
+			return n, fmt.Errorf("compressedtrie: chunk %d is longer than %d bytes", r.i, c.Size)
+		}
+		if err == io.EOF {
+			if r.off != c.Size {
+				return n, fmt.Errorf("compressedtrie: chunk %d has %d bytes, expected %d: %w", r.i, r.off, c.Size, io.ErrUnexpectedEOF)
+			}
+			if sum := r.crc.Sum32(); sum != c.Checksum {
+				return n, fmt.Errorf("compressedtrie: chunk %d has checksum %08x, expected %08x", r.i, sum, c.Checksum)
+			}
+			r.close()
+			r.i, r.off = r.i+1, 0
+			r.crc.Reset()
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (r *chunkReader) close() {
+	if r.cur != nil {
+		r.cur.Close()
+		r.cur = nil
+	}
+}
+
+// MarshalBinary encodes m as the u32 CtreeManifestMagic, the uvarint total
+// size and the uvarint number of chunks, followed by the uvarint size and u32
+// checksum of each chunk. Fixed size integers are big endian.
+func (m *Manifest) MarshalBinary() ([]byte, error) {
+	b := binary.BigEndian.AppendUint32(nil, CtreeManifestMagic)
+	b = binary.AppendUvarint(b, uint64(m.Size))
+	b = binary.AppendUvarint(b, uint64(len(m.Chunks)))
+	for _, c := range m.Chunks {
+		b = binary.AppendUvarint(b, uint64(c.Size))
+		b = binary.BigEndian.AppendUint32(b, c.Checksum)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a manifest written by MarshalBinary into m. Returns
+// a *FormatError if data is not a valid manifest.
+func (m *Manifest) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 || binary.BigEndian.Uint32(data) != CtreeManifestMagic {
+		return formatError(0, -1, "not a manifest")
+	}
+	off := 4
+	uvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(data[off:])
+		off += max(n, 0)
+		return v, n > 0
+	}
+	size, ok := uvarint()
+	if !ok || size > 1<<62 {
+		return formatError(int64(off), -1, "bad manifest size")
+	}
+	count, ok := uvarint()
+	if !ok || count > uint64(len(data)-off)/5 {
+		return formatError(int64(off), -1, "bad chunk count")
+	}
+	g := Manifest{Size: int64(size), Chunks: make([]Chunk, count)}
+	var total uint64
+	for i := range g.Chunks {
+		csize, ok := uvarint()
+		if !ok || csize > size-total || len(data)-off < 4 {
+			return formatError(int64(off), -1, "bad chunk %d", i)
+		}
+		g.Chunks[i] = Chunk{Offset: int64(total), Size: int64(csize), Checksum: binary.BigEndian.Uint32(data[off:])}
+		off += 4
+		total += csize
+	}
+	if total != size || off != len(data) {
+		return formatError(int64(off), -1, "chunks hold %d bytes of %d", total, size)
+	}
+	*m = g
+	return nil
+}
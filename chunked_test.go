@@ -0,0 +1,111 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// nopCloser is a bytes.Buffer that can be closed, and records that it was.
+type nopCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *nopCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestSerializeChunked(t *testing.T) {
+	tree := perfTree(3000)
+	opts := SerializeOptions{LabelDictionary: true}
+	var whole bytes.Buffer
+	tree.SerializeWithOptions(&whole, opts)
+
+	var chunks []*nopCloser
+	m, err := tree.SerializeChunked(opts, 4000, func(i int) (io.WriteCloser, error) {
+		if i != len(chunks) {
+			t.Errorf("Created chunk %d after %d chunks", i, len(chunks))
+		}
+		chunks = append(chunks, &nopCloser{})
+		return chunks[i], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Size != int64(whole.Len()) || len(m.Chunks) != len(chunks) || len(chunks) != (whole.Len()+3999)/4000 {
+		t.Fatalf("Unexpected manifest for %d bytes: %+v", whole.Len(), m)
+	}
+	var joined []byte
+	for i, c := range chunks {
+		if !c.closed || int64(c.Len()) != m.Chunks[i].Size || m.Chunks[i].Size > 4000 || m.Chunks[i].Size < m.Size/int64(len(chunks)) {
+			t.Errorf("Chunk %d has %d bytes, closed %v: %+v", i, c.Len(), c.closed, m.Chunks[i])
+		}
+		if m.Chunks[i].Offset != int64(len(joined)) {
+			t.Errorf("Chunk %d at %d, expected %d", i, m.Chunks[i].Offset, len(joined))
+		}
+		joined = append(joined, c.Bytes()...)
+	}
+	if !bytes.Equal(joined, whole.Bytes()) {
+		t.Errorf("Chunks don't join up to the serialized tree")
+	}
+
+	// The manifest round trips
+	b, _ := m.MarshalBinary()
+	var decoded Manifest
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Size != m.Size || !slices.Equal(decoded.Chunks, m.Chunks) {
+		t.Errorf("Manifest changed in a round trip")
+	}
+	for n := range len(b) {
+		if err := decoded.UnmarshalBinary(b[:n]); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("Manifest cut to %d bytes: expected ErrInvalidFormat, got %v", n, err)
+		}
+	}
+
+	open := func(parts [][]byte) func(int) (io.ReadCloser, error) {
+		return func(i int) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(parts[i])), nil
+		}
+	}
+	var parts [][]byte
+	for _, c := range chunks {
+		parts = append(parts, c.Bytes())
+	}
+	read, err := DeserializeChunked(&decoded, open(parts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(read.FindWordsWithPrefix(""), tree.FindWordsWithPrefix("")) {
+		t.Errorf("Reassembled tree differs")
+	}
+
+	// Damaged chunks are caught, including the end of the last one
+	for _, i := range []int{0, len(parts) / 2, len(parts) - 1} {
+		damaged := slices.Clone(parts)
+		damaged[i] = slices.Clone(parts[i])
+		damaged[i][len(damaged[i])-1] ^= 1
+		if _, err := DeserializeChunked(&decoded, open(damaged)); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("Chunk %d damaged: expected ErrInvalidFormat, got %v", i, err)
+		}
+		damaged[i] = parts[i][:len(parts[i])-1]
+		if _, err := DeserializeChunked(&decoded, open(damaged)); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Chunk %d short: expected io.ErrUnexpectedEOF, got %v", i, err)
+		}
+	}
+
+	failed := errors.New("upload failed")
+	if _, err := tree.SerializeChunked(opts, 4000, func(i int) (io.WriteCloser, error) {
+		if i == 1 {
+			return nil, failed
+		}
+		return &nopCloser{}, nil
+	}); !errors.Is(err, failed) {
+		t.Errorf("Expected the create error, got %v", err)
+	}
+}
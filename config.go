@@ -0,0 +1,97 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// ConfigMatcher resolves dotted configuration keys such as "db.primary.timeout"
+// to the most specific value set on the key or one of its ancestors,
+// "db.primary" and "db", with the value set on the empty key as the default
+// for every key. Keys are matched whole segment by whole segment, so a value
+// on "db.primary" doesn't apply to "db.primary2".
+type ConfigMatcher[V any] struct {
+	values *MultiMap[V]
+}
+
+// NewConfigMatcher returns a ConfigMatcher with no values.
+func NewConfigMatcher[V any]() *ConfigMatcher[V] {
+	return &ConfigMatcher[V]{values: NewMultiMap[V](WithDuplicatePolicy(DuplicateOverwrite))}
+}
+
+// configKey returns key as it is stored, with a trailing dot so that only whole
+// segments match. The empty key stays empty, the root.
+func configKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "."
+}
+
+// Set sets the value of key, replacing any it had. It returns an error if key
+// has an empty segment, such as "a..b" or ".a".
+func (m *ConfigMatcher[V]) Set(key string, v V) error {
+	if key != "" && slices.Contains(strings.Split(key, "."), "") {
+		return fmt.Errorf("compressedtrie: invalid config key %q", key)
+	}
+	return m.values.Append(configKey(key), v)
+}
+
+// Delete removes the value set on key, and reports whether there was one. Keys
+// below it inherit from its ancestors again.
+func (m *ConfigMatcher[V]) Delete(key string) bool {
+	return m.values.Delete(configKey(key))
+}
+
+// Lookup returns the value that applies to key, the one set on the longest of
+// key and its ancestors, along with the key it was set on. It returns false if
+// none of them has a value.
+func (m *ConfigMatcher[V]) Lookup(key string) (v V, from string, ok bool) {
+	for k, value := range m.Chain(key) {
+		return value, k, true
+	}
+	return v, "", false
+}
+
+// Chain returns an iterator over the values set on key and its ancestors, most
+// specific first, and the keys they were set on, for merging layered
+// configurations.
+func (m *ConfigMatcher[V]) Chain(key string) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		s := configKey(key)
+		var nodes []*Node
+		var ends []int
+		cur := m.values.tree.root
+		for consumed := 0; ; {
+			if cur.isWord {
+				nodes = append(nodes, cur)
+				ends = append(ends, consumed)
+			}
+			if consumed == len(s) {
+				break
+			}
+			child, exists := cur.children[s[consumed]]
+			if !exists || !strings.HasPrefix(s[consumed:], child.label) {
+				break
+			}
+			consumed += len(child.label)
+			cur = child
+		}
+		for i := len(nodes) - 1; i >= 0; i-- {
+			values := nodeValues[V](nodes[i])
+			if len(values) == 0 {
+				continue
+			}
+			if !yield(strings.TrimSuffix(s[:ends[i]], "."), values[len(values)-1]) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of keys with a value set.
+func (m *ConfigMatcher[V]) Len() int {
+	return m.values.Len()
+}
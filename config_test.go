@@ -0,0 +1,63 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConfigMatcher(t *testing.T) {
+	m := NewConfigMatcher[int]()
+	for key, v := range map[string]int{"": 1, "db": 10, "db.primary": 20, "db.primary.timeout": 30, "cache": 40} {
+		if err := m.Set(key, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"a..b", ".a", "a."} {
+		if err := m.Set(key, 0); err == nil {
+			t.Errorf("Expected an error for key %q", key)
+		}
+	}
+
+	cases := []struct {
+		Key      string
+		Expected int
+		From     string
+	}{
+		{"db.primary.timeout", 30, "db.primary.timeout"},
+		{"db.primary.retries", 20, "db.primary"},
+		{"db.primary", 20, "db.primary"},
+		// Only whole segments match
+		{"db.primary2", 10, "db"},
+		{"dbx", 1, ""},
+		{"cache.ttl", 40, "cache"},
+		{"", 1, ""},
+	}
+	for _, tc := range cases {
+		if v, from, ok := m.Lookup(tc.Key); !ok || v != tc.Expected || from != tc.From {
+			t.Errorf("%q: expected %d from %q, got %d from %q %v", tc.Key, tc.Expected, tc.From, v, from, ok)
+		}
+	}
+
+	var chain []string
+	var values []int
+	for key, v := range m.Chain("db.primary.timeout.ms") {
+		chain = append(chain, key)
+		values = append(values, v)
+	}
+	if !slices.Equal(chain, []string{"db.primary.timeout", "db.primary", "db", ""}) || !slices.Equal(values, []int{30, 20, 10, 1}) {
+		t.Errorf("Unexpected chain %q %v", chain, values)
+	}
+
+	// Setting again replaces, deleting inherits again
+	m.Set("db", 11)
+	if !m.Delete("db.primary") || m.Delete("db.primary") || m.Len() != 4 {
+		t.Errorf("Unexpected Delete results")
+	}
+	if v, from, _ := m.Lookup("db.primary.retries"); v != 11 || from != "db" {
+		t.Errorf("Expected 11 from db, got %d from %q", v, from)
+	}
+	m.Delete("")
+	if _, _, ok := m.Lookup("other"); ok {
+		t.Errorf("Expected no value without a default")
+	}
+}
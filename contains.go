@@ -0,0 +1,50 @@
+package compressedtrie
+
+// FindWordsWithPrefixContaining returns the words in t that start with prefix
+// and contain substr somewhere after it, in the same order as
+// FindWordsWithPrefix. This is a search box that matches a category prefix
+// plus a query token. The substring is matched as the tree is walked, so each
+// byte below the prefix is examined once and once a path holds substr its
+// whole subtree is taken without further checks. Subtrees whose words are all
+// too short to hold substr are skipped.
+func (t *Tree) FindWordsWithPrefixContaining(prefix, substr string) []string {
+	words, _ := t.Query(prefix, QueryOptions{Contains: substr})
+	return words
+}
+
+// A matcher finds a pattern in a stream of bytes with the Knuth-Morris-Pratt
+// algorithm. Its state is the length of the longest prefix of the pattern that
+// ends the bytes seen so far, the pattern has been found when it reaches
+// len(pattern).
+type matcher struct {
+	pattern string
+	fail    []int // fail[i] is the state to fall back to from state i+1
+}
+
+func newMatcher(pattern string) *matcher {
+	m := &matcher{pattern: pattern, fail: make([]int, len(pattern))}
+	for i, k := 1, 0; i < len(pattern); i++ {
+		for k > 0 && pattern[i] != pattern[k] {
+			k = m.fail[k-1]
+		}
+		if pattern[i] == pattern[k] {
+			k++
+		}
+		m.fail[i] = k
+	}
+	return m
+}
+
+// advance returns the state after seeing s in state, stopping as soon as the
+// pattern is found.
+func (m *matcher) advance(state int, s string) int {
+	for i := 0; i < len(s) && state < len(m.pattern); i++ {
+		for state > 0 && s[i] != m.pattern[state] {
+			state = m.fail[state-1]
+		}
+		if s[i] == m.pattern[state] {
+			state++
+		}
+	}
+	return state
+}
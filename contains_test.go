@@ -0,0 +1,53 @@
+package compressedtrie
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindWordsWithPrefixContaining(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"books/go-programming", "books/gopher", "books/rust", "music/go-go", "books/", "books/aabaab", "books/abaaba"} {
+		tree.Insert(word)
+	}
+	cases := []struct {
+		Prefix, Substr string
+		Expected       []string
+	}{
+		{"books/", "go", []string{"books/go-programming", "books/gopher"}},
+		{"books/", "gram", []string{"books/go-programming"}},
+		{"", "go", []string{"books/go-programming", "books/gopher", "music/go-go"}},
+		{"books/", "", []string{"books/", "books/aabaab", "books/abaaba", "books/go-programming", "books/gopher", "books/rust"}},
+		// Only after the prefix
+		{"books", "s/", nil},
+		{"books/g", "o", []string{"books/go-programming", "books/gopher"}},
+		// Partial matches fall back correctly
+		{"books/", "abaab", []string{"books/aabaab", "books/abaaba"}},
+		{"books/", "aaba", []string{"books/aabaab", "books/abaaba"}},
+		{"books/", "abab", nil},
+		{"books/", "baaba", []string{"books/abaaba"}},
+		{"books/", "gophers", nil},
+		{"films/", "go", nil},
+	}
+	for _, tc := range cases {
+		actual := tree.FindWordsWithPrefixContaining(tc.Prefix, tc.Substr)
+		if !slices.Equal(actual, tc.Expected) {
+			t.Errorf("(%q, %q): expected %v, got %v", tc.Prefix, tc.Substr, tc.Expected, actual)
+		}
+	}
+
+	// Agrees with filtering every word
+	big := perfTree(3000)
+	for _, q := range [][2]string{{"", "ing"}, {"a", "er"}, {"b", "ss"}, {"", "aaa"}} {
+		var expected []string
+		for _, word := range big.FindWordsWithPrefix(q[0]) {
+			if strings.Contains(word[len(q[0]):], q[1]) {
+				expected = append(expected, word)
+			}
+		}
+		if actual := big.FindWordsWithPrefixContaining(q[0], q[1]); !slices.Equal(actual, expected) {
+			t.Errorf("%q: expected %d words, got %d", q, len(expected), len(actual))
+		}
+	}
+}
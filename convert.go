@@ -0,0 +1,60 @@
+package compressedtrie
+
+import "iter"
+
+// The functions below move keys and values between a MultiMap and other radix
+// tree packages, such as github.com/hashicorp/go-immutable-radix and
+// github.com/armon/go-radix, without this package depending on them. Importing
+// from an iradix.Tree r looks like
+//
+//	m, err := compressedtrie.FromIterator(compressedtrie.WalkIterator(
+//		func(fn func([]byte, any) bool) { r.Root().Walk(fn) }))
+//
+// and exporting to one looks like
+//
+//	txn := iradix.New().Txn()
+//	for key, v := range m.ToIterator() {
+//		txn.Insert([]byte(key), v)
+//	}
+//	r = txn.Commit()
+//
+// Those packages hold one value per key, so a key with several values in m
+// keeps only the last. Ranging over All instead and inserting the []V keeps
+// them all.
+
+// FromIterator returns a MultiMap holding the keys and values of seq, a key
+// that appears more than once keeps all its values in order. The options apply
+// to the Tree that holds the keys, FromIterator returns the first error from
+// inserting a key.
+func FromIterator[K ~string | ~[]byte, V any](seq iter.Seq2[K, V], opts ...Option) (*MultiMap[V], error) {
+	m := NewMultiMap[V](opts...)
+	for key, v := range seq {
+		if err := m.Append(string(key), v); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ToIterator returns an iterator over the keys of m and their values in the
+// same order as All, yielding a key once for each of its values.
+func (m *MultiMap[V]) ToIterator() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		for key, values := range m.All("") {
+			for _, v := range values {
+				if !yield(key, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// WalkIterator turns a walk function into an iterator. walk calls fn for each
+// key and value until fn returns true, which is how the Walk methods of
+// go-immutable-radix and go-radix stop.
+func WalkIterator[K ~string | ~[]byte, V any](walk func(fn func(K, V) bool)) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		walk(func(key K, v V) bool { return !yield(key, v) })
+	}
+}
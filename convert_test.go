@@ -0,0 +1,84 @@
+package compressedtrie
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// walkMap stands in for the Walk method of a radix tree package, it calls fn
+// for the keys in order until fn returns true.
+type walkMap map[string]int
+
+func (w walkMap) Walk(fn func(k []byte, v any) bool) {
+	keys := make([]string, 0, len(w))
+	for k := range w {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if fn([]byte(k), w[k]) {
+			return
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	src := walkMap{"": 0, "toast": 1, "toaster": 2, "slow": 3, "\xff\x00": 4}
+	m, err := FromIterator(WalkIterator(func(fn func([]byte, any) bool) { src.Walk(fn) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != len(src) {
+		t.Errorf("Expected %d keys, got %d", len(src), m.Len())
+	}
+
+	// And back again, in order and without losing anything
+	dst := walkMap{}
+	var keys []string
+	for key, v := range m.ToIterator() {
+		dst[key] = v.(int)
+		keys = append(keys, key)
+	}
+	if !slices.IsSorted(keys) || len(dst) != len(src) {
+		t.Errorf("Unexpected keys %q", keys)
+	}
+	for k, v := range src {
+		if dst[k] != v {
+			t.Errorf("%q: expected %d, got %d", k, v, dst[k])
+		}
+	}
+
+	// Stopping early stops the walk
+	n := 0
+	for range WalkIterator(func(fn func([]byte, any) bool) { src.Walk(fn) }) {
+		if n++; n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 keys, got %d", n)
+	}
+
+	// Several values for a key are yielded in order
+	pairs := func(yield func(string, int) bool) {
+		_ = yield("b", 1) && yield("a", 2) && yield("b", 3)
+	}
+	multi, err := FromIterator(pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []int
+	for _, v := range multi.ToIterator() {
+		values = append(values, v)
+	}
+	if !slices.Equal(values, []int{2, 1, 3}) {
+		t.Errorf("Expected [2 1 3], got %v", values)
+	}
+
+	long := WalkIterator(func(fn func([]byte, any) bool) { walkMap{"toolong": 1}.Walk(fn) })
+	if _, err := FromIterator(long, WithMaxWordLength(3)); !errors.Is(err, ErrWordTooLong) {
+		t.Errorf("Expected ErrWordTooLong, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package compressedtrie
+
+import "strings"
+
+// A Cursor is a position at a node of a tree that can move down to children and
+// back up to ancestors. It keeps the nodes between the root and its position on
+// a stack, so moving up is as cheap as moving down and nodes need no parent
+// links. Hierarchical lookups, such as finding the most specific setting for a
+// key, descend once and then walk up to the nearest word:
+//
+//	c := tree.Cursor()
+//	c.Descend("net/http/client/timeout")
+//	if c.IsWord() || c.UpToWord() {
+//		// c.Path() is the longest word that is a prefix of the key
+//	}
+//
+// Changing the tree, with Insert, InsertSortedNext, a Delete that finds the
+// word, ApplyDirty or DeserializeInto, makes its cursors stale even if the
+// words are the same, since the nodes they hold may have been split, merged,
+// copied or reused. A stale cursor doesn't move: Descend, Up and
+// UpToWord return false, IsWord reports false and Err returns ErrStaleCursor.
+// Path and Depth still describe where it was, so a long-lived cursor can be
+// brought back with Reset and a Descend of its old path. Lookups that don't
+// change the tree leave its cursors valid.
+type Cursor struct {
+	tree    *Tree
+	nodes   []*Node // from the root to the current node
+	path    string  // path of the current node from the root
+	changes uint64  // tree.changes when nodes was last valid
+}
+
+// Cursor returns a cursor positioned at the root of t.
+func (t *Tree) Cursor() *Cursor {
+	return &Cursor{tree: t, nodes: []*Node{t.root}, changes: t.changes}
+}
+
+// Reset moves c back to the root of its tree as it is now, which makes a
+// stale cursor valid again.
+func (c *Cursor) Reset() {
+	c.nodes = append(c.nodes[:0], c.tree.root)
+	c.path = ""
+	c.changes = c.tree.changes
+}
+
+// Err returns ErrStaleCursor if the tree of c has changed since c was created
+// or last Reset, and nil otherwise.
+func (c *Cursor) Err() error {
+	if c.changes != c.tree.changes {
+		return ErrStaleCursor
+	}
+	return nil
+}
+
+// Path returns the path from the root to the node c is at.
+func (c *Cursor) Path() string {
+	return c.path
+}
+
+// Depth returns the number of edges between the root and the node c is at.
+func (c *Cursor) Depth() int {
+	return len(c.nodes) - 1
+}
+
+// IsWord reports whether the node c is at marks the end of a word.
+func (c *Cursor) IsWord() bool {
+	return c.Err() == nil && c.nodes[len(c.nodes)-1].isWord
+}
+
+// Descend moves c down through the nodes whose labels s spells out in full,
+// stopping at the last one, and reports whether all of s was consumed. Note
+// that c only ever rests at nodes, so a trailing part of s that ends inside a
+// label is not consumed.
+func (c *Cursor) Descend(s string) bool {
+	if c.Err() != nil {
+		return false
+	}
+	cur := c.nodes[len(c.nodes)-1]
+	for s != "" {
+		child, exists := cur.children[s[0]]
+		if !exists || !strings.HasPrefix(s, child.label) {
+			return false
+		}
+		s = s[len(child.label):]
+		cur = child
+		c.nodes = append(c.nodes, cur)
+		c.path += child.label
+	}
+	return true
+}
+
+// Up moves c to the parent of the node it is at, and reports whether it moved,
+// which it doesn't at the root.
+func (c *Cursor) Up() bool {
+	if len(c.nodes) == 1 || c.Err() != nil {
+		return false
+	}
+	node := c.nodes[len(c.nodes)-1]
+	c.nodes = c.nodes[:len(c.nodes)-1]
+	c.path = c.path[:len(c.path)-len(node.label)]
+	return true
+}
+
+// UpToWord moves c to the nearest node above it that marks the end of a word,
+// and reports whether there is one. If there isn't c is left where it was.
+func (c *Cursor) UpToWord() bool {
+	if c.Err() != nil {
+		return false
+	}
+	for i := len(c.nodes) - 2; i >= 0; i-- {
+		if c.nodes[i].isWord {
+			for len(c.nodes) > i+1 {
+				c.Up()
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"net", "net/http", "net/http/client/timeout", "net/http/client/retries", "os"} {
+		tree.Insert(word)
+	}
+
+	c := tree.Cursor()
+	if c.Path() != "" || c.Depth() != 0 || c.IsWord() || c.Up() || c.UpToWord() {
+		t.Errorf("Unexpected state at the root")
+	}
+
+	if c.Descend("net/http/client/timeouts") {
+		t.Errorf("Descend consumed a key that runs off the tree")
+	}
+	if c.Path() != "net/http/client/timeout" || !c.IsWord() || c.Depth() != 4 {
+		t.Errorf("Expected to stop at net/http/client/timeout, got %q at depth %d", c.Path(), c.Depth())
+	}
+
+	// Walking up from a node between words finds the nearest one above
+	c.Up()
+	if c.Path() != "net/http/client/" || c.IsWord() {
+		t.Errorf("Expected to move up to net/http/client/, got %q", c.Path())
+	}
+	if !c.UpToWord() || c.Path() != "net/http" {
+		t.Errorf("Expected the nearest word above to be net/http, got %q", c.Path())
+	}
+	if !c.UpToWord() || c.Path() != "net" {
+		t.Errorf("Expected the nearest word above to be net, got %q", c.Path())
+	}
+	if c.UpToWord() || c.Path() != "net" {
+		t.Errorf("Expected to stay at net, got %q", c.Path())
+	}
+
+	// Descending continues from the current position
+	if !c.Descend("/http") || c.Path() != "net/http" {
+		t.Errorf("Expected to descend to net/http, got %q", c.Path())
+	}
+	c.Reset()
+	if c.Path() != "" || c.Depth() != 0 {
+		t.Errorf("Reset left the cursor at %q", c.Path())
+	}
+
+	// The cursor agrees with LongestPrefix
+	for _, s := range []string{"net/http/client/timeout", "net/http/clients", "net/https", "nets", "o", "os/exec", ""} {
+		c.Reset()
+		c.Descend(s)
+		found := c.IsWord() || c.UpToWord()
+		prefix, ok := tree.LongestPrefix(s)
+		if found != ok || ok && c.Path() != prefix {
+			t.Errorf("%q: cursor found %q %v, LongestPrefix %q %v", s, c.Path(), found, prefix, ok)
+		}
+	}
+}
+
+func TestCursorStale(t *testing.T) {
+	tree := NewTree(WithHistory(2))
+	for _, word := range []string{"net", "net/http", "os"} {
+		tree.Insert(word)
+	}
+	c := tree.Cursor()
+	c.Descend("net/http")
+	if c.Err() != nil {
+		t.Fatal(c.Err())
+	}
+
+	// Lookups and deleting a missing word leave the cursor valid
+	tree.Contains("os")
+	tree.FindWordsWithPrefix("n")
+	if tree.Delete("net/https") || c.Err() != nil || !c.IsWord() {
+		t.Errorf("Cursor went stale without a change, %v", c.Err())
+	}
+
+	// So do inserts the tree's limits reject
+	limited := NewTree(WithMaxDepth(1), WithMaxWordLength(5), WithHistory(1))
+	limited.Insert("abc")
+	limited.Commit()
+	lc := limited.Cursor()
+	root := limited.root
+	for _, insert := range []func(string) error{limited.Insert, limited.InsertSortedNext} {
+		for _, word := range []string{"abd", "ab", "abcx", "toolong"} {
+			if err := insert(word); err == nil {
+				t.Errorf("Expected %q to be rejected", word)
+			}
+		}
+	}
+	if lc.Err() != nil || limited.root != root {
+		t.Errorf("Rejected inserts changed the tree, %v", lc.Err())
+	}
+
+	delta := &bytes.Buffer{}
+	if err := NewTree(WithDirtyTracking()).SerializeDirty(delta); err != nil {
+		t.Fatal(err)
+	}
+	changes := []struct {
+		Name   string
+		Change func()
+	}{
+		{"Insert", func() { tree.Insert("net/url") }},
+		{"Insert again", func() { tree.Insert("net/url") }},
+		{"InsertSortedNext", func() { tree.InsertSortedNext("path") }},
+		{"Delete", func() { tree.Delete("path") }},
+		{"DeserializeInto", func() { tree.DeserializeInto(bytes.NewReader(tree.Freeze())) }},
+		{"ApplyDirty", func() { tree.ApplyDirty(bytes.NewReader(delta.Bytes())) }},
+		{"Insert after Commit", func() { tree.Commit(); tree.Insert("net/http/client") }},
+	}
+	for _, tc := range changes {
+		name := tc.Name
+		c.Reset()
+		if !c.Descend("net/http") || c.Err() != nil {
+			t.Fatalf("%s: Reset didn't revalidate the cursor", name)
+		}
+		tc.Change()
+		if !errors.Is(c.Err(), ErrStaleCursor) {
+			t.Errorf("%s: expected ErrStaleCursor, got %v", name, c.Err())
+		}
+		if c.IsWord() || c.Up() || c.UpToWord() || c.Descend("") {
+			t.Errorf("%s: stale cursor moved", name)
+		}
+		if c.Path() != "net/http" {
+			t.Errorf("%s: stale cursor lost its position %q", name, c.Path())
+		}
+	}
+}
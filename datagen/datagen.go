@@ -0,0 +1,116 @@
+// Package datagen generates synthetic word sets for benchmarking and fuzzing
+// compressedtrie. The words are chosen by walking a random virtual trie, so
+// how much of a prefix the words share can be controlled through the number of
+// branches at each step and how the walk picks between them.
+package datagen
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+)
+
+// Config describes a word set. The zero value of each field picks a default,
+// and values out of range are brought into it rather than rejected.
+type Config struct {
+	// Count is the number of distinct words to generate. Fewer are returned if
+	// the other parameters don't allow that many.
+	Count int
+
+	// Seed makes the output reproducible, the same Config always gives the
+	// same words.
+	Seed uint64
+
+	// Alphabet is the bytes words are made of, a to z by default.
+	Alphabet string
+
+	// MinLength and MaxLength bound the length of the words before any
+	// suffix, 1 and 12 by default. A MaxLength below MinLength is taken to
+	// be MinLength.
+	MinLength, MaxLength int
+
+	// LengthSkew is the exponent of the Zipf distribution the lengths are
+	// drawn from, so that short words are more common than long ones. The
+	// Zipf distribution needs an exponent greater than 1, 0 or any other
+	// value gives uniformly distributed lengths.
+	LengthSkew float64
+
+	// Branching is the number of different bytes that can follow any given
+	// prefix, at most len(Alphabet) which is also the default for values
+	// out of range. Small values make the words share more of their
+	// prefixes.
+	Branching int
+
+	// BranchSkew is the exponent of the Zipf distribution used to choose
+	// between the branches, making some continuations much more popular
+	// than others. As for LengthSkew, values of 1 or less choose uniformly.
+	BranchSkew float64
+
+	// Suffixes are endings, such as "ing" or "ed", that are appended to a
+	// word with probability SuffixRate, one chosen uniformly. Natural
+	// languages share suffixes as well as prefixes.
+	Suffixes   []string
+	SuffixRate float64
+}
+
+const defaultAlphabet = "abcdefghijklmnopqrstuvwxyz"
+
+// Generate returns the words described by cfg, in the order they were
+// generated.
+func Generate(cfg Config) []string {
+	if cfg.Alphabet == "" {
+		cfg.Alphabet = defaultAlphabet
+	}
+	if cfg.MinLength <= 0 {
+		cfg.MinLength = 1
+	}
+	if cfg.MaxLength <= 0 {
+		cfg.MaxLength = max(12, cfg.MinLength)
+	}
+	cfg.MaxLength = max(cfg.MaxLength, cfg.MinLength)
+	cfg.Count = max(cfg.Count, 0)
+	if cfg.Branching <= 0 || cfg.Branching > len(cfg.Alphabet) {
+		cfg.Branching = len(cfg.Alphabet)
+	}
+
+	r := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15))
+	length := picker(r, cfg.LengthSkew, cfg.MaxLength-cfg.MinLength+1)
+	branch := picker(r, cfg.BranchSkew, cfg.Branching)
+
+	seen := make(map[string]bool, cfg.Count)
+	words := make([]string, 0, cfg.Count)
+	// Give up once a long run of attempts only finds duplicates
+	for misses := 0; len(words) < cfg.Count && misses < 1000; {
+		n := cfg.MinLength + length()
+		word := make([]byte, 0, n)
+		for range n {
+			// The branches of a prefix are a window onto the alphabet that
+			// depends only on the prefix and the seed.
+			h := fnv.New64a()
+			h.Write(word)
+			start := (h.Sum64() ^ cfg.Seed) % uint64(len(cfg.Alphabet))
+			word = append(word, cfg.Alphabet[(start+uint64(branch()))%uint64(len(cfg.Alphabet))])
+		}
+		if len(cfg.Suffixes) > 0 && r.Float64() < cfg.SuffixRate {
+			word = append(word, cfg.Suffixes[r.IntN(len(cfg.Suffixes))]...)
+		}
+
+		if seen[string(word)] {
+			misses++
+			continue
+		}
+		misses = 0
+		seen[string(word)] = true
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// picker returns a function that picks a number in [0, n), from a Zipf
+// distribution with exponent skew or uniformly if skew is 1 or less.
+func picker(r *rand.Rand, skew float64, n int) func() int {
+	if !(skew > 1) || n == 1 {
+		return func() int { return r.IntN(n) }
+	}
+	z := rand.NewZipf(r, skew, 1, uint64(n-1))
+	return func() int { return int(z.Uint64()) }
+}
@@ -0,0 +1,96 @@
+package datagen
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	cfg := Config{Count: 2000, Seed: 7, Alphabet: "abcdefgh", MinLength: 3, MaxLength: 9, LengthSkew: 1.5, Branching: 3, BranchSkew: 2}
+	words := Generate(cfg)
+	if len(words) != cfg.Count {
+		t.Fatalf("Expected %d words, got %d", cfg.Count, len(words))
+	}
+	if !slices.Equal(words, Generate(cfg)) {
+		t.Errorf("Expected the same words for the same Config")
+	}
+	cfg.Seed++
+	if slices.Equal(words, Generate(cfg)) {
+		t.Errorf("Expected different words for a different seed")
+	}
+
+	seen := make(map[string]bool)
+	next := make(map[string]map[byte]bool)
+	for _, word := range words {
+		if seen[word] {
+			t.Fatalf("Duplicate word %q", word)
+		}
+		seen[word] = true
+		if len(word) < 3 || len(word) > 9 || strings.Trim(word, "abcdefgh") != "" {
+			t.Fatalf("Word %q doesn't match the Config", word)
+		}
+		for i := range len(word) {
+			if next[word[:i]] == nil {
+				next[word[:i]] = make(map[byte]bool)
+			}
+			next[word[:i]][word[i]] = true
+		}
+	}
+	for prefix, bytes := range next {
+		if len(bytes) > 3 {
+			t.Errorf("Prefix %q has %d branches, expected at most 3", prefix, len(bytes))
+		}
+	}
+
+	lengths := make([]int, 13)
+	for _, word := range Generate(Config{Count: 2000, LengthSkew: 1.5}) {
+		lengths[len(word)]++
+	}
+	if lengths[10] >= lengths[4] {
+		t.Errorf("Expected long words to be rarer than short ones, got %v", lengths)
+	}
+}
+
+func TestGenerateExhausted(t *testing.T) {
+	// There are only 4 words of length 2 over 2 bytes
+	words := Generate(Config{Count: 10, Alphabet: "ab", MinLength: 2, MaxLength: 2})
+	slices.Sort(words)
+	if !slices.Equal(words, []string{"aa", "ab", "ba", "bb"}) {
+		t.Errorf("Expected every 2 byte word, got %v", words)
+	}
+}
+
+func TestGenerateSuffixes(t *testing.T) {
+	words := Generate(Config{Count: 500, Suffixes: []string{"ing", "ed"}, SuffixRate: 0.5})
+	n := 0
+	for _, word := range words {
+		if strings.HasSuffix(word, "ing") || strings.HasSuffix(word, "ed") {
+			n++
+		}
+	}
+	if n < 200 {
+		t.Errorf("Expected about half the words to have a suffix, got %d of %d", n, len(words))
+	}
+}
+
+func TestGenerateOutOfRange(t *testing.T) {
+	for _, cfg := range []Config{
+		{Count: 100, MinLength: 20, MaxLength: 10},
+		{Count: 100, LengthSkew: 0.5, BranchSkew: -2},
+		{Count: 100, LengthSkew: 1, BranchSkew: 1},
+		{Count: 100, Branching: -3},
+		{Count: 100, Branching: 1000, Alphabet: "xyz"},
+		{Count: -5},
+	} {
+		words := Generate(cfg)
+		if len(words) != max(cfg.Count, 0) {
+			t.Errorf("%+v: expected %d words, got %d", cfg, max(cfg.Count, 0), len(words))
+		}
+		for _, word := range words {
+			if cfg.MinLength > 0 && len(word) != cfg.MinLength {
+				t.Errorf("%+v: expected words of %d bytes, got %q", cfg, cfg.MinLength, word)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package compressedtrie
+
+import "fmt"
+
+// A DuplicateReport counts the inserts of words that were already in a tree,
+// see WithDuplicateReport.
+type DuplicateReport struct {
+	Count    int      // number of inserts that found the word already present
+	Examples []string // the first few duplicated words, each listed once
+
+	limit int
+	seen  map[string]bool // the words in Examples
+}
+
+// WithDuplicateReport makes Insert count the words it is given that are
+// already in the tree, which otherwise pass silently, and keep up to examples
+// of them. Duplicates in input that should be unique usually point at a bug
+// further up a data pipeline, DuplicateReport lets it be noticed.
+func WithDuplicateReport(examples int) Option {
+	return func(t *Tree) {
+		t.dups = &DuplicateReport{limit: examples, seen: make(map[string]bool)}
+	}
+}
+
+// DuplicatePolicy is what a MultiMap or WeightedTree does with a value for a
+// key that already has one, see WithDuplicatePolicy.
+type DuplicatePolicy int
+
+const (
+	// DuplicateCombine keeps both: a MultiMap appends the value to those of
+	// the key and a WeightedTree adds the weights.
+	DuplicateCombine DuplicatePolicy = iota
+	// DuplicateOverwrite replaces the key's values with the new one.
+	DuplicateOverwrite
+	// DuplicateKeepFirst drops the new value.
+	DuplicateKeepFirst
+	// DuplicateError drops the new value and returns an error matching
+	// ErrDuplicateKey.
+	DuplicateError
+)
+
+// WithDuplicatePolicy sets what the MultiMap or WeightedTree holding t does
+// with a value for a key that already has one, DuplicateCombine by default.
+// ETL jobs that expect one payload per key can use DuplicateError to catch
+// conflicting payloads instead of silently merging or clobbering them. Plain
+// trees have no values and ignore it.
+func WithDuplicatePolicy(p DuplicatePolicy) Option {
+	return func(t *Tree) { t.duplicates = p }
+}
+
+// duplicate applies t's DuplicatePolicy to a new value for key, which already
+// has values. It returns whether to keep the value and whether it replaces
+// those there, or the error for DuplicateError.
+func (t *Tree) duplicate(key string) (keep, replace bool, err error) {
+	switch t.duplicates {
+	case DuplicateOverwrite:
+		return true, true, nil
+	case DuplicateKeepFirst:
+		return false, false, nil
+	case DuplicateError:
+		return false, false, fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+	}
+	return true, false, nil
+}
+
+// add records an insert of word, which is already in the tree.
+func (r *DuplicateReport) add(word string) {
+	if r == nil {
+		return
+	}
+	r.Count++
+	if len(r.Examples) < r.limit && !r.seen[word] {
+		r.seen[word] = true
+		r.Examples = append(r.Examples, word)
+	}
+}
+
+// Duplicates returns the duplicate inserts seen since t was created or the last
+// call to ResetDuplicates, and false if t was not created with
+// WithDuplicateReport.
+func (t *Tree) Duplicates() (DuplicateReport, bool) {
+	if t.dups == nil {
+		return DuplicateReport{}, false
+	}
+	return DuplicateReport{Count: t.dups.Count, Examples: append([]string(nil), t.dups.Examples...)}, true
+}
+
+// ResetDuplicates forgets the duplicate inserts seen so far, for example
+// between batches of input.
+func (t *Tree) ResetDuplicates() {
+	if t.dups != nil {
+		t.dups.Count = 0
+		t.dups.Examples = nil
+		clear(t.dups.seen)
+	}
+}
@@ -0,0 +1,84 @@
+package compressedtrie
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDuplicateReport(t *testing.T) {
+	tree := NewTree(WithDuplicateReport(2))
+	for _, word := range []string{"alpha", "alphabet", "alpha", "beta", "alpha", "beta", "alphabet", "gamma", ""} {
+		tree.Insert(word)
+	}
+	report, ok := tree.Duplicates()
+	if !ok || report.Count != 4 || !slices.Equal(report.Examples, []string{"alpha", "beta"}) {
+		t.Errorf("Expected 4 duplicates with examples alpha and beta, got %+v", report)
+	}
+	if tree.WordCount() != 5 {
+		t.Errorf("Expected 5 words, got %d", tree.WordCount())
+	}
+
+	tree.ResetDuplicates()
+	tree.Insert("")
+	if report, _ := tree.Duplicates(); report.Count != 1 || !slices.Equal(report.Examples, []string{""}) {
+		t.Errorf("Expected the empty word as the only duplicate after a reset, got %+v", report)
+	}
+
+	if _, ok := NewTree().Duplicates(); ok {
+		t.Errorf("Expected no report without WithDuplicateReport")
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	cases := []struct {
+		Policy  DuplicatePolicy
+		Values  []int
+		Weight  float64
+		Errored bool
+	}{
+		{DuplicateCombine, []int{1, 2, 3}, 6, false},
+		{DuplicateOverwrite, []int{3}, 3, false},
+		{DuplicateKeepFirst, []int{1}, 1, false},
+		{DuplicateError, []int{1}, 1, true},
+	}
+	for _, tc := range cases {
+		m := NewMultiMap[int](WithDuplicatePolicy(tc.Policy), WithDuplicateReport(1))
+		w := NewWeightedTree(WithDuplicatePolicy(tc.Policy), WithDuplicateReport(1))
+		var mErr, wErr error
+		for _, v := range []int{1, 2, 3} {
+			if err := m.Append("key", v); err != nil {
+				mErr = err
+			}
+			if err := w.Add("key", float64(v)); err != nil {
+				wErr = err
+			}
+		}
+		if values := m.Values("key"); !slices.Equal(values, tc.Values) {
+			t.Errorf("%d: expected values %v, got %v", tc.Policy, tc.Values, values)
+		}
+		if weight, _ := w.Weight("key"); weight != tc.Weight || w.TotalWeight() != tc.Weight {
+			t.Errorf("%d: expected weight %v, got %v", tc.Policy, tc.Weight, weight)
+		}
+		if tc.Errored != errors.Is(mErr, ErrDuplicateKey) || tc.Errored != errors.Is(wErr, ErrDuplicateKey) {
+			t.Errorf("%d: expected an error %v, got %v and %v", tc.Policy, tc.Errored, mErr, wErr)
+		}
+
+		// Every policy reports the two duplicates, once each
+		mReport, _ := m.Tree().Duplicates()
+		wReport, _ := w.Tree().Duplicates()
+		if mReport.Count != 2 || wReport.Count != 2 {
+			t.Errorf("%d: expected 2 duplicates, got %d and %d", tc.Policy, mReport.Count, wReport.Count)
+		}
+
+		// Keys without values yet aren't duplicates
+		m.Tree().ResetDuplicates()
+		m.Tree().Insert("bare")
+		if err := m.Append("bare", 4); err != nil || !slices.Equal(m.Values("bare"), []int{4}) {
+			t.Errorf("%d: key without values: %v %v", tc.Policy, err, m.Values("bare"))
+		}
+		if report, _ := m.Tree().Duplicates(); report.Count != 0 {
+			t.Errorf("%d: expected no duplicates for a key without values, got %d", tc.Policy, report.Count)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package compressedtrie
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DelimitedOptions configures ReadDelimited.
+type DelimitedOptions[V any] struct {
+	// Comma is the field delimiter, it defaults to a tab. Use ',' for CSV.
+	Comma rune
+
+	// KeyColumn is the index of the column holding the key, from 0.
+	KeyColumn int
+
+	// Header skips the first record, the column names.
+	Header bool
+
+	// LazyQuotes allows quotes in unquoted fields and unescaped quotes in
+	// quoted ones, as written by many TSV exporters. Quoted fields follow
+	// RFC 4180 either way.
+	LazyQuotes bool
+
+	// Value builds the value for a record from its fields, including the
+	// key. The slice is reused for the next record, so it must not be kept,
+	// and the fields share the memory of the whole record, strings.Clone any
+	// kept in the value. Returning an error stops the read. Required.
+	Value func(fields []string) (V, error)
+
+	// Options apply to the Tree that holds the keys.
+	Options []Option
+}
+
+// ReadDelimited returns a MultiMap of the records of a CSV or TSV file, keyed
+// on one column with a value built from the record by opts.Value. Records are
+// read one at a time, so the file is never held in memory. A key on more than
+// one record gets the value of each, subject to the DuplicatePolicy in
+// opts.Options. Errors from parsing, building a value or inserting a key give
+// the line of the record.
+func ReadDelimited[V any](r io.Reader, opts DelimitedOptions[V]) (*MultiMap[V], error) {
+	if opts.Value == nil {
+		return nil, errors.New("compressedtrie: DelimitedOptions.Value is required")
+	}
+	cr := csv.NewReader(r)
+	cr.Comma = '\t'
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.LazyQuotes = opts.LazyQuotes
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	m := NewMultiMap[V](opts.Options...)
+	for first := true; ; first = false {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("compressedtrie: %w", err)
+		}
+		if first && opts.Header {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		if opts.KeyColumn < 0 || opts.KeyColumn >= len(fields) {
+			return nil, fmt.Errorf("compressedtrie: line %d: no column %d in %d fields", line, opts.KeyColumn, len(fields))
+		}
+		v, err := opts.Value(fields)
+		if err != nil {
+			return nil, fmt.Errorf("compressedtrie: line %d: %w", line, err)
+		}
+		if err := m.Append(strings.Clone(fields[opts.KeyColumn]), v); err != nil {
+			return nil, fmt.Errorf("compressedtrie: line %d: %w", line, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package compressedtrie
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadDelimited(t *testing.T) {
+	type entry struct {
+		Lang  string
+		Count int
+	}
+	value := func(fields []string) (entry, error) {
+		if len(fields) != 3 {
+			return entry{}, fmt.Errorf("%d fields", len(fields))
+		}
+		n, err := strconv.Atoi(fields[2])
+		return entry{strings.Clone(fields[1]), n}, err
+	}
+
+	tsv := "word\tlang\tcount\ncolour\ten-GB\t12\ncolor\ten-US\t40\ncolour\ten-CA\t3\n\"tab\tbed\"\tde\t1\n"
+	m, err := ReadDelimited(strings.NewReader(tsv), DelimitedOptions[entry]{Header: true, Value: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 3 {
+		t.Errorf("Expected 3 keys, got %d", m.Len())
+	}
+	if values := m.Values("colour"); !slices.Equal(values, []entry{{"en-GB", 12}, {"en-CA", 3}}) {
+		t.Errorf("Unexpected values %v", values)
+	}
+	if values := m.Values("tab\tbed"); !slices.Equal(values, []entry{{"de", 1}}) {
+		t.Errorf("Quoted key lost, got %v", values)
+	}
+
+	// CSV, another key column, and the DuplicatePolicy
+	csv := "en-GB,colour,12\nen-GB,flavour,3\nen-US,color,40\n"
+	byLang, err := ReadDelimited(strings.NewReader(csv), DelimitedOptions[string]{
+		Comma:     ',',
+		KeyColumn: 0,
+		Value:     func(fields []string) (string, error) { return strings.Clone(fields[1]), nil },
+		Options:   []Option{WithDuplicatePolicy(DuplicateKeepFirst)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := byLang.Values("en-GB"); !slices.Equal(values, []string{"colour"}) {
+		t.Errorf("Unexpected values %v", values)
+	}
+
+	if _, err := ReadDelimited(strings.NewReader(`say "hi"`+"\tx\t1\n"), DelimitedOptions[entry]{Value: value}); err == nil {
+		t.Errorf("Expected a quoting error")
+	}
+	if m, err := ReadDelimited(strings.NewReader(`say "hi"`+"\tx\t1\n"), DelimitedOptions[entry]{LazyQuotes: true, Value: value}); err != nil || m.Values(`say "hi"`) == nil {
+		t.Errorf("LazyQuotes: %v", err)
+	}
+
+	// Errors give the line
+	bad := "a\tx\t1\nb\ty\tmany\n"
+	if _, err := ReadDelimited(strings.NewReader(bad), DelimitedOptions[entry]{Value: value}); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+	if _, err := ReadDelimited(strings.NewReader("a\tx\t1\nb\n"), DelimitedOptions[entry]{Value: value}); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+	if _, err := ReadDelimited(strings.NewReader("a\tx\t1\n"), DelimitedOptions[entry]{KeyColumn: 3, Value: value}); err == nil {
+		t.Errorf("Expected an error for a missing key column")
+	}
+	long := fmt.Sprintf("%s\tx\t1\n", strings.Repeat("a", 20))
+	if _, err := ReadDelimited(strings.NewReader(long), DelimitedOptions[entry]{Value: value, Options: []Option{WithMaxWordLength(10)}}); !errors.Is(err, ErrWordTooLong) {
+		t.Errorf("Expected ErrWordTooLong, got %v", err)
+	}
+	if _, err := ReadDelimited(strings.NewReader(long), DelimitedOptions[entry]{}); err == nil {
+		t.Errorf("Expected an error without a Value")
+	}
+}
@@ -0,0 +1,354 @@
+package compressedtrie
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// A delta written by SerializeDirty starts with the u32 CtreeDeltaMagic and the
+// u32 delta format version, followed by a uvarint count of entries. Each entry
+// is the uvarint length prefixed path from the root of a node and a u8 kind:
+//
+//	0 subtree  the node and everything below it are new. A uvarint length of
+//	           the path of the node's parent follows, then the subtree in the
+//	           version 2 depth first layout without a dictionary.
+//	1 word     the node now marks the end of a word.
+//	2 unword   the node no longer marks the end of a word.
+//	3 remove   the node, a child of the root, has been removed. The child of
+//	           the root that starts with the first byte of the path is removed,
+//	           whatever its label, as a merge or split since the tree was marked
+//	           clean may have changed it. A node removed further down is
+//	           recorded as a new subtree at its parent.
+//
+// Entries are in path order and no subtree entry holds another entry.
+
+// 32-bit magic number for deltas written by SerializeDirty
+const CtreeDeltaMagic uint32 = 'C'<<24 | 'T'<<16 | 'R'<<8 | 'D'
+
+const deltaVersion uint32 = 1
+
+// Kinds of delta entry
+const (
+	deltaSubtree byte = iota
+	deltaWord
+	deltaUnword
+	deltaRemove
+)
+
+// dirtyEntry records a change to a node since the tree was last marked clean.
+type dirtyEntry struct {
+	path  string // path of the node from the root
+	kind  byte   // one of the delta entry kinds
+	added bool   // for a subtree, whether its place was empty when the tree was marked clean
+}
+
+// WithDirtyTracking makes t keep track of the parts of the tree that have
+// changed since it was created or last passed to MarkClean, so that
+// SerializeDirty can write just those parts. The bookkeeping costs a map entry
+// per change and a lookup per level on every Insert and Delete.
+func WithDirtyTracking() Option {
+	return func(t *Tree) { t.dirty = make(map[*Node]dirtyEntry) }
+}
+
+// MarkClean forgets the changes recorded for SerializeDirty, typically once the
+// tree, or a delta, has been written out.
+func (t *Tree) MarkClean() {
+	if t.dirty != nil {
+		clear(t.dirty)
+	}
+}
+
+// markDirty records node, whose path from the root is path, as changed unless
+// covered reports that it is inside a subtree that is already recorded.
+func (t *Tree) markDirty(node *Node, path string, kind byte, covered bool) {
+	if t.dirty == nil || covered {
+		return
+	}
+	if e, ok := t.dirty[node]; ok && e.kind == deltaSubtree {
+		return
+	}
+	t.dirty[node] = dirtyEntry{path: path, kind: kind}
+}
+
+// markAdded records node, whose path from the root is path, as a new subtree
+// in a place where there was no node when the tree was marked clean, unless
+// covered. Unlike the subtree entries for nodes whose subtrees have changed,
+// there is nothing to record if it is removed again.
+func (t *Tree) markAdded(node *Node, path string, covered bool) {
+	if t.dirty != nil && !covered {
+		t.dirty[node] = dirtyEntry{path: path, kind: deltaSubtree, added: true}
+	}
+}
+
+// dirtySubtree reports whether node's whole subtree is recorded as changed.
+func (t *Tree) dirtySubtree(node *Node) bool {
+	e, ok := t.dirty[node]
+	return ok && e.kind == deltaSubtree
+}
+
+// SerializeDirty writes the changes to t since it was last marked clean as a
+// delta, which ApplyDirty applies to a copy of the tree as it was then. t must
+// have been created with WithDirtyTracking. The changes are not forgotten, call
+// MarkClean once the delta has been delivered.
+func (t *Tree) SerializeDirty(w io.Writer) error {
+	if t.dirty == nil {
+		return fmt.Errorf("compressedtrie: tree was not created with WithDirtyTracking")
+	}
+
+	type entry struct {
+		node *Node
+		dirtyEntry
+	}
+	var entries []entry
+	for node, e := range t.dirty {
+		entries = append(entries, entry{node, e})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		if c := strings.Compare(a.path, b.path); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.kind, b.kind)
+	})
+
+	// A path that extends the path of a new subtree is, or was, inside that
+	// subtree and is already covered by it.
+	var kept []entry
+	for _, e := range entries {
+		if len(kept) > 0 {
+			last := kept[len(kept)-1]
+			if last.kind == deltaSubtree && strings.HasPrefix(e.path, last.path) {
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+
+	buf := bufio.NewWriter(w)
+	e := &encoder{w: buf, sizes: make(map[*Node]uint64)}
+	if err := binary.Write(buf, binary.BigEndian, [2]uint32{CtreeDeltaMagic, deltaVersion}); err != nil {
+		return err
+	}
+	if err := e.writeUvarint(uint64(len(kept))); err != nil {
+		return err
+	}
+	for _, k := range kept {
+		if err := e.writeUvarint(uint64(len(k.path))); err != nil {
+			return err
+		}
+		if err := e.writeString(k.path); err != nil {
+			return err
+		}
+		if err := e.writeByte(k.kind); err != nil {
+			return err
+		}
+		if k.kind != deltaSubtree {
+			continue
+		}
+		if err := e.writeUvarint(uint64(len(k.path) - len(k.node.label))); err != nil {
+			return err
+		}
+		e.measure(k.node)
+		if err := e.writeNode(k.node); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+// ApplyDirty applies a delta written by SerializeDirty to t, which must hold
+// the same words as the tree the delta was taken from did when it was last
+// marked clean. Returns a *FormatError if the delta is corrupt and
+// ErrDeltaMismatch if it doesn't fit t, in both cases without changing t.
+func (t *Tree) ApplyDirty(r io.Reader) error {
+	var head [2]uint32
+	if err := binary.Read(r, binary.BigEndian, &head); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return &FormatError{Offset: 0, Node: -1, Reason: "reading delta header", Err: err}
+	}
+	if head[0] != CtreeDeltaMagic {
+		return formatError(0, -1, "magic number %#x", head[0])
+	}
+	if head[1] != deltaVersion {
+		return fmt.Errorf("%w: delta version %d", ErrUnsupportedVersion, head[1])
+	}
+
+	// Decode and check every entry before changing anything
+	type change struct {
+		path    string
+		kind    byte
+		parent  *Node // the node to attach subtree to, or the word node
+		subtree *Node
+		nodes   int // size of subtree
+		words   int
+	}
+	counts := &Tree{}
+	d := &decoder{r: &reader{r: bufio.NewReader(r), off: 8}, tree: counts, node: -1}
+	n, err := d.readUvarint("entry count")
+	if err != nil {
+		return err
+	}
+	var changes []change
+	for range n {
+		off := d.r.off
+		path, err := d.readString("path")
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			last := changes[len(changes)-1]
+			if path <= last.path || last.kind == deltaSubtree && strings.HasPrefix(path, last.path) {
+				return d.errorf(off, "path %q out of order", path)
+			}
+		}
+		off = d.r.off
+		kind, err := d.r.ReadByte()
+		if err != nil {
+			return d.readError(off, "entry kind", err)
+		}
+
+		c := change{path: path, kind: kind}
+		switch kind {
+		case deltaWord, deltaUnword:
+			if c.parent = t.nodeAt(path); c.parent == nil {
+				return fmt.Errorf("%w: no node at %q", ErrDeltaMismatch, path)
+			}
+		case deltaRemove:
+			if path == "" {
+				return d.errorf(off, "removal of the root")
+			}
+			c.parent = t.root
+			if c.subtree = t.root.children[path[0]]; c.subtree == nil {
+				return fmt.Errorf("%w: no node at %q", ErrDeltaMismatch, path)
+			}
+		case deltaSubtree:
+			off := d.r.off
+			parentLen, err := d.readUvarint("parent path length")
+			if err != nil {
+				return err
+			}
+			if parentLen >= uint64(len(path)) {
+				return d.errorf(off, "parent path length %d", parentLen)
+			}
+			if c.parent = t.nodeAt(path[:parentLen]); c.parent == nil {
+				return fmt.Errorf("%w: no node at %q", ErrDeltaMismatch, path[:parentLen])
+			}
+			c.subtree = &Node{}
+			counts.nodes, counts.words = 0, 0
+			off = d.r.off
+			if err := d.decodeNode(c.subtree, []byte{path[parentLen]}); err != nil {
+				return err
+			}
+			if c.subtree.label != path[parentLen:] {
+				return d.errorf(off, "subtree label %q for path %q", c.subtree.label, path)
+			}
+			c.nodes, c.words = counts.nodes, counts.words
+			computeLengths(c.subtree, len(path))
+		default:
+			return d.errorf(off, "entry kind %d", kind)
+		}
+		changes = append(changes, c)
+	}
+
+	t.finger = nil
+	t.changes++
+	for _, c := range changes {
+		if t.history != nil {
+			// The nodes found above may belong to a committed revision, find
+			// the copies that can be changed
+			switch c.kind {
+			case deltaWord, deltaUnword:
+				t.own(c.path)
+				c.parent = t.nodeAt(c.path)
+			case deltaRemove:
+				t.own("")
+				c.parent = t.root
+			case deltaSubtree:
+				parentPath := c.path[:len(c.path)-len(c.subtree.label)]
+				t.own(parentPath)
+				c.parent = t.nodeAt(parentPath)
+			}
+			if c.parent == nil {
+				// Removed by an earlier entry
+				continue
+			}
+		}
+		switch c.kind {
+		case deltaWord:
+			if !c.parent.isWord {
+				c.parent.isWord = true
+				t.words++
+				t.noteLength(c.path)
+			}
+			t.markDirty(c.parent, c.path, deltaWord, false)
+		case deltaUnword:
+			if c.parent.isWord {
+				c.parent.isWord = false
+				t.words--
+				t.refreshLengths(c.path)
+			}
+			t.markDirty(c.parent, c.path, deltaUnword, false)
+		case deltaRemove:
+			// An earlier entry may have replaced the node already
+			k := c.path[0]
+			if t.root.children[k] != c.subtree {
+				continue
+			}
+			nodes, words := countNodes(c.subtree)
+			t.nodes -= nodes
+			t.words -= words
+			delete(t.root.children, k)
+			t.refreshLengths("")
+			t.markDirty(c.subtree, c.path, deltaRemove, false)
+		case deltaSubtree:
+			k := c.subtree.label[0]
+			if old, ok := c.parent.children[k]; ok {
+				nodes, words := countNodes(old)
+				t.nodes -= nodes
+				t.words -= words
+			}
+			c.parent.children[k] = c.subtree
+			t.nodes += c.nodes
+			t.words += c.words
+			t.refreshLengths(c.path[:len(c.path)-len(c.subtree.label)])
+			t.markDirty(c.subtree, c.path, deltaSubtree, false)
+		}
+	}
+	t.misses.clear()
+	return nil
+}
+
+// nodeAt returns the node whose path from the root is path, or nil if there
+// isn't one.
+func (t *Tree) nodeAt(path string) *Node {
+	cur := t.root
+	for path != "" {
+		child, exists := cur.children[path[0]]
+		if !exists || !strings.HasPrefix(path, child.label) {
+			return nil
+		}
+		path = path[len(child.label):]
+		cur = child
+	}
+	return cur
+}
+
+// countNodes returns the number of nodes and words in the subtree at node.
+func countNodes(node *Node) (nodes, words int) {
+	nodes = 1
+	if node.isWord {
+		words = 1
+	}
+	for _, child := range node.children {
+		n, w := countNodes(child)
+		nodes += n
+		words += w
+	}
+	return nodes, words
+}
@@ -0,0 +1,163 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSerializeDirty(t *testing.T) {
+	base := []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus", "test", "toaster"}
+	tree := NewTree(WithDirtyTracking())
+	replica := NewTree()
+	for _, word := range base {
+		tree.Insert(word)
+		replica.Insert(word)
+	}
+	tree.MarkClean()
+
+	for _, word := range [][]string{
+		{"toasting", "slow", "slowly", "rom", "rub", "rubicundusaurus", "t"},
+		{""},
+		{"te", "tea", "team"},
+	} {
+		for _, w := range word {
+			tree.Insert(w)
+		}
+		buf := &bytes.Buffer{}
+		if err := tree.SerializeDirty(buf); err != nil {
+			t.Fatal(err)
+		}
+		full := &bytes.Buffer{}
+		tree.Serialize(full)
+		if buf.Len() >= full.Len() {
+			t.Errorf("Delta of %d bytes is not smaller than the tree, %d bytes", buf.Len(), full.Len())
+		}
+
+		if err := replica.ApplyDirty(buf); err != nil {
+			t.Fatal(err)
+		}
+		tree.MarkClean()
+		if asDot(replica) != asDot(tree) {
+			t.Fatalf("Replica differs after applying %v", word)
+		}
+		if replica.NodeCount() != tree.NodeCount() || replica.WordCount() != tree.WordCount() {
+			t.Errorf("Expected %d nodes and %d words, got %d and %d", tree.NodeCount(), tree.WordCount(), replica.NodeCount(), replica.WordCount())
+		}
+	}
+
+	// A delta from a different tree doesn't apply
+	tree.Insert("zebra")
+	tree.Insert("rubicundusauruses")
+	buf := &bytes.Buffer{}
+	tree.SerializeDirty(buf)
+	other := NewTree()
+	other.Insert("apple")
+	before := asDot(other)
+	if err := other.ApplyDirty(buf); !errors.Is(err, ErrDeltaMismatch) {
+		t.Errorf("Expected ErrDeltaMismatch, got %v", err)
+	}
+	if asDot(other) != before {
+		t.Errorf("Tree changed by a delta that doesn't apply")
+	}
+
+	if err := NewTree().SerializeDirty(&bytes.Buffer{}); err == nil {
+		t.Errorf("Expected an error without dirty tracking")
+	}
+}
+
+func TestSerializeDirtyDelete(t *testing.T) {
+	words := perfWords(1000)
+	tree := NewTree(WithDirtyTracking())
+	replica := NewTree()
+	for _, word := range words {
+		tree.Insert(word)
+		replica.Insert(word)
+	}
+	tree.MarkClean()
+
+	// Mix deletes with inserts, including reinserting deleted words and
+	// deleting new ones
+	rng := rand.New(rand.NewPCG(3, 4))
+	for round := range 5 {
+		for range 50 {
+			word := words[rng.IntN(len(words))]
+			if rng.IntN(3) == 0 {
+				tree.Insert(word + "x")
+			} else if !tree.Delete(word) {
+				tree.Insert(word)
+			}
+		}
+		buf := &bytes.Buffer{}
+		if err := tree.SerializeDirty(buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := replica.ApplyDirty(buf); err != nil {
+			t.Fatalf("Round %d: %v", round, err)
+		}
+		tree.MarkClean()
+		if asDot(replica) != asDot(tree) {
+			t.Fatalf("Round %d: replica differs", round)
+		}
+		if replica.NodeCount() != tree.NodeCount() || replica.WordCount() != tree.WordCount() {
+			t.Errorf("Round %d: expected %d nodes and %d words, got %d and %d", round, tree.NodeCount(), tree.WordCount(), replica.NodeCount(), replica.WordCount())
+		}
+		checkLengths(t, replica)
+	}
+}
+
+// TestSerializeDirtyRandom checks deltas against random inserts and deletes of
+// short words, which merge and split nodes that are themselves recorded, with
+// and without commits in between.
+func TestSerializeDirtyRandom(t *testing.T) {
+	for _, history := range []bool{false, true} {
+		rng := rand.New(rand.NewPCG(5, 6))
+		for round := range 2000 {
+			opts := []Option{WithDirtyTracking()}
+			if history {
+				opts = append(opts, WithHistory(4))
+			}
+			tree := NewTree(opts...)
+			replica := NewTree()
+			word := func() string {
+				b := make([]byte, 1+rng.IntN(4))
+				for i := range b {
+					b[i] = "abc"[rng.IntN(3)]
+				}
+				return string(b)
+			}
+			for range rng.IntN(6) {
+				w := word()
+				tree.Insert(w)
+				replica.Insert(w)
+			}
+			tree.MarkClean()
+
+			for range 1 + rng.IntN(8) {
+				if w := word(); rng.IntN(2) == 0 {
+					tree.Insert(w)
+				} else {
+					tree.Delete(w)
+				}
+				if history && rng.IntN(3) == 0 {
+					tree.Commit()
+				}
+			}
+			buf := &bytes.Buffer{}
+			if err := tree.SerializeDirty(buf); err != nil {
+				t.Fatal(err)
+			}
+			if err := replica.ApplyDirty(buf); err != nil {
+				t.Fatalf("History %v, round %d: %v", history, round, err)
+			}
+			if got, expected := replica.FindWordsWithPrefix(""), tree.FindWordsWithPrefix(""); !slices.Equal(got, expected) {
+				t.Fatalf("History %v, round %d: expected %v, got %v", history, round, expected, got)
+			}
+			if asDot(replica) != asDot(tree) {
+				t.Fatalf("History %v, round %d: replica differs", history, round)
+			}
+		}
+	}
+}
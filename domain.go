@@ -0,0 +1,71 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DomainMatcher finds the public suffix of host names, the part under which
+// names can be registered, from a set of rules in the style of the Public
+// Suffix List (https://publicsuffix.org):
+//
+//	com       a plain rule, com is a public suffix
+//	*.ck      a wildcard rule, every name directly under ck is a public suffix
+//	!www.ck   an exception to a wildcard rule, www.ck is not a public suffix
+//
+// Names are matched whole label by whole label, without regard to ASCII case.
+type DomainMatcher struct {
+	plain      *SuffixTree
+	wildcards  *SuffixTree
+	exceptions *SuffixTree
+}
+
+// NewDomainMatcher returns a DomainMatcher with no rules.
+func NewDomainMatcher() *DomainMatcher {
+	return &DomainMatcher{plain: NewSuffixTree(), wildcards: NewSuffixTree(), exceptions: NewSuffixTree()}
+}
+
+// normalizeDomain returns name in lower case with a leading dot, so that
+// suffixes stored the same way only ever match whole labels.
+func normalizeDomain(name string) string {
+	return "." + strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// Add adds a rule to m, see DomainMatcher for the syntax.
+func (m *DomainMatcher) Add(rule string) error {
+	suffixes, name := m.plain, rule
+	switch {
+	case strings.HasPrefix(rule, "*."):
+		suffixes, name = m.wildcards, rule[2:]
+	case strings.HasPrefix(rule, "!"):
+		suffixes, name = m.exceptions, rule[1:]
+	}
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "*!") {
+		return fmt.Errorf("compressedtrie: invalid domain rule %q", rule)
+	}
+	return suffixes.Insert(normalizeDomain(name))
+}
+
+// Match returns the longest public suffix of host according to the rules in m,
+// and whether it comes from a wildcard rule. The suffix is in lower case, it is
+// "" if no rule matches host.
+func (m *DomainMatcher) Match(host string) (suffix string, wildcard bool) {
+	name := normalizeDomain(host)
+
+	// An exception overrides everything, the public suffix is the exception
+	// without its first label.
+	if s, ok := m.exceptions.LongestSuffix(name); ok {
+		_, parent, _ := strings.Cut(s[1:], ".")
+		return parent, false
+	}
+
+	plain, _ := m.plain.LongestSuffix(name)
+	if s, ok := m.wildcards.LongestSuffix(name); ok && len(s) < len(name) {
+		// The wildcard adds the label before the suffix
+		label := strings.LastIndexByte(name[:len(name)-len(s)], '.')
+		if s = name[label:]; len(s) > len(plain) {
+			return s[1:], true
+		}
+	}
+	return strings.TrimPrefix(plain, "."), false
+}
@@ -0,0 +1,43 @@
+package compressedtrie
+
+import "testing"
+
+func TestDomainMatcher(t *testing.T) {
+	m := NewDomainMatcher()
+	for _, rule := range []string{"com", "uk", "co.uk", "*.ck", "!www.ck", "*.kawasaki.jp", "jp"} {
+		if err := m.Add(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rule := range []string{"", "*.", "!", ".com", "a.*.com"} {
+		if err := m.Add(rule); err == nil {
+			t.Errorf("Expected an error for rule %q", rule)
+		}
+	}
+
+	cases := []struct {
+		Host     string
+		Suffix   string
+		Wildcard bool
+	}{
+		{"example.com", "com", false},
+		{"WWW.Example.COM.", "com", false},
+		{"bbc.co.uk", "co.uk", false},
+		{"parliament.uk", "uk", false},
+		{"www.example.ck", "example.ck", true},
+		{"example.ck", "example.ck", true},
+		{"ck", "", false},
+		{"www.ck", "ck", false},
+		{"a.www.ck", "ck", false},
+		{"city.kawasaki.jp", "city.kawasaki.jp", true},
+		{"kawasaki.jp", "jp", false},
+		{"examplecom", "", false},
+		{"example.org", "", false},
+	}
+	for _, tc := range cases {
+		suffix, wildcard := m.Match(tc.Host)
+		if suffix != tc.Suffix || wildcard != tc.Wildcard {
+			t.Errorf("Match(%q): expected %q, %v, got %q, %v", tc.Host, tc.Suffix, tc.Wildcard, suffix, wildcard)
+		}
+	}
+}
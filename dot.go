@@ -0,0 +1,95 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DOT returns a Graphviz DOT description of t. Nodes that end a word are drawn
+// as double circles and edges are labelled with the node labels.
+func (t *Tree) DOT() string {
+	return t.DOTWithOptions(DOTOptions{})
+}
+
+// DOTOptions configures DOTWithOptions. The zero value gives the output of DOT.
+type DOTOptions struct {
+	// HexNonASCII writes every label byte that isn't printable ASCII as \xNN,
+	// for trees of binary keys where showing bytes as UTF-8 would mislead.
+	HexNonASCII bool
+}
+
+// DOTWithOptions is DOT with control over how labels are written. Quotes and
+// backslashes in labels are always escaped, and control bytes, bytes that
+// aren't valid UTF-8 and runes that aren't printable are always written as
+// \xNN, which Graphviz shows as is, so the output parses whatever the keys.
+func (t *Tree) DOTWithOptions(opts DOTOptions) string {
+	return t.dot(nil, opts)
+}
+
+// dotLabel returns label escaped for a quoted DOT string.
+func dotLabel(label string, opts DOTOptions) string {
+	var sb strings.Builder
+	hex := func(s string) {
+		for i := range len(s) {
+			fmt.Fprintf(&sb, `\\x%02x`, s[i])
+		}
+	}
+	for len(label) > 0 {
+		r, size := utf8.DecodeRuneInString(label)
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == utf8.RuneError && size == 1, !unicode.IsPrint(r) && r != ' ', opts.HexNonASCII && r >= utf8.RuneSelf:
+			hex(label[:size])
+		default:
+			sb.WriteString(label[:size])
+		}
+		label = label[size:]
+	}
+	return sb.String()
+}
+
+// dot generates the DOT description of t. style, if set, returns the extra
+// attributes of each node and of the edge leading to it, each starting with
+// ", ".
+func (t *Tree) dot(style func(node *Node) (nodeAttrs, edgeAttrs string), opts DOTOptions) string {
+	var sb strings.Builder
+	sb.WriteString("digraph Trie {\n")
+	sb.WriteString("  node [shape=circle];\n")
+
+	nodeCounter := 0
+	var traverse func(node *Node, parentID int)
+	traverse = func(node *Node, parentID int) {
+		nodeID := nodeCounter
+		nodeCounter++
+
+		var nodeAttrs, edgeAttrs string
+		if style != nil {
+			nodeAttrs, edgeAttrs = style(node)
+		}
+
+		// Label with prefix and isWord status
+		attrs := `label=""`
+		if node.isWord {
+			attrs += ", shape=doublecircle"
+		}
+		fmt.Fprintf(&sb, "  n%d [%s%s];\n", nodeID, attrs, nodeAttrs)
+
+		if parentID >= 0 {
+			fmt.Fprintf(&sb, "  n%d -> n%d [label=\"%s\"%s];\n", parentID, nodeID, dotLabel(node.label, opts), edgeAttrs)
+		}
+
+		for _, k := range slices.Sorted(maps.Keys(node.children)) {
+			traverse(node.children[k], nodeID)
+		}
+	}
+	traverse(t.root, -1)
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,37 @@
+package compressedtrie
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDOTLabels(t *testing.T) {
+	cases := []struct {
+		Label    string
+		Opts     DOTOptions
+		Expected string
+	}{
+		{"plain", DOTOptions{}, "plain"},
+		{`say "hi"`, DOTOptions{}, `say \"hi\"`},
+		{`C:\dir`, DOTOptions{}, `C:\\dir`},
+		{"tab\there", DOTOptions{}, `tab\\x09here`},
+		{"\xff\x00", DOTOptions{}, `\\xff\\x00`},
+		{"café", DOTOptions{}, "café"},
+		{"zero\u200bwidth", DOTOptions{}, `zero\\xe2\\x80\\x8bwidth`},
+		{"café", DOTOptions{HexNonASCII: true}, `caf\\xc3\\xa9`},
+		{`"\`, DOTOptions{HexNonASCII: true}, `\"\\`},
+	}
+	for _, tc := range cases {
+		if actual := dotLabel(tc.Label, tc.Opts); actual != tc.Expected {
+			t.Errorf("%q %+v: expected %s, got %s", tc.Label, tc.Opts, tc.Expected, actual)
+		}
+	}
+
+	// Binary keys no longer break out of the quoted label
+	tree := NewTree()
+	tree.Insert("a\"b\n\\")
+	dot := tree.DOTWithOptions(DOTOptions{HexNonASCII: true})
+	if !strings.Contains(dot, `label="a\"b\\x0a\\"`) {
+		t.Errorf("Unexpected DOT %s", dot)
+	}
+}
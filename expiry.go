@@ -0,0 +1,151 @@
+package compressedtrie
+
+import (
+	"container/heap"
+	"time"
+)
+
+// ExpiringTree is a tree whose words each have an expiry time, such as recent
+// searches offered as suggestions that should age out. Queries skip expired
+// words and remove those they come across, and Sweep removes all of them.
+// Removing a word deletes it from the tree, so nodes that only led to it are
+// removed and their neighbours merged again.
+type ExpiringTree struct {
+	tree  *Tree // the value of each word's node is its expiry time
+	queue expiryQueue
+	now   func() time.Time
+}
+
+// NewExpiringTree returns an empty ExpiringTree, the options apply to the
+// Tree that holds the words.
+func NewExpiringTree(opts ...Option) *ExpiringTree {
+	return &ExpiringTree{tree: NewTree(opts...), now: time.Now}
+}
+
+// Insert adds word to e until expires, or sets the expiry of word if it is
+// already there, later or earlier. It returns the same errors as
+// Tree.Insert.
+func (e *ExpiringTree) Insert(word string, expires time.Time) error {
+	if err := e.tree.Insert(word); err != nil {
+		return err
+	}
+	e.tree.nodeAt(word).value = expires
+	heap.Push(&e.queue, expiryEntry{expires, e.tree.copied(word)})
+	return nil
+}
+
+// InsertFor is Insert with an expiry of ttl from now.
+func (e *ExpiringTree) InsertFor(word string, ttl time.Duration) error {
+	return e.Insert(word, e.now().Add(ttl))
+}
+
+// expired reports whether node, a word node, has expired by now.
+func expired(node *Node, now time.Time) bool {
+	expires, ok := node.value.(time.Time)
+	return ok && !now.Before(expires)
+}
+
+// Contains reports whether word is in e and hasn't expired. An expired word is
+// removed.
+func (e *ExpiringTree) Contains(word string) bool {
+	node := e.tree.nodeAt(word)
+	if node == nil || !node.isWord {
+		return false
+	}
+	if expired(node, e.now()) {
+		e.tree.Delete(word)
+		return false
+	}
+	return true
+}
+
+// Expires returns the expiry time of word, and whether it is in e and hasn't
+// expired.
+func (e *ExpiringTree) Expires(word string) (time.Time, bool) {
+	if !e.Contains(word) {
+		return time.Time{}, false
+	}
+	expires, _ := e.tree.nodeAt(word).value.(time.Time)
+	return expires, true
+}
+
+// Delete removes word from e and reports whether it was there and hadn't
+// expired.
+func (e *ExpiringTree) Delete(word string) bool {
+	return e.Contains(word) && e.tree.Delete(word)
+}
+
+// FindWordsWithPrefix returns the words in e that start with prefix and haven't
+// expired, see Tree.FindWordsWithPrefix. The expired words found are removed.
+func (e *ExpiringTree) FindWordsWithPrefix(prefix string) []string {
+	var words, dead []string
+	now := e.now()
+	if node, path := e.tree.descend(prefix); node != nil {
+		e.tree.yieldWords(node, path, func(word string, node *Node) bool {
+			if expired(node, now) {
+				dead = append(dead, word)
+			} else {
+				words = append(words, word)
+			}
+			return true
+		})
+	}
+	for _, word := range dead {
+		e.tree.Delete(word)
+	}
+	return words
+}
+
+// Sweep removes every word that has expired and returns how many there were.
+// Its cost is proportional to the number removed and the number of times
+// their expiry was set, so it can be called often.
+func (e *ExpiringTree) Sweep() int {
+	now := e.now()
+	n := 0
+	for len(e.queue) > 0 && !now.Before(e.queue[0].expires) {
+		x := heap.Pop(&e.queue).(expiryEntry)
+		// The word may have been deleted, or its expiry set again
+		node := e.tree.nodeAt(x.word)
+		if node == nil || !node.isWord || node.value != any(x.expires) {
+			continue
+		}
+		e.tree.Delete(x.word)
+		n++
+	}
+	return n
+}
+
+// Len returns the number of words in e, including those that have expired but
+// haven't been removed yet.
+func (e *ExpiringTree) Len() int {
+	return e.tree.WordCount()
+}
+
+// Tree returns the tree that holds the words of e, expired ones included, for
+// queries that ExpiringTree doesn't provide. The value of the node of each
+// word is its expiry time. It must not be changed directly.
+func (e *ExpiringTree) Tree() *Tree {
+	return e.tree
+}
+
+// expiryEntry is a time a word expires, unless its expiry has been set
+// again since.
+type expiryEntry struct {
+	expires time.Time
+	word    string
+}
+
+// expiryQueue is a heap of expiry entries, soonest first.
+type expiryQueue []expiryEntry
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].expires.Before(q[j].expires) }
+func (q expiryQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *expiryQueue) Push(x any)        { *q = append(*q, x.(expiryEntry)) }
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
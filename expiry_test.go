@@ -0,0 +1,59 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExpiringTree(t *testing.T) {
+	e := NewExpiringTree()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+
+	e.InsertFor("toast", time.Minute)
+	e.InsertFor("toaster", 2*time.Minute)
+	e.InsertFor("team", 3*time.Minute)
+	e.InsertFor("tea", time.Minute)
+	e.InsertFor("tea", 4*time.Minute) // extended
+	if words := e.FindWordsWithPrefix("t"); !slices.Equal(words, []string{"tea", "team", "toast", "toaster"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+
+	// Queries skip expired words and remove them
+	now = now.Add(time.Minute)
+	if e.Contains("toast") || e.Len() != 3 {
+		t.Errorf("Expected toast to have expired and gone, %d words", e.Len())
+	}
+	if expires, ok := e.Expires("tea"); !ok || !expires.Equal(now.Add(3*time.Minute)) {
+		t.Errorf("Unexpected expiry %v %v", expires, ok)
+	}
+	now = now.Add(time.Minute)
+	if words := e.FindWordsWithPrefix("to"); words != nil || e.Len() != 2 {
+		t.Errorf("Expected toaster to have expired and gone, got %q", words)
+	}
+
+	// Sweep removes the rest, skipping the entries of words deleted or
+	// extended since
+	e.InsertFor("x", time.Minute)
+	e.InsertFor("y", time.Minute)
+	if !e.Delete("y") || e.Delete("y") {
+		t.Errorf("Unexpected results deleting y")
+	}
+	now = now.Add(time.Minute)
+	if n := e.Sweep(); n != 2 {
+		t.Errorf("Expected team and x, swept %d", n)
+	}
+	if words := e.Tree().FindWordsWithPrefix(""); !slices.Equal(words, []string{"tea"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+	expected := NewTree()
+	expected.Insert("tea")
+	if asDot(e.Tree()) != asDot(expected) {
+		t.Errorf("Removal left the tree in a different shape")
+	}
+	now = now.Add(time.Hour)
+	if n := e.Sweep(); n != 1 || e.Len() != 0 || len(e.queue) != 0 {
+		t.Errorf("Expected tea swept and nothing left, swept %d", n)
+	}
+}
@@ -0,0 +1,118 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExplainOutcome describes where a prefix search ended.
+type ExplainOutcome int
+
+const (
+	// PrefixFound means the whole prefix was matched, there may be words
+	// below it.
+	PrefixFound ExplainOutcome = iota
+	// NoChild means no child of the last node visited continues the prefix.
+	NoChild
+	// LabelMismatch means the prefix and the label of the last node visited
+	// differ part way through the label.
+	LabelMismatch
+)
+
+func (o ExplainOutcome) String() string {
+	switch o {
+	case PrefixFound:
+		return "prefix found"
+	case NoChild:
+		return "no child"
+	case LabelMismatch:
+		return "label mismatch"
+	}
+	return fmt.Sprintf("ExplainOutcome(%d)", int(o))
+}
+
+// An ExplainStep is one node visited while searching for a prefix.
+type ExplainStep struct {
+	Path    string // the path from the root to the node, including its label
+	Label   string // the node's label
+	Matched int    // number of bytes of Label that matched the prefix
+	IsWord  bool   // whether the node marks the end of a word
+}
+
+// An Explanation is the trace of a prefix search produced by ExplainPrefix.
+type Explanation struct {
+	Prefix  string
+	Steps   []ExplainStep // the nodes visited below the root, in order
+	Outcome ExplainOutcome
+	Matched int // number of bytes of Prefix that were matched
+	Words   int // number of words that start with Prefix
+
+	tree  *Tree
+	nodes []*Node // the nodes of Steps, for DOT
+}
+
+// ExplainPrefix searches t for prefix the same way as FindWordsWithPrefix, but
+// returns a trace of the search instead of the words. It is intended for
+// debugging why a word isn't being found.
+func (t *Tree) ExplainPrefix(prefix string) Explanation {
+	e := Explanation{Prefix: prefix, tree: t}
+
+	cur := t.root
+	path := ""
+	rest := prefix
+	for rest != "" {
+		child, exists := cur.children[rest[0]]
+		if !exists {
+			e.Outcome = NoChild
+			return e
+		}
+
+		matched := commonPrefixLen(rest, child.label)
+		path += child.label
+		e.Steps = append(e.Steps, ExplainStep{Path: path, Label: child.label, Matched: matched, IsWord: child.isWord})
+		e.nodes = append(e.nodes, child)
+		e.Matched += matched
+		if matched < len(child.label) && matched < len(rest) {
+			e.Outcome = LabelMismatch
+			return e
+		}
+
+		rest = rest[matched:]
+		cur = child
+	}
+
+	e.Outcome = PrefixFound
+	var words []string
+	t.gatherWords(cur, path, &words)
+	e.Words = len(words)
+	return e
+}
+
+// String returns a human readable, multi-line version of e.
+func (e Explanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "prefix %q: %s after matching %d of %d bytes, %d words\n", e.Prefix, e.Outcome, e.Matched, len(e.Prefix), e.Words)
+	for _, step := range e.Steps {
+		word := ""
+		if step.IsWord {
+			word = " (word)"
+		}
+		fmt.Fprintf(&sb, "  %q: matched %d of label %q%s\n", step.Path, step.Matched, step.Label, word)
+	}
+	return sb.String()
+}
+
+// DOT returns the Graphviz DOT description of the tree that e was produced
+// from, see Tree.DOT, with the nodes visited by the search drawn in red.
+func (e Explanation) DOT() string {
+	highlight := map[*Node]bool{e.tree.root: true}
+	for _, node := range e.nodes {
+		highlight[node] = true
+	}
+	return e.tree.dot(func(node *Node) (string, string) {
+		if highlight[node] {
+			return ", color=red", ", color=red"
+		}
+		return "", ""
+	}, DOTOptions{})
+}
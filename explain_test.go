@@ -0,0 +1,64 @@
+package compressedtrie
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExplainPrefix(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"test", "toaster", "toasting", "slow", "slowly"} {
+		tree.Insert(word)
+	}
+
+	cases := []struct {
+		Prefix  string
+		Outcome ExplainOutcome
+		Matched int
+		Words   int
+		Paths   []string
+	}{
+		{"", PrefixFound, 0, 5, nil},
+		{"toas", PrefixFound, 4, 2, []string{"t", "toast"}},
+		{"toasting", PrefixFound, 8, 1, []string{"t", "toast", "toasting"}},
+		{"toad", LabelMismatch, 3, 0, []string{"t", "toast"}},
+		{"tx", NoChild, 1, 0, []string{"t"}},
+		{"slowest", NoChild, 4, 0, []string{"slow"}},
+		{"slowlx", LabelMismatch, 5, 0, []string{"slow", "slowly"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Prefix, func(t *testing.T) {
+			e := tree.ExplainPrefix(tc.Prefix)
+			if e.Outcome != tc.Outcome || e.Matched != tc.Matched || e.Words != tc.Words {
+				t.Errorf("Expected %v after %d bytes with %d words, got %v after %d with %d\n%s", tc.Outcome, tc.Matched, tc.Words, e.Outcome, e.Matched, e.Words, e)
+			}
+			var paths []string
+			for _, step := range e.Steps {
+				paths = append(paths, step.Path)
+			}
+			if !slices.Equal(paths, tc.Paths) {
+				t.Errorf("Expected to visit %v, visited %v", tc.Paths, paths)
+			}
+			if words := tree.FindWordsWithPrefix(tc.Prefix); len(words) != e.Words {
+				t.Errorf("Explanation disagrees with FindWordsWithPrefix %v", words)
+			}
+		})
+	}
+}
+
+func TestExplanationDOT(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"test", "toaster", "toasting"} {
+		tree.Insert(word)
+	}
+
+	dot := tree.ExplainPrefix("toad").DOT()
+	// The root, t and oast nodes and the edges into t and oast are highlighted
+	if n := strings.Count(dot, "color=red"); n != 5 {
+		t.Errorf("Expected 5 highlighted elements, got %d\n%s", n, dot)
+	}
+	if !strings.Contains(dot, `[label="oast", color=red]`) {
+		t.Errorf("Expected the oast edge to be highlighted\n%s", dot)
+	}
+}
@@ -0,0 +1,166 @@
+package compressedtrie
+
+import (
+	"cmp"
+	"container/heap"
+	"iter"
+	"os"
+)
+
+// defaultSortWords is the default for SortOptions.MaxWords.
+const defaultSortWords = 1 << 20
+
+// SortOptions configures SortUnique.
+type SortOptions struct {
+	// MaxWords is the number of distinct words held in a tree before they
+	// are spilled to a file, 0 for 1<<20.
+	MaxWords int
+
+	// Dir is the directory for the spill files, "" for os.TempDir.
+	Dir string
+}
+
+// SortUnique returns an iterator over the distinct words of words in byte
+// order, for streams too large to sort in memory. The words go into a tree,
+// which shares their common prefixes, and each time it holds opts.MaxWords
+// words it is written to a file and a new tree begun. The files are opened
+// with OpenFrozen and removed straight away, so they don't outlive the
+// iteration, and the words are then read from them and the last tree
+// and merged. Only the files' pages in use need be in memory on platforms
+// where OpenFrozen maps them.
+//
+// An error from words, or from writing or opening a file, is yielded once,
+// after which the iteration stops. Nothing is spilled before the iteration
+// starts, and stopping it early releases the files.
+func SortUnique(words iter.Seq2[string, error], opts SortOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		maxWords := cmp.Or(opts.MaxWords, defaultSortWords)
+		var spills []*FrozenTree
+		defer func() {
+			for _, f := range spills {
+				f.Close()
+			}
+		}()
+
+		tree := NewTree()
+		for word, err := range words {
+			if err != nil {
+				yield("", err)
+				return
+			}
+			tree.Insert(word)
+			if tree.WordCount() >= maxWords {
+				f, err := spill(tree, opts.Dir)
+				if err != nil {
+					yield("", err)
+					return
+				}
+				spills = append(spills, f)
+				tree = NewTree()
+			}
+		}
+
+		runs := []iter.Seq[string]{tree.WordsWithPrefix("")}
+		for _, f := range spills {
+			runs = append(runs, f.allWords)
+		}
+		for word := range mergeRuns(runs) {
+			if !yield(word, nil) {
+				return
+			}
+		}
+	}
+}
+
+// spill writes tree to a new file in dir and returns it opened as a
+// FrozenTree, having removed the file.
+func spill(tree *Tree, dir string) (*FrozenTree, error) {
+	file, err := os.CreateTemp(dir, "compressedtrie-sort-*.ctree")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	err = tree.Serialize(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return OpenFrozen(file.Name())
+}
+
+// allWords yields the words of f in byte order.
+func (f *FrozenTree) allWords(yield func(string) bool) {
+	n, err := f.node(f.root)
+	if err != nil {
+		return
+	}
+	var walk func(n record, path []byte) bool
+	walk = func(n record, path []byte) bool {
+		if n.isWord && !yield(string(path)) {
+			return false
+		}
+		more := true
+		f.eachChild(n, func(k byte, c record) error {
+			more = more && walk(c, append(append(path, k), c.tail...))
+			return nil
+		})
+		return more
+	}
+	walk(n, nil)
+}
+
+// mergeRuns returns an iterator over the distinct words of runs, each of which
+// is in byte order, in byte order.
+func mergeRuns(runs []iter.Seq[string]) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		var q runQueue
+		for _, run := range runs {
+			next, stop := iter.Pull(run)
+			defer stop()
+			if word, ok := next(); ok {
+				q = append(q, runHead{word, next})
+			}
+		}
+		heap.Init(&q)
+
+		last, any := "", false
+		for len(q) > 0 {
+			head := &q[0]
+			if word := head.word; !any || word != last {
+				if !yield(word) {
+					return
+				}
+				last, any = word, true
+			}
+			if word, ok := head.next(); ok {
+				head.word = word
+				heap.Fix(&q, 0)
+			} else {
+				heap.Pop(&q)
+			}
+		}
+	}
+}
+
+// runHead is the next word of a run being merged by mergeRuns.
+type runHead struct {
+	word string
+	next func() (string, bool)
+}
+
+// runQueue is a heap of runs by their next word.
+type runQueue []runHead
+
+func (q runQueue) Len() int           { return len(q) }
+func (q runQueue) Less(i, j int) bool { return q[i].word < q[j].word }
+func (q runQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *runQueue) Push(x any)        { *q = append(*q, x.(runHead)) }
+
+func (q *runQueue) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,81 @@
+package compressedtrie
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestSortUnique(t *testing.T) {
+	rng := rand.New(rand.NewSource(1209))
+	var words []string
+	for range 5000 {
+		words = append(words, fmt.Sprintf("w%x", rng.Intn(3000)))
+	}
+	words = append(words, "", "w", "")
+	expected := slices.Compact(slices.Sorted(slices.Values(words)))
+
+	for _, maxWords := range []int{0, 7, 100, 999} {
+		dir := t.TempDir()
+		var got []string
+		for word, err := range SortUnique(wordSource(words), SortOptions{MaxWords: maxWords, Dir: dir}) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, word)
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("MaxWords %d: got %d words, expected %d", maxWords, len(got), len(expected))
+		}
+
+		// Stopping early leaves nothing behind either
+		n := 0
+		for range SortUnique(wordSource(words), SortOptions{MaxWords: maxWords, Dir: dir}) {
+			if n++; n == 10 {
+				break
+			}
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("MaxWords %d: %d files left in %s", maxWords, len(entries), dir)
+		}
+	}
+
+	readErr := errors.New("read failed")
+	failing := func(yield func(string, error) bool) {
+		_ = yield("zeta", nil) && yield("alpha", nil) && yield("", readErr) && yield("beta", nil)
+	}
+	var got []string
+	var errs []error
+	for word, err := range SortUnique(failing, SortOptions{MaxWords: 1, Dir: t.TempDir()}) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		got = append(got, word)
+	}
+	if len(got) != 0 || len(errs) != 1 || errs[0] != readErr {
+		t.Errorf("Expected just the source error, got %q and %v", got, errs)
+	}
+
+	// The spill directory doesn't exist
+	missing := t.TempDir() + "/missing"
+	for _, err := range SortUnique(wordSource(words), SortOptions{MaxWords: 10, Dir: missing}) {
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected ErrNotExist, got %v", err)
+		}
+	}
+}
+
+// wordSource returns a source of words for SortUnique.
+func wordSource(words []string) func(yield func(string, error) bool) {
+	return func(yield func(string, error) bool) {
+		for _, word := range words {
+			if !yield(word, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package compressedtrie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// FilterKind selects the kind of probabilistic filter built by BuildFilter.
+type FilterKind uint8
+
+const (
+	// BloomFilter is a Bloom filter, its false positive rate is about
+	// 0.6185^bitsPerKey, e.g. 1% for 10 bits per key.
+	BloomFilter FilterKind = iota + 1
+	// XorFilter is an xor filter with 8 bit fingerprints. It always uses about
+	// 9.84 bits per key, for a false positive rate of 0.4%, and is slower to
+	// build than a Bloom filter of the same size but more accurate.
+	XorFilter
+)
+
+// 32-bit magic number for filters written by Filter.MarshalBinary
+const CtreeFilterMagic uint32 = 'C'<<24 | 'T'<<16 | 'R'<<8 | 'F'
+
+// A Filter answers whether a word might be in the tree it was built from, with
+// no false negatives and a small rate of false positives. It is far smaller
+// than the tree, and its hashes don't depend on the platform, so it can be
+// shipped to clients to rule out obvious misses locally. Build one with
+// Tree.BuildFilter.
+type Filter struct {
+	kind FilterKind
+	seed uint64
+
+	// Bloom filter
+	hashes uint32   // number of bits set per word
+	nbits  uint64   // number of bits in the filter
+	words  []uint64 // the bits
+
+	// Xor filter
+	blockLength  uint32
+	fingerprints []uint8
+}
+
+// BuildFilter returns a filter of the given kind holding the words in t. For
+// BloomFilter bitsPerKey sets the size of the filter, it must be between 1
+// and 64. XorFilter ignores bitsPerKey.
+func (t *Tree) BuildFilter(kind FilterKind, bitsPerKey int) (*Filter, error) {
+	var hashes []uint64
+	for word := range t.WordsWithPrefix("") {
+		hashes = append(hashes, filterHash(word))
+	}
+
+	switch kind {
+	case BloomFilter:
+		if bitsPerKey < 1 || bitsPerKey > 64 {
+			return nil, fmt.Errorf("compressedtrie: invalid bits per key %d", bitsPerKey)
+		}
+		return buildBloom(hashes, bitsPerKey), nil
+	case XorFilter:
+		return buildXor(hashes)
+	}
+	return nil, fmt.Errorf("compressedtrie: unknown filter kind %d", kind)
+}
+
+// filterHash returns the hash of word that filters are built from.
+func filterHash(word string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(word))
+	return h.Sum64()
+}
+
+// mix64 is the MurmurHash3 finalizer, it spreads the bits of h.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func buildBloom(hashes []uint64, bitsPerKey int) *Filter {
+	nbits := max(uint64(len(hashes))*uint64(bitsPerKey), 64)
+	f := &Filter{
+		kind:   BloomFilter,
+		hashes: uint32(min(max(math.Round(float64(bitsPerKey)*math.Ln2), 1), 30)),
+		nbits:  nbits,
+		words:  make([]uint64, (nbits+63)/64),
+	}
+	for _, h := range hashes {
+		f.bloomBits(h, func(bit uint64) bool {
+			f.words[bit/64] |= 1 << (bit % 64)
+			return true
+		})
+	}
+	return f
+}
+
+// bloomBits calls fn with the index of each bit for hash h, stopping if fn
+// returns false.
+func (f *Filter) bloomBits(h uint64, fn func(bit uint64) bool) {
+	h1, h2 := h, mix64(h)|1
+	for i := range uint64(f.hashes) {
+		if !fn((h1 + i*h2) % f.nbits) {
+			return
+		}
+	}
+}
+
+// xorSlots returns the three fingerprint slots for hash h.
+func (f *Filter) xorSlots(h uint64) [3]uint32 {
+	h = mix64(h + f.seed)
+	reduce := func(v uint64) uint32 {
+		return uint32((uint64(uint32(v)) * uint64(f.blockLength)) >> 32)
+	}
+	return [3]uint32{
+		reduce(h),
+		reduce(bits.RotateLeft64(h, 21)) + f.blockLength,
+		reduce(bits.RotateLeft64(h, 42)) + 2*f.blockLength,
+	}
+}
+
+func (f *Filter) xorFingerprint(h uint64) uint8 {
+	h = mix64(h + f.seed)
+	return uint8(h ^ h>>32)
+}
+
+// buildXor builds an xor filter by the peeling algorithm of Graf and Lemire,
+// "Xor Filters: Faster and Smaller Than Bloom and Cuckoo Filters", 2020.
+func buildXor(hashes []uint64) (*Filter, error) {
+	capacity := 32 + uint32(math.Ceil(1.23*float64(len(hashes))))
+	capacity = capacity / 3 * 3
+	f := &Filter{kind: XorFilter, blockLength: capacity / 3, fingerprints: make([]uint8, capacity)}
+
+	counts := make([]uint32, capacity)
+	masks := make([]uint64, capacity)
+	type peeled struct {
+		hash uint64
+		slot uint32
+	}
+	stack := make([]peeled, 0, len(hashes))
+	var queue []uint32
+
+	for attempt := range 100 {
+		f.seed = mix64(uint64(attempt) + 1)
+		clear(counts)
+		clear(masks)
+		for _, h := range hashes {
+			for _, s := range f.xorSlots(h) {
+				counts[s]++
+				masks[s] ^= h
+			}
+		}
+
+		// Repeatedly remove a slot that only one hash maps to
+		queue = queue[:0]
+		for s, c := range counts {
+			if c == 1 {
+				queue = append(queue, uint32(s))
+			}
+		}
+		stack = stack[:0]
+		for len(queue) > 0 {
+			s := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if counts[s] != 1 {
+				continue
+			}
+			h := masks[s]
+			stack = append(stack, peeled{h, s})
+			for _, o := range f.xorSlots(h) {
+				counts[o]--
+				masks[o] ^= h
+				if counts[o] == 1 {
+					queue = append(queue, o)
+				}
+			}
+		}
+		if len(stack) < len(hashes) {
+			continue
+		}
+
+		clear(f.fingerprints)
+		for i := len(stack) - 1; i >= 0; i-- {
+			p := stack[i]
+			fp := f.xorFingerprint(p.hash)
+			for _, o := range f.xorSlots(p.hash) {
+				if o != p.slot {
+					fp ^= f.fingerprints[o]
+				}
+			}
+			f.fingerprints[p.slot] = fp
+		}
+		return f, nil
+	}
+	return nil, errors.New("compressedtrie: failed to build xor filter, are there duplicate hashes?")
+}
+
+// MayContain reports whether word might be in the tree f was built from. A
+// false result means it definitely isn't.
+func (f *Filter) MayContain(word string) bool {
+	h := filterHash(word)
+	switch f.kind {
+	case BloomFilter:
+		found := true
+		f.bloomBits(h, func(bit uint64) bool {
+			found = f.words[bit/64]&(1<<(bit%64)) != 0
+			return found
+		})
+		return found
+	case XorFilter:
+		s := f.xorSlots(h)
+		return f.xorFingerprint(h) == f.fingerprints[s[0]]^f.fingerprints[s[1]]^f.fingerprints[s[2]]
+	}
+	return false
+}
+
+// MarshalBinary encodes f as the u32 CtreeFilterMagic, the u8 kind and then,
+// for a Bloom filter, the u8 number of hashes, the u64 number of bits and the
+// bits as u64 words. An xor filter has the u64 seed, the u32 block length and
+// the fingerprints. All integers are big endian.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	b := binary.BigEndian.AppendUint32(nil, CtreeFilterMagic)
+	b = append(b, byte(f.kind))
+	switch f.kind {
+	case BloomFilter:
+		b = append(b, byte(f.hashes))
+		b = binary.BigEndian.AppendUint64(b, f.nbits)
+		for _, w := range f.words {
+			b = binary.BigEndian.AppendUint64(b, w)
+		}
+	case XorFilter:
+		b = binary.BigEndian.AppendUint64(b, f.seed)
+		b = binary.BigEndian.AppendUint32(b, f.blockLength)
+		b = append(b, f.fingerprints...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a filter written by MarshalBinary into f. Returns a
+// *FormatError if data is not a valid filter.
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 || binary.BigEndian.Uint32(data) != CtreeFilterMagic {
+		return formatError(0, -1, "not a filter")
+	}
+	g := Filter{kind: FilterKind(data[4])}
+	data = data[5:]
+	switch g.kind {
+	case BloomFilter:
+		if len(data) < 9 {
+			return formatError(5, -1, "truncated Bloom filter")
+		}
+		g.hashes = uint32(data[0])
+		g.nbits = binary.BigEndian.Uint64(data[1:])
+		data = data[9:]
+		if g.hashes == 0 || g.nbits == 0 || uint64(len(data)) != (g.nbits+63)/64*8 {
+			return formatError(14, -1, "Bloom filter of %d bits with %d bytes", g.nbits, len(data))
+		}
+		g.words = make([]uint64, len(data)/8)
+		for i := range g.words {
+			g.words[i] = binary.BigEndian.Uint64(data[8*i:])
+		}
+	case XorFilter:
+		if len(data) < 12 {
+			return formatError(5, -1, "truncated xor filter")
+		}
+		g.seed = binary.BigEndian.Uint64(data)
+		g.blockLength = binary.BigEndian.Uint32(data[8:])
+		data = data[12:]
+		if uint64(len(data)) != 3*uint64(g.blockLength) {
+			return formatError(17, -1, "xor filter with block length %d and %d fingerprints", g.blockLength, len(data))
+		}
+		g.fingerprints = append([]uint8(nil), data...)
+	default:
+		return formatError(4, -1, "filter kind %d", g.kind)
+	}
+	*f = g
+	return nil
+}
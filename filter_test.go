@@ -0,0 +1,68 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	tree := NewTree()
+	for i := range 10000 {
+		tree.Insert(fmt.Sprintf("word%d", i))
+	}
+
+	cases := []struct {
+		Kind       FilterKind
+		BitsPerKey int
+		MaxRate    float64
+	}{
+		{BloomFilter, 10, 0.02},
+		{BloomFilter, 4, 0.2},
+		{XorFilter, 0, 0.01},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%d-%d", tc.Kind, tc.BitsPerKey), func(t *testing.T) {
+			built, err := tree.BuildFilter(tc.Kind, tc.BitsPerKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := built.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := &Filter{}
+			if err := f.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+
+			for word := range tree.WordsWithPrefix("") {
+				if !f.MayContain(word) {
+					t.Fatalf("False negative for %q", word)
+				}
+			}
+			positives := 0
+			for i := range 10000 {
+				if f.MayContain(fmt.Sprintf("other%d", i)) {
+					positives++
+				}
+			}
+			if rate := float64(positives) / 10000; rate > tc.MaxRate {
+				t.Errorf("False positive rate %v exceeds %v", rate, tc.MaxRate)
+			}
+
+			if err := f.UnmarshalBinary(data[:len(data)-1]); err == nil {
+				t.Errorf("Expected an error for a truncated filter")
+			}
+		})
+	}
+
+	if _, err := tree.BuildFilter(BloomFilter, 0); err == nil {
+		t.Errorf("Expected an error for 0 bits per key")
+	}
+	if _, err := tree.BuildFilter(0, 10); err == nil {
+		t.Errorf("Expected an error for an unknown kind")
+	}
+	if f, err := NewTree().BuildFilter(XorFilter, 0); err != nil || f.MayContain("") {
+		t.Errorf("Expected an empty filter, got %v", err)
+	}
+}
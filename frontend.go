@@ -0,0 +1,121 @@
+package compressedtrie
+
+import (
+	"context"
+	"sync"
+)
+
+// FrontendOptions configures a QueryFrontend.
+type FrontendOptions struct {
+	// MaxConcurrent is the largest number of queries walking the tree at
+	// once, further queries wait their turn. 0 means no limit.
+	MaxConcurrent int
+}
+
+// QueryFrontend runs queries against a tree shared by a service, coalescing
+// identical queries made while one is already running, so that when a prefix
+// suddenly becomes popular the tree is walked once for all the requests for
+// it rather than once each, and capping the number of walks at once so that a
+// flood of different prefixes queues rather than starving the rest of the
+// service. It is safe for concurrent use.
+//
+// Queries are identical if they are for the same prefix with the same
+// QueryOptions, and of the same tree. A Budget with a Deadline makes each
+// query distinct unless the callers share the deadline.
+type QueryFrontend struct {
+	load  func() *Tree
+	slots chan struct{} // a token is held by each running walk, nil for no limit
+
+	mu    sync.Mutex
+	calls map[frontendQuery]*frontendCall
+	stats FrontendStats
+}
+
+// FrontendStats counts the queries made through a QueryFrontend.
+type FrontendStats struct {
+	Queries   uint64 // calls of Query
+	Walks     uint64 // walks of the tree, each for one or more queries
+	Coalesced uint64 // queries that joined a walk already under way
+}
+
+// frontendQuery identifies identical queries.
+type frontendQuery struct {
+	tree   *Tree
+	prefix string
+	opts   QueryOptions
+}
+
+// frontendCall is a walk being made for one or more queries.
+type frontendCall struct {
+	done      chan struct{} // closed once words and truncated are set
+	words     []string
+	truncated bool
+}
+
+// NewQueryFrontend returns a QueryFrontend for the tree load returns, which is
+// called once per query so that the tree can be replaced, for example by
+// AtomicTree.Load or SnapshotTree.Load. The tree must not be changed while
+// queries run.
+func NewQueryFrontend(load func() *Tree, opts FrontendOptions) *QueryFrontend {
+	f := &QueryFrontend{load: load, calls: make(map[frontendQuery]*frontendCall)}
+	if opts.MaxConcurrent > 0 {
+		f.slots = make(chan struct{}, opts.MaxConcurrent)
+	}
+	return f
+}
+
+// Query returns the result of t.Query(prefix, opts) for the current tree t,
+// joining an identical query if one is running. The words are shared by the
+// callers that joined and must not be changed. If ctx is done before the
+// result is ready Query returns ctx.Err(), the walk carries on for the others
+// waiting for it.
+func (f *QueryFrontend) Query(ctx context.Context, prefix string, opts QueryOptions) ([]string, bool, error) {
+	key := frontendQuery{tree: f.load(), prefix: prefix, opts: opts}
+	f.mu.Lock()
+	f.stats.Queries++
+	c, ok := f.calls[key]
+	if ok {
+		f.stats.Coalesced++
+	} else {
+		f.stats.Walks++
+		c = &frontendCall{done: make(chan struct{})}
+		f.calls[key] = c
+		go f.run(key, c)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.words, c.truncated, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// Stats returns the counts of queries made through f so far.
+func (f *QueryFrontend) Stats() FrontendStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// FindWordsWithPrefix is Query with no options.
+func (f *QueryFrontend) FindWordsWithPrefix(ctx context.Context, prefix string) ([]string, error) {
+	words, _, err := f.Query(ctx, prefix, QueryOptions{})
+	return words, err
+}
+
+// run makes the walk for c once there is a slot for it.
+func (f *QueryFrontend) run(key frontendQuery, c *frontendCall) {
+	if f.slots != nil {
+		f.slots <- struct{}{}
+		defer func() { <-f.slots }()
+	}
+	c.words, c.truncated = key.tree.Query(key.prefix, key.opts)
+
+	// Queries from now on make a walk of their own
+	f.mu.Lock()
+	delete(f.calls, key)
+	f.mu.Unlock()
+	close(c.done)
+}
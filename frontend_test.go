@@ -0,0 +1,67 @@
+package compressedtrie
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueryFrontend(t *testing.T) {
+	current := NewAtomicTree(perfTree(3000))
+	f := NewQueryFrontend(current.Load, FrontendOptions{MaxConcurrent: 1})
+	expected := current.Load().FindWordsWithPrefix("b")
+
+	// With the only slot taken, identical queries pile up on one walk
+	f.slots <- struct{}{}
+	const callers = 50
+	results := make([][]string, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			words, err := f.FindWordsWithPrefix(context.Background(), "b")
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = words
+		}()
+	}
+	for f.Stats().Queries < callers {
+		time.Sleep(time.Millisecond)
+	}
+
+	// A different query waits for a slot too
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := f.Query(ctx, "c", QueryOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	<-f.slots
+	wg.Wait()
+	for i, words := range results {
+		if !slices.Equal(words, expected) || &words[0] != &results[0][0] {
+			t.Fatalf("Caller %d: expected the shared result", i)
+		}
+	}
+	if stats := f.Stats(); stats != (FrontendStats{Queries: callers + 1, Walks: 2, Coalesced: callers - 1}) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Once done a query walks again, and so does one of a new tree
+	words, _, err := f.Query(context.Background(), "b", QueryOptions{Limit: 3})
+	if err != nil || !slices.Equal(words, expected[:3]) {
+		t.Errorf("Unexpected words %v, %v", words, err)
+	}
+	current.Store(NewTree())
+	if words, err := f.FindWordsWithPrefix(context.Background(), "b"); err != nil || words != nil {
+		t.Errorf("Expected nothing from the new tree, got %v, %v", words, err)
+	}
+	if stats := f.Stats(); stats.Walks != 4 {
+		t.Errorf("Expected 4 walks, got %+v", stats)
+	}
+}
@@ -0,0 +1,352 @@
+package compressedtrie
+
+import "bytes"
+
+// A FrozenTree is a read-only tree that answers queries directly from the
+// version 2 serialized form, without decoding it into nodes. Because it holds
+// nothing but a []byte the representation can be placed anywhere, for example
+// in a file or shared memory segment mapped by several processes which then
+// share a single copy of the dictionary (see OpenFrozen).
+//
+// The bytes must not be modified while the FrozenTree is in use. Queries do
+// not trust the data, a corrupt tree gives wrong answers but never panics. Use
+// Verify to check the whole structure up front.
+type FrozenTree struct {
+	records
+	data []byte
+
+	unmap func() error // releases data, set by OpenFrozen
+}
+
+// Freeze returns t in the representation used by FrozenTree, which is the same
+// as written by Serialize. It panics with ErrTooLarge if t can't be written,
+// which SerializedSize reports without panicking.
+func (t *Tree) Freeze() []byte {
+	return t.FreezeWithOptions(SerializeOptions{})
+}
+
+// FreezeWithOptions is Freeze with the encoding controlled by opts, the same as
+// SerializeWithOptions. The BreadthFirst layout keeps the top levels of the tree
+// together at the start, which suits a FrozenTree that is larger than cache
+// or memory.
+func (t *Tree) FreezeWithOptions(opts SerializeOptions) []byte {
+	buf := &bytes.Buffer{}
+	// Writes to a bytes.Buffer can't fail, only a tree too large to write
+	if err := t.SerializeWithOptions(buf, opts); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// AttachFrozen returns a FrozenTree that uses data, as returned by Freeze or
+// written by Serialize, in place. Only the header is checked. Returns a
+// *FormatError if the data is not a serialized tree and an error matching
+// ErrUnsupportedVersion for version 1 files, which can't be used without
+// decoding.
+func AttachFrozen(data []byte) (*FrozenTree, error) {
+	if len(data) < headerSize {
+		return nil, formatError(int64(len(data)), -1, "truncated header")
+	}
+	r, err := openRecords(sliceSource(data))
+	if err != nil {
+		return nil, err
+	}
+	return &FrozenTree{records: r, data: data}, nil
+}
+
+// MustAttachFrozen is like AttachFrozen but panics if data can't be attached.
+// It is used by the code written by WriteGoSource.
+func MustAttachFrozen(data []byte) *FrozenTree {
+	f, err := AttachFrozen(data)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Close releases the memory mapped by OpenFrozen. It does nothing for trees
+// created with AttachFrozen. The tree must not be used after Close.
+func (f *FrozenTree) Close() error {
+	if f.unmap == nil {
+		return nil
+	}
+	err := f.unmap()
+	f.unmap, f.data, f.src = nil, nil, sliceSource(nil)
+	return err
+}
+
+// NodeCount returns the number of nodes in f, as recorded in its header.
+func (f *FrozenTree) NodeCount() int {
+	return f.nodes
+}
+
+// Contains reports whether word is in f. If f was written with LengthBounds the
+// search stops at the first node with no words of the same length as word.
+func (f *FrozenTree) Contains(word string) bool {
+	ok, _ := f.contains(word)
+	return ok
+}
+
+// FindWordsWithPrefix returns all the words in f that start with prefix.
+func (f *FrozenTree) FindWordsWithPrefix(prefix string) []string {
+	words, _ := f.findWordsWithPrefix(prefix)
+	return words
+}
+
+// Prefetch asks the operating system to start reading the part of f that holds
+// the words starting with prefix into memory, and returns without waiting for
+// it. Warming up the subtrees of predicted queries in the background hides the
+// latency of a tree on disk. In the BreadthFirst layout a subtree is spread
+// over the file, so only the records of the children of the node for prefix
+// are prefetched. Prefetch does nothing for a tree that wasn't mapped by
+// OpenFrozen, or on platforms other than Linux.
+func (f *FrozenTree) Prefetch(prefix string) error {
+	if f.unmap == nil {
+		return nil
+	}
+	start, end, ok := f.span(prefix)
+	if !ok {
+		return nil
+	}
+	// The mapping starts on a page boundary, advice has to as well
+	start -= start % int64(pageSize)
+	return advise(f.data[start:end])
+}
+
+// span returns the offsets of the start and end of the records of the subtree
+// holding the words that start with prefix, or in the breadth first layout of
+// the children of its root.
+func (f *FrozenTree) span(prefix string) (start, end int64, ok bool) {
+	n, err := f.node(f.root)
+	if err != nil {
+		return 0, 0, false
+	}
+	start, end = f.root, f.src.size()
+	for prefix != "" {
+		i := bytes.IndexByte(n.keys, prefix[0])
+		if i < 0 {
+			return 0, 0, false
+		}
+		if start, end, err = f.childSpan(n, i); err != nil {
+			return 0, 0, false
+		}
+		c, err := f.node(start)
+		if err != nil {
+			return 0, 0, false
+		}
+
+		prefix = prefix[1:]
+		m := min(len(prefix), len(c.tail))
+		if prefix[:m] != string(c.tail[:m]) {
+			return 0, 0, false
+		}
+		prefix = prefix[m:]
+		n = c
+	}
+
+	if f.bfs {
+		if len(n.keys) == 0 {
+			return 0, 0, false
+		}
+		// The children are next to each other, up to the end of the last
+		if _, end, err = f.childSpan(n, len(n.keys)-1); err != nil {
+			return 0, 0, false
+		}
+		start = n.children
+	}
+	return start, end, true
+}
+
+// Verify walks the whole of f and checks that it is well formed, returning a
+// *FormatError, which matches ErrInvalidFormat, if it isn't.
+func (f *FrozenTree) Verify() error {
+	if f.bfs {
+		return f.verifyBreadthFirst()
+	}
+	nodes := 0
+	if _, err := f.verifyDepthFirst(f.root, f.src.size(), &nodes); err != nil {
+		return err
+	}
+	if nodes != f.nodes {
+		return formatError(f.src.size(), -1, "header records %d nodes, found %d", f.nodes, nodes)
+	}
+	return nil
+}
+
+// verifyDepthFirst checks the subtree at off, which must end at end, counting
+// its nodes into nodes. It returns the number of words in the subtree.
+func (f *FrozenTree) verifyDepthFirst(off, end int64, nodes *int) (int, error) {
+	n, err := f.node(off)
+	if err != nil {
+		return 0, formatError(off, *nodes, "malformed node record")
+	}
+	index := *nodes
+	*nodes++
+	words := 0
+	if n.isWord {
+		words++
+	}
+	pos, sizes := n.children, f.cursor(n.sizes)
+	for range n.keys {
+		soff := sizes.off
+		next, err := f.skipSubtree(&sizes, pos)
+		if err != nil {
+			return 0, formatError(soff, index, "subtree size")
+		}
+		below, err := f.verifyDepthFirst(pos, next, nodes)
+		if err != nil {
+			return 0, err
+		}
+		words += below
+		pos = next
+	}
+	if pos != end {
+		return 0, formatError(off, index, "subtree ends at offset %d, expected %d", pos, end)
+	}
+	if n.counted && n.words != words {
+		return 0, formatError(off, index, "word count %d, found %d", n.words, words)
+	}
+	return words, nil
+}
+
+// verifyBreadthFirst checks the records, which in the breadth first layout fill
+// the rest of the file, and that each node's children follow on from those of
+// the nodes before it.
+func (f *FrozenTree) verifyBreadthFirst() error {
+	var records []record
+	for off := f.root; off < f.src.size(); {
+		n, err := f.node(off)
+		if err != nil {
+			return formatError(off, len(records), "malformed node record")
+		}
+		records = append(records, n)
+		off = n.end
+	}
+	if len(records) != f.nodes {
+		return formatError(f.src.size(), -1, "header records %d nodes, found %d", f.nodes, len(records))
+	}
+
+	next := 1 // index of the first record not yet claimed as a child
+	first := make([]int, len(records))
+	for i, n := range records {
+		first[i] = next
+		if len(n.keys) == 0 {
+			continue
+		}
+		if next+len(n.keys) > len(records) {
+			return formatError(n.end, i, "%d children, only %d records left", len(n.keys), len(records)-next)
+		}
+		if want := records[next-1].end; n.children != want {
+			return formatError(n.end-4, i, "first child recorded at offset %d, expected %d", n.children, want)
+		}
+		next += len(n.keys)
+	}
+	if next != len(records) {
+		return formatError(records[next-1].end, next, "record is not the child of any node")
+	}
+
+	// Children come after their parents, so count the words from the end
+	words := make([]int, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		n := records[i]
+		if n.isWord {
+			words[i]++
+		}
+		for j := range n.keys {
+			words[i] += words[first[i]+j]
+		}
+		if n.counted && n.words != words[i] {
+			return formatError(n.end, i, "word count %d, found %d", n.words, words[i])
+		}
+	}
+	return nil
+}
+
+// WordToID returns the rank of word among the words of f in byte order,
+// starting at 0, and whether it is in f. The IDs of a file never change, so
+// a FrozenTree can serve as a string interning table, with IDs stored in place
+// of the words and IDToWord turning them back. A tree written with WordCounts
+// only looks at the nodes on the path of word and their siblings, otherwise
+// the words below the siblings before the path are counted one by one.
+func (f *FrozenTree) WordToID(word string) (int, bool) {
+	n, err := f.node(f.root)
+	if err != nil {
+		return 0, false
+	}
+	id := 0
+	for word != "" {
+		if n.isWord {
+			id++
+		}
+		var next record
+		found := false
+		f.eachChild(n, func(k byte, c record) error {
+			switch {
+			case k < word[0]:
+				id += f.wordCount(c)
+			case k == word[0]:
+				next, found = c, true
+			}
+			return nil
+		})
+		word = word[1:]
+		if !found || len(word) < len(next.tail) || word[:len(next.tail)] != string(next.tail) {
+			return 0, false
+		}
+		word = word[len(next.tail):]
+		n = next
+	}
+	return id, n.isWord
+}
+
+// IDToWord returns the word whose rank in f is id, see WordToID, and whether
+// there is one.
+func (f *FrozenTree) IDToWord(id int) (string, bool) {
+	n, err := f.node(f.root)
+	if err != nil || id < 0 {
+		return "", false
+	}
+	var path []byte
+	for {
+		if n.isWord {
+			if id == 0 {
+				return string(path), true
+			}
+			id--
+		}
+		var next record
+		found := false
+		f.eachChild(n, func(k byte, c record) error {
+			if found {
+				return nil
+			}
+			if words := f.wordCount(c); id >= words {
+				id -= words
+				return nil
+			}
+			next, found = c, true
+			path = append(append(path, k), c.tail...)
+			return nil
+		})
+		if !found {
+			return "", false
+		}
+		n = next
+	}
+}
+
+// wordCount returns the number of words in the subtree of n.
+func (f *FrozenTree) wordCount(n record) int {
+	if n.counted {
+		return n.words
+	}
+	words := 0
+	if n.isWord {
+		words++
+	}
+	f.eachChild(n, func(_ byte, c record) error {
+		words += f.wordCount(c)
+		return nil
+	})
+	return words
+}
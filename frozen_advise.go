@@ -0,0 +1,13 @@
+//go:build linux
+
+package compressedtrie
+
+import "syscall"
+
+var pageSize = syscall.Getpagesize()
+
+// advise tells the kernel that b, which is part of a mapping, will be read
+// soon.
+func advise(b []byte) error {
+	return syscall.Madvise(b, syscall.MADV_WILLNEED)
+}
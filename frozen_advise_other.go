@@ -0,0 +1,12 @@
+//go:build !linux
+
+package compressedtrie
+
+import "os"
+
+var pageSize = os.Getpagesize()
+
+// advise does nothing, there is no madvise in syscall on this platform.
+func advise(b []byte) error {
+	return nil
+}
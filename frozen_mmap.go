@@ -0,0 +1,41 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package compressedtrie
+
+import (
+	"os"
+	"syscall"
+)
+
+// OpenFrozen maps the serialized tree in the file at path into memory and
+// attaches a FrozenTree to it. The mapping is shared and read-only, so every
+// process that opens the same file uses the same physical pages. Call Close to
+// unmap the file.
+func OpenFrozen(path string) (*FrozenTree, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < headerSize || int64(int(fi.Size())) != fi.Size() {
+		return nil, formatError(fi.Size(), -1, "truncated header")
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := AttachFrozen(data)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+	f.unmap = func() error { return syscall.Munmap(data) }
+	return f, nil
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package compressedtrie
+
+import "os"
+
+// OpenFrozen reads the serialized tree in the file at path and attaches a
+// FrozenTree to it. On this platform the file is read into memory rather than
+// mapped, so it is not shared with other processes.
+func OpenFrozen(path string) (*FrozenTree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return AttachFrozen(data)
+}
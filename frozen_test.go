@@ -0,0 +1,267 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var frozenWords = []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus", "test", "toaster", "toasting", "slow", "slowly"}
+
+var frozenOptions = []struct {
+	Name string
+	Opts SerializeOptions
+}{
+	{"Depth first", SerializeOptions{}},
+	{"Breadth first", SerializeOptions{Layout: BreadthFirst}},
+	{"Dictionary", SerializeOptions{LabelDictionary: true}},
+	{"Breadth first dictionary", SerializeOptions{LabelDictionary: true, Layout: BreadthFirst}},
+	{"Length bounds", SerializeOptions{LengthBounds: true}},
+	{"Breadth first length bounds", SerializeOptions{LengthBounds: true, Layout: BreadthFirst}},
+	{"Word counts", SerializeOptions{WordCounts: true}},
+	{"Breadth first word counts", SerializeOptions{WordCounts: true, Layout: BreadthFirst, LabelDictionary: true}},
+}
+
+func TestFrozenTree(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+
+	for _, tc := range frozenOptions {
+		t.Run(tc.Name, func(t *testing.T) {
+			frozen, err := AttachFrozen(tree.FreezeWithOptions(tc.Opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := frozen.Verify(); err != nil {
+				t.Fatal(err)
+			}
+			if frozen.NodeCount() != tree.NodeCount() {
+				t.Errorf("Expected %d nodes, got %d", tree.NodeCount(), frozen.NodeCount())
+			}
+
+			for _, word := range append(frozenWords, "", "r", "rom", "roman", "slowl", "slowlyy", "toast", "x") {
+				if expected, actual := tree.Contains(word), frozen.Contains(word); expected != actual {
+					t.Errorf("Contains(%q): expected %v, got %v", word, expected, actual)
+				}
+			}
+
+			for _, prefix := range []string{"", "r", "ro", "rom", "roma", "romanes", "rub", "rubi", "t", "to", "toas", "tx", "slow", "x"} {
+				expected := tree.FindWordsWithPrefix(prefix)
+				actual := frozen.FindWordsWithPrefix(prefix)
+				if !slices.Equal(actual, expected) {
+					t.Errorf("FindWordsWithPrefix(%q): expected %v, got %v", prefix, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestFrozenTreeCorrupt(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+
+	for _, tc := range frozenOptions {
+		t.Run(tc.Name, func(t *testing.T) {
+			data := tree.FreezeWithOptions(tc.Opts)
+			if _, err := AttachFrozen(data[:8]); !errors.Is(err, ErrInvalidFormat) {
+				t.Errorf("Expected ErrInvalidFormat for a truncated header, got %v", err)
+			}
+
+			// Every truncation and single byte corruption must be caught by
+			// Verify, or at least not crash the queries.
+			for i := headerSize; i < len(data); i++ {
+				frozen, err := AttachFrozen(data[:i])
+				if err == nil {
+					if err := frozen.Verify(); !errors.Is(err, ErrInvalidFormat) {
+						t.Errorf("Truncated at %d: expected ErrInvalidFormat, got %v", i, err)
+					}
+					frozen.FindWordsWithPrefix("")
+					frozen.Contains("rubicundus")
+				}
+
+				corrupt := slices.Clone(data)
+				corrupt[i] ^= 0xff
+				if frozen, err = AttachFrozen(corrupt); err == nil {
+					frozen.Verify()
+					frozen.FindWordsWithPrefix("")
+					frozen.Contains("rubicundus")
+				}
+			}
+		})
+	}
+}
+
+func TestOpenFrozen(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	filename := filepath.Join(t.TempDir(), "words.ctree")
+	if err := os.WriteFile(filename, tree.Freeze(), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	frozen, err := OpenFrozen(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := frozen.FindWordsWithPrefix(""); !slices.Equal(actual, tree.FindWordsWithPrefix("")) {
+		t.Errorf("Expected %v, got %v", tree.FindWordsWithPrefix(""), actual)
+	}
+	if err := frozen.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFrozenLengthBounds(t *testing.T) {
+	tree := perfTree(3000)
+	plain := tree.Freeze()
+	data := tree.FreezeWithOptions(SerializeOptions{LengthBounds: true})
+	t.Logf("%d bytes without length bounds, %d with", len(plain), len(data))
+
+	decoded, err := DeserializeTree(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asDot(decoded) != asDot(tree) {
+		t.Errorf("Tree does not survive a round trip with length bounds")
+	}
+
+	frozen, err := AttachFrozen(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mostly misses, of every length around those of the words
+	for _, word := range perfWords(500) {
+		for _, q := range []string{word, word[:len(word)/2], word + "s", word + "zzzzzzzzzzzzzzzzzzzz", word[1:]} {
+			if expected, actual := tree.Contains(q), frozen.Contains(q); expected != actual {
+				t.Errorf("Contains(%q): expected %v, got %v", q, expected, actual)
+			}
+			if expected, actual := tree.FindWordsWithPrefix(q), frozen.FindWordsWithPrefix(q); !slices.Equal(actual, expected) {
+				t.Errorf("FindWordsWithPrefix(%q): expected %v, got %v", q, expected, actual)
+			}
+		}
+	}
+
+	// An empty tree has no bounds to record
+	empty := NewTree().FreezeWithOptions(SerializeOptions{LengthBounds: true})
+	if !bytes.Equal(empty, NewTree().Freeze()) {
+		t.Errorf("Expected an empty tree to be written without bounds")
+	}
+}
+
+func TestFrozenWordIDs(t *testing.T) {
+	tree := perfTree(3000)
+	tree.Insert("")
+	words := tree.FindWordsWithPrefix("")
+	for _, tc := range frozenOptions {
+		frozen, err := AttachFrozen(tree.FreezeWithOptions(tc.Opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := frozen.Verify(); err != nil {
+			t.Fatalf("%s: %v", tc.Name, err)
+		}
+		// Without counts each lookup counts the words before it, check fewer
+		step := 1
+		if !tc.Opts.WordCounts {
+			step = 17
+		}
+		for id := 0; id < len(words); id += step {
+			word := words[id]
+			if actual, ok := frozen.WordToID(word); !ok || actual != id {
+				t.Fatalf("%s: WordToID(%q): expected %d, got %d %v", tc.Name, word, id, actual, ok)
+			}
+			if actual, ok := frozen.IDToWord(id); !ok || actual != word {
+				t.Fatalf("%s: IDToWord(%d): expected %q, got %q %v", tc.Name, id, word, actual, ok)
+			}
+		}
+		for _, word := range []string{words[1] + "\xff", words[1][:1] + "\xff", "\xff"} {
+			if _, ok := frozen.WordToID(word); ok {
+				t.Errorf("%s: WordToID(%q) found a word", tc.Name, word)
+			}
+		}
+		for _, id := range []int{-1, len(words)} {
+			if _, ok := frozen.IDToWord(id); ok {
+				t.Errorf("%s: IDToWord(%d) found a word", tc.Name, id)
+			}
+		}
+	}
+
+	// Verify checks the counts. The root record is its empty label, its
+	// flags and then its count.
+	data := tree.FreezeWithOptions(SerializeOptions{WordCounts: true})
+	data[headerSize+2]++
+	frozen, err := AttachFrozen(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fe *FormatError
+	if err := frozen.Verify(); !errors.As(err, &fe) || fe.Node != 0 || !strings.Contains(fe.Reason, "word count") {
+		t.Errorf("Expected a FormatError for the root's word count, got %v", err)
+	}
+}
+
+func TestFrozenPrefetch(t *testing.T) {
+	tree := perfTree(3000)
+	prefixes := []string{"", "a", "ba", "cat", "st", "zzzz", "q"}
+	for _, tc := range frozenOptions[:2] {
+		frozen, err := AttachFrozen(tree.FreezeWithOptions(tc.Opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, prefix := range prefixes {
+			node, _ := tree.descend(prefix)
+			start, end, ok := frozen.span(prefix)
+			if ok != (node != nil) {
+				t.Fatalf("%s, %q: expected a span %v, got %v", tc.Name, prefix, node != nil, ok)
+			}
+			if !ok {
+				continue
+			}
+			if tc.Opts.Layout == BreadthFirst {
+				// The records of the children
+				records := 0
+				for off := start; off < end; records++ {
+					n, _ := frozen.node(off)
+					off = n.end
+				}
+				if records != len(node.children) {
+					t.Errorf("%s, %q: span holds %d records, expected %d", tc.Name, prefix, records, len(node.children))
+				}
+				continue
+			}
+			// The whole subtree
+			nodes := 0
+			if _, err := frozen.verifyDepthFirst(start, end, &nodes); err != nil {
+				t.Errorf("%s, %q: %v", tc.Name, prefix, err)
+			}
+			if expected, _ := countNodes(node); nodes != expected {
+				t.Errorf("%s, %q: span holds %d nodes, expected %d", tc.Name, prefix, nodes, expected)
+			}
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "words.ctree")
+	if err := os.WriteFile(filename, tree.Freeze(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	frozen, err := OpenFrozen(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer frozen.Close()
+	for _, prefix := range prefixes {
+		if err := frozen.Prefetch(prefix); err != nil {
+			t.Errorf("Prefetch(%q): %v", prefix, err)
+		}
+	}
+}
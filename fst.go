@@ -0,0 +1,164 @@
+package compressedtrie
+
+import (
+	"encoding/binary"
+	"maps"
+	"slices"
+)
+
+// FST is a minimal acyclic finite state transducer that maps the words of a
+// tree to their ordinals, the positions of the words in byte order starting
+// at 0, and back. It is the structure behind the term dictionaries of
+// Lucene-style search engines: states that accept the same set of suffixes
+// are merged, so common endings are shared as well as common prefixes, and
+// each arc has an output such that the outputs along the path of a word add
+// up to its ordinal. The output of an arc is the number of words that come
+// before those through it from the same state, that is one for the state if
+// it is final plus the words through arcs with smaller labels.
+//
+// The states and arcs can be walked with Start, IsFinal and Arcs to feed the
+// automaton to other code. An FST is a snapshot of the tree and is safe for
+// concurrent use.
+type FST struct {
+	arcs  []FSTArc
+	first []int32 // the arcs of state s are arcs[first[s]:first[s+1]]
+	final []bool
+	words []uint64 // the number of words accepted from each state
+	start int
+}
+
+// An FSTArc is a transition of an FST from one state to Target on the byte
+// Label, adding Output to the ordinal.
+type FSTArc struct {
+	Label  byte
+	Output uint64
+	Target int
+}
+
+// FST builds the minimal transducer from the words of t to their ordinals.
+// The ordinals follow byte order whatever the child order of t.
+func (t *Tree) FST() *FST {
+	f := &FST{first: []int32{0}}
+	register := make(map[string]int)
+	var key []byte
+
+	// add returns the state that is final or not with arcs to targets,
+	// adding it unless there is one already
+	add := func(final bool, labels []byte, targets []int) int {
+		key = key[:0]
+		if final {
+			key = append(key, 1)
+		} else {
+			key = append(key, 0)
+		}
+		for i, label := range labels {
+			key = append(key, label)
+			key = binary.AppendUvarint(key, uint64(targets[i]))
+		}
+		if s, ok := register[string(key)]; ok {
+			return s
+		}
+
+		var words uint64
+		if final {
+			words = 1
+		}
+		for i, label := range labels {
+			f.arcs = append(f.arcs, FSTArc{Label: label, Output: words, Target: targets[i]})
+			words += f.words[targets[i]]
+		}
+		s := len(f.final)
+		f.first = append(f.first, int32(len(f.arcs)))
+		f.final = append(f.final, final)
+		f.words = append(f.words, words)
+		register[string(key)] = s
+		return s
+	}
+
+	var build func(node *Node) int
+	build = func(node *Node) int {
+		keys := slices.Sorted(maps.Keys(node.children))
+		targets := make([]int, len(keys))
+		for i, k := range keys {
+			child := node.children[k]
+			// The bytes of the label after the first each lead to a state
+			// with a single arc
+			target := build(child)
+			for j := len(child.label) - 1; j > 0; j-- {
+				target = add(false, []byte{child.label[j]}, []int{target})
+			}
+			targets[i] = target
+		}
+		return add(node.isWord, keys, targets)
+	}
+	f.start = build(t.root)
+	return f
+}
+
+// Len returns the number of words f maps.
+func (f *FST) Len() int {
+	return int(f.words[f.start])
+}
+
+// NumStates returns the number of states of f.
+func (f *FST) NumStates() int {
+	return len(f.final)
+}
+
+// Start returns the start state of f. States are numbered from 0 to
+// NumStates()-1, each after the states its arcs lead to.
+func (f *FST) Start() int {
+	return f.start
+}
+
+// IsFinal reports whether the input that leads to state s is a word.
+func (f *FST) IsFinal(s int) bool {
+	return f.final[s]
+}
+
+// Arcs returns the arcs from state s in ascending order of label. The slice
+// belongs to f and must not be changed.
+func (f *FST) Arcs(s int) []FSTArc {
+	return slices.Clip(f.arcs[f.first[s]:f.first[s+1]])
+}
+
+// Ordinal returns the position of word among the words of f in byte order,
+// and whether it is one of them.
+func (f *FST) Ordinal(word string) (uint64, bool) {
+	s, ord := f.start, uint64(0)
+	for i := 0; i < len(word); i++ {
+		arcs := f.Arcs(s)
+		j, ok := slices.BinarySearchFunc(arcs, word[i], func(a FSTArc, b byte) int { return int(a.Label) - int(b) })
+		if !ok {
+			return 0, false
+		}
+		ord += arcs[j].Output
+		s = arcs[j].Target
+	}
+	return ord, f.final[s]
+}
+
+// Word returns the word with ordinal ord, and whether there is one.
+func (f *FST) Word(ord uint64) (string, bool) {
+	if ord >= f.words[f.start] {
+		return "", false
+	}
+	var word []byte
+	for s := f.start; ; {
+		if f.final[s] && ord == 0 {
+			return string(word), true
+		}
+		// The last arc whose words start at or before ord
+		arcs := f.Arcs(s)
+		j, _ := slices.BinarySearchFunc(arcs, ord+1, func(a FSTArc, ord uint64) int {
+			if a.Output < ord {
+				return -1
+			}
+			return 1
+		})
+		arc := arcs[j-1]
+		word = append(word, arc.Label)
+		ord -= arc.Output
+		s = arc.Target
+	}
+}
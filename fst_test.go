@@ -0,0 +1,80 @@
+package compressedtrie
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFST(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"tops", "tap", "taps", "top"} {
+		tree.Insert(word)
+	}
+	f := tree.FST()
+	// t, then a or o to the same state, p, s
+	if f.NumStates() != 5 || f.Len() != 4 {
+		t.Errorf("Expected 4 words in 5 states, got %d in %d", f.Len(), f.NumStates())
+	}
+	for i, word := range []string{"tap", "taps", "top", "tops"} {
+		if ord, ok := f.Ordinal(word); !ok || ord != uint64(i) {
+			t.Errorf("%q: expected ordinal %d, got %d %v", word, i, ord, ok)
+		}
+		if actual, ok := f.Word(uint64(i)); !ok || actual != word {
+			t.Errorf("%d: expected %q, got %q %v", i, word, actual, ok)
+		}
+	}
+	for _, word := range []string{"", "t", "ta", "tapss", "x"} {
+		if _, ok := f.Ordinal(word); ok {
+			t.Errorf("%q: unexpected ordinal", word)
+		}
+	}
+	if _, ok := f.Word(4); ok {
+		t.Errorf("Found a word past the end")
+	}
+
+	// The outputs along the path of a word add up to its ordinal
+	s, sum := f.Start(), uint64(0)
+	for _, c := range []byte("tops") {
+		for _, arc := range f.Arcs(s) {
+			if arc.Label == c {
+				sum += arc.Output
+				s = arc.Target
+			}
+		}
+	}
+	if !f.IsFinal(s) || sum != 3 {
+		t.Errorf("Expected a final state with ordinal 3, got %v %d", f.IsFinal(s), sum)
+	}
+
+	// Byte order whatever the child order, the empty word first
+	big := perfTree(3000)
+	big.Insert("")
+	words := big.FindWordsWithPrefix("")
+	reversed := NewTree(WithChildOrder(func(a, b string) int { return strings.Compare(b, a) }))
+	for _, word := range words {
+		reversed.Insert(word)
+	}
+	f = reversed.FST()
+	trieStates := 1 // an uncompressed trie has a state for each byte of each label
+	for _, node := range big.Nodes() {
+		trieStates += len(node.Label())
+	}
+	if f.Len() != len(words) || f.NumStates() >= trieStates {
+		t.Errorf("Expected %d words in fewer than %d states, got %d in %d", len(words), trieStates, f.Len(), f.NumStates())
+	}
+	for i, word := range words {
+		if ord, ok := f.Ordinal(word); !ok || ord != uint64(i) {
+			t.Fatalf("%q: expected ordinal %d, got %d %v", word, i, ord, ok)
+		}
+		if actual, ok := f.Word(uint64(i)); !ok || actual != word {
+			t.Fatalf("%d: expected %q, got %q %v", i, word, actual, ok)
+		}
+	}
+	if !slices.IsSorted(words) {
+		t.Errorf("Words not in byte order")
+	}
+	if empty := NewTree().FST(); empty.Len() != 0 {
+		t.Errorf("Expected an empty FST, got %d words", empty.Len())
+	}
+}
@@ -0,0 +1,73 @@
+package compressedtrie
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// goSourceLineBytes is the number of bytes of the tree on each line of the
+// generated string literal.
+const goSourceLineBytes = 32
+
+// WriteGoSource writes a Go source file for package pkg that embeds t, frozen
+// with opts, and declares it as a *FrozenTree variable called name. The data is
+// a package level []byte literal which the compiler places in the binary as is,
+// so using the tree involves no file I/O or decoding at startup, only
+// AttachFrozen checking the header. This suits command line tools with a
+// dictionary fixed at compile time, with the file produced by a go:generate
+// step.
+func (t *Tree) WriteGoSource(w io.Writer, pkg, name string, opts SerializeOptions) error {
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("compressedtrie: invalid package name %q", pkg)
+	}
+	if !token.IsIdentifier(name) {
+		return fmt.Errorf("compressedtrie: invalid variable name %q", name)
+	}
+	r, n := utf8.DecodeRuneInString(name)
+	data := string(unicode.ToLower(r)) + name[n:] + "Data"
+
+	frozen := t.FreezeWithOptions(opts)
+
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "// Code generated by compressedtrie.WriteGoSource. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+	fmt.Fprintf(buf, "import \"github.com/chriskillpack/compressedtrie\"\n\n")
+	fmt.Fprintf(buf, "// %s holds %d words in %d nodes.\n", name, t.WordCount(), t.NodeCount())
+	fmt.Fprintf(buf, "var %s = compressedtrie.MustAttachFrozen(%s)\n\n", name, data)
+	fmt.Fprintf(buf, "var %s = []byte(\"\" +\n", data)
+	for len(frozen) > 0 {
+		line := frozen[:min(goSourceLineBytes, len(frozen))]
+		frozen = frozen[len(line):]
+
+		sep := " +"
+		if len(frozen) == 0 {
+			sep = ")"
+		}
+		fmt.Fprintf(buf, "\t\"%s\"%s\n", quoteBytes(line), sep)
+	}
+	return buf.Flush()
+}
+
+// quoteBytes returns b escaped for a Go interpreted string literal, without
+// the quotes. Anything other than printable ASCII is written as a hex escape so
+// the result is valid whatever the bytes are.
+func quoteBytes(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "\\x%02x", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+func TestWriteGoSource(t *testing.T) {
+	tree := NewTree()
+	for _, word := range append(frozenWords, `quote"back\slash`, "caf\xc3\xa9", "\x00\xff") {
+		tree.Insert(word)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tree.WriteGoSource(buf, "words", "Words", SerializeOptions{LabelDictionary: true}); err != nil {
+		t.Fatal(err)
+	}
+	if formatted, err := format.Source(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(formatted, buf.Bytes()) {
+		t.Errorf("Generated source is not gofmt formatted:\n%s", buf.Bytes())
+	}
+
+	// Recover the data from the string literals
+	file, err := parser.ParseFile(token.NewFileSet(), "words.go", buf.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name.Name != "words" {
+		t.Errorf("Expected package words, got %s", file.Name.Name)
+	}
+	var data []byte
+	ast.Inspect(file, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data = append(data, s...)
+		}
+		return true
+	})
+	data = data[len("github.com/chriskillpack/compressedtrie"):]
+	if !bytes.Equal(data, tree.FreezeWithOptions(SerializeOptions{LabelDictionary: true})) {
+		t.Errorf("Generated data does not match the frozen tree")
+	}
+
+	for _, name := range [][2]string{{"main", "1words"}, {"a-b", "Words"}} {
+		if err := tree.WriteGoSource(&bytes.Buffer{}, name[0], name[1], SerializeOptions{}); err == nil {
+			t.Errorf("Expected an error for %q %q", name[0], name[1])
+		}
+	}
+}
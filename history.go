@@ -0,0 +1,129 @@
+package compressedtrie
+
+import (
+	"maps"
+	"strings"
+)
+
+// A Revision identifies a state of a tree recorded by Commit. Revisions count
+// up from 1.
+type Revision uint64
+
+// history holds the committed revisions of a tree, oldest first.
+type history struct {
+	limit     int
+	revisions []committed
+	last      Revision
+}
+
+type committed struct {
+	revision Revision
+	tree     *Tree
+}
+
+// WithHistory makes t keep the last n states recorded by Commit, which At
+// returns as read-only trees. The revisions share every node that hasn't changed
+// since, so a revision costs only the nodes on the paths of the words inserted
+// or deleted after it: once a revision has been committed Insert and Delete
+// copy the nodes they would change instead of changing them. This serves
+// queries against the dictionary as it was at some earlier deploy, for
+// reproducing search results. The values of a MultiMap are not copied and are
+// shared by every revision.
+func WithHistory(n int) Option {
+	return func(t *Tree) { t.history = &history{limit: max(n, 1)} }
+}
+
+// Commit records the current state of t as a new revision, forgetting the
+// oldest revision if more are held than WithHistory allows. It panics if t was
+// not created with WithHistory.
+func (t *Tree) Commit() Revision {
+	if t.history == nil {
+		panic("compressedtrie: Commit on a tree created without WithHistory")
+	}
+	h := t.history
+	h.last++
+	snapshot := &Tree{
+		root:       t.root,
+		nodes:      t.nodes,
+		words:      t.words,
+		maxWordLen: t.maxWordLen,
+		maxDepth:   t.maxDepth,
+		order:      t.order,
+		gen:        t.gen,
+	}
+	h.revisions = append(h.revisions, committed{h.last, snapshot})
+	if len(h.revisions) > h.limit {
+		h.revisions = append(h.revisions[:0], h.revisions[len(h.revisions)-h.limit:]...)
+	}
+
+	// Everything reachable now belongs to snapshot as well
+	t.gen++
+	return h.last
+}
+
+// At returns the tree as it was when revision v was committed, or false if v has
+// been forgotten or was never committed. The tree shares nodes with t and
+// other revisions and must not be changed.
+func (t *Tree) At(v Revision) (*Tree, bool) {
+	if t.history == nil {
+		return nil, false
+	}
+	for _, c := range t.history.revisions {
+		if c.revision == v {
+			return c.tree, true
+		}
+	}
+	return nil, false
+}
+
+// Revisions returns the revisions of t that At can return, oldest first.
+func (t *Tree) Revisions() []Revision {
+	if t.history == nil {
+		return nil
+	}
+	revisions := make([]Revision, len(t.history.revisions))
+	for i, c := range t.history.revisions {
+		revisions[i] = c.revision
+	}
+	return revisions
+}
+
+// own makes sure that the nodes on the path of word, and the first node off
+// it, belong to t alone and not to a committed revision, copying those that
+// don't, so that they can be changed. It does nothing without WithHistory.
+func (t *Tree) own(word string) {
+	if t.history == nil {
+		return
+	}
+	t.changes++
+	t.root = t.ownNode(t.root)
+	cur := t.root
+	for word != "" {
+		child, exists := cur.children[word[0]]
+		if !exists {
+			return
+		}
+		child = t.ownNode(child)
+		cur.children[word[0]] = child
+		if !strings.HasPrefix(word, child.label) {
+			return
+		}
+		word = word[len(child.label):]
+		cur = child
+	}
+}
+
+// ownNode returns node if it belongs to t, otherwise a copy of it that does.
+func (t *Tree) ownNode(node *Node) *Node {
+	if node.gen == t.gen {
+		return node
+	}
+	c := *node
+	c.children = maps.Clone(node.children)
+	c.gen = t.gen
+	if e, ok := t.dirty[node]; ok {
+		delete(t.dirty, node)
+		t.dirty[&c] = e
+	}
+	return &c
+}
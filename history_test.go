@@ -0,0 +1,117 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	tree := NewTree(WithHistory(2))
+	for _, word := range []string{"alpha", "alphabet", "beta"} {
+		tree.Insert(word)
+	}
+	v1 := tree.Commit()
+	first := tree.FindWordsWithPrefix("")
+
+	tree.Insert("alp")
+	tree.Insert("gamma")
+	tree.Delete("beta")
+	tree.Delete("alphabet")
+	v2 := tree.Commit()
+	second := tree.FindWordsWithPrefix("")
+
+	// Changes after the last commit don't show either
+	tree.Insert("alphanumeric")
+	tree.Delete("alp")
+
+	old, ok := tree.At(v1)
+	if !ok {
+		t.Fatalf("Revision %d missing", v1)
+	}
+	if words := old.FindWordsWithPrefix(""); !slices.Equal(words, first) {
+		t.Errorf("Revision %d: expected %v, got %v", v1, first, words)
+	}
+	if old.WordCount() != 3 || !old.Contains("alphabet") || old.Contains("alp") {
+		t.Errorf("Revision %d changed", v1)
+	}
+	old, _ = tree.At(v2)
+	if words := old.FindWordsWithPrefix(""); !slices.Equal(words, second) {
+		t.Errorf("Revision %d: expected %v, got %v", v2, second, words)
+	}
+	expected := []string{"alpha", "alphanumeric", "gamma"}
+	if words := tree.FindWordsWithPrefix(""); !slices.Equal(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+
+	// Only the last two revisions are kept
+	v3 := tree.Commit()
+	if revs := tree.Revisions(); !slices.Equal(revs, []Revision{v2, v3}) {
+		t.Errorf("Expected revisions %v, got %v", []Revision{v2, v3}, revs)
+	}
+	if _, ok := tree.At(v1); ok {
+		t.Errorf("Revision %d was kept", v1)
+	}
+}
+
+func TestHistoryAgrees(t *testing.T) {
+	// Revisions agree with trees built from scratch
+	words := perfWords(2000)
+	tree := NewTree(WithHistory(100))
+	var revs []Revision
+	for i, word := range words {
+		tree.Insert(word)
+		if i%3 == 2 {
+			tree.Delete(words[i/2])
+		}
+		if i%100 == 99 {
+			revs = append(revs, tree.Commit())
+		}
+	}
+	for i, rev := range revs {
+		expected := NewTree()
+		for j, word := range words[:(i+1)*100] {
+			expected.Insert(word)
+			if j%3 == 2 {
+				expected.Delete(words[j/2])
+			}
+		}
+		old, ok := tree.At(rev)
+		if !ok {
+			t.Fatalf("Revision %d missing", rev)
+		}
+		if !slices.Equal(old.FindWordsWithPrefix(""), expected.FindWordsWithPrefix("")) || old.NodeCount() != expected.NodeCount() {
+			t.Fatalf("Revision %d differs from a tree built from scratch", rev)
+		}
+	}
+}
+
+func TestHistoryApplyDirty(t *testing.T) {
+	base := NewTree(WithDirtyTracking())
+	tree := NewTree(WithHistory(1))
+	for _, word := range []string{"alpha", "alphabet", "beta"} {
+		base.Insert(word)
+		tree.Insert(word)
+	}
+	base.MarkClean()
+	v := tree.Commit()
+
+	base.Insert("alp")
+	base.Insert("gamma")
+	base.Delete("beta")
+	var buf bytes.Buffer
+	if err := base.SerializeDirty(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.ApplyDirty(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if words, expected := tree.FindWordsWithPrefix(""), base.FindWordsWithPrefix(""); !slices.Equal(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+	old, _ := tree.At(v)
+	expected := []string{"alpha", "alphabet", "beta"}
+	if words := old.FindWordsWithPrefix(""); !slices.Equal(words, expected) {
+		t.Errorf("Revision %d: expected %v, got %v", v, expected, words)
+	}
+}
@@ -0,0 +1,66 @@
+package compressedtrie
+
+import (
+	"iter"
+	"maps"
+	"slices"
+)
+
+// The accessors below give tools such as histograms, converters and
+// visualizers read-only access to the structure of a tree. There is no way to
+// change a node through them.
+
+// Root returns the root node of t. Its label is empty.
+func (t *Tree) Root() *Node {
+	return t.root
+}
+
+// Nodes returns an iterator over every node of t and its path from the root,
+// parents before children and children in key order.
+func (t *Tree) Nodes() iter.Seq2[string, *Node] {
+	return func(yield func(string, *Node) bool) {
+		t.traverse(t.root, "", traversal{
+			enter: func(node *Node, path []byte, _ int) step {
+				if !yield(string(path), node) {
+					return stepStop
+				}
+				return stepInto
+			},
+			visit:    func(string, *Node, int) step { return stepInto },
+			keyOrder: true,
+		})
+	}
+}
+
+// Label returns the label of the edge from n's parent to n.
+func (n *Node) Label() string {
+	return n.label
+}
+
+// IsWord reports whether n marks the end of a word.
+func (n *Node) IsWord() bool {
+	return n.isWord
+}
+
+// NumChildren returns the number of children of n.
+func (n *Node) NumChildren() int {
+	return len(n.children)
+}
+
+// Child returns the child of n whose label starts with k, if there is one.
+func (n *Node) Child(k byte) (*Node, bool) {
+	child, ok := n.children[k]
+	return child, ok
+}
+
+// Children returns an iterator over the children of n and the first bytes of
+// their labels, in ascending order.
+func (n *Node) Children() iter.Seq2[byte, *Node] {
+	return func(yield func(byte, *Node) bool) {
+		for _, k := range slices.Sorted(maps.Keys(n.children)) {
+			if !yield(k, n.children[k]) {
+				return
+			}
+		}
+	}
+}
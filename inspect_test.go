@@ -0,0 +1,49 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"alphabet", "elephant", "alpha"} {
+		tree.Insert(word)
+	}
+
+	root := tree.Root()
+	if root.Label() != "" || root.IsWord() || root.NumChildren() != 2 {
+		t.Errorf("Unexpected root %q %v %d", root.Label(), root.IsWord(), root.NumChildren())
+	}
+	alpha, ok := root.Child('a')
+	if !ok || alpha.Label() != "alpha" || !alpha.IsWord() {
+		t.Fatalf("Expected a child alpha")
+	}
+	if _, ok := root.Child('b'); ok {
+		t.Errorf("Unexpected child b")
+	}
+
+	var keys []byte
+	for k, child := range root.Children() {
+		if child.Label()[0] != k {
+			t.Errorf("Child %q has key %q", child.Label(), k)
+		}
+		keys = append(keys, k)
+	}
+	if string(keys) != "ae" {
+		t.Errorf("Expected children a and e, got %q", keys)
+	}
+
+	var paths []string
+	words := 0
+	for path, node := range tree.Nodes() {
+		paths = append(paths, path)
+		if node.IsWord() {
+			words++
+		}
+	}
+	expected := []string{"", "alpha", "alphabet", "elephant"}
+	if !slices.Equal(paths, expected) || len(paths) != tree.NodeCount() || words != tree.WordCount() {
+		t.Errorf("Expected nodes %v, got %v with %d words", expected, paths, words)
+	}
+}
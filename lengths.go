@@ -0,0 +1,117 @@
+package compressedtrie
+
+// Every node records the lengths, from the root, of the shortest and longest
+// words in its subtree so that length constrained queries can skip subtrees
+// without visiting them. Insert keeps them up to date along the path of the
+// word it adds, anything that builds or rearranges nodes wholesale calls
+// computeLengths.
+
+// addLength widens the range of word lengths of node to include n.
+func (node *Node) addLength(n int) {
+	l := uint32(n)
+	if node.lenHi == 0 {
+		node.lenLo, node.lenHi = l, l+1
+		return
+	}
+	node.lenLo = min(node.lenLo, l)
+	node.lenHi = max(node.lenHi, l+1)
+}
+
+// hasLength reports whether the subtree at node can hold a word between minLen
+// and maxLen bytes long.
+func (node *Node) hasLength(minLen, maxLen int) bool {
+	return node.lenHi > 0 && int(node.lenHi)-1 >= minLen && int(node.lenLo) <= maxLen
+}
+
+// noteLength adds the length of word, which must be in t, to the nodes on its
+// path.
+func (t *Tree) noteLength(word string) {
+	t.noteLengthBelow(t.root, 0, word)
+}
+
+// noteLengthBelow is noteLength for the nodes on the path of word from start,
+// whose path is the first consumed bytes of word, down.
+func (t *Tree) noteLengthBelow(start *Node, consumed int, word string) {
+	cur := start
+	cur.addLength(len(word))
+	for consumed < len(word) {
+		cur = cur.children[word[consumed]]
+		cur.addLength(len(word))
+		consumed += len(cur.label)
+	}
+}
+
+// computeLengths sets the word lengths of node, whose path from the root is
+// depth bytes long, and everything below it from scratch.
+func computeLengths(node *Node, depth int) {
+	node.lenLo, node.lenHi = 0, 0
+	if node.isWord {
+		node.addLength(depth)
+	}
+	for _, child := range node.children {
+		computeLengths(child, depth+len(child.label))
+		node.mergeLengths(child)
+	}
+}
+
+// mergeLengths widens the range of word lengths of node to include those of
+// child.
+func (node *Node) mergeLengths(child *Node) {
+	if child.lenHi == 0 {
+		return
+	}
+	node.addLength(int(child.lenLo))
+	node.addLength(int(child.lenHi) - 1)
+}
+
+// refreshLengths recomputes the word lengths of the nodes on path, which must
+// lead to a node in t, from their children. It is for use after the subtree at
+// the end of path has changed.
+func (t *Tree) refreshLengths(path string) {
+	nodes := []*Node{t.root}
+	for consumed := 0; consumed < len(path); {
+		cur := nodes[len(nodes)-1].children[path[consumed]]
+		nodes = append(nodes, cur)
+		consumed += len(cur.label)
+	}
+
+	depth := len(path)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		node.lenLo, node.lenHi = 0, 0
+		if node.isWord {
+			node.addLength(depth)
+		}
+		for _, child := range node.children {
+			node.mergeLengths(child)
+		}
+		depth -= len(node.label)
+	}
+}
+
+// FindWordsWithPrefixAndLength returns the words in t that start with prefix
+// and are between minLen and maxLen bytes long, inclusive, in the same order as
+// FindWordsWithPrefix. Subtrees whose words are all too short or too long are
+// skipped without being visited, which makes this much faster than filtering
+// the output of FindWordsWithPrefix when few words qualify.
+func (t *Tree) FindWordsWithPrefixAndLength(prefix string, minLen, maxLen int) []string {
+	var words []string
+	node, path := t.descend(prefix)
+	if node != nil && node.hasLength(minLen, maxLen) {
+		t.traverse(node, path, traversal{
+			enter: func(node *Node, _ []byte, _ int) step {
+				if !node.hasLength(minLen, maxLen) {
+					return stepOver
+				}
+				return stepInto
+			},
+			visit: func(word string, _ *Node, _ int) step {
+				if len(word) >= minLen && len(word) <= maxLen {
+					words = append(words, word)
+				}
+				return stepInto
+			},
+		})
+	}
+	return words
+}
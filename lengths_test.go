@@ -0,0 +1,88 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// checkLengths fails t if any node's word lengths are not what computeLengths
+// would give.
+func checkLengths(t *testing.T, tree *Tree) {
+	t.Helper()
+	var walk func(node *Node, path string) (lo, hi uint32)
+	walk = func(node *Node, path string) (lo, hi uint32) {
+		if node.isWord {
+			lo, hi = uint32(len(path)), uint32(len(path))+1
+		}
+		for _, child := range node.children {
+			clo, chi := walk(child, path+child.label)
+			if chi > 0 && (hi == 0 || clo < lo) {
+				lo = clo
+			}
+			hi = max(hi, chi)
+		}
+		if node.lenLo != lo && hi > 0 || node.lenHi != hi {
+			t.Errorf("Node %q: expected lengths [%d, %d), got [%d, %d)", path, lo, hi, node.lenLo, node.lenHi)
+		}
+		return lo, hi
+	}
+	walk(tree.root, "")
+}
+
+func TestFindWordsWithPrefixAndLength(t *testing.T) {
+	tree := NewTree()
+	words := append(slices.Clone(frozenWords), "r", "ro", "rom", "")
+	for _, word := range words {
+		tree.Insert(word)
+	}
+	checkLengths(t, tree)
+
+	for _, prefix := range []string{"", "r", "rom", "ru", "t", "x"} {
+		for minLen := range 12 {
+			for maxLen := minLen - 1; maxLen < 12; maxLen++ {
+				var expected []string
+				for _, word := range tree.FindWordsWithPrefix(prefix) {
+					if len(word) >= minLen && len(word) <= maxLen {
+						expected = append(expected, word)
+					}
+				}
+				actual := tree.FindWordsWithPrefixAndLength(prefix, minLen, maxLen)
+				if !slices.Equal(actual, expected) {
+					t.Errorf("FindWordsWithPrefixAndLength(%q, %d, %d): expected %v, got %v", prefix, minLen, maxLen, expected, actual)
+				}
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	tree.Serialize(buf)
+	decoded, err := DeserializeTreeFiltered(buf, []string{"ru", "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLengths(t, decoded)
+}
+
+func TestLengthsAfterApplyDirty(t *testing.T) {
+	tree := NewTree(WithDirtyTracking())
+	replica := NewTree()
+	for i := range 50 {
+		word := strings.Repeat("ab", i%7) + fmt.Sprint(i)
+		tree.Insert(word)
+		replica.Insert(word)
+	}
+	tree.MarkClean()
+	for _, word := range []string{"ab", "ababababababababab", "x", "abab9"} {
+		tree.Insert(word)
+	}
+	buf := &bytes.Buffer{}
+	tree.SerializeDirty(buf)
+	if err := replica.ApplyDirty(buf); err != nil {
+		t.Fatal(err)
+	}
+	checkLengths(t, tree)
+	checkLengths(t, replica)
+}
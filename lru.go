@@ -0,0 +1,113 @@
+package compressedtrie
+
+import "container/list"
+
+// lruWordOverhead is the estimate of the bytes a word in an LRUTree takes
+// beyond its own bytes: its node, child map entry, list element and index
+// entry.
+const lruWordOverhead = 160
+
+// LRUTree is a tree with a budget on its size that evicts the least recently
+// used words to stay within it, such as a prefix searchable cache of recent
+// queries. Evicting a word deletes it from the tree, so nodes that only led to
+// it are removed and their neighbours merged again.
+type LRUTree struct {
+	tree     *Tree
+	order    *list.List // words, most recently used first
+	index    map[string]*list.Element
+	maxWords int
+	maxBytes int
+	bytes    int
+}
+
+// NewLRUTree returns an empty LRUTree that holds at most maxWords words and
+// about maxBytes bytes, either is unbounded if <= 0. The bytes of a word are
+// estimated as its length plus a fixed overhead, see Bytes. The options apply
+// to the Tree that holds the words.
+func NewLRUTree(maxWords, maxBytes int, opts ...Option) *LRUTree {
+	return &LRUTree{
+		tree:     NewTree(opts...),
+		order:    list.New(),
+		index:    make(map[string]*list.Element),
+		maxWords: maxWords,
+		maxBytes: maxBytes,
+	}
+}
+
+// Insert adds word to c, or marks it as just used if it is already there, and
+// then evicts the least recently used words until c is within its budget. A
+// word too big for the byte budget on its own is evicted straight away. It
+// returns the same errors as Tree.Insert.
+func (c *LRUTree) Insert(word string) error {
+	if e, ok := c.index[word]; ok {
+		c.order.MoveToFront(e)
+		return nil
+	}
+	if err := c.tree.Insert(word); err != nil {
+		return err
+	}
+	word = c.tree.copied(word)
+	c.index[word] = c.order.PushFront(word)
+	c.bytes += len(word) + lruWordOverhead
+	for c.over() {
+		c.evict(c.order.Back())
+	}
+	return nil
+}
+
+// over reports whether c is over its budget.
+func (c *LRUTree) over() bool {
+	return c.maxWords > 0 && len(c.index) > c.maxWords || c.maxBytes > 0 && c.bytes > c.maxBytes
+}
+
+func (c *LRUTree) evict(e *list.Element) {
+	word := c.order.Remove(e).(string)
+	delete(c.index, word)
+	c.bytes -= len(word) + lruWordOverhead
+	c.tree.Delete(word)
+}
+
+// Contains reports whether word is in c, and if it is marks it as just used.
+func (c *LRUTree) Contains(word string) bool {
+	e, ok := c.index[word]
+	if ok {
+		c.order.MoveToFront(e)
+	}
+	return ok
+}
+
+// Delete removes word from c and reports whether it was there.
+func (c *LRUTree) Delete(word string) bool {
+	e, ok := c.index[word]
+	if ok {
+		c.evict(e)
+	}
+	return ok
+}
+
+// FindWordsWithPrefix returns the words in c that start with prefix, see
+// Tree.FindWordsWithPrefix. It doesn't mark them as used, a prefix search
+// shouldn't keep every word it lists in the cache.
+func (c *LRUTree) FindWordsWithPrefix(prefix string) []string {
+	return c.tree.FindWordsWithPrefix(prefix)
+}
+
+// Len returns the number of words in c.
+func (c *LRUTree) Len() int {
+	return len(c.index)
+}
+
+// Bytes returns the estimated size of the words in c, which the byte budget
+// bounds. Each word counts its length plus a fixed overhead for its node and
+// bookkeeping, so the estimate is close for words that don't share long
+// prefixes and an overestimate for those that do.
+func (c *LRUTree) Bytes() int {
+	return c.bytes
+}
+
+// Tree returns the tree that holds the words of c, for queries that LRUTree
+// doesn't provide. It must not be changed directly, and queries through it
+// don't mark words as used.
+func (c *LRUTree) Tree() *Tree {
+	return c.tree
+}
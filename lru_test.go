@@ -0,0 +1,54 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLRUTree(t *testing.T) {
+	c := NewLRUTree(3, 0)
+	for _, word := range []string{"toast", "toaster", "team"} {
+		c.Insert(word)
+	}
+	c.Contains("toast")
+	c.Insert("tea")
+	// toaster was the least recently used
+	if words := c.FindWordsWithPrefix(""); !slices.Equal(words, []string{"tea", "team", "toast"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+	// The node for toaster is gone and tea/team/toast are as if inserted alone
+	expected := NewTree()
+	for _, word := range []string{"tea", "team", "toast"} {
+		expected.Insert(word)
+	}
+	if asDot(c.Tree()) != asDot(expected) {
+		t.Errorf("Eviction left the tree in a different shape")
+	}
+
+	// Inserting an existing word marks it as used
+	c.Insert("team")
+	c.Insert("x")
+	if words := c.FindWordsWithPrefix(""); !slices.Equal(words, []string{"tea", "team", "x"}) {
+		t.Errorf("Expected toast to be evicted, got %q", words)
+	}
+	if !c.Delete("x") || c.Delete("x") || c.Len() != 2 || c.Tree().WordCount() != 2 {
+		t.Errorf("Unexpected results deleting x")
+	}
+
+	// The byte budget
+	b := NewLRUTree(0, 3*(10+lruWordOverhead))
+	for _, word := range perfWords(100) {
+		b.Insert(word)
+		if b.Bytes() > 3*(10+lruWordOverhead) {
+			t.Fatalf("Over budget with %d bytes", b.Bytes())
+		}
+	}
+	if b.Len() < 3 || b.Tree().WordCount() != b.Len() {
+		t.Errorf("Expected at least 3 words, got %d in a tree of %d", b.Len(), b.Tree().WordCount())
+	}
+	huge := NewLRUTree(0, 10)
+	huge.Insert("abc")
+	if huge.Len() != 0 || huge.Bytes() != 0 || huge.Tree().NodeCount() != 1 {
+		t.Errorf("Expected a word too big for the budget to be evicted")
+	}
+}
@@ -0,0 +1,75 @@
+package compressedtrie
+
+import "iter"
+
+// A Source tells MergeIterate where a word came from.
+type Source uint8
+
+const (
+	FromTree  Source = 1 << iota // the word is in the tree
+	FromOther                    // the word is in the other stream
+)
+
+// MergeIterate returns an iterator over the union of the words in t and
+// other, in ascending byte order whatever the child order of t, each once and
+// tagged with where it was found, FromTree|FromOther for a word in both. other
+// must yield its words in ascending order, repeats are merged, and it is read
+// as the iteration proceeds, so a new dictionary can be built from an old tree
+// and a sorted file of additions without holding either in a slice. If other
+// is out of order the output is too.
+func MergeIterate(t *Tree, other iter.Seq[string]) iter.Seq2[string, Source] {
+	return func(yield func(string, Source) bool) {
+		next, stop := iter.Pull(other)
+		defer stop()
+		pending, ok := next()
+
+		// emit yields word from the tree after any words of other that come
+		// before it
+		emit := func(word string) bool {
+			for ok && pending < word {
+				if !yield(pending, FromOther) {
+					return false
+				}
+				if ok = skipRepeats(next, &pending); !ok {
+					break
+				}
+			}
+			src := FromTree
+			if ok && pending == word {
+				src |= FromOther
+				ok = skipRepeats(next, &pending)
+			}
+			return yield(word, src)
+		}
+		done := t.traverse(t.root, "", traversal{
+			visit: func(word string, _ *Node, _ int) step {
+				if !emit(word) {
+					return stepStop
+				}
+				return stepInto
+			},
+			keyOrder: true,
+		})
+		if !done {
+			return
+		}
+		for ok {
+			if !yield(pending, FromOther) {
+				return
+			}
+			ok = skipRepeats(next, &pending)
+		}
+	}
+}
+
+// skipRepeats replaces *word with the next word from next that differs from it, and
+// reports whether there was one.
+func skipRepeats(next func() (string, bool), word *string) bool {
+	for {
+		w, ok := next()
+		if !ok || w != *word {
+			*word = w
+			return ok
+		}
+	}
+}
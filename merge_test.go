@@ -0,0 +1,76 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeIterate(t *testing.T) {
+	tree := NewTree(WithChildOrder(func(a, b string) int { return len(b) - len(a) }))
+	for _, word := range []string{"beta", "delta", "", "alpha", "alphabet"} {
+		tree.Insert(word)
+	}
+	other := []string{"aardvark", "alpha", "alpha", "bet", "delta", "epsilon", "zeta"}
+
+	type tagged struct {
+		Word string
+		Src  Source
+	}
+	var merged []tagged
+	for word, src := range MergeIterate(tree, slices.Values(other)) {
+		merged = append(merged, tagged{word, src})
+	}
+	expected := []tagged{
+		{"", FromTree},
+		{"aardvark", FromOther},
+		{"alpha", FromTree | FromOther},
+		{"alphabet", FromTree},
+		{"bet", FromOther},
+		{"beta", FromTree},
+		{"delta", FromTree | FromOther},
+		{"epsilon", FromOther},
+		{"zeta", FromOther},
+	}
+	if !slices.Equal(merged, expected) {
+		t.Errorf("Expected %v, got %v", expected, merged)
+	}
+
+	// The union of big inputs is sorted and complete
+	big := perfTree(2000)
+	extra := slices.Sorted(slices.Values(perfWords(3000)[1000:]))
+	union := NewTree()
+	for _, word := range extra {
+		union.Insert(word)
+	}
+	union = Union(big, union)
+	var words []string
+	for word, src := range MergeIterate(big, slices.Values(extra)) {
+		if src&FromTree != 0 != big.Contains(word) || src&FromOther != 0 != slices.Contains(extra, word) {
+			t.Fatalf("%q has the wrong source %d", word, src)
+		}
+		words = append(words, word)
+	}
+	if !slices.IsSorted(words) || len(words) != union.WordCount() {
+		t.Errorf("Expected the %d sorted words of the union, got %d", union.WordCount(), len(words))
+	}
+
+	// Stopping early stops reading other, the repeat of alpha and bet are
+	// read to move past alpha
+	read := 0
+	counted := func(yield func(string) bool) {
+		for _, word := range other {
+			read++
+			if !yield(word) {
+				return
+			}
+		}
+	}
+	for word := range MergeIterate(tree, counted) {
+		if word == "alpha" {
+			break
+		}
+	}
+	if read != 4 {
+		t.Errorf("Expected to read 4 words of other, read %d", read)
+	}
+}
@@ -0,0 +1,112 @@
+package compressedtrie
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// WithMissCache makes t remember up to n recently queried prefixes that no word
+// in t starts with, so that repeating a query for one of them returns straight
+// away instead of descending the tree. The least recently used prefix is
+// forgotten when the cache is full. Insert evicts the prefixes of the word it
+// adds. A value of 0 disables the cache.
+//
+// The cache is safe for concurrent queries, but as always concurrent queries
+// must not overlap with an Insert.
+func WithMissCache(n int) Option {
+	return func(t *Tree) {
+		if n > 0 {
+			t.misses = newMissCache(n)
+		} else {
+			t.misses = nil
+		}
+	}
+}
+
+// missCache is an LRU set of prefixes.
+type missCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List               // most recently used at the front
+	entries map[string]*list.Element // the elements of order by prefix
+}
+
+func newMissCache(size int) *missCache {
+	return &missCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// contains reports whether prefix is a known miss, marking it as recently used.
+func (c *missCache) contains(prefix string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[prefix]
+	if ok {
+		c.order.MoveToFront(e)
+	}
+	return ok
+}
+
+// add records prefix as a miss.
+func (c *missCache) add(prefix string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[prefix]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	// The prefix may be a slice of a much larger string
+	prefix = strings.Clone(prefix)
+	c.entries[prefix] = c.order.PushFront(prefix)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(string))
+	}
+}
+
+// invalidate forgets the prefixes of word, which are no longer misses once word
+// has been inserted.
+func (c *missCache) invalidate(word string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) == 0 {
+		return
+	}
+	for i := range len(word) + 1 {
+		if e, ok := c.entries[word[:i]]; ok {
+			c.order.Remove(e)
+			delete(c.entries, word[:i])
+		}
+	}
+}
+
+// clear forgets every prefix.
+func (c *missCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+// len returns the number of prefixes in the cache.
+func (c *missCache) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,50 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMissCache(t *testing.T) {
+	tree := NewTree(WithMissCache(2))
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+
+	for _, prefix := range []string{"x", "romx", "rom", "ro"} {
+		tree.FindWordsWithPrefix(prefix)
+	}
+	if n := tree.misses.len(); n != 2 {
+		t.Fatalf("Expected 2 cached misses, got %d", n)
+	}
+	if !tree.misses.contains("x") || !tree.misses.contains("romx") {
+		t.Errorf("Expected x and romx to be cached")
+	}
+
+	// Contains only caches words that no word starts with
+	tree.Contains("roman")
+	tree.Contains("xylophone")
+	if tree.misses.contains("roman") || !tree.misses.contains("xylophone") {
+		t.Errorf("Expected only xylophone to be cached")
+	}
+	if tree.misses.contains("x") {
+		t.Errorf("Expected x to have been evicted")
+	}
+
+	// Inserting a word forgets its prefixes
+	tree.FindWordsWithPrefix("xy")
+	tree.Insert("xylophone")
+	if tree.misses.len() != 0 {
+		t.Errorf("Expected the cache to be empty, it has %d entries", tree.misses.len())
+	}
+	if actual := tree.FindWordsWithPrefix("xy"); !slices.Equal(actual, []string{"xylophone"}) {
+		t.Errorf("Expected [xylophone], got %v", actual)
+	}
+	if !tree.Contains("xylophone") {
+		t.Errorf("Expected xylophone to be found")
+	}
+
+	if NewTree(WithMissCache(0)).misses != nil {
+		t.Errorf("Expected WithMissCache(0) to disable the cache")
+	}
+}
@@ -0,0 +1,157 @@
+package compressedtrie
+
+import (
+	"io"
+	"iter"
+	"slices"
+)
+
+// MultiMap stores a list of values for each key, such as the postings of the
+// terms of an inverted index, in the word nodes of a Tree. Keys can then be
+// looked up and listed by prefix along with their values.
+type MultiMap[V any] struct {
+	tree *Tree
+}
+
+// NewMultiMap returns an empty MultiMap, the options apply to the Tree that
+// holds the keys.
+func NewMultiMap[V any](opts ...Option) *MultiMap[V] {
+	return &MultiMap[V]{tree: NewTree(opts...)}
+}
+
+// Append adds v to the end of the values of key, adding key if it isn't in m.
+// If key already has values the DuplicatePolicy of m's tree decides what
+// happens to v, see WithDuplicatePolicy, and the append is counted by
+// WithDuplicateReport whatever the policy. It returns the same errors as
+// Tree.Insert, and one matching ErrDuplicateKey for DuplicateError.
+func (m *MultiMap[V]) Append(key string, v V) error {
+	replace := false
+	if node := m.tree.nodeAt(key); node == nil || !node.isWord {
+		if err := m.tree.Insert(key); err != nil {
+			return err
+		}
+	} else {
+		if len(nodeValues[V](node)) > 0 {
+			// The policy may drop v, the duplicate is reported either way
+			m.tree.dups.add(key)
+			keep, r, err := m.tree.duplicate(key)
+			if !keep {
+				return err
+			}
+			replace = r
+		}
+		// key is already in the tree, only its values change
+		m.tree.own(key)
+	}
+	node := m.tree.nodeAt(key)
+	values, ok := node.value.(*[]V)
+	if !ok || replace {
+		values = new([]V)
+		node.value = values
+	}
+	*values = append(*values, v)
+	return nil
+}
+
+// Values returns the values of key in the order they were appended, or nil if
+// key isn't in m. The slice belongs to m, appending to it is safe but changing
+// its elements changes m.
+func (m *MultiMap[V]) Values(key string) []V {
+	node := m.tree.nodeAt(key)
+	if node == nil || !node.isWord {
+		return nil
+	}
+	return nodeValues[V](node)
+}
+
+// nodeValues returns the values stored at node.
+func nodeValues[V any](node *Node) []V {
+	if values, ok := node.value.(*[]V); ok {
+		return slices.Clip(*values)
+	}
+	return nil
+}
+
+// setValues replaces the values of key, which must be in m, with values.
+func (m *MultiMap[V]) setValues(key string, values []V) {
+	m.tree.own(key)
+	m.tree.nodeAt(key).value = &values
+}
+
+// Delete removes key and its values from m, and reports whether it was there.
+func (m *MultiMap[V]) Delete(key string) bool {
+	return m.tree.Delete(key)
+}
+
+// All returns an iterator over the keys in m that start with prefix and their
+// values, in the same order as Tree.FindWordsWithPrefix.
+func (m *MultiMap[V]) All(prefix string) iter.Seq2[string, []V] {
+	return func(yield func(string, []V) bool) {
+		if node, path := m.tree.descend(prefix); node != nil {
+			m.tree.yieldWords(node, path, func(key string, node *Node) bool {
+				return yield(key, nodeValues[V](node))
+			})
+		}
+	}
+}
+
+// Len returns the number of keys in m.
+func (m *MultiMap[V]) Len() int {
+	return m.tree.WordCount()
+}
+
+// Tree returns the tree that holds the keys of m, for queries that MultiMap
+// doesn't provide. Keys inserted directly into it have no values.
+func (m *MultiMap[V]) Tree() *Tree {
+	return m.tree
+}
+
+// A ValueCodec converts the values of a key to and from bytes, for writing a
+// MultiMap in the serialized tree format. Marshal is only called for keys with
+// at least one value.
+type ValueCodec[V any] struct {
+	Marshal   func(values []V) ([]byte, error)
+	Unmarshal func(data []byte) ([]V, error)
+}
+
+// Serialize writes m in the serialized tree format with the values of each key
+// encoded by codec.Marshal. The keys and values travel in one file that
+// DeserializeMultiMap reads back. Readers of plain trees, such as
+// DeserializeTree and FrozenTree, can also read it and skip the values.
+func (m *MultiMap[V]) Serialize(w io.Writer, codec ValueCodec[V]) error {
+	return m.SerializeWithOptions(w, SerializeOptions{}, codec)
+}
+
+// SerializeWithOptions is Serialize with control over how the tree is encoded,
+// see Tree.SerializeWithOptions.
+func (m *MultiMap[V]) SerializeWithOptions(w io.Writer, opts SerializeOptions, codec ValueCodec[V]) error {
+	opts.values = func(value any) ([]byte, error) {
+		return codec.Marshal(*value.(*[]V))
+	}
+	return m.tree.SerializeWithOptions(w, opts)
+}
+
+// DeserializeMultiMap reads a MultiMap written by Serialize, decoding the values
+// of each key with codec.Unmarshal. It returns the same errors as
+// DeserializeTree, with an error from codec wrapped in a *FormatError. A file
+// without values, such as one written by Tree.Serialize, gives a MultiMap whose
+// keys have none.
+func DeserializeMultiMap[V any](r io.Reader, codec ValueCodec[V]) (*MultiMap[V], error) {
+	return DeserializeMultiMapWithOptions(r, DeserializeOptions{}, codec)
+}
+
+// DeserializeMultiMapWithOptions is DeserializeMultiMap configured by opts.
+func DeserializeMultiMapWithOptions[V any](r io.Reader, opts DeserializeOptions, codec ValueCodec[V]) (*MultiMap[V], error) {
+	opts.values = func(data []byte) (any, error) {
+		values, err := codec.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return &values, nil
+	}
+	tree, err := DeserializeTreeWithOptions(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiMap[V]{tree: tree}, nil
+}
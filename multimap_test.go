@@ -0,0 +1,160 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMultiMap(t *testing.T) {
+	docs := []string{
+		"the quick brown fox",
+		"the lazy dog",
+		"quick thinking",
+		"a quick brown dog",
+	}
+	index := NewMultiMap[int]()
+	for doc, text := range docs {
+		for term := range strings.FieldsSeq(text) {
+			index.Append(term, doc)
+		}
+	}
+
+	if postings := index.Values("quick"); !slices.Equal(postings, []int{0, 2, 3}) {
+		t.Errorf("Expected quick in docs [0 2 3], got %v", postings)
+	}
+	if postings := index.Values("qui"); postings != nil {
+		t.Errorf("Expected no postings for a prefix, got %v", postings)
+	}
+	if index.Len() != 8 {
+		t.Errorf("Expected 8 terms, got %d", index.Len())
+	}
+
+	// Terms are listed by prefix, appending to the returned slice doesn't
+	// change the map
+	got := maps.Collect(index.All("th"))
+	expected := map[string][]int{"the": {0, 1}, "thinking": {2}}
+	if !maps.EqualFunc(got, expected, slices.Equal) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	_ = append(got["the"], 9)
+	if postings := index.Values("the"); !slices.Equal(postings, []int{0, 1}) {
+		t.Errorf("Appending to a result changed the map, got %v", postings)
+	}
+
+	// Values follow their key when a delete merges nodes. "the" and
+	// "thinking" share the node "th", deleting "thinking" merges it into
+	// "the".
+	if !index.Delete("thinking") || index.Values("thinking") != nil {
+		t.Errorf("Expected thinking to be deleted")
+	}
+	if postings := index.Values("the"); !slices.Equal(postings, []int{0, 1}) {
+		t.Errorf("Expected the in docs [0 1] after a merge, got %v", postings)
+	}
+	index.Append("thinking", 5)
+	if postings := index.Values("thinking"); !slices.Equal(postings, []int{5}) {
+		t.Errorf("Expected a reinserted key to start with no postings, got %v", postings)
+	}
+
+	limited := NewMultiMap[string](WithMaxWordLength(3))
+	if err := limited.Append("long", "x"); !errors.Is(err, ErrWordTooLong) {
+		t.Errorf("Expected ErrWordTooLong, got %v", err)
+	}
+}
+
+// postingsCodec encodes ascending document numbers as uvarint deltas.
+var postingsCodec = ValueCodec[int]{
+	Marshal: func(docs []int) ([]byte, error) {
+		var b []byte
+		prev := 0
+		for _, doc := range docs {
+			b = binary.AppendUvarint(b, uint64(doc-prev))
+			prev = doc
+		}
+		return b, nil
+	},
+	Unmarshal: func(data []byte) ([]int, error) {
+		var docs []int
+		prev := 0
+		for len(data) > 0 {
+			d, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errBadPostings
+			}
+			prev += int(d)
+			docs = append(docs, prev)
+			data = data[n:]
+		}
+		return docs, nil
+	},
+}
+
+var errBadPostings = errors.New("bad postings")
+
+func TestMultiMapSerialize(t *testing.T) {
+	index := NewMultiMap[int]()
+	for doc, word := range perfWords(2000) {
+		index.Append(word, doc)
+		index.Append(word[:len(word)/2+1], doc)
+	}
+	// A key without values
+	index.Tree().Insert("zzz")
+
+	for _, opts := range []SerializeOptions{{}, {LabelDictionary: true}, {Layout: BreadthFirst}} {
+		buf := &bytes.Buffer{}
+		if err := index.SerializeWithOptions(buf, opts, postingsCodec); err != nil {
+			t.Fatal(err)
+		}
+		data := bytes.Clone(buf.Bytes())
+
+		loaded, err := DeserializeMultiMap(buf, postingsCodec)
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		if loaded.Len() != index.Len() {
+			t.Errorf("%+v: expected %d keys, got %d", opts, index.Len(), loaded.Len())
+		}
+		for key, postings := range index.All("") {
+			if got := loaded.Values(key); !slices.Equal(got, postings) {
+				t.Fatalf("%+v: %q: expected %v, got %v", opts, key, postings, got)
+			}
+		}
+
+		// Plain trees skip the values
+		tree, err := DeserializeTree(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		if asDot(tree) != asDot(index.Tree()) {
+			t.Errorf("%+v: plain tree differs", opts)
+		}
+		frozen, err := AttachFrozen(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := frozen.Verify(); err != nil {
+			t.Errorf("%+v: %v", opts, err)
+		}
+		if got, expected := frozen.FindWordsWithPrefix("a"), index.Tree().FindWordsWithPrefix("a"); !slices.Equal(got, expected) {
+			t.Errorf("%+v: frozen tree found %d words, expected %d", opts, len(got), len(expected))
+		}
+	}
+
+	// Codec errors are reported as format errors
+	buf := &bytes.Buffer{}
+	index.Serialize(buf, postingsCodec)
+	failing := ValueCodec[int]{Unmarshal: func([]byte) ([]int, error) { return nil, errBadPostings }}
+	_, err := DeserializeMultiMap(buf, failing)
+	var fe *FormatError
+	if !errors.As(err, &fe) || !errors.Is(err, errBadPostings) {
+		t.Errorf("Expected a FormatError wrapping the codec error, got %v", err)
+	}
+	failing.Marshal = func([]int) ([]byte, error) { return nil, errBadPostings }
+	if err := index.Serialize(&bytes.Buffer{}, failing); !errors.Is(err, errBadPostings) {
+		t.Errorf("Expected the codec error, got %v", err)
+	}
+}
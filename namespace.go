@@ -0,0 +1,127 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// NamespaceSeparator separates the tenant from the key in the words of the tree
+// returned by Namespaced.Tree, a tenant can't contain it.
+const NamespaceSeparator byte = 0
+
+// Namespaced holds the keys of many tenants, for multi-tenant services that
+// would otherwise prefix every key with a tenant ID by hand. Each tenant has
+// its own Tree, so dropping a tenant doesn't touch the others' keys and takes
+// the same time however many keys it had. The zero value is not usable, create
+// one with NewNamespaced.
+type Namespaced struct {
+	opts    []Option
+	tenants map[string]*Tree
+}
+
+// NewNamespaced returns an empty Namespaced. The options apply to the tree of
+// each tenant, so WithMaxWordLength and WithMaxDepth limit keys without
+// counting the tenant.
+func NewNamespaced(opts ...Option) *Namespaced {
+	return &Namespaced{opts: opts, tenants: make(map[string]*Tree)}
+}
+
+// Insert adds key to the keys of tenant, see Tree.Insert.
+func (n *Namespaced) Insert(tenant, key string) error {
+	if strings.IndexByte(tenant, NamespaceSeparator) >= 0 {
+		return fmt.Errorf("compressedtrie: tenant %q contains the namespace separator", tenant)
+	}
+	t, ok := n.tenants[tenant]
+	if !ok {
+		t = NewTree(n.opts...)
+	}
+	if err := t.Insert(key); err != nil {
+		return err
+	}
+	n.tenants[tenant] = t
+	return nil
+}
+
+// Delete removes key from the keys of tenant and reports whether it was there.
+// A tenant left without keys is dropped.
+func (n *Namespaced) Delete(tenant, key string) bool {
+	t, ok := n.tenants[tenant]
+	if !ok || !t.Delete(key) {
+		return false
+	}
+	if t.WordCount() == 0 {
+		delete(n.tenants, tenant)
+	}
+	return true
+}
+
+// Contains reports whether key is one of the keys of tenant.
+func (n *Namespaced) Contains(tenant, key string) bool {
+	t, ok := n.tenants[tenant]
+	return ok && t.Contains(key)
+}
+
+// FindWordsWithPrefix returns the keys of tenant that start with prefix, see
+// Tree.FindWordsWithPrefix.
+func (n *Namespaced) FindWordsWithPrefix(tenant, prefix string) []string {
+	if t, ok := n.tenants[tenant]; ok {
+		return t.FindWordsWithPrefix(prefix)
+	}
+	return nil
+}
+
+// DropNamespace removes every key of tenant and returns how many there were.
+func (n *Namespaced) DropNamespace(tenant string) int {
+	t, ok := n.tenants[tenant]
+	if !ok {
+		return 0
+	}
+	delete(n.tenants, tenant)
+	return t.WordCount()
+}
+
+// WordCount returns the number of keys tenant has.
+func (n *Namespaced) WordCount(tenant string) int {
+	if t, ok := n.tenants[tenant]; ok {
+		return t.WordCount()
+	}
+	return 0
+}
+
+// Tenants returns the tenants that have keys, in ascending order.
+func (n *Namespaced) Tenants() []string {
+	return slices.Sorted(maps.Keys(n.tenants))
+}
+
+// Tree returns a copy of n as a single Tree, for serializing or freezing it.
+// Its words are each tenant, NamespaceSeparator and then the key, so the
+// tenant's keys are the words with the prefix tenant+"\x00". The copy has the
+// limits and child order given to NewNamespaced, applied to the whole word.
+func (n *Namespaced) Tree() *Tree {
+	t := NewTree()
+	tenants := n.Tenants()
+	for _, tenant := range tenants {
+		t.Insert(tenant + string(NamespaceSeparator))
+	}
+
+	// Each tenant is now a node with no children, graft a copy of the
+	// tenant's tree onto it.
+	for _, tenant := range tenants {
+		path := tenant + string(NamespaceSeparator)
+		node, _ := t.descend(path)
+		c := cloneNode(n.tenants[tenant].root)
+		node.children = c.children
+		node.isWord = c.isWord
+		node.value = c.value
+		if !node.isWord && len(node.children) == 1 {
+			t.mergeChild(node, path, false)
+		}
+	}
+	t.recount()
+	computeLengths(t.root, 0)
+	cfg := NewTree(n.opts...)
+	t.maxWordLen, t.maxDepth, t.order = cfg.maxWordLen, cfg.maxDepth, cfg.order
+	return t
+}
@@ -0,0 +1,75 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestNamespaced(t *testing.T) {
+	n := NewNamespaced(WithMaxWordLength(8))
+	keys := map[string][]string{
+		"acme":  {"alpha", "beta", ""},
+		"ac":    {"alpha", "gamma"},
+		"zeta9": {"delta"},
+	}
+	for tenant, ks := range keys {
+		for _, key := range ks {
+			if err := n.Insert(tenant, key); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := n.Insert("acme", "toolongkey"); err == nil {
+		t.Errorf("Expected the per tenant limit to apply")
+	}
+	if err := n.Insert("a\x00b", "key"); err == nil {
+		t.Errorf("Expected an error for a tenant containing the separator")
+	}
+
+	if tenants := n.Tenants(); !slices.Equal(tenants, []string{"ac", "acme", "zeta9"}) {
+		t.Errorf("Unexpected tenants %q", tenants)
+	}
+	if n.WordCount("acme") != 3 || n.WordCount("ac") != 2 || n.WordCount("none") != 0 {
+		t.Errorf("Unexpected counts %d %d", n.WordCount("acme"), n.WordCount("ac"))
+	}
+	if !n.Contains("ac", "gamma") || n.Contains("acme", "gamma") || !n.Contains("acme", "") {
+		t.Errorf("Keys leaked between tenants")
+	}
+	if words := n.FindWordsWithPrefix("ac", "a"); !slices.Equal(words, []string{"alpha"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+
+	// The combined tree prefixes each key with its tenant
+	tree := n.Tree()
+	expected := []string{"ac\x00alpha", "ac\x00gamma", "acme\x00", "acme\x00alpha", "acme\x00beta", "zeta9\x00delta"}
+	if words := tree.FindWordsWithPrefix(""); !slices.Equal(words, expected) {
+		t.Errorf("Expected %q, got %q", expected, words)
+	}
+	rebuilt := NewTree()
+	for _, word := range expected {
+		rebuilt.Insert(word)
+	}
+	var a, b bytes.Buffer
+	tree.Serialize(&a)
+	rebuilt.Serialize(&b)
+	if tree.WordCount() != 6 || tree.NodeCount() != rebuilt.NodeCount() || !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Errorf("Combined tree differs from inserting the prefixed words")
+	}
+
+	if dropped := n.DropNamespace("acme"); dropped != 3 {
+		t.Errorf("Expected to drop 3 keys, dropped %d", dropped)
+	}
+	if n.DropNamespace("acme") != 0 || n.Contains("acme", "alpha") || !n.Contains("ac", "alpha") {
+		t.Errorf("Dropping a tenant affected the wrong keys")
+	}
+	if !n.Delete("zeta9", "delta") || n.Delete("zeta9", "delta") {
+		t.Errorf("Unexpected Delete results")
+	}
+	if tenants := n.Tenants(); !slices.Equal(tenants, []string{"ac"}) {
+		t.Errorf("Unexpected tenants %q", tenants)
+	}
+	if !tree.Contains("acme\x00beta") {
+		t.Errorf("Combined tree changed with n")
+	}
+}
@@ -0,0 +1,244 @@
+package compressedtrie
+
+import (
+	"iter"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Normalizer maps a word or query to the form it is stored and searched in,
+// for example folding case and accents so that "Café" and "cafe" complete the
+// same way. Any func(string) string will do, such as norm.NFKC.String from
+// golang.org/x/text/unicode/norm for full Unicode compatibility
+// normalization.
+type Normalizer func(string) string
+
+// Chain returns a Normalizer that applies stages in order, e.g.
+//
+//	Chain(Lowercase, Normalizer(norm.NFKC.String), StripDiacritics)
+func Chain(stages ...Normalizer) Normalizer {
+	return func(s string) string {
+		for _, stage := range stages {
+			s = stage(s)
+		}
+		return s
+	}
+}
+
+// Lowercase maps s to lower case.
+func Lowercase(s string) string {
+	return strings.ToLower(s)
+}
+
+// FoldWidth maps the fullwidth forms of ASCII characters, as typed by East
+// Asian input methods, and the ideographic space to ASCII. This is the part of
+// NFKC that matters most for search boxes.
+func FoldWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '！' && r <= '～':
+			return r - '！' + '!'
+		case r == '　':
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// CollapseSpaces trims leading and trailing white space from s and replaces
+// each run of white space inside it with a single space.
+func CollapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Precomposed Latin letters and the letters StripDiacritics maps them to.
+const (
+	accented   = "ÀÁÂÃÄÅàáâãäåÇçÈÉÊËèéêëÌÍÎÏìíîïÑñÒÓÔÕÖØòóôõöøÙÚÛÜùúûüÝýÿĀāĂăĄąĆćĈĉĊċČčĎďĐđĒēĔĕĖėĘęĚěĜĝĞğĠġĢģĤĥĦħĨĩĪīĬĭĮįİıĴĵĶķĹĺĻļĽľĿŀŁłŃńŅņŇňŌōŎŏŐőŔŕŖŗŘřŚśŜŝŞşŠšŢţŤťŦŧŨũŪūŬŭŮůŰűŲųŴŵŶŷŸŹźŻżŽž"
+	unaccented = "AAAAAAaaaaaaCcEEEEeeeeIIIIiiiiNnOOOOOOooooooUUUUuuuuYyyAaAaAaCcCcCcCcDdDdEeEeEeEeEeGgGgGgGgHhHhIiIiIiIiIiJjKkLlLlLlLlLlNnNnNnOoOoOoRrRrRrSsSsSsSsTtTtTtUuUuUuUuUuUuWwYyYZzZzZz"
+)
+
+// unaccent maps each letter of accented to the one in the same place in
+// unaccented.
+var unaccent = func() map[rune]rune {
+	m := make(map[rune]rune)
+	base := []rune(unaccented)
+	for i, r := range []rune(accented) {
+		m[r] = base[i]
+	}
+	return m
+}()
+
+// StripDiacritics removes accents from Latin letters, mapping "é" to "e" and
+// "Ł" to "L", and drops combining marks so that decomposed input is handled
+// too. Other characters are left alone.
+func StripDiacritics(s string) string {
+	return strings.Map(func(r rune) rune {
+		if base, ok := unaccent[r]; ok {
+			return base
+		}
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// NormalizedTree is a Tree whose words and queries are passed through the same
+// Normalizer, so that inserts and lookups always agree on the form of a word.
+// The words it returns are the normalized forms, unless it was created with
+// NewPreservingTree.
+type NormalizedTree struct {
+	tree     *Tree
+	norm     Normalizer
+	preserve bool // whether words are returned in the form first inserted
+}
+
+// NewNormalizedTree returns an empty NormalizedTree that normalizes with n, the
+// options apply to the Tree that holds the words.
+func NewNormalizedTree(n Normalizer, opts ...Option) *NormalizedTree {
+	return &NormalizedTree{tree: NewTree(opts...), norm: n}
+}
+
+// NewPreservingTree is NewNormalizedTree for a tree that matches words by
+// their normalized form but returns them in the form they were first inserted
+// in, such as product names that must be displayed as "iPhone" but completed
+// from "IPH". Inserting another form of a word that is already there doesn't
+// change how it is returned, delete it first to do that. The original forms
+// are the values of the nodes of Tree, so it can't be used as a MultiMap.
+func NewPreservingTree(n Normalizer, opts ...Option) *NormalizedTree {
+	return &NormalizedTree{tree: NewTree(opts...), norm: n, preserve: true}
+}
+
+// Insert adds the normalized form of word, see Tree.Insert.
+func (t *NormalizedTree) Insert(word string) error {
+	key := t.norm(word)
+	if err := t.tree.Insert(key); err != nil {
+		return err
+	}
+	if node := t.tree.nodeAt(key); t.preserve && node.value == nil {
+		node.value = word
+	}
+	return nil
+}
+
+// Delete removes the normalized form of word, see Tree.Delete.
+func (t *NormalizedTree) Delete(word string) bool {
+	return t.tree.Delete(t.norm(word))
+}
+
+// Contains reports whether the normalized form of word is in t.
+func (t *NormalizedTree) Contains(word string) bool {
+	return t.tree.Contains(t.norm(word))
+}
+
+// FindWordsWithPrefix returns the words in t that start with the normalized
+// form of prefix, in the order of their normalized forms.
+func (t *NormalizedTree) FindWordsWithPrefix(prefix string) []string {
+	if t.preserve {
+		return slices.Collect(t.WordsWithPrefix(prefix))
+	}
+	return t.tree.FindWordsWithPrefix(t.norm(prefix))
+}
+
+// WordsWithPrefix is FindWordsWithPrefix as an iterator, see
+// Tree.WordsWithPrefix.
+func (t *NormalizedTree) WordsWithPrefix(prefix string) iter.Seq[string] {
+	if !t.preserve {
+		return t.tree.WordsWithPrefix(t.norm(prefix))
+	}
+	return func(yield func(string) bool) {
+		if node, path := t.tree.descend(t.norm(prefix)); node != nil {
+			t.tree.yieldWords(node, path, func(key string, node *Node) bool {
+				if word, ok := node.value.(string); ok {
+					return yield(word)
+				}
+				// Inserted into Tree directly
+				return yield(key)
+			})
+		}
+	}
+}
+
+// Query returns the words in t that start with the normalized form of prefix
+// and satisfy opts, in the order of their normalized forms, see Tree.Query.
+// opts.Contains is normalized too.
+func (t *NormalizedTree) Query(prefix string, opts QueryOptions) ([]string, bool) {
+	if opts.Contains != "" {
+		opts.Contains = t.norm(opts.Contains)
+	}
+	words, truncated := t.tree.Query(t.norm(prefix), opts)
+	if t.preserve {
+		for i, key := range words {
+			words[i] = t.original(key)
+		}
+	}
+	return words, truncated
+}
+
+// QueryMatches is Query with the range of each word that matched prefix. For
+// the normalized forms that is the length of the normalized prefix. For the
+// forms kept by NewPreservingTree it is the shortest start of the word that
+// normalizes to something starting with the normalized prefix, and any
+// combining marks after it, so that "IPH" matches "iPh" of "iPhone" and
+// "creme" matches "Crème" of "Crème Brûlée" whatever the byte lengths.
+func (t *NormalizedTree) QueryMatches(prefix string, opts QueryOptions) ([]Match, bool) {
+	if opts.Contains != "" {
+		opts.Contains = t.norm(opts.Contains)
+	}
+	key := t.norm(prefix)
+	matches, truncated := t.tree.QueryMatches(key, opts)
+	if t.preserve {
+		for i, m := range matches {
+			if word := t.original(m.Word); word != m.Word {
+				matches[i] = Match{Word: word, End: t.matchEnd(word, key)}
+			}
+		}
+	}
+	return matches, truncated
+}
+
+// original returns the form key was first inserted in.
+func (t *NormalizedTree) original(key string) string {
+	if word, ok := t.tree.nodeAt(key).value.(string); ok {
+		return word
+	}
+	// Inserted into Tree directly
+	return key
+}
+
+// matchEnd returns the end of the shortest start of word whose normalized form
+// starts with key, extended over the combining marks that follow it. It
+// returns len(word) if there is none, which a Normalizer that isn't
+// consistent between a word and its starts could lead to.
+func (t *NormalizedTree) matchEnd(word, key string) int {
+	if key == "" {
+		return 0
+	}
+	for end := range word {
+		if end > 0 && strings.HasPrefix(t.norm(word[:end]), key) {
+			for end < len(word) {
+				r, size := utf8.DecodeRuneInString(word[end:])
+				if !unicode.In(r, unicode.M) {
+					break
+				}
+				end += size
+			}
+			return end
+		}
+	}
+	return len(word)
+}
+
+// Normalize returns the normalized form of s, for queries that NormalizedTree
+// doesn't provide.
+func (t *NormalizedTree) Normalize(s string) string {
+	return t.norm(s)
+}
+
+// Tree returns the tree that holds the normalized words of t. Words inserted
+// into it directly are not normalized.
+func (t *NormalizedTree) Tree() *Tree {
+	return t.tree
+}
@@ -0,0 +1,128 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizers(t *testing.T) {
+	cases := []struct {
+		Name       string
+		Normalizer Normalizer
+		In, Out    string
+	}{
+		{"Lowercase", Lowercase, "Straße ÉCOLE", "straße école"},
+		{"FoldWidth", FoldWidth, "ＡＢＣ　１２３！～", "ABC 123!~"},
+		{"CollapseSpaces", CollapseSpaces, "  new \t york\n city ", "new york city"},
+		{"StripDiacritics", StripDiacritics, "Crème Brûlée in Łódź, São Paulo", "Creme Brulee in Lodz, Sao Paulo"},
+		// e followed by a combining acute accent
+		{"StripDiacritics decomposed", StripDiacritics, "cafe\u0301", "cafe"},
+		{"StripDiacritics other scripts", StripDiacritics, "東京 Москва", "東京 Москва"},
+		{"Chain", Chain(FoldWidth, Lowercase, StripDiacritics, CollapseSpaces), " ＣＡＦÉ  Olé ", "cafe ole"},
+		{"Empty chain", Chain(), "Café", "Café"},
+	}
+	for _, tc := range cases {
+		if actual := tc.Normalizer(tc.In); actual != tc.Out {
+			t.Errorf("%s(%q): expected %q, got %q", tc.Name, tc.In, tc.Out, actual)
+		}
+	}
+}
+
+func TestNormalizedTree(t *testing.T) {
+	tree := NewNormalizedTree(Chain(Lowercase, StripDiacritics))
+	for _, word := range []string{"Café", "cafeteria", "Crème brûlée", "CREPE"} {
+		tree.Insert(word)
+	}
+	if tree.Tree().WordCount() != 4 {
+		t.Errorf("Expected 4 words, got %d", tree.Tree().WordCount())
+	}
+	tree.Insert("CAFE")
+	if tree.Tree().WordCount() != 4 {
+		t.Errorf("Expected CAFE to be the same word as Café")
+	}
+
+	expected := []string{"cafe", "cafeteria"}
+	if words := tree.FindWordsWithPrefix("CAFÉ"); !slices.Equal(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+	if words := slices.Collect(tree.WordsWithPrefix("Cré")); !slices.Equal(words, []string{"creme brulee", "crepe"}) {
+		t.Errorf("Unexpected words %v", words)
+	}
+	if !tree.Contains("crème BRÛLÉE") || tree.Contains("creme") {
+		t.Errorf("Contains doesn't normalize")
+	}
+	if !tree.Delete("Crêpe") || tree.Contains("crepe") {
+		t.Errorf("Delete doesn't normalize")
+	}
+	if tree.Normalize("Ñandú") != "nandu" {
+		t.Errorf("Unexpected normalized form %q", tree.Normalize("Ñandú"))
+	}
+}
+
+func TestPreservingTree(t *testing.T) {
+	tree := NewPreservingTree(Chain(Lowercase, StripDiacritics))
+	for _, word := range []string{"iPhone", "iPad", "IPHONE", "Crème Brûlée", "iMac"} {
+		tree.Insert(word)
+	}
+	if tree.Tree().WordCount() != 4 {
+		t.Errorf("Expected 4 words, got %d", tree.Tree().WordCount())
+	}
+	// In the order of the normalized forms, as first inserted
+	expected := []string{"iMac", "iPad", "iPhone"}
+	if words := tree.FindWordsWithPrefix("I"); !slices.Equal(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+	if words := tree.FindWordsWithPrefix("CREME"); !slices.Equal(words, []string{"Crème Brûlée"}) {
+		t.Errorf("Unexpected words %v", words)
+	}
+	if !tree.Contains("IPAD") || !tree.Contains("crème brûlée") {
+		t.Errorf("Contains doesn't normalize")
+	}
+
+	// Deleting forgets the original form
+	if !tree.Delete("IPHONE") {
+		t.Errorf("Delete doesn't normalize")
+	}
+	tree.Insert("IPHONE")
+	if words := tree.FindWordsWithPrefix("iph"); !slices.Equal(words, []string{"IPHONE"}) {
+		t.Errorf("Expected the new form, got %v", words)
+	}
+	if words := tree.FindWordsWithPrefix("x"); words != nil {
+		t.Errorf("Expected nothing, got %v", words)
+	}
+}
+
+func TestNormalizedQueryMatches(t *testing.T) {
+	tree := NewPreservingTree(Chain(Lowercase, StripDiacritics))
+	for _, word := range []string{"iPhone", "Crème Brûlée", "Cre\u0300me fraîche", "ÉCLAIR"} {
+		tree.Insert(word)
+	}
+	// The accent of the second crème is a combining mark, which the match
+	// takes in
+	cases := []struct {
+		Prefix   string
+		Expected []Match
+	}{
+		{"IPH", []Match{{"iPhone", 0, 3}}},
+		{"creme", []Match{{"Crème Brûlée", 0, 6}, {"Cre\u0300me fraîche", 0, 7}}},
+		{"CRE", []Match{{"Crème Brûlée", 0, 4}, {"Cre\u0300me fraîche", 0, 5}}},
+		{"e", []Match{{"ÉCLAIR", 0, 2}}},
+		{"", []Match{{"Crème Brûlée", 0, 0}, {"Cre\u0300me fraîche", 0, 0}, {"ÉCLAIR", 0, 0}, {"iPhone", 0, 0}}},
+	}
+	for _, tc := range cases {
+		matches, truncated := tree.QueryMatches(tc.Prefix, QueryOptions{})
+		if truncated || !slices.Equal(matches, tc.Expected) {
+			t.Errorf("%q: expected %q, got %q truncated %v", tc.Prefix, tc.Expected, matches, truncated)
+		}
+	}
+	if words, _ := tree.Query("CRÈME", QueryOptions{Contains: "FRA"}); !slices.Equal(words, []string{"Cre\u0300me fraîche"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+
+	// Without preserving, the words are the normalized forms
+	plain := NewNormalizedTree(Lowercase)
+	plain.Insert("ÉCLAIR")
+	if matches, _ := plain.QueryMatches("ÉC", QueryOptions{}); !slices.Equal(matches, []Match{{"éclair", 0, 3}}) {
+		t.Errorf("Unexpected matches %q", matches)
+	}
+}
@@ -0,0 +1,157 @@
+package compressedtrie
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A PartitionedTree is a tree that is safe for concurrent use, split into 256
+// partitions by the first byte of each word. Every partition is a Tree with
+// its own lock, so writers working on words with different first bytes don't
+// wait for each other, and readers only wait for writers to their partition.
+// Queries that span partitions, such as FindWordsWithPrefix with an empty
+// prefix, lock one partition at a time and so are not a consistent snapshot
+// while writes are in progress.
+type PartitionedTree struct {
+	parts [256]partition
+	empty atomic.Bool // whether the empty word is in the tree
+}
+
+type partition struct {
+	mu   sync.RWMutex
+	tree *Tree
+}
+
+// NewPartitionedTree returns an empty PartitionedTree. The options apply to
+// each partition, WithMaxDepth and WithMaxWordLength limit words the same way
+// they would in a single Tree.
+func NewPartitionedTree(opts ...Option) *PartitionedTree {
+	p := &PartitionedTree{}
+	for i := range p.parts {
+		p.parts[i].tree = NewTree(opts...)
+	}
+	return p
+}
+
+// Insert adds word to p, see Tree.Insert.
+func (p *PartitionedTree) Insert(word string) error {
+	if word == "" {
+		p.empty.Store(true)
+		return nil
+	}
+	part := &p.parts[word[0]]
+	part.mu.Lock()
+	defer part.mu.Unlock()
+	return part.tree.Insert(word)
+}
+
+// Delete removes word from p and reports whether it was there.
+func (p *PartitionedTree) Delete(word string) bool {
+	if word == "" {
+		return p.empty.Swap(false)
+	}
+	part := &p.parts[word[0]]
+	part.mu.Lock()
+	defer part.mu.Unlock()
+	return part.tree.Delete(word)
+}
+
+// Contains reports whether word is in p.
+func (p *PartitionedTree) Contains(word string) bool {
+	if word == "" {
+		return p.empty.Load()
+	}
+	part := &p.parts[word[0]]
+	part.mu.RLock()
+	defer part.mu.RUnlock()
+	return part.tree.Contains(word)
+}
+
+// FindWordsWithPrefix returns the words in p that start with prefix in
+// ascending order, unless the partitions were created with WithChildOrder in
+// which case the partitions are still visited in byte order.
+func (p *PartitionedTree) FindWordsWithPrefix(prefix string) []string {
+	if prefix != "" {
+		part := &p.parts[prefix[0]]
+		part.mu.RLock()
+		defer part.mu.RUnlock()
+		return part.tree.FindWordsWithPrefix(prefix)
+	}
+
+	var words []string
+	if p.empty.Load() {
+		words = append(words, "")
+	}
+	for i := range p.parts {
+		part := &p.parts[i]
+		part.mu.RLock()
+		words = append(words, part.tree.FindWordsWithPrefix("")...)
+		part.mu.RUnlock()
+	}
+	return words
+}
+
+// WordCount returns the number of distinct words in p.
+func (p *PartitionedTree) WordCount() int {
+	n := 0
+	if p.empty.Load() {
+		n++
+	}
+	for i := range p.parts {
+		part := &p.parts[i]
+		part.mu.RLock()
+		n += part.tree.WordCount()
+		part.mu.RUnlock()
+	}
+	return n
+}
+
+// NodeCount returns the number of nodes p would have as a single Tree,
+// including the root.
+func (p *PartitionedTree) NodeCount() int {
+	n := 1
+	for i := range p.parts {
+		part := &p.parts[i]
+		part.mu.RLock()
+		n += part.tree.NodeCount() - 1
+		part.mu.RUnlock()
+	}
+	return n
+}
+
+// Tree returns a copy of p as a single Tree, for serializing or freezing it.
+// The partitions are copied one at a time, see PartitionedTree. The copy has
+// the limits and child order given to NewPartitionedTree.
+func (p *PartitionedTree) Tree() *Tree {
+	t := NewTree()
+	t.root.isWord = p.empty.Load()
+	if t.root.isWord {
+		t.words++
+	}
+	for i := range p.parts {
+		part := &p.parts[i]
+		part.mu.RLock()
+		src := part.tree
+		if child, ok := src.root.children[byte(i)]; ok {
+			t.root.children[byte(i)] = cloneNode(child)
+			t.nodes += src.nodes - 1
+			t.words += src.words
+		}
+		if i == 0 {
+			t.maxWordLen, t.maxDepth, t.order = src.maxWordLen, src.maxDepth, src.order
+		}
+		part.mu.RUnlock()
+	}
+	computeLengths(t.root, 0)
+	return t
+}
+
+// cloneNode returns a deep copy of the subtree at node. Values are shared.
+func cloneNode(node *Node) *Node {
+	c := *node
+	c.children = make(map[byte]*Node, len(node.children))
+	for k, child := range node.children {
+		c.children[k] = cloneNode(child)
+	}
+	return &c
+}
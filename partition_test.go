@@ -0,0 +1,66 @@
+package compressedtrie
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestPartitionedTree(t *testing.T) {
+	words := perfWords(4000)
+	p := NewPartitionedTree()
+
+	// Writers to different partitions, and to the same one, can run at once
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < len(words); i += 8 {
+				if err := p.Insert(words[i]); err != nil {
+					t.Error(err)
+				}
+				p.Contains(words[i/2])
+			}
+		}()
+	}
+	wg.Wait()
+	p.Insert("")
+
+	expected := NewTree()
+	expected.Insert("")
+	for _, word := range words {
+		expected.Insert(word)
+	}
+	if got := p.FindWordsWithPrefix(""); !slices.Equal(got, expected.FindWordsWithPrefix("")) {
+		t.Errorf("Expected %d words, got %d", expected.WordCount(), len(got))
+	}
+	if got := p.FindWordsWithPrefix("ab"); !slices.Equal(got, expected.FindWordsWithPrefix("ab")) {
+		t.Errorf("Expected %v, got %v", expected.FindWordsWithPrefix("ab"), got)
+	}
+	if p.WordCount() != expected.WordCount() || p.NodeCount() != expected.NodeCount() {
+		t.Errorf("Expected %d words and %d nodes, got %d and %d", expected.WordCount(), expected.NodeCount(), p.WordCount(), p.NodeCount())
+	}
+
+	tree := p.Tree()
+	if asDot(tree) != asDot(expected) {
+		t.Errorf("Combined tree differs")
+	}
+	if tree.WordCount() != expected.WordCount() || tree.NodeCount() != expected.NodeCount() {
+		t.Errorf("Combined tree has %d words and %d nodes", tree.WordCount(), tree.NodeCount())
+	}
+	checkLengths(t, tree)
+
+	// The copy is independent of p
+	if !p.Delete(words[0]) || !p.Delete("") || p.Contains(words[0]) || p.Contains("") {
+		t.Errorf("Expected %q and the empty word to be deleted", words[0])
+	}
+	if !tree.Contains(words[0]) || !tree.Contains("") {
+		t.Errorf("Deleting from the partitioned tree changed the copy")
+	}
+
+	limited := NewPartitionedTree(WithMaxWordLength(3))
+	if err := limited.Insert("long"); err != ErrWordTooLong {
+		t.Errorf("Expected ErrWordTooLong, got %v", err)
+	}
+}
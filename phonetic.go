@@ -0,0 +1,126 @@
+package compressedtrie
+
+import "slices"
+
+// An Encoder maps a word to a key under which words that sound alike are
+// grouped together, such as a Soundex or Metaphone code.
+type Encoder interface {
+	Encode(word string) string
+}
+
+// EncoderFunc adapts a function to the Encoder interface.
+type EncoderFunc func(word string) string
+
+func (f EncoderFunc) Encode(word string) string {
+	return f(word)
+}
+
+// Soundex is an Encoder for American Soundex, which codes a word as its first
+// letter followed by three digits for the consonant sounds that follow, e.g.
+// "Robert" and "Rupert" both encode to "R163". Bytes other than ASCII letters
+// are ignored.
+var Soundex Encoder = EncoderFunc(soundex)
+
+// soundexCodes maps lower case letters to their Soundex digit. Vowels are 0,
+// and h and w, which are skipped without separating consonants, are '-'.
+const soundexCodes = "01230120022455012623010202"
+
+func soundex(word string) string {
+	var code [4]byte
+	n := 0
+	var last byte
+	for i := 0; i < len(word) && n < len(code); i++ {
+		c := lower(word[i])
+		if c < 'a' || c > 'z' {
+			continue
+		}
+		digit := soundexCodes[c-'a']
+		if c == 'h' || c == 'w' {
+			digit = '-'
+		}
+		switch {
+		case n == 0:
+			code[0] = c - 'a' + 'A'
+			n++
+		case digit == '-':
+			// Doesn't reset last, so consonants either side of h and w that
+			// share a digit are coded once.
+			continue
+		case digit != '0' && digit != last:
+			code[n] = digit
+			n++
+		}
+		last = digit
+	}
+	if n == 0 {
+		return ""
+	}
+	for ; n < len(code); n++ {
+		code[n] = '0'
+	}
+	return string(code[:])
+}
+
+// PhoneticIndex stores words under their phonetic code so that words can be
+// looked up by how they sound, e.g. for matching names. It is a MultiMap from
+// each code to its words.
+type PhoneticIndex struct {
+	enc   Encoder
+	codes *MultiMap[string] // the words of each code, in byte order
+	words int
+}
+
+// NewPhoneticIndex returns an empty PhoneticIndex that groups words with enc,
+// for example Soundex. The options apply to the tree that holds the codes, so
+// WithMaxWordLength limits the length of a code rather than of a word.
+func NewPhoneticIndex(enc Encoder, opts ...Option) *PhoneticIndex {
+	return &PhoneticIndex{enc: enc, codes: NewMultiMap[string](opts...)}
+}
+
+// Insert adds word to p under its phonetic code. Adding a word that is already
+// in p does nothing.
+func (p *PhoneticIndex) Insert(word string) error {
+	code := p.enc.Encode(word)
+	words := p.codes.Values(code)
+	i, found := slices.BinarySearch(words, word)
+	switch {
+	case found:
+		return nil
+	case len(words) == 0:
+		if err := p.codes.Append(code, word); err != nil {
+			return err
+		}
+	default:
+		p.codes.setValues(code, slices.Insert(words, i, word))
+	}
+	p.words++
+	return nil
+}
+
+// Delete removes word from p, and reports whether it was there.
+func (p *PhoneticIndex) Delete(word string) bool {
+	code := p.enc.Encode(word)
+	words := p.codes.Values(code)
+	i, found := slices.BinarySearch(words, word)
+	switch {
+	case !found:
+		return false
+	case len(words) == 1:
+		p.codes.Delete(code)
+	default:
+		p.codes.setValues(code, slices.Concat(words[:i], words[i+1:]))
+	}
+	p.words--
+	return true
+}
+
+// FindSoundsLike returns the words in p that have the same phonetic code as
+// word, in alphabetical order.
+func (p *PhoneticIndex) FindSoundsLike(word string) []string {
+	return slices.Clone(p.codes.Values(p.enc.Encode(word)))
+}
+
+// Len returns the number of words in p.
+func (p *PhoneticIndex) Len() int {
+	return p.words
+}
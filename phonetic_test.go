@@ -0,0 +1,86 @@
+package compressedtrie
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSoundex(t *testing.T) {
+	cases := []struct {
+		Word     string
+		Expected string
+	}{
+		{"Robert", "R163"},
+		{"Rupert", "R163"},
+		{"Rubin", "R150"},
+		{"Ashcraft", "A261"},
+		{"Tymczak", "T522"},
+		{"Pfister", "P236"},
+		{"Honeyman", "H555"},
+		{"A", "A000"},
+		{"O'Hara", "O600"},
+		{"", ""},
+		{"123", ""},
+	}
+	for _, tc := range cases {
+		if actual := Soundex.Encode(tc.Word); actual != tc.Expected {
+			t.Errorf("Soundex(%q): expected %q, got %q", tc.Word, tc.Expected, actual)
+		}
+	}
+}
+
+func TestPhoneticIndex(t *testing.T) {
+	index := NewPhoneticIndex(Soundex)
+	for _, name := range []string{"Robert", "Rupert", "Rubin", "Smith", "Smyth", "Schmidt"} {
+		if err := index.Insert(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if index.Len() != 6 {
+		t.Errorf("Expected 6 words, got %d", index.Len())
+	}
+
+	cases := []struct {
+		Word     string
+		Expected []string
+	}{
+		{"Rupurt", []string{"Robert", "Rupert"}},
+		{"Smithe", []string{"Schmidt", "Smith", "Smyth"}},
+		{"Rubyn", []string{"Rubin"}},
+		{"Jones", nil},
+	}
+	for _, tc := range cases {
+		if actual := index.FindSoundsLike(tc.Word); !slices.Equal(actual, tc.Expected) {
+			t.Errorf("FindSoundsLike(%q): expected %v, got %v", tc.Word, tc.Expected, actual)
+		}
+	}
+}
+
+func TestPhoneticIndexDelete(t *testing.T) {
+	index := NewPhoneticIndex(Soundex)
+	for _, name := range []string{"Smith", "Smyth", "Robert", "Smith"} {
+		if err := index.Insert(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if index.Len() != 3 {
+		t.Errorf("Expected 3 words after inserting Smith twice, got %d", index.Len())
+	}
+
+	if !index.Delete("Smith") || index.Delete("Smith") || index.Delete("Rupert") {
+		t.Errorf("Expected only the first delete of Smith to succeed")
+	}
+	if words := index.FindSoundsLike("Smithe"); !slices.Equal(words, []string{"Smyth"}) {
+		t.Errorf("Expected [Smyth] after deleting Smith, got %v", words)
+	}
+	if !index.Delete("Robert") || index.FindSoundsLike("Rupert") != nil || index.Len() != 1 {
+		t.Errorf("Expected Robert's code to be gone with its last word, %d words left", index.Len())
+	}
+
+	// The options apply to the codes
+	short := NewPhoneticIndex(EncoderFunc(func(word string) string { return word }), WithMaxWordLength(3))
+	if err := short.Insert("Smith"); !errors.Is(err, ErrWordTooLong) || short.Len() != 0 {
+		t.Errorf("Expected ErrWordTooLong for a code over the limit, got %v", err)
+	}
+}
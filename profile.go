@@ -0,0 +1,147 @@
+package compressedtrie
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// NodeVisits is the number of visits queries have made to a node, and to the
+// nodes of its subtree, in a profile.
+type NodeVisits struct {
+	Path    string // the path of the node from the root
+	Visits  uint64 // visits to the node
+	Subtree uint64 // visits to the node and the nodes below it
+}
+
+// visitCounts are the visits to the nodes of a tree. Queries run concurrently,
+// so the counts are locked.
+type visitCounts struct {
+	mu     sync.Mutex
+	visits map[*Node]uint64
+}
+
+func newVisitCounts() *visitCounts {
+	return &visitCounts{visits: make(map[*Node]uint64)}
+}
+
+// add counts a visit to node.
+func (c *visitCounts) add(node *Node) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.visits[node]++
+	c.mu.Unlock()
+}
+
+// snapshot returns a copy of the counts.
+func (c *visitCounts) snapshot() map[*Node]uint64 {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.visits)
+}
+
+// Profile returns the visits queries have made to the nodes of t since it was
+// created or ResetProfile was called, for the nodes whose subtrees have been
+// visited, parents before children and children in key order. The counts
+// show which subtrees are hot enough to be worth optimizing, such as with a
+// dispatch array or by pinning them in memory.
+//
+// Nothing is counted unless the program is built with the
+// compressedtrie_profile build tag, which makes every tree count the nodes
+// its queries visit at the cost of a locked map update per node:
+//
+//	go build -tags compressedtrie_profile ./cmd/server
+//
+// In other builds the profile is always empty. Nodes removed by Delete, or
+// merged after one, take their counts with them.
+func (t *Tree) Profile() []NodeVisits {
+	visits := t.profile.snapshot()
+	if len(visits) == 0 {
+		return nil
+	}
+	subtree := make(map[*Node]uint64)
+	var sum func(node *Node) uint64
+	sum = func(node *Node) uint64 {
+		n := visits[node]
+		for _, child := range node.children {
+			n += sum(child)
+		}
+		subtree[node] = n
+		return n
+	}
+	sum(t.root)
+
+	var profile []NodeVisits
+	t.traverse(t.root, "", traversal{
+		enter: func(node *Node, path []byte, _ int) step {
+			if subtree[node] == 0 {
+				return stepOver
+			}
+			profile = append(profile, NodeVisits{Path: string(path), Visits: visits[node], Subtree: subtree[node]})
+			return stepInto
+		},
+		visit:      func(string, *Node, int) step { return stepInto },
+		keyOrder:   true,
+		unprofiled: true,
+	})
+	return profile
+}
+
+// ResetProfile clears the counts of t's profile.
+func (t *Tree) ResetProfile() {
+	if t.profile != nil {
+		t.profile.mu.Lock()
+		clear(t.profile.visits)
+		t.profile.mu.Unlock()
+	}
+}
+
+// WriteProfileCSV writes t's profile to w as CSV with the columns path,
+// visits and subtree, after a header row.
+func (t *Tree) WriteProfileCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "visits", "subtree"})
+	for _, v := range t.Profile() {
+		cw.Write([]string{v.Path, strconv.FormatUint(v.Visits, 10), strconv.FormatUint(v.Subtree, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ProfileDOT returns the Graphviz DOT description of t, see Tree.DOT, as a
+// heat map of its profile: each node is filled in red as deep as its visits,
+// on a log scale up to the most visited node, and each edge is drawn as wide
+// as the visits to the subtree below it.
+func (t *Tree) ProfileDOT() string {
+	var hottest, widest uint64
+	visits := make(map[*Node]NodeVisits)
+	profile := t.Profile()
+	for _, v := range profile {
+		node := t.nodeAt(v.Path)
+		visits[node] = v
+		hottest, widest = max(hottest, v.Visits), max(widest, v.Subtree)
+	}
+	scale := func(n, top uint64) float64 {
+		if top == 0 {
+			return 0
+		}
+		return math.Log1p(float64(n)) / math.Log1p(float64(top))
+	}
+	return t.dot(func(node *Node) (string, string) {
+		v, ok := visits[node]
+		if !ok {
+			return "", ""
+		}
+		return fmt.Sprintf(`, style=filled, fillcolor="0.000 %.3f 1.000", xlabel="%d"`, scale(v.Visits, hottest), v.Visits),
+			fmt.Sprintf(", penwidth=%.2f", 1+4*scale(v.Subtree, widest))
+	}, DOTOptions{})
+}
@@ -0,0 +1,9 @@
+//go:build !compressedtrie_profile
+
+package compressedtrie
+
+// profiling reports whether the build counts node visits, see Tree.Profile.
+const profiling = false
+
+// visit does nothing, the build doesn't count node visits.
+func (t *Tree) visit(*Node) {}
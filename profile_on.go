@@ -0,0 +1,11 @@
+//go:build compressedtrie_profile
+
+package compressedtrie
+
+// profiling reports whether the build counts node visits, see Tree.Profile.
+const profiling = true
+
+// visit counts a visit by a query to node.
+func (t *Tree) visit(node *Node) {
+	t.profile.add(node)
+}
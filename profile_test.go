@@ -0,0 +1,120 @@
+package compressedtrie
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"tea", "ted", "ten", "to"} {
+		tree.Insert(word)
+	}
+	if !profiling && tree.Profile() != nil {
+		t.Errorf("Expected no profile without the build tag, got %v", tree.Profile())
+	}
+
+	// Counts made by hand so that the test doesn't need the build tag
+	tree.profile = newVisitCounts()
+	for _, path := range []string{"", "", "", "t", "t", "te", "ted", "ted", "ted"} {
+		tree.profile.add(tree.nodeAt(path))
+	}
+	expected := []NodeVisits{
+		{Path: "", Visits: 3, Subtree: 9},
+		{Path: "t", Visits: 2, Subtree: 6},
+		{Path: "te", Visits: 1, Subtree: 4},
+		{Path: "ted", Visits: 3, Subtree: 3},
+	}
+	if got := tree.Profile(); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	var sb strings.Builder
+	if err := tree.WriteProfileCSV(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if csv := "path,visits,subtree\n,3,9\nt,2,6\nte,1,4\nted,3,3\n"; sb.String() != csv {
+		t.Errorf("Expected the CSV\n%s\ngot\n%s", csv, sb.String())
+	}
+
+	dot := tree.ProfileDOT()
+	for _, want := range []string{
+		`n0 [label="", style=filled, fillcolor="0.000 1.000 1.000", xlabel="3"]`,
+		`fillcolor="0.000 0.500 1.000", xlabel="1"`,
+		`[label="t", penwidth=4.38]`,
+		`[label="o"]`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("Expected %s in the DOT\n%s", want, dot)
+		}
+	}
+
+	tree.ResetProfile()
+	if got := tree.Profile(); got != nil {
+		t.Errorf("Expected an empty profile after ResetProfile, got %v", got)
+	}
+}
+
+func TestProfileQueries(t *testing.T) {
+	if !profiling {
+		t.Skip("needs -tags compressedtrie_profile")
+	}
+	tree := NewTree()
+	for _, word := range []string{"tea", "ted", "ten", "to"} {
+		tree.Insert(word)
+	}
+	tree.Contains("ted")
+	tree.FindWordsWithPrefix("te")
+	tree.LongestPrefix("tox")
+
+	visits := make(map[string]uint64)
+	for _, v := range tree.Profile() {
+		visits[v.Path] = v.Visits
+	}
+	// descend hands "te" over to the walk of its subtree, which counts it
+	// again
+	expected := map[string]uint64{"": 3, "t": 3, "te": 3, "ted": 2, "tea": 1, "ten": 1, "to": 1}
+	for path, n := range expected {
+		if visits[path] != n {
+			t.Errorf("%q: expected %d visits, got %d", path, n, visits[path])
+		}
+	}
+
+	// The iterators share the walk, and so the counts, of FindWordsWithPrefix
+	tree.ResetProfile()
+	tree.FindWordsWithPrefix("t")
+	want := tree.Profile()
+	for name, query := range map[string]func(){
+		"WordsWithPrefix": func() {
+			for range tree.WordsWithPrefix("t") {
+			}
+		},
+		"FrontCodedWords": func() {
+			for range tree.FrontCodedWords("t") {
+			}
+		},
+		"AppendWords": func() {
+			for range tree.AppendWords(nil, "t") {
+			}
+		},
+		"FindWordsWithPrefixStats": func() { tree.FindWordsWithPrefixStats("t") },
+	} {
+		tree.ResetProfile()
+		query()
+		if got := tree.Profile(); !slices.Equal(got, want) {
+			t.Errorf("%s: expected the profile %v, got %v", name, want, got)
+		}
+	}
+	if got := tree.Profile(); !slices.Equal(got, tree.Profile()) {
+		t.Errorf("Reading the profile changed it")
+	}
+
+	// MatchLength counts the nodes it matches in full, as LongestPrefix does
+	tree.ResetProfile()
+	tree.MatchLength("tedious")
+	want = []NodeVisits{{Path: "", Visits: 1, Subtree: 4}, {Path: "t", Visits: 1, Subtree: 3}, {Path: "te", Visits: 1, Subtree: 2}, {Path: "ted", Visits: 1, Subtree: 1}}
+	if got := tree.Profile(); !slices.Equal(got, want) {
+		t.Errorf("MatchLength: expected the profile %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,73 @@
+package compressedtrie
+
+import (
+	"sync"
+	"time"
+)
+
+// progressInterval is the number of nodes between reports of progress.
+const progressInterval = 1 << 16
+
+// Progress is how far a Serializer or Deserializer has got through a tree,
+// reported to the Progress callback of SerializeOptions or DeserializeOptions
+// every 65536 nodes and once more when it's done, so that long saves and loads
+// can drive progress bars and alerts. The last report is a summary of the
+// whole call.
+type Progress struct {
+	Bytes   int64         // bytes written or read so far, including the header
+	Nodes   int           // nodes written or read so far
+	Total   int           // nodes in the tree, as recorded in the header when reading
+	Elapsed time.Duration // time since the call started
+	Done    bool          // whether this is the last report, made once every node is done
+}
+
+// progress counts the nodes of a serialization for a Progress callback. A nil
+// *progress counts nothing.
+type progress struct {
+	fn    func(Progress)
+	start time.Time
+	total int
+	nodes int
+	off   int64      // bytes done by the workers of a parallel decode
+	mu    sync.Mutex // held by those workers, see add
+}
+
+// newProgress returns a progress that reports to fn, nil if fn is nil.
+func newProgress(fn func(Progress), total int) *progress {
+	if fn == nil {
+		return nil
+	}
+	return &progress{fn: fn, start: time.Now(), total: total}
+}
+
+// node counts a node that ends before byte off.
+func (p *progress) node(off int64) {
+	if p == nil {
+		return
+	}
+	p.nodes++
+	if p.nodes%progressInterval == 0 {
+		p.report(off, false)
+	}
+}
+
+// add counts the nodes of a whole subtree of size bytes at once, from any
+// goroutine, and reports them.
+func (p *progress) add(nodes int, size int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nodes += nodes
+	p.off += size
+	p.report(p.off, false)
+}
+
+// report calls the callback with the progress up to byte off.
+func (p *progress) report(off int64, done bool) {
+	if p == nil {
+		return
+	}
+	p.fn(Progress{Bytes: off, Nodes: p.nodes, Total: p.total, Elapsed: time.Since(p.start), Done: done})
+}
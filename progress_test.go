@@ -0,0 +1,57 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	tree := perfTree(60000)
+	var reports []Progress
+	record := func(p Progress) { reports = append(reports, p) }
+	check := func(name string, size int, intermediate int) {
+		t.Helper()
+		if len(reports) != intermediate+1 {
+			t.Fatalf("%s: expected %d reports, got %d", name, intermediate+1, len(reports))
+		}
+		for i, p := range reports {
+			if i > 0 && (p.Nodes < reports[i-1].Nodes || p.Nodes == reports[i-1].Nodes && !p.Done || p.Bytes < reports[i-1].Bytes || p.Elapsed < reports[i-1].Elapsed) {
+				t.Errorf("%s: report %+v goes backwards", name, p)
+			}
+			if p.Done != (i == len(reports)-1) || p.Total != tree.NodeCount() {
+				t.Errorf("%s: unexpected report %+v", name, p)
+			}
+		}
+		if last := reports[len(reports)-1]; last.Nodes != tree.NodeCount() || last.Bytes != int64(size) {
+			t.Errorf("%s: expected a summary of %d nodes in %d bytes, got %+v", name, tree.NodeCount(), size, last)
+		}
+		reports = nil
+	}
+
+	for _, tc := range frozenOptions {
+		opts := tc.Opts
+		opts.Progress = record
+		buf := &bytes.Buffer{}
+		if err := tree.SerializeWithOptions(buf, opts); err != nil {
+			t.Fatal(err)
+		}
+		size := buf.Len()
+		check(tc.Name+" written", size, tree.NodeCount()/progressInterval)
+
+		data := buf.Bytes()
+		if _, err := DeserializeTreeWithOptions(bytes.NewReader(data), DeserializeOptions{Progress: record}); err != nil {
+			t.Fatal(err)
+		}
+		check(tc.Name+" read", size, tree.NodeCount()/progressInterval)
+
+		// Each of the root's subtrees is reported as it is done
+		if _, err := DeserializeTreeWithOptions(bytes.NewReader(data), DeserializeOptions{Progress: record, Workers: 4}); err != nil {
+			t.Fatal(err)
+		}
+		intermediate := tree.root.NumChildren()
+		if opts.Layout == BreadthFirst {
+			intermediate = tree.NodeCount() / progressInterval
+		}
+		check(tc.Name+" read by workers", size, intermediate)
+	}
+}
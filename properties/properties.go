@@ -0,0 +1,245 @@
+// Package properties checks compressedtrie trees against a model, a
+// map[string]bool of the words they should hold. Random sequences of
+// operations, or ones decoded from fuzzer input, are applied to both and
+// after each one the tree must give the answers the model does and have the
+// structure a tree built from scratch with the same words has. Changes to how
+// trees insert, delete or serialize can then be tested with
+//
+//	func TestProperties(t *testing.T) {
+//		for seed := range uint64(100) {
+//			ops := properties.Random(properties.Config{Seed: seed})
+//			if err := properties.Run(newTree, ops); err != nil {
+//				t.Fatal(err)
+//			}
+//		}
+//	}
+//
+// where newTree returns an empty tree with the options under test.
+package properties
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"github.com/chriskillpack/compressedtrie"
+)
+
+// Kind is the kind of an Op.
+type Kind uint8
+
+const (
+	Insert    Kind = iota // insert the word
+	Delete                // delete the word
+	Contains              // look the word up, and its longest prefix
+	Prefix                // find the words with the word as a prefix
+	RoundTrip             // serialize the tree and carry on with it deserialized
+	numKinds
+)
+
+var kindNames = [numKinds]string{"Insert", "Delete", "Contains", "Prefix", "RoundTrip"}
+
+func (k Kind) String() string {
+	if k < numKinds {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", k)
+}
+
+// An Op is an operation on a tree and its model.
+type Op struct {
+	Kind Kind
+	Word string // unused by RoundTrip
+}
+
+func (op Op) String() string {
+	if op.Kind == RoundTrip {
+		return op.Kind.String()
+	}
+	return fmt.Sprintf("%v(%q)", op.Kind, op.Word)
+}
+
+// Config describes a random sequence of operations. The zero value of each
+// field picks a default.
+type Config struct {
+	// Ops is the number of operations, 200 by default.
+	Ops int
+
+	// Seed makes the output reproducible, the same Config always gives the
+	// same operations.
+	Seed uint64
+
+	// Alphabet is the bytes words are made of, "abc" by default. Few bytes
+	// and short words make the words share prefixes, so that inserts split
+	// nodes and deletes merge them.
+	Alphabet string
+
+	// MaxLength is the length of the longest word, 6 by default. Words as
+	// short as the empty word are chosen.
+	MaxLength int
+}
+
+// Random returns the operations described by cfg. Inserts are the most
+// common, so that the trees grow, with one RoundTrip in about 20.
+func Random(cfg Config) []Op {
+	if cfg.Ops <= 0 {
+		cfg.Ops = 200
+	}
+	if cfg.Alphabet == "" {
+		cfg.Alphabet = "abc"
+	}
+	if cfg.MaxLength <= 0 {
+		cfg.MaxLength = 6
+	}
+
+	r := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15))
+	ops := make([]Op, cfg.Ops)
+	for i := range ops {
+		word := make([]byte, r.IntN(cfg.MaxLength+1))
+		for j := range word {
+			word[j] = cfg.Alphabet[r.IntN(len(cfg.Alphabet))]
+		}
+		var kind Kind
+		switch n := r.IntN(20); {
+		case n < 8:
+			kind = Insert
+		case n < 13:
+			kind = Delete
+		case n < 16:
+			kind = Contains
+		case n < 19:
+			kind = Prefix
+		default:
+			kind = RoundTrip
+		}
+		ops[i] = Op{Kind: kind, Word: string(word)}
+	}
+	return ops
+}
+
+// Encode returns ops in the form Decode reads, to seed a fuzzer's corpus.
+// Words are cut to 255 bytes.
+func Encode(ops []Op) []byte {
+	var b []byte
+	for _, op := range ops {
+		word := op.Word[:min(len(op.Word), 255)]
+		b = append(b, byte(op.Kind), byte(len(word)))
+		b = append(b, word...)
+	}
+	return b
+}
+
+// Decode returns the operations in data, so that any input from a fuzzer is a
+// sequence of them: each is a byte for its kind, taken modulo the number of
+// kinds, a byte for the length of its word and the word, cut short at the end
+// of data.
+func Decode(data []byte) []Op {
+	var ops []Op
+	for len(data) >= 2 {
+		kind, n := Kind(data[0])%numKinds, min(int(data[1]), len(data)-2)
+		ops = append(ops, Op{Kind: kind, Word: string(data[2 : 2+n])})
+		data = data[2+n:]
+	}
+	return ops
+}
+
+// Run applies ops to the empty tree newTree returns and to a model, and
+// returns an error describing the first operation after which they disagree.
+// newTree is also used for the trees built from scratch to compare the
+// structure with, so it must return a new tree each time.
+func Run(newTree func() *compressedtrie.Tree, ops []Op) error {
+	tree := newTree()
+	model := make(map[string]bool)
+	for i, op := range ops {
+		if err := step(&tree, model, op, newTree); err != nil {
+			return fmt.Errorf("properties: op %d %v: %w", i, op, err)
+		}
+		if err := check(tree, model, newTree); err != nil {
+			return fmt.Errorf("properties: after op %d %v: %w", i, op, err)
+		}
+	}
+	return nil
+}
+
+// step applies op to *tree and model, checking what the tree returns. A
+// RoundTrip deserializes into a tree from newTree.
+func step(tree **compressedtrie.Tree, model map[string]bool, op Op, newTree func() *compressedtrie.Tree) error {
+	t := *tree
+	switch op.Kind {
+	case Insert:
+		if err := t.Insert(op.Word); err != nil {
+			return err
+		}
+		model[op.Word] = true
+	case Delete:
+		if found := t.Delete(op.Word); found != model[op.Word] {
+			return fmt.Errorf("returned %v", found)
+		}
+		delete(model, op.Word)
+	case Contains:
+		if found := t.Contains(op.Word); found != model[op.Word] {
+			return fmt.Errorf("returned %v", found)
+		}
+		expected, expectedOK := "", false
+		for n := len(op.Word); n >= 0; n-- {
+			if model[op.Word[:n]] {
+				expected, expectedOK = op.Word[:n], true
+				break
+			}
+		}
+		if prefix, ok := t.LongestPrefix(op.Word); prefix != expected || ok != expectedOK {
+			return fmt.Errorf("LongestPrefix returned %q %v, expected %q %v", prefix, ok, expected, expectedOK)
+		}
+	case Prefix:
+		var expected []string
+		for word := range model {
+			if strings.HasPrefix(word, op.Word) {
+				expected = append(expected, word)
+			}
+		}
+		slices.Sort(expected)
+		// In sorted order, whatever the order of the tree
+		words := t.FindWordsWithPrefix(op.Word)
+		slices.Sort(words)
+		if !slices.Equal(words, expected) {
+			return fmt.Errorf("returned %q, expected %q", words, expected)
+		}
+	case RoundTrip:
+		var buf bytes.Buffer
+		if err := t.Serialize(&buf); err != nil {
+			return err
+		}
+		t = newTree()
+		if err := t.DeserializeInto(&buf); err != nil {
+			return err
+		}
+		*tree = t
+	default:
+		return fmt.Errorf("unknown kind")
+	}
+	return nil
+}
+
+// check compares tree with model, and with a tree of the same words built
+// from scratch.
+func check(tree *compressedtrie.Tree, model map[string]bool, newTree func() *compressedtrie.Tree) error {
+	if tree.WordCount() != len(model) {
+		return fmt.Errorf("tree has %d words, expected %d", tree.WordCount(), len(model))
+	}
+	fresh := newTree()
+	for _, word := range slices.Sorted(maps.Keys(model)) {
+		if err := fresh.Insert(word); err != nil {
+			return fmt.Errorf("building the expected tree: %w", err)
+		}
+	}
+	if tree.NodeCount() != fresh.NodeCount() {
+		return fmt.Errorf("tree has %d nodes, expected %d", tree.NodeCount(), fresh.NodeCount())
+	}
+	if dot, expected := tree.DOT(), fresh.DOT(); dot != expected {
+		return fmt.Errorf("tree has a different structure\nActual=%q\nExpected=%q", dot, expected)
+	}
+	return nil
+}
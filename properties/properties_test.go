@@ -0,0 +1,59 @@
+package properties
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/chriskillpack/compressedtrie"
+)
+
+func newTree() *compressedtrie.Tree {
+	return compressedtrie.NewTree()
+}
+
+func TestRun(t *testing.T) {
+	for seed := range uint64(100) {
+		if err := Run(newTree, Random(Config{Seed: seed})); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+	// Longer words from a larger alphabet
+	cfg := Config{Ops: 500, Seed: 1, Alphabet: "abcdefgh", MaxLength: 12}
+	if err := Run(newTree, Random(cfg)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunFails(t *testing.T) {
+	// A tree that rejects long words disagrees with the model
+	limited := func() *compressedtrie.Tree { return compressedtrie.NewTree(compressedtrie.WithMaxWordLength(2)) }
+	ops := []Op{{Insert, "ab"}, {Prefix, "a"}, {Insert, "abc"}}
+	err := Run(limited, ops)
+	if err == nil || !strings.HasPrefix(err.Error(), `properties: op 2 Insert("abc"): `) {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	ops := Random(Config{Seed: 3})
+	if decoded := Decode(Encode(ops)); !slices.Equal(decoded, ops) {
+		t.Errorf("Decode(Encode(ops)) differs")
+	}
+	// Any input decodes, a word is cut short by the end
+	expected := []Op{{Contains, "a"}, {Insert, "bc"}}
+	if decoded := Decode([]byte{7, 1, 'a', 5, 9, 'b', 'c'}); !slices.Equal(decoded, expected) {
+		t.Errorf("Expected %v, got %v", expected, decoded)
+	}
+}
+
+func FuzzRun(f *testing.F) {
+	for seed := range uint64(4) {
+		f.Add(Encode(Random(Config{Ops: 30, Seed: seed})))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := Run(newTree, Decode(data)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
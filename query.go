@@ -0,0 +1,153 @@
+package compressedtrie
+
+import (
+	"math"
+	"time"
+)
+
+// QueryOptions configures Query. Each field narrows or bounds the search for
+// words with a prefix, the zero value gives FindWordsWithPrefix. Options for
+// new kinds of prefix search belong here, rather than in another variant of
+// FindWordsWithPrefix, so that they can be combined.
+type QueryOptions struct {
+	// Contains is a substring the words must contain after the prefix, see
+	// FindWordsWithPrefixContaining.
+	Contains string
+
+	// Limit is the largest number of words returned. Ignored if <= 0.
+	Limit int
+
+	// Budget bounds the work done, see FindWordsWithPrefixBudget.
+	Budget QueryBudget
+
+	// NonNil makes Query return an empty slice rather than nil when no words
+	// are found, so that encoding/json writes [] rather than null.
+	NonNil bool
+
+	// Presize counts the words under prefix before collecting them, up to
+	// Limit if that is set, to allocate the result once. The count walks the
+	// subtree again, except for the prefix "" whose count is WordCount, so it
+	// pays off for large results. Ignored with a Budget, which the count
+	// isn't bounded by, and an overestimate with Contains.
+	Presize bool
+}
+
+// Query returns the words in t that start with prefix and satisfy opts, in the
+// same order as FindWordsWithPrefix, and whether the search stopped before
+// finding all of them, because either opts.Limit was reached with more words
+// to come or opts.Budget ran out. Like FindWordsWithPrefix it returns nil if
+// there are none, unless opts.NonNil is set.
+func (t *Tree) Query(prefix string, opts QueryOptions) (words []string, truncated bool) {
+	defer func() {
+		// A presized result can be empty
+		if len(words) == 0 {
+			words = nil
+			if opts.NonNil {
+				words = []string{}
+			}
+		}
+	}()
+	node, path := t.descend(prefix)
+	if node == nil {
+		return words, false
+	}
+	if opts.Presize && opts.Budget.MaxNodes <= 0 && opts.Budget.Deadline.IsZero() {
+		if n := t.subtreeWords(node, opts.Limit); n > 0 {
+			words = make([]string, 0, n)
+		}
+	}
+
+	substr := opts.Contains
+	var m *matcher
+	state := 0
+	if substr != "" {
+		m = newMatcher(substr)
+		state = m.advance(0, path[len(prefix):])
+	}
+	minLen := len(prefix) + len(substr)
+
+	budget := opts.Budget
+	visited := 0
+	spent := func() bool {
+		if budget.MaxNodes > 0 && visited >= budget.MaxNodes {
+			return true
+		}
+		return !budget.Deadline.IsZero() && visited%deadlineCheck == 0 && !time.Now().Before(budget.Deadline)
+	}
+
+	// Once a path holds substr its whole subtree is taken without further
+	// checks.
+	finished := t.traverse(node, path, traversal{
+		enter: func(node *Node, _ []byte, state int) step {
+			if spent() {
+				return stepStop
+			}
+			visited++
+			if state < len(substr) && !node.hasLength(minLen, math.MaxInt) {
+				return stepOver
+			}
+			return stepInto
+		},
+		visit: func(word string, _ *Node, state int) step {
+			if state < len(substr) {
+				return stepInto
+			}
+			if opts.Limit > 0 && len(words) == opts.Limit {
+				return stepStop
+			}
+			words = append(words, word)
+			return stepInto
+		},
+		advance: func(state int, label string) int {
+			if state < len(substr) {
+				state = m.advance(state, label)
+			}
+			return state
+		},
+		state: state,
+	})
+	return words, !finished
+}
+
+// subtreeWords returns the number of words in the subtree of node, or limit if
+// that is fewer and > 0.
+func (t *Tree) subtreeWords(node *Node, limit int) int {
+	if node == t.root {
+		if limit > 0 {
+			return min(t.words, limit)
+		}
+		return t.words
+	}
+	n := 0
+	// Only the count is wanted, so neither paths nor the child order matter
+	t.traverse(node, "", traversal{
+		visitBytes: func([]byte, *Node, int) step {
+			if n++; n == limit {
+				return stepStop
+			}
+			return stepInto
+		},
+		keyOrder: true,
+	})
+	return n
+}
+
+// A Match is a word found by QueryMatches with the byte range of it that
+// matched the query prefix, Word[Start:End], so that autocomplete can bold
+// that part.
+type Match struct {
+	Word       string
+	Start, End int
+}
+
+// QueryMatches is Query with the range of each word that matched prefix,
+// which in a Tree is always its first len(prefix) bytes. See
+// NormalizedTree.QueryMatches for words whose stored form differs.
+func (t *Tree) QueryMatches(prefix string, opts QueryOptions) ([]Match, bool) {
+	words, truncated := t.Query(prefix, opts)
+	matches := make([]Match, len(words))
+	for i, word := range words {
+		matches[i] = Match{Word: word, End: len(prefix)}
+	}
+	return matches, truncated
+}
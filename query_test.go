@@ -0,0 +1,95 @@
+package compressedtrie
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	tree := perfTree(3000)
+	if words, truncated := tree.Query("b", QueryOptions{}); truncated || !slices.Equal(words, tree.FindWordsWithPrefix("b")) {
+		t.Errorf("Zero options returned %d words truncated %v", len(words), truncated)
+	}
+
+	var matching []string
+	for _, word := range tree.FindWordsWithPrefix("a") {
+		if strings.Contains(word[1:], "er") {
+			matching = append(matching, word)
+		}
+	}
+	if len(matching) < 10 {
+		t.Fatalf("Expected at least 10 matching words, got %d", len(matching))
+	}
+
+	// Options combine
+	cases := []struct {
+		Opts      QueryOptions
+		Expected  []string
+		Truncated bool
+	}{
+		{QueryOptions{Contains: "er", Limit: 5}, matching[:5], true},
+		{QueryOptions{Contains: "er", Limit: len(matching)}, matching, false},
+		{QueryOptions{Contains: "er", Limit: len(matching) + 1}, matching, false},
+		{QueryOptions{Contains: "er", Budget: QueryBudget{MaxNodes: tree.NodeCount()}}, matching, false},
+	}
+	for _, tc := range cases {
+		words, truncated := tree.Query("a", tc.Opts)
+		if truncated != tc.Truncated || !slices.Equal(words, tc.Expected) {
+			t.Errorf("%+v: expected %d words truncated %v, got %d truncated %v", tc.Opts, len(tc.Expected), tc.Truncated, len(words), truncated)
+		}
+	}
+	words, truncated := tree.Query("a", QueryOptions{Contains: "er", Budget: QueryBudget{MaxNodes: 50}})
+	if !truncated || !slices.Equal(words, matching[:len(words)]) {
+		t.Errorf("Expected a truncated start of the matches, got %d words truncated %v", len(words), truncated)
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"tea", "team", "toast"} {
+		tree.Insert(word)
+	}
+	matches, truncated := tree.QueryMatches("te", QueryOptions{Limit: 1})
+	if !truncated || !slices.Equal(matches, []Match{{"tea", 0, 2}}) {
+		t.Errorf("Unexpected matches %v truncated %v", matches, truncated)
+	}
+}
+
+func TestQueryEmptyAndPresize(t *testing.T) {
+	tree := perfTree(3000)
+
+	// Nothing found is nil throughout, unless NonNil asks for []
+	if words := tree.FindWordsWithPrefix("zzzzzz"); words != nil {
+		t.Errorf("Expected nil, got %#v", words)
+	}
+	cases := []struct {
+		Prefix string
+		Opts   QueryOptions
+	}{
+		{"zzzzzz", QueryOptions{}},
+		{"zzzzzz", QueryOptions{Presize: true}},
+		{"a", QueryOptions{Contains: "zzzzzz", Presize: true}},
+	}
+	for _, tc := range cases {
+		if words, _ := tree.Query(tc.Prefix, tc.Opts); words != nil {
+			t.Errorf("%+v: expected nil, got %#v", tc.Opts, words)
+		}
+		tc.Opts.NonNil = true
+		words, _ := tree.Query(tc.Prefix, tc.Opts)
+		if encoded, _ := json.Marshal(words); string(encoded) != "[]" {
+			t.Errorf("%+v: expected [], got %s", tc.Opts, encoded)
+		}
+	}
+
+	expected := tree.FindWordsWithPrefix("b")
+	words, _ := tree.Query("b", QueryOptions{Presize: true})
+	if !slices.Equal(words, expected) || cap(words) != len(expected) {
+		t.Errorf("Expected %d words in as many capacity, got %d in %d", len(expected), len(words), cap(words))
+	}
+	words, truncated := tree.Query("", QueryOptions{Presize: true, Limit: 10})
+	if !truncated || len(words) != 10 || cap(words) != 10 {
+		t.Errorf("Expected 10 words in as many capacity, got %d in %d", len(words), cap(words))
+	}
+}
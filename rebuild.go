@@ -0,0 +1,177 @@
+package compressedtrie
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"iter"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// An AtomicTree holds the current version of a tree that is refreshed by
+// replacing it wholesale, typically by a Rebuilder. Readers Load the tree and
+// query it without locking, as it is never changed once stored. The zero
+// value holds nil.
+type AtomicTree struct {
+	p atomic.Pointer[Tree]
+}
+
+// NewAtomicTree returns an AtomicTree holding t.
+func NewAtomicTree(t *Tree) *AtomicTree {
+	a := &AtomicTree{}
+	a.Store(t)
+	return a
+}
+
+// Load returns the current tree.
+func (a *AtomicTree) Load() *Tree {
+	return a.p.Load()
+}
+
+// Store makes t the current tree. t must not be changed afterwards.
+func (a *AtomicTree) Store(t *Tree) {
+	a.p.Store(t)
+}
+
+// Swap makes t the current tree and returns the previous one.
+func (a *AtomicTree) Swap(t *Tree) *Tree {
+	return a.p.Swap(t)
+}
+
+// Lines returns an iterator over the lines of r, without their line endings,
+// for use as the source of a Rebuilder or InsertAllErr. A read error is
+// yielded once, after which the iteration stops.
+func Lines(r io.Reader) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			if !yield(s.Text(), nil) {
+				return
+			}
+		}
+		if err := s.Err(); err != nil {
+			yield("", err)
+		}
+	}
+}
+
+// RebuildOptions configures a Rebuilder.
+type RebuildOptions struct {
+	// Options are passed to NewTree for each new tree.
+	Options []Option
+
+	// WordsPerSecond limits how quickly words are inserted into the new tree,
+	// so that a rebuild running alongside a busy service takes a bounded
+	// share of its CPU. 0 means no limit.
+	WordsPerSecond int
+}
+
+// rebuildPace is how many words are inserted between checks of the rate limit
+// and the context.
+const rebuildPace = 256
+
+// A Rebuilder builds fresh trees in the background and swaps each one into an
+// AtomicTree once it is complete. Readers of the AtomicTree keep using the old
+// tree until then, and a failed rebuild leaves it in place.
+type Rebuilder struct {
+	dst  *AtomicTree
+	opts RebuildOptions
+
+	mu      sync.Mutex
+	running bool
+	done    chan struct{} // closed when the running rebuild finishes
+	err     error         // the result of the last rebuild
+}
+
+// NewRebuilder returns a Rebuilder that stores the trees it builds in dst.
+func NewRebuilder(dst *AtomicTree, opts RebuildOptions) *Rebuilder {
+	done := make(chan struct{})
+	close(done)
+	return &Rebuilder{dst: dst, opts: opts, done: done}
+}
+
+// Start begins building a tree from the words produced by src in a new
+// goroutine, and reports whether it did. It doesn't if a rebuild is already
+// running. The rebuild stops without storing anything if ctx is cancelled, src
+// yields an error or a word is rejected by Insert, see Wait.
+func (r *Rebuilder) Start(ctx context.Context, src iter.Seq2[string, error]) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return false
+	}
+	r.running = true
+	r.done = make(chan struct{})
+	go func() {
+		err := r.Rebuild(ctx, src)
+		r.mu.Lock()
+		r.running = false
+		r.err = err
+		close(r.done)
+		r.mu.Unlock()
+	}()
+	return true
+}
+
+// Wait waits for the running rebuild, if there is one, and returns the error
+// from the last rebuild started by Start.
+func (r *Rebuilder) Wait() error {
+	r.mu.Lock()
+	done := r.done
+	r.mu.Unlock()
+	<-done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Rebuild is the synchronous form of Start, it builds a tree from src in the
+// calling goroutine and stores it. It can run alongside a rebuild started by
+// Start, the tree that finishes last wins.
+func (r *Rebuilder) Rebuild(ctx context.Context, src iter.Seq2[string, error]) error {
+	t := NewTree(r.opts.Options...)
+	start := time.Now()
+	n := 0
+	for word, err := range src {
+		if err != nil {
+			return err
+		}
+		if err := t.InsertSortedNext(word); err != nil {
+			return err
+		}
+		if n++; n%rebuildPace == 0 {
+			if err := r.pace(ctx, start, n); err != nil {
+				return err
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.dst.Store(t)
+	return nil
+}
+
+// pace waits until inserting n words since start is within the rate limit,
+// returning early with an error if ctx is cancelled.
+func (r *Rebuilder) pace(ctx context.Context, start time.Time, n int) error {
+	if r.opts.WordsPerSecond <= 0 {
+		return ctx.Err()
+	}
+	due := start.Add(time.Duration(n) * time.Second / time.Duration(r.opts.WordsPerSecond))
+	wait := time.Until(due)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,101 @@
+package compressedtrie
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRebuilder(t *testing.T) {
+	old := NewTree()
+	old.Insert("old")
+	current := NewAtomicTree(old)
+	r := NewRebuilder(current, RebuildOptions{Options: []Option{WithMaxWordLength(8)}})
+
+	if err := r.Wait(); err != nil {
+		t.Errorf("Wait before any rebuild: %v", err)
+	}
+	if !r.Start(context.Background(), Lines(strings.NewReader("alpha\nbeta\ngamma\n"))) {
+		t.Fatal("Start failed")
+	}
+	if err := r.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"alpha", "beta", "gamma"}
+	if words := current.Load().FindWordsWithPrefix(""); !slices.Equal(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+
+	// A failed rebuild leaves the tree in place
+	before := current.Load()
+	if !r.Start(context.Background(), Lines(strings.NewReader("delta\nepsilonic\n"))) {
+		t.Fatal("Start failed")
+	}
+	if err := r.Wait(); !errors.Is(err, ErrWordTooLong) {
+		t.Errorf("Expected ErrWordTooLong, got %v", err)
+	}
+	if current.Load() != before {
+		t.Errorf("Failed rebuild replaced the tree")
+	}
+	readErr := errors.New("read failed")
+	failing := func(yield func(string, error) bool) {
+		_ = yield("zeta", nil) && yield("", readErr)
+	}
+	if err := r.Rebuild(context.Background(), failing); err != readErr {
+		t.Errorf("Expected the source error, got %v", err)
+	}
+	if current.Load() != before {
+		t.Errorf("Failed rebuild replaced the tree")
+	}
+}
+
+func TestRebuilderRateLimit(t *testing.T) {
+	words := perfWords(1000)
+	current := &AtomicTree{}
+	r := NewRebuilder(current, RebuildOptions{WordsPerSecond: 5000})
+
+	start := time.Now()
+	if !r.Start(context.Background(), slicesSeq2(words)) {
+		t.Fatal("Start failed")
+	}
+	if r.Start(context.Background(), slicesSeq2(words)) {
+		t.Errorf("Started a second rebuild while one was running")
+	}
+	if err := r.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("1000 words at 5000 per second took only %v", elapsed)
+	}
+	if current.Load().WordCount() != len(words) {
+		t.Errorf("Expected %d words, got %d", len(words), current.Load().WordCount())
+	}
+
+	// Cancelling stops a rebuild part way through
+	ctx, cancel := context.WithCancel(context.Background())
+	slow := NewRebuilder(current, RebuildOptions{WordsPerSecond: 100})
+	before := current.Load()
+	slow.Start(ctx, slicesSeq2(words))
+	cancel()
+	if err := slow.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if current.Load() != before {
+		t.Errorf("Cancelled rebuild replaced the tree")
+	}
+}
+
+// slicesSeq2 returns a rebuild source that yields words without errors.
+func slicesSeq2(words []string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for _, word := range words {
+			if !yield(word, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// recordSource holds the bytes of a version 2 serialized tree. FrozenTree
+// reads them from a slice and RangeTree through a block cache.
+type recordSource interface {
+	// size returns the number of bytes in the source.
+	size() int64
+	// read returns the n bytes at off, which must be within the source. The
+	// bytes must not be changed.
+	read(off int64, n int) ([]byte, error)
+}
+
+// sliceSource is a recordSource held in memory.
+type sliceSource []byte
+
+func (s sliceSource) size() int64 {
+	return int64(len(s))
+}
+
+func (s sliceSource) read(off int64, n int) ([]byte, error) {
+	return s[off : off+int64(n)], nil
+}
+
+// records parses the node records of a version 2 serialized tree in place,
+// and answers the queries that FrozenTree and RangeTree share. Nothing in the
+// source is trusted, a malformed record gives a *FormatError.
+type records struct {
+	src   recordSource
+	root  int64    // offset of the root node
+	dict  [][]byte // the label dictionary, nil if there is none
+	bfs   bool     // whether the nodes are in the breadth first layout
+	nodes int      // number of nodes, as recorded in the header
+}
+
+// record is a parsed node record.
+type record struct {
+	tail     []byte // label without the leading key byte
+	isWord   bool
+	bounded  bool   // whether minLen and maxLen were recorded
+	minLen   int    // length of the shortest word in the subtree
+	maxLen   int    // length of the longest word in the subtree
+	counted  bool   // whether words was recorded
+	words    int    // number of words in the subtree
+	keys     []byte // keys of the children in ascending order
+	sizes    int64  // offset of the size of the first child, depth first only
+	children int64  // offset of the first child
+	end      int64  // offset just past the record
+}
+
+// openRecords checks the header of the tree in src and reads the label
+// dictionary. Returns the error reading src, a *FormatError if it doesn't hold
+// a serialized tree and an error matching ErrUnsupportedVersion for version 1
+// files.
+func openRecords(src recordSource) (records, error) {
+	r := records{src: src, root: headerSize}
+	if src.size() < headerSize {
+		return r, formatError(src.size(), -1, "truncated header")
+	}
+	head, err := src.read(0, headerSize)
+	if err != nil {
+		return r, err
+	}
+	if magic := binary.BigEndian.Uint32(head[0:]); magic != CtreeMagic {
+		return r, formatError(0, -1, "magic number %#x", magic)
+	}
+	if v := binary.BigEndian.Uint32(head[4:]); v != Version {
+		return r, fmt.Errorf("%w: %d", ErrUnsupportedVersion, v)
+	}
+	flags := binary.BigEndian.Uint32(head[12:])
+	if flags&^knownHeaderFlags != 0 {
+		return r, formatError(12, -1, "unknown header flags %#x", flags&^knownHeaderFlags)
+	}
+	r.bfs = flags&headerFlagBreadthFirst != 0
+	r.nodes = int(binary.BigEndian.Uint32(head[8:]))
+
+	if flags&headerFlagLabelDictionary != 0 {
+		if err := r.readDictionary(); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// readDictionary reads the label dictionary at r.root and moves r.root past
+// it.
+func (r *records) readDictionary() error {
+	c := r.cursor(r.root)
+	n := c.uvarint()
+	if c.err == nil && n > maxDictionaryEntries {
+		return formatError(r.root, -1, "malformed label dictionary")
+	}
+	r.dict = make([][]byte, n)
+	for i := range r.dict {
+		r.dict[i] = c.bytes(c.uvarint())
+	}
+	if c.err != nil {
+		return c.err
+	}
+	r.root = c.off
+	return nil
+}
+
+// contains reports whether word is in the tree. If it was written with
+// LengthBounds the search stops at the first node with no words of the same
+// length as word.
+func (r *records) contains(word string) (bool, error) {
+	n, err := r.node(r.root)
+	if err != nil {
+		return false, err
+	}
+	length := len(word)
+	for word != "" {
+		if n.bounded && (length < n.minLen || length > n.maxLen) {
+			return false, nil
+		}
+		c, ok, err := r.child(n, word[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		word = word[1:]
+		if len(word) < len(c.tail) || word[:len(c.tail)] != string(c.tail) {
+			return false, nil
+		}
+		word = word[len(c.tail):]
+		n = c
+	}
+	return n.isWord, nil
+}
+
+// findWordsWithPrefix returns all the words in the tree that start with
+// prefix, in order.
+func (r *records) findWordsWithPrefix(prefix string) ([]string, error) {
+	var words []string
+
+	n, err := r.node(r.root)
+	if err != nil {
+		return nil, err
+	}
+	path := make([]byte, 0, 64)
+	length := len(prefix)
+	for prefix != "" {
+		if n.bounded && length > n.maxLen {
+			// Every word below is shorter than the prefix
+			return nil, nil
+		}
+		c, ok, err := r.child(n, prefix[0])
+		if !ok || err != nil {
+			return nil, err
+		}
+		path = append(path, prefix[0])
+		path = append(path, c.tail...)
+		prefix = prefix[1:]
+
+		if len(prefix) >= len(c.tail) {
+			// The prefix covers the whole label, keep descending
+			if prefix[:len(c.tail)] != string(c.tail) {
+				return nil, nil
+			}
+			prefix = prefix[len(c.tail):]
+			n = c
+			continue
+		}
+
+		// The prefix ends inside the label
+		if !bytes.HasPrefix(c.tail, []byte(prefix)) {
+			return nil, nil
+		}
+		n = c
+		break
+	}
+
+	if err := r.gatherWords(n, path, &words); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// gatherWords appends all the words in the subtree of n to words, path is the
+// path to n.
+func (r *records) gatherWords(n record, path []byte, words *[]string) error {
+	if n.isWord {
+		*words = append(*words, string(path))
+	}
+	return r.eachChild(n, func(k byte, c record) error {
+		return r.gatherWords(c, append(append(path, k), c.tail...), words)
+	})
+}
+
+// eachChild calls fn for each child of n, in key order, stopping at the first
+// error from fn or from parsing a child.
+func (r *records) eachChild(n record, fn func(k byte, c record) error) error {
+	pos, sizes := n.children, r.cursor(n.sizes)
+	for _, k := range n.keys {
+		c, err := r.node(pos)
+		if err != nil {
+			return err
+		}
+		if err := fn(k, c); err != nil {
+			return err
+		}
+		if r.bfs {
+			pos = c.end
+		} else if pos, err = r.skipSubtree(&sizes, pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// child returns the child of n with key k, and whether there is one.
+func (r *records) child(n record, k byte) (record, bool, error) {
+	i := bytes.IndexByte(n.keys, k)
+	if i < 0 {
+		return record{}, false, nil
+	}
+	start, _, err := r.childSpan(n, i)
+	if err != nil {
+		return record{}, false, err
+	}
+	c, err := r.node(start)
+	return c, err == nil, err
+}
+
+// childSpan returns the offset of the record of child i of n. In the depth
+// first layout it also returns the offset just past the child's subtree,
+// otherwise end is the offset just past its record.
+func (r *records) childSpan(n record, i int) (start, end int64, err error) {
+	pos := n.children
+	if r.bfs {
+		// Siblings are next to each other
+		for range i + 1 {
+			c, err := r.node(pos)
+			if err != nil {
+				return 0, 0, err
+			}
+			start, pos = pos, c.end
+		}
+		return start, pos, nil
+	}
+
+	// Skip over the subtrees of the children before it
+	sizes := r.cursor(n.sizes)
+	for range i + 1 {
+		start = pos
+		if pos, err = r.skipSubtree(&sizes, pos); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, pos, nil
+}
+
+// skipSubtree reads the next subtree size from sizes and returns the offset
+// just past the subtree at pos.
+func (r *records) skipSubtree(sizes *recordCursor, pos int64) (int64, error) {
+	off := sizes.off
+	size := sizes.uvarint()
+	if sizes.err != nil {
+		return 0, sizes.err
+	}
+	if size > uint64(r.src.size()-pos) {
+		return 0, formatError(off, -1, "subtree size")
+	}
+	return pos + int64(size), nil
+}
+
+// node parses the node record at off.
+func (r *records) node(off int64) (record, error) {
+	var n record
+	c := r.cursor(off)
+	bad := func(reason string) (record, error) {
+		if c.err != nil {
+			return n, c.err
+		}
+		return n, formatError(off, -1, "%s", reason)
+	}
+
+	ref := c.uvarint()
+	switch {
+	case r.dict == nil || ref&1 == 0:
+		if r.dict != nil {
+			ref >>= 1
+		}
+		n.tail = c.bytes(ref)
+	case ref>>1 < uint64(len(r.dict)):
+		n.tail = r.dict[ref>>1]
+	default:
+		return bad("label dictionary reference")
+	}
+
+	flags := c.byte()
+	if c.err != nil || flags&^knownNodeFlags != 0 || flags&nodeFlagValues != 0 && flags&nodeFlagWord == 0 {
+		return bad(fmt.Sprintf("node flags %#x", flags))
+	}
+	n.isWord = flags&nodeFlagWord != 0
+	if flags&nodeFlagValues != 0 {
+		// Values are only decoded by a MultiMap, skip them
+		c.skip(c.uvarint())
+	}
+	if flags&nodeFlagBounds != 0 {
+		lo, span := c.uvarint(), c.uvarint()
+		if c.err != nil || lo > math.MaxInt32 || span > math.MaxInt32 {
+			return bad("length bounds")
+		}
+		n.bounded, n.minLen, n.maxLen = true, int(lo), int(lo+span)
+	}
+	if flags&nodeFlagWordCount != 0 {
+		words := c.uvarint()
+		if c.err != nil || words > math.MaxInt32 {
+			return bad("word count")
+		}
+		n.counted, n.words = true, int(words)
+	}
+
+	nc := c.uvarint()
+	if c.err != nil || nc > 256 {
+		return bad(fmt.Sprintf("child count %d", nc))
+	}
+	n.keys = c.bytes(nc)
+
+	if r.bfs {
+		if nc > 0 {
+			if b := c.bytes(4); b != nil {
+				n.children = int64(binary.BigEndian.Uint32(b))
+			}
+			// Children always come after their parent, which also rules out
+			// cycles in corrupt data.
+			if c.err == nil && n.children < c.off {
+				return bad("child offset")
+			}
+		}
+	} else {
+		n.sizes = c.off
+		for range nc {
+			c.uvarint()
+		}
+		n.children = c.off
+	}
+	if c.err != nil {
+		return bad("")
+	}
+	n.end = c.off
+	return n, nil
+}
+
+// cursor returns a recordCursor reading from off.
+func (r *records) cursor(off int64) recordCursor {
+	return recordCursor{src: r.src, off: off}
+}
+
+// recordCursor reads the fields of a record in turn. The first error is kept
+// in err, after which reads return zero values.
+type recordCursor struct {
+	src recordSource
+	off int64
+	err error
+}
+
+func (c *recordCursor) bytes(n uint64) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if c.off < 0 || c.off > c.src.size() || n > uint64(c.src.size()-c.off) {
+		c.err = formatError(c.off, -1, "truncated record")
+		return nil
+	}
+	b, err := c.src.read(c.off, int(n))
+	if err != nil {
+		c.err = err
+		return nil
+	}
+	c.off += int64(n)
+	return b
+}
+
+func (c *recordCursor) skip(n uint64) {
+	if c.err == nil && (c.off < 0 || c.off > c.src.size() || n > uint64(c.src.size()-c.off)) {
+		c.err = formatError(c.off, -1, "truncated record")
+	}
+	if c.err == nil {
+		c.off += int64(n)
+	}
+}
+
+func (c *recordCursor) byte() byte {
+	if b := c.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (c *recordCursor) uvarint() uint64 {
+	if c.err != nil {
+		return 0
+	}
+	if c.off < 0 || c.off >= c.src.size() {
+		c.err = formatError(c.off, -1, "truncated record")
+		return 0
+	}
+	b, err := c.src.read(c.off, int(min(binary.MaxVarintLen64, c.src.size()-c.off)))
+	if err != nil {
+		c.err = err
+		return 0
+	}
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		c.err = formatError(c.off, -1, "malformed uvarint")
+		return 0
+	}
+	c.off += int64(n)
+	return v
+}
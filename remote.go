@@ -0,0 +1,306 @@
+package compressedtrie
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Defaults for RangeOptions.
+const (
+	rangeBlockSize   = 16 << 10
+	rangeCacheBlocks = 256
+)
+
+// RangeOptions configures a RangeTree.
+type RangeOptions struct {
+	// BlockSize is the size of the blocks the file is read and cached in,
+	// 0 for 16KiB. Each read of the file is for a whole block.
+	BlockSize int
+
+	// CacheBlocks is the largest number of blocks kept, the least recently
+	// used are dropped to make room. 0 for 256.
+	CacheBlocks int
+}
+
+// RangeTree queries a serialized tree in place, like a FrozenTree, through an
+// io.ReaderAt, reading only the parts of the file that the queries visit. The
+// file is read in blocks that are cached, so the top levels of the tree, which
+// every query passes through, are soon held in memory. It suits a file served
+// over HTTP with range requests, see OpenHTTP, so that an edge function
+// can query a centrally hosted .ctree without downloading all of it. A tree
+// written with the BreadthFirst layout keeps the top levels together in the
+// first blocks. A RangeTree is safe for concurrent use.
+//
+// Queries return the error reading the file, or a *FormatError if the file is
+// malformed.
+type RangeTree struct {
+	records
+	r *blockCache
+}
+
+// RangeStats counts the reads of a RangeTree.
+type RangeStats struct {
+	Reads uint64 // blocks read from the file
+	Hits  uint64 // blocks found in the cache
+}
+
+// OpenRange returns a RangeTree for the serialized tree of size bytes read
+// from r. The header, and the label dictionary if there is one, are read
+// straight away. Returns the error reading r, a *FormatError if it doesn't
+// hold a serialized tree and an error matching ErrUnsupportedVersion for
+// version 1 files.
+func OpenRange(r io.ReaderAt, size int64, opts RangeOptions) (*RangeTree, error) {
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = rangeBlockSize
+	}
+	if opts.CacheBlocks <= 0 {
+		opts.CacheBlocks = rangeCacheBlocks
+	}
+	t := &RangeTree{r: newBlockCache(r, size, opts)}
+	var err error
+	if t.records, err = openRecords(t.r); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NodeCount returns the number of nodes in t, as recorded in the header.
+func (t *RangeTree) NodeCount() int {
+	return t.nodes
+}
+
+// Stats returns the counts of blocks read so far.
+func (t *RangeTree) Stats() RangeStats {
+	return t.r.stats()
+}
+
+// Contains reports whether word is in t. If t was written with LengthBounds the
+// search stops at the first node with no words of the same length as word.
+func (t *RangeTree) Contains(word string) (bool, error) {
+	return t.contains(word)
+}
+
+// FindWordsWithPrefix returns all the words in t that start with prefix, in
+// order.
+func (t *RangeTree) FindWordsWithPrefix(prefix string) ([]string, error) {
+	return t.findWordsWithPrefix(prefix)
+}
+
+// blockCache reads a file in blocks and keeps the most recently used ones.
+type blockCache struct {
+	r         io.ReaderAt
+	length    int64
+	blockSize int64
+	max       int
+
+	mu     sync.Mutex
+	blocks map[int64]*list.Element // by block number, the values are *cachedBlock
+	order  *list.List              // most recently used first
+	reads  uint64
+	hits   uint64
+}
+
+type cachedBlock struct {
+	n    int64
+	data []byte
+}
+
+func newBlockCache(r io.ReaderAt, size int64, opts RangeOptions) *blockCache {
+	return &blockCache{
+		r:         r,
+		length:    size,
+		blockSize: int64(opts.BlockSize),
+		max:       opts.CacheBlocks,
+		blocks:    make(map[int64]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// size returns the size of the file.
+func (b *blockCache) size() int64 {
+	return b.length
+}
+
+// read returns the n bytes at off, which must be within the file. The bytes
+// must not be changed, they may be shared with the cache.
+func (b *blockCache) read(off int64, n int) ([]byte, error) {
+	first, last := off/b.blockSize, (off+int64(n)-1)/b.blockSize
+	if n == 0 || first == last {
+		block, err := b.block(first)
+		if err != nil {
+			return nil, err
+		}
+		start := off - first*b.blockSize
+		return block[start : start+int64(n)], nil
+	}
+
+	// Copy the parts of the blocks it spans
+	buf := make([]byte, 0, n)
+	for i := first; i <= last; i++ {
+		block, err := b.block(i)
+		if err != nil {
+			return nil, err
+		}
+		start := max(off-i*b.blockSize, 0)
+		end := min(off+int64(n)-i*b.blockSize, int64(len(block)))
+		buf = append(buf, block[start:end]...)
+	}
+	return buf, nil
+}
+
+// block returns block i, reading it if it isn't cached. Concurrent misses for
+// the same block each read it.
+func (b *blockCache) block(i int64) ([]byte, error) {
+	b.mu.Lock()
+	if e, ok := b.blocks[i]; ok {
+		b.order.MoveToFront(e)
+		b.hits++
+		b.mu.Unlock()
+		return e.Value.(*cachedBlock).data, nil
+	}
+	b.reads++
+	b.mu.Unlock()
+
+	data := make([]byte, min(b.blockSize, b.length-i*b.blockSize))
+	if n, err := b.r.ReadAt(data, i*b.blockSize); n < len(data) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.blocks[i]; !ok {
+		b.blocks[i] = b.order.PushFront(&cachedBlock{i, data})
+		for b.order.Len() > b.max {
+			e := b.order.Back()
+			b.order.Remove(e)
+			delete(b.blocks, e.Value.(*cachedBlock).n)
+		}
+	}
+	return data, nil
+}
+
+func (b *blockCache) stats() RangeStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return RangeStats{Reads: b.reads, Hits: b.hits}
+}
+
+// ErrRemoteChanged is returned by an HTTPRangeReader when the file has changed
+// on the server since the reader was created.
+var ErrRemoteChanged = errors.New("compressedtrie: remote file changed")
+
+// HTTPRangeReader is an io.ReaderAt for a file served over HTTP, each read is
+// a GET with a Range header. If the server gives the file an ETag, reads are
+// made conditional on it, so that a file replaced on the server isn't mixed
+// with the old one.
+type HTTPRangeReader struct {
+	client *http.Client
+	url    string
+	etag   string
+	size   int64
+}
+
+// NewHTTPRangeReader returns an HTTPRangeReader for url, making a request for
+// the first byte to find the size of the file. Requests are made with client,
+// http.DefaultClient if nil. Only that first request carries ctx, the reads
+// made later by ReadAt outlive it and are bounded by the client's Timeout
+// instead. Returns an error if the server doesn't support range requests.
+func NewHTTPRangeReader(ctx context.Context, client *http.Client, url string) (*HTTPRangeReader, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	r := &HTTPRangeReader{client: client, url: url}
+	resp, err := r.get(ctx, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Content-Range: bytes 0-0/size
+	_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("compressedtrie: %s: no file size in Content-Range %q", url, resp.Header.Get("Content-Range"))
+	}
+	r.etag, r.size = resp.Header.Get("ETag"), size
+	return r, nil
+}
+
+// Size returns the size of the file.
+func (r *HTTPRangeReader) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes from the file at off. The request isn't tied to
+// a context, set a Timeout on the client to bound it.
+func (r *HTTPRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	want := min(int64(len(p)), r.size-off)
+	resp, err := r.get(context.Background(), off, want)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	n, err := io.ReadFull(resp.Body, p[:want])
+	if err == nil && want < int64(len(p)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// get requests n bytes at off and checks that the server answered with them.
+func (r *HTTPRangeReader) get(ctx context.Context, off, n int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	if r.etag != "" {
+		req.Header.Set("If-Match", r.etag)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, fmt.Errorf("%w: %s", ErrRemoteChanged, r.url)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("compressedtrie: %s: server ignored the range request", r.url)
+	}
+	return nil, fmt.Errorf("compressedtrie: %s: %s", r.url, resp.Status)
+}
+
+// OpenHTTP is OpenRange for the serialized tree at url, read with an
+// HTTPRangeReader. As with NewHTTPRangeReader, ctx only carries the request
+// for the size of the file, not the reads of the header or the queries that
+// follow:
+//
+//	tree, err := compressedtrie.OpenHTTP(ctx, nil, "https://cdn.example.com/words.ctree", compressedtrie.RangeOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	words, err := tree.FindWordsWithPrefix("toa")
+func OpenHTTP(ctx context.Context, client *http.Client, url string, opts RangeOptions) (*RangeTree, error) {
+	r, err := NewHTTPRangeReader(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	return OpenRange(r, r.Size(), opts)
+}
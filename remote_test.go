@@ -0,0 +1,167 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRangeTree(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+
+	for _, tc := range frozenOptions {
+		t.Run(tc.Name, func(t *testing.T) {
+			data := tree.FreezeWithOptions(tc.Opts)
+			// Small blocks so that records span them
+			remote, err := OpenRange(bytes.NewReader(data), int64(len(data)), RangeOptions{BlockSize: 7, CacheBlocks: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if remote.NodeCount() != tree.NodeCount() {
+				t.Errorf("Expected %d nodes, got %d", tree.NodeCount(), remote.NodeCount())
+			}
+
+			for _, word := range append(frozenWords, "", "r", "rom", "roman", "slowl", "slowlyy", "toast", "x") {
+				actual, err := remote.Contains(word)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected := tree.Contains(word); expected != actual {
+					t.Errorf("Contains(%q): expected %v, got %v", word, expected, actual)
+				}
+			}
+
+			for _, prefix := range []string{"", "r", "ro", "rom", "roma", "romanes", "rub", "rubi", "t", "to", "toas", "tx", "slow", "x"} {
+				actual, err := remote.FindWordsWithPrefix(prefix)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected := tree.FindWordsWithPrefix(prefix); !slices.Equal(actual, expected) {
+					t.Errorf("FindWordsWithPrefix(%q): expected %v, got %v", prefix, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestRangeTreeCorrupt(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+
+	for _, tc := range frozenOptions {
+		t.Run(tc.Name, func(t *testing.T) {
+			data := tree.FreezeWithOptions(tc.Opts)
+			if _, err := OpenRange(bytes.NewReader(data), 8, RangeOptions{}); !errors.Is(err, ErrInvalidFormat) {
+				t.Errorf("Expected ErrInvalidFormat for a truncated header, got %v", err)
+			}
+
+			// Truncation and corruption must give errors or wrong answers,
+			// not crashes.
+			for i := headerSize; i < len(data); i++ {
+				queryRange(data[:i])
+				corrupt := slices.Clone(data)
+				corrupt[i] ^= 0xff
+				queryRange(corrupt)
+			}
+		})
+	}
+}
+
+// queryRange runs queries against data through a RangeTree, ignoring the
+// results.
+func queryRange(data []byte) {
+	remote, err := OpenRange(bytes.NewReader(data), int64(len(data)), RangeOptions{BlockSize: 5})
+	if err != nil {
+		return
+	}
+	remote.FindWordsWithPrefix("")
+	remote.FindWordsWithPrefix("rub")
+	remote.Contains("rubicundus")
+}
+
+func TestOpenHTTP(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	data := tree.FreezeWithOptions(SerializeOptions{Layout: BreadthFirst})
+
+	var requests atomic.Int64
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("ETag", etag.Load().(string))
+		http.ServeContent(w, r, "words.ctree", modified, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	remote, err := OpenHTTP(ctx, srv.Client(), srv.URL, RangeOptions{BlockSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	words, err := remote.FindWordsWithPrefix("rub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := tree.FindWordsWithPrefix("rub"); !slices.Equal(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+
+	// The blocks read are cached
+	before := requests.Load()
+	for range 3 {
+		if ok, err := remote.Contains("rubicon"); !ok || err != nil {
+			t.Errorf("Contains(rubicon): got %v, %v", ok, err)
+		}
+	}
+	if n := requests.Load(); n != before {
+		t.Errorf("Expected no more requests for cached blocks, got %d", n-before)
+	}
+	if s := remote.Stats(); s.Reads == 0 || s.Hits == 0 || s.Reads+1 != uint64(before) {
+		t.Errorf("Expected a read for each request after the first, got %+v for %d requests", s, before)
+	}
+
+	// The context of the open doesn't reach the queries
+	opened, cancel := context.WithCancel(ctx)
+	later, err := OpenHTTP(opened, srv.Client(), srv.URL, RangeOptions{BlockSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if ok, err := later.Contains("rubicon"); !ok || err != nil {
+		t.Errorf("Contains(rubicon) after the open's context was cancelled: got %v, %v", ok, err)
+	}
+
+	// A file replaced on the server isn't read
+	small, err := OpenHTTP(ctx, srv.Client(), srv.URL, RangeOptions{BlockSize: 32, CacheBlocks: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag.Store(`"v2"`)
+	if _, err := small.FindWordsWithPrefix(""); !errors.Is(err, ErrRemoteChanged) {
+		t.Errorf("Expected ErrRemoteChanged, got %v", err)
+	}
+
+	// Servers that don't do ranges are rejected
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer plain.Close()
+	if _, err := OpenHTTP(ctx, plain.Client(), plain.URL, RangeOptions{}); err == nil {
+		t.Error("Expected an error for a server without range requests")
+	}
+}
@@ -0,0 +1,154 @@
+package compressedtrie
+
+import (
+	"iter"
+	"maps"
+	"slices"
+)
+
+// WordScanner finds every occurrence of the words of a tree in a text in one
+// pass, with the Aho-Corasick algorithm. It is compiled from a snapshot of the
+// tree, so later changes to the tree aren't seen, and is safe for concurrent
+// use.
+//
+// Every prefix of a word is a state of the scanner. Each state links to the
+// state of its longest proper suffix that is also a prefix of a word, which is
+// where the scan continues when the next byte of the text leads nowhere, and
+// to the next word among those suffixes, so that all the words ending at a
+// position are found without visiting the states in between.
+type WordScanner struct {
+	edges  [][]scanEdge // edges[s] are the transitions from state s, by byte
+	fail   []int32      // the longest proper suffix of each state
+	output []int32      // the longest proper suffix of each state that is a word, -1 if none
+	depth  []int32      // the length of each state
+	isWord []bool
+}
+
+type scanEdge struct {
+	b  byte
+	to int32
+}
+
+// NewWordScanner compiles a WordScanner for the words of t. The empty word is
+// never reported.
+func NewWordScanner(t *Tree) *WordScanner {
+	s := &WordScanner{}
+	s.addState(0, false)
+
+	// The tree's states, one for each byte of each label
+	var walk func(node *Node, state int32, depth int32)
+	walk = func(node *Node, state int32, depth int32) {
+		for _, k := range slices.Sorted(maps.Keys(node.children)) {
+			child := node.children[k]
+			cur := state
+			for i := range len(child.label) {
+				next := s.addState(depth+int32(i)+1, i == len(child.label)-1 && child.isWord)
+				s.edges[cur] = append(s.edges[cur], scanEdge{child.label[i], next})
+				cur = next
+			}
+			walk(child, cur, depth+int32(len(child.label)))
+		}
+	}
+	walk(t.root, 0, 0)
+
+	// The suffix links of a state only depend on shorter states, so set them
+	// breadth first
+	queue := []int32{0}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, e := range s.edges[state] {
+			fail := int32(0)
+			if state != 0 {
+				f := s.fail[state]
+				for {
+					if next, ok := s.next(f, e.b); ok {
+						fail = next
+						break
+					}
+					if f == 0 {
+						break
+					}
+					f = s.fail[f]
+				}
+			}
+			s.fail[e.to] = fail
+			if s.isWord[fail] {
+				s.output[e.to] = fail
+			} else {
+				s.output[e.to] = s.output[fail]
+			}
+			queue = append(queue, e.to)
+		}
+	}
+	return s
+}
+
+// addState adds a state of length depth and returns it.
+func (s *WordScanner) addState(depth int32, isWord bool) int32 {
+	s.edges = append(s.edges, nil)
+	s.fail = append(s.fail, 0)
+	s.output = append(s.output, -1)
+	s.depth = append(s.depth, depth)
+	s.isWord = append(s.isWord, isWord)
+	return int32(len(s.depth) - 1)
+}
+
+// next returns the state after b from state, if there is one.
+func (s *WordScanner) next(state int32, b byte) (int32, bool) {
+	edges := s.edges[state]
+	if len(edges) > 8 {
+		i, ok := slices.BinarySearchFunc(edges, b, func(e scanEdge, b byte) int { return int(e.b) - int(b) })
+		if !ok {
+			return 0, false
+		}
+		return edges[i].to, true
+	}
+	for _, e := range edges {
+		if e.b == b {
+			return e.to, true
+		}
+	}
+	return 0, false
+}
+
+// An Occurrence is a word found by a WordScanner, at text[Start:End].
+type Occurrence struct {
+	Start, End int
+}
+
+// Scan returns an iterator over the occurrences of words in text, in order of
+// where they end and the longest first for words that end at the same place.
+// Overlapping occurrences are all reported, so scanning "she" for "she", "he"
+// and "e" gives all three.
+func (s *WordScanner) Scan(text string) iter.Seq[Occurrence] {
+	return func(yield func(Occurrence) bool) {
+		state := int32(0)
+		for i := 0; i < len(text); i++ {
+			for {
+				if next, ok := s.next(state, text[i]); ok {
+					state = next
+					break
+				}
+				if state == 0 {
+					break
+				}
+				state = s.fail[state]
+			}
+			out := state
+			if !s.isWord[out] {
+				out = s.output[out]
+			}
+			for ; out > 0; out = s.output[out] {
+				if !yield(Occurrence{Start: i + 1 - int(s.depth[out]), End: i + 1}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FindAll returns the occurrences of words in text in the order of Scan.
+func (s *WordScanner) FindAll(text string) []Occurrence {
+	return slices.Collect(s.Scan(text))
+}
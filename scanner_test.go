@@ -0,0 +1,61 @@
+package compressedtrie
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWordScanner(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"he", "she", "his", "hers", "e", ""} {
+		tree.Insert(word)
+	}
+	s := NewWordScanner(tree)
+	text := "ushers"
+	var actual []string
+	for _, o := range s.FindAll(text) {
+		actual = append(actual, text[o.Start:o.End])
+	}
+	if expected := []string{"she", "he", "e", "hers"}; !slices.Equal(actual, expected) {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+	if o := s.FindAll("xyz"); o != nil {
+		t.Errorf("Expected nothing, got %v", o)
+	}
+	// Changes to the tree aren't seen
+	tree.Insert("us")
+	if o := s.FindAll("us"); o != nil {
+		t.Errorf("Expected nothing, got %v", o)
+	}
+
+	// Agrees with looking for every word at every position
+	words := perfWords(2000)
+	big := NewTree()
+	for _, word := range words {
+		big.Insert(word)
+	}
+	s = NewWordScanner(big)
+	r := rand.New(rand.NewPCG(1, 2))
+	var b strings.Builder
+	for b.Len() < 5000 {
+		if r.IntN(3) == 0 {
+			b.WriteString(words[r.IntN(len(words))])
+		} else {
+			b.WriteByte(byte('a' + r.IntN(26)))
+		}
+	}
+	text = b.String()
+	var expected []Occurrence
+	for end := 1; end <= len(text); end++ {
+		for start := 0; start < end; start++ {
+			if big.Contains(text[start:end]) {
+				expected = append(expected, Occurrence{start, end})
+			}
+		}
+	}
+	if actual := s.FindAll(text); !slices.Equal(actual, expected) {
+		t.Errorf("Expected %d occurrences, got %d", len(expected), len(actual))
+	}
+}
@@ -0,0 +1,1688 @@
+package compressedtrie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A serialized tree starts with a SerializedTreeHeader. In version 1 of the
+// format the header is followed by the root node, each node being
+//
+//	label    u16 length followed by the bytes of the label
+//	isWord   u8, 1 if the node marks the end of a word
+//	children u8 count, followed by a u8 key and the child node for each child
+//
+// Version 2 follows the header with u32 flags and then the root node. Nodes are
+// laid out depth first and each node records the encoded size of its children's
+// subtrees, so that a reader can jump over the parts of the tree it isn't
+// interested in.
+//
+//	label    uvarint length followed by the bytes of the label. The first byte
+//	         of a child's label is its key in the parent and is not repeated.
+//	flags    u8, bit 0 is set if the node marks the end of a word, bit 1 if
+//	         it has values, bit 2 if it has length bounds and bit 3 if it has
+//	         a word count
+//	values   only if bit 1 of flags is set, the uvarint length prefixed values
+//	         of the word as encoded by the ValueCodec of a MultiMap
+//	bounds   only if bit 2 of flags is set, the uvarint length in bytes of the
+//	         shortest word in the node's subtree followed by the uvarint
+//	         difference between it and the length of the longest
+//	words    only if bit 3 of flags is set, the uvarint number of words in the
+//	         node's subtree, including the node's own
+//	count    uvarint number of children n
+//	keys     n bytes, the first byte of each child's label in ascending order
+//	sizes    n uvarints, the encoded size in bytes of each child's subtree
+//
+// The record is followed by the n children in key order.
+//
+// If bit 1 of the header flags is set the nodes are instead laid out breadth
+// first, in level order, so that the top of the tree which every query visits
+// is in one small region of the file. The children of a node are then stored
+// one after the other, and the record ends with the u32 offset from the start
+// of the file of the first child's record in place of the sizes. Nodes without
+// children have no offset.
+//
+// If bit 0 of the header flags is set a label dictionary sits between the flags
+// and the root node: a uvarint count followed by that many uvarint length
+// prefixed strings. Each label is then instead a uvarint v, if the low bit of v
+// is set the label is entry v>>1 of the dictionary, otherwise it is v>>1 bytes
+// long and the bytes follow.
+//
+// The files Serialize writes are reproducible: the same words, with the same
+// values, written with the same options give the same bytes whatever order the
+// words were inserted and deleted in, so files can be compared and cached by
+// their hashes. The tree of a set of words is unique in canonical form, with
+// its nodes written in key order and the dictionary in order of savings and
+// then of label. A tree that isn't canonical, which only decoding a file with
+// SanitizeNone can give, is written as the canonical tree of its words.
+type SerializedTreeHeader struct {
+	Magic   uint32 // magic number (CtreeMagic)
+	Version uint32 // file format version
+	Nodes   uint32 // number of nodes in the tree
+}
+
+const (
+	// 32-bit magic number for the serialized tree binary format
+	CtreeMagic uint32 = 'C'<<24 | 'T'<<16 | 'R'<<8 | 'E'
+	// File format version written by Serialize. Version 1 files can still be
+	// read.
+	Version uint32 = 2
+)
+
+// Bits of the version 2 header flags
+const (
+	headerFlagLabelDictionary uint32 = 1 << iota
+	headerFlagBreadthFirst
+
+	knownHeaderFlags = headerFlagLabelDictionary | headerFlagBreadthFirst
+)
+
+// headerSize is the size of SerializedTreeHeader plus the version 2 flags.
+const headerSize = 16
+
+// Layout is the order nodes are written in by SerializeWithOptions.
+type Layout int
+
+const (
+	// DepthFirst writes each subtree contiguously. This is the only layout
+	// that lets DeserializeTreeFiltered skip over subtrees.
+	DepthFirst Layout = iota
+	// BreadthFirst writes the tree one level at a time, keeping the nodes
+	// near the root, which every query visits, close together. This makes
+	// a FrozenTree more cache, and page, friendly.
+	BreadthFirst
+)
+
+// Bits of the version 2 node flags byte
+const (
+	nodeFlagWord byte = 1 << iota
+	nodeFlagValues
+	nodeFlagBounds
+	nodeFlagWordCount
+
+	knownNodeFlags = nodeFlagWord | nodeFlagValues | nodeFlagBounds | nodeFlagWordCount
+)
+
+// maxDictionaryEntries bounds the size of the label dictionary.
+const maxDictionaryEntries = 1 << 16
+
+// A FormatError describes where a serialized tree is malformed. It matches
+// ErrInvalidFormat with errors.Is, and also the underlying error, such as
+// io.ErrUnexpectedEOF for a truncated file, if there is one.
+type FormatError struct {
+	Offset int64  // offset in the file of the bad data
+	Node   int    // index of the node record in file order, -1 if not known
+	Reason string // what is wrong, e.g. "child count 300"
+	Err    error  // the underlying error, may be nil
+}
+
+func (e *FormatError) Error() string {
+	s := fmt.Sprintf("%v: %s at offset %d", ErrInvalidFormat, e.Reason, e.Offset)
+	if e.Node >= 0 {
+		s += fmt.Sprintf(" (node %d)", e.Node)
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *FormatError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrInvalidFormat}
+	}
+	return []error{ErrInvalidFormat, e.Err}
+}
+
+func formatError(off int64, node int, format string, args ...any) error {
+	return &FormatError{Offset: off, Node: node, Reason: fmt.Sprintf(format, args...)}
+}
+
+// SerializeOptions configures SerializeWithOptions. The zero value gives the
+// output of Serialize.
+type SerializeOptions struct {
+	// LabelDictionary stores labels that occur many times, such as common
+	// word endings, once in a dictionary at the start of the file and refers
+	// to them by index. This usually makes natural language dictionaries
+	// noticeably smaller at the cost of an extra pass over the tree.
+	LabelDictionary bool
+
+	// Layout is the order the nodes are written in, DepthFirst by default.
+	Layout Layout
+
+	// LengthBounds records in each node the lengths of the shortest and
+	// longest words below it. A FrozenTree uses them to give up on words and
+	// prefixes that are too long or too short for a subtree without
+	// descending any further, which speeds up workloads that are mostly
+	// misses at the cost of a few bytes a node.
+	LengthBounds bool
+
+	// WordCounts records in each node the number of words in its subtree,
+	// which lets FrozenTree.WordToID and IDToWord find the rank of a word
+	// from the nodes on its path and their siblings alone, at the cost of a
+	// few bytes a node. Without them the words of every subtree passed over
+	// are counted.
+	WordCounts bool
+
+	// BufferSize is the size of the buffer used to write the file, 0 for the
+	// bufio default of 4096 bytes.
+	BufferSize int
+
+	// Progress, if set, is told how far the writing has got, see Progress.
+	Progress func(Progress)
+
+	// AnonymizeKey, if set, writes the tree as anonymized by Anonymize with
+	// the key, for files of a tree's shape that can be shared without
+	// sharing its words. The words read back are the pseudonyms.
+	AnonymizeKey []byte
+
+	// values encodes the value of a node, set by MultiMap
+	values func(value any) ([]byte, error)
+}
+
+// written returns the tree whose encoding with opts is that of t: t anonymized
+// if opts asks for it, and in canonical form.
+func (opts SerializeOptions) written(t *Tree) *Tree {
+	if opts.AnonymizeKey != nil {
+		t = t.Anonymize(opts.AnonymizeKey)
+	}
+	if !t.isCanonical() {
+		t = t.rebuild()
+	}
+	return t
+}
+
+// Serialize a tree into an io.Writer. The serialized format is binary.
+func (t *Tree) Serialize(w io.Writer) error {
+	return t.SerializeWithOptions(w, SerializeOptions{})
+}
+
+// SerializeWithOptions is Serialize with control over how the tree is encoded.
+// DeserializeTree reads the output whatever the options.
+func (t *Tree) SerializeWithOptions(w io.Writer, opts SerializeOptions) error {
+	return NewSerializer(opts).Serialize(w, t)
+}
+
+// A Serializer writes trees with a fixed set of options, reusing its buffers
+// from one tree to the next. This saves allocations when writing many small
+// trees. A Serializer must not be used by more than one goroutine at a time.
+type Serializer struct {
+	opts  SerializeOptions
+	buf   *bufio.Writer
+	sizes map[*Node]uint64
+}
+
+// NewSerializer returns a Serializer that writes trees as configured by opts.
+func NewSerializer(opts SerializeOptions) *Serializer {
+	return &Serializer{opts: opts}
+}
+
+// Serialize writes t to w, the same as t.SerializeWithOptions.
+func (s *Serializer) Serialize(w io.Writer, t *Tree) error {
+	return s.serialize(w, t, nil)
+}
+
+// serialize writes t to w, and its shards to theirs, see SerializeWithShards.
+func (s *Serializer) serialize(w io.Writer, t *Tree, shards []ShardWriter) error {
+	t = s.opts.written(t)
+	if int(uint32(t.nodes)) != t.nodes {
+		return ErrTooLarge
+	}
+	opts := s.opts
+
+	if s.buf == nil {
+		s.buf = bufio.NewWriterSize(w, opts.BufferSize)
+	} else {
+		s.buf.Reset(w)
+	}
+	// Don't hold on to w or the tree once done
+	defer s.buf.Reset(nil)
+	defer clear(s.sizes)
+
+	buf := s.buf
+	e := &encoder{w: buf, off: headerSize, bounds: opts.LengthBounds, progress: newProgress(opts.Progress, t.nodes)}
+	if opts.WordCounts {
+		e.counts = countWords(t.root)
+	}
+	var flags uint32
+	if opts.LabelDictionary {
+		flags |= headerFlagLabelDictionary
+	}
+	if opts.Layout == BreadthFirst {
+		flags |= headerFlagBreadthFirst
+	}
+	var dict []string
+	if opts.LabelDictionary {
+		dict = buildDictionary(t.root)
+	}
+	if err := e.writeStart(t.nodes, flags, dict); err != nil {
+		return err
+	}
+	if opts.values != nil {
+		var err error
+		if e.values, err = encodeValues(t.root, "", opts.values); err != nil {
+			return err
+		}
+	}
+
+	switch opts.Layout {
+	case DepthFirst:
+		if s.sizes == nil {
+			s.sizes = make(map[*Node]uint64, t.nodes)
+		}
+		e.sizes = s.sizes
+		if len(shards) == 0 {
+			e.measure(t.root)
+			if err := e.writeNode(t.root); err != nil {
+				return err
+			}
+			break
+		}
+		e.subtreeNodes = make(map[*Node]int, t.nodes)
+		e.measure(t.root)
+		if err := e.writeShards(t.root, shards, flags, dict, opts.BufferSize); err != nil {
+			return err
+		}
+	case BreadthFirst:
+		if err := e.writeBreadthFirst(t.root); err != nil {
+			return err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	e.progress.report(e.off, true)
+	return nil
+}
+
+// SerializedSize returns the number of bytes Serialize would write for t. The
+// tree is measured rather than encoded, so callers can size buffers, set a
+// Content-Length or enforce a budget before writing anything. It returns
+// ErrTooLarge if t can't be written.
+func (t *Tree) SerializedSize() (int64, error) {
+	return t.SerializedSizeWithOptions(SerializeOptions{})
+}
+
+// SerializedSizeWithOptions is SerializedSize for SerializeWithOptions.
+func (t *Tree) SerializedSizeWithOptions(opts SerializeOptions) (int64, error) {
+	t = opts.written(t)
+	if int(uint32(t.nodes)) != t.nodes {
+		return 0, ErrTooLarge
+	}
+	e := &encoder{off: headerSize, bounds: opts.LengthBounds}
+	if opts.WordCounts {
+		e.counts = countWords(t.root)
+	}
+	if opts.LabelDictionary {
+		e.off += e.useDictionary(buildDictionary(t.root))
+	}
+	if opts.values != nil {
+		var err error
+		if e.values, err = encodeValues(t.root, "", opts.values); err != nil {
+			return 0, err
+		}
+	}
+
+	switch opts.Layout {
+	case DepthFirst:
+		e.sizes = make(map[*Node]uint64, t.nodes)
+		e.off += int64(e.measure(t.root))
+	case BreadthFirst:
+		var walk func(node *Node)
+		walk = func(node *Node) {
+			e.off += int64(e.headSize(node))
+			if len(node.children) > 0 {
+				e.off += 4
+			}
+			for _, child := range node.children {
+				walk(child)
+			}
+		}
+		walk(t.root)
+		if e.off > math.MaxUint32 {
+			return 0, ErrTooLarge
+		}
+	}
+	return e.off, nil
+}
+
+// DeserializeTree returns a *Tree from an io.Reader. Returns an error matching
+// ErrUnsupportedVersion if the serialize format is an unsupported version, or a
+// *FormatError, which matches ErrInvalidFormat, if the file is unrecognized,
+// corrupt or cut short, or if reading it fails. The FormatError wraps the read
+// error, io.ErrUnexpectedEOF for a file that ends early. Nothing is returned
+// but the error in these cases, never a partly decoded tree.
+func DeserializeTree(r io.Reader) (*Tree, error) {
+	return deserializeTree(bufio.NewReader(r), nil, nil)
+}
+
+// DeserializeInto replaces the words of t with the tree read from r, in the
+// same way as DeserializeTree, keeping the options t was created with and the
+// revisions committed with WithHistory. The limits of WithMaxWordLength and
+// WithMaxDepth apply to the tree read, a file with a longer word or a deeper
+// node gives a *FormatError. Everything else starts again as for a
+// deserialized tree: the split count of Stats is 0, the profile and duplicate
+// report are empty and the new nodes belong to t alone until the next Commit.
+// The new tree is built out of t's nodes and their child maps before any more
+// are allocated, so a service that reloads its dictionary every few minutes
+// doesn't leave the garbage collector a whole tree to clean up each time.
+// Nodes that belong to revisions kept by WithHistory are not reused. Nothing
+// must be using t or its nodes during or after the call, a Cursor is made
+// stale. If it fails t is left empty.
+func (t *Tree) DeserializeInto(r io.Reader) error {
+	var free []*Node
+	if t.history == nil {
+		free = make([]*Node, 0, t.nodes)
+		var walk func(node *Node)
+		walk = func(node *Node) {
+			free = append(free, node)
+			for _, child := range node.children {
+				walk(child)
+			}
+		}
+		walk(t.root)
+	}
+
+	d := &decoder{r: &reader{r: bufio.NewReader(r)}, node: -1, free: free, alloc: t.alloc}
+	tree, err := d.decode()
+	if err == nil {
+		err = t.checkLimits(tree.root, d.r.off)
+	}
+	if err != nil {
+		tree = NewTree()
+	}
+	t.root, t.nodes, t.words = tree.root, tree.nodes, tree.words
+	// The decoded nodes are of generation 0 and shared with no revision
+	t.splits, t.gen = 0, 0
+	t.ResetProfile()
+	t.ResetDuplicates()
+	t.misses.clear()
+	clear(t.dirty)
+	t.finger = nil
+	t.changes++
+	return err
+}
+
+// checkLimits returns a *FormatError if the subtree at root, decoded from a
+// file of size bytes, has a word longer or a node deeper than t allows.
+func (t *Tree) checkLimits(root *Node, size int64) error {
+	if t.maxWordLen <= 0 && t.maxDepth <= 0 {
+		return nil
+	}
+	var walk func(node *Node, depth, length int) error
+	walk = func(node *Node, depth, length int) error {
+		length += len(node.label)
+		if t.maxDepth > 0 && depth > t.maxDepth {
+			return formatError(size, -1, "node at depth %d, the limit is %d", depth, t.maxDepth)
+		}
+		if t.maxWordLen > 0 && node.isWord && length > t.maxWordLen {
+			return formatError(size, -1, "word of length %d, the limit is %d", length, t.maxWordLen)
+		}
+		for _, child := range node.children {
+			if err := walk(child, depth+1, length); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, 0, 0)
+}
+
+// Sanitize is how DeserializeTreeWithOptions treats a file that decodes to a
+// tree which isn't canonical, that is one Insert could not have built.
+type Sanitize int
+
+const (
+	// SanitizeNone trusts the file and uses the tree as it is.
+	SanitizeNone Sanitize = iota
+	// SanitizeReject returns an error matching ErrNotCanonical.
+	SanitizeReject
+	// SanitizeRepair rebuilds the tree from the words it holds.
+	SanitizeRepair
+)
+
+// DeserializeOptions configures DeserializeTreeWithOptions. The zero value
+// gives the behavior of DeserializeTree.
+type DeserializeOptions struct {
+	// Sanitize checks that the decoded tree is canonical: that every node
+	// below the root has a non-empty label starting with its key, and is
+	// either a word or has at least two children. A valid file only ever
+	// holds canonical trees, but the format can't rule the others out.
+	Sanitize Sanitize
+
+	// BufferSize is the size of the buffer used to read the file, 0 for the
+	// bufio default of 4096 bytes.
+	BufferSize int
+
+	// Progress, if set, is told how far the reading has got, see Progress.
+	// With Workers it is called as each subtree of the root is done, from
+	// the goroutine that decoded it, but never concurrently.
+	Progress func(Progress)
+
+	// Workers is the number of goroutines that decode the subtrees of the
+	// root's children of a depth first file at the same time, which cuts the
+	// time to load a large file by about that factor. The whole file is read
+	// into memory first. 0 or 1 decodes as the file is read, on the calling
+	// goroutine, as do files in the BreadthFirst layout and version 1 files.
+	// A ValueCodec's Unmarshal is then called concurrently.
+	Workers int
+
+	// values decodes the values of a node, set by MultiMap. Values are
+	// skipped if it is nil.
+	values func(data []byte) (any, error)
+}
+
+// DeserializeTreeWithOptions is DeserializeTree configured by opts.
+func DeserializeTreeWithOptions(r io.Reader, opts DeserializeOptions) (*Tree, error) {
+	return NewDeserializer(opts).Deserialize(r)
+}
+
+// A Deserializer reads trees with a fixed set of options, reusing its buffer
+// from one tree to the next. A Deserializer must not be used by more than one
+// goroutine at a time.
+type Deserializer struct {
+	opts DeserializeOptions
+	buf  *bufio.Reader
+}
+
+// NewDeserializer returns a Deserializer that reads trees as configured by
+// opts.
+func NewDeserializer(opts DeserializeOptions) *Deserializer {
+	return &Deserializer{opts: opts}
+}
+
+// Deserialize reads a tree from r, the same as DeserializeTreeWithOptions. Like
+// DeserializeTree it may read past the end of the tree, into its buffer.
+func (d *Deserializer) Deserialize(r io.Reader) (*Tree, error) {
+	opts := d.opts
+	switch {
+	case d.buf != nil:
+		d.buf.Reset(r)
+	case opts.BufferSize > 0:
+		d.buf = bufio.NewReaderSize(r, opts.BufferSize)
+	default:
+		d.buf = bufio.NewReader(r)
+	}
+	defer d.buf.Reset(nil)
+
+	var tree *Tree
+	var err error
+	p := newProgress(opts.Progress, 0)
+	if opts.Workers > 1 {
+		tree, err = decodeParallel(d.buf, opts.values, opts.Workers, p)
+	} else {
+		dec := &decoder{r: &reader{r: d.buf}, values: opts.values, node: -1, progress: p}
+		tree, err = dec.decode()
+	}
+	if err != nil || opts.Sanitize == SanitizeNone {
+		return tree, err
+	}
+	if err := tree.checkCanonical(); err != nil {
+		if opts.Sanitize == SanitizeReject {
+			return nil, err
+		}
+		tree = tree.rebuild()
+	}
+	return tree, nil
+}
+
+// DeserializeTreeFiltered is like DeserializeTree but only loads the words that
+// start with one of prefixes, the rest of the file is skipped over without
+// being decoded. An empty string in prefixes selects every word, a nil or empty
+// prefixes selects none. Filtering version 1 files, or files written in the
+// BreadthFirst layout, works but has to decode the whole tree first.
+func DeserializeTreeFiltered(r io.Reader, prefixes []string) (*Tree, error) {
+	if prefixes == nil {
+		prefixes = []string{}
+	}
+	return deserializeTree(bufio.NewReader(r), prefixes, nil)
+}
+
+func deserializeTree(buf *bufio.Reader, prefixes []string, values func([]byte) (any, error)) (*Tree, error) {
+	d := &decoder{r: &reader{r: buf}, prefixes: prefixes, values: values, node: -1}
+	return d.decode()
+}
+
+// decodeParallel reads all of buf and then decodes it as deserializeTree does,
+// with the subtrees of the root's children of a depth first file decoded on up
+// to workers goroutines.
+func decodeParallel(buf *bufio.Reader, values func([]byte) (any, error), workers int, p *progress) (*Tree, error) {
+	data, err := io.ReadAll(buf)
+	if err != nil {
+		return nil, &FormatError{Offset: int64(len(data)), Node: -1, Reason: "reading file", Err: err}
+	}
+	d := &decoder{r: &reader{r: bufio.NewReader(bytes.NewReader(data))}, values: values, node: -1, data: data, workers: workers, progress: p}
+	return d.decode()
+}
+
+// decode reads a whole tree with d.
+func (d *decoder) decode() (*Tree, error) {
+	prefixes := d.prefixes
+	tree := &Tree{root: d.newNode(), nodes: 1}
+	d.makeChildren(tree.root, 0)
+	d.tree = tree
+
+	// Read the header in
+	hdr := SerializedTreeHeader{}
+	if err := binary.Read(d.r, binary.BigEndian, &hdr); err != nil {
+		return nil, d.readError(0, "header", err)
+	}
+	if hdr.Magic != CtreeMagic {
+		return nil, formatError(0, -1, "magic number %#x", hdr.Magic)
+	}
+	if d.progress != nil {
+		d.progress.total = int(hdr.Nodes)
+	}
+
+	switch hdr.Version {
+	case 1:
+		tree.nodes = int(hdr.Nodes)
+		if err := d.decodeNodeV1(tree.root); err != nil {
+			return nil, err
+		}
+		if prefixes != nil {
+			filterNode(tree.root, "", prefixes)
+			pruneNode(tree.root)
+			tree.recount()
+		}
+	case 2:
+		var flags uint32
+		if err := binary.Read(d.r, binary.BigEndian, &flags); err != nil {
+			return nil, d.readError(12, "header flags", err)
+		}
+		if flags&^knownHeaderFlags != 0 {
+			return nil, formatError(12, -1, "unknown header flags %#x", flags&^knownHeaderFlags)
+		}
+
+		if flags&headerFlagLabelDictionary != 0 {
+			if err := d.readDictionary(); err != nil {
+				return nil, err
+			}
+		}
+		tree.nodes = 0
+		if flags&headerFlagBreadthFirst != 0 {
+			// Subtrees aren't contiguous so there's nothing to skip, decode
+			// everything and filter afterwards.
+			if err := d.decodeBreadthFirst(tree.root); err != nil {
+				return nil, err
+			}
+			if tree.nodes != int(hdr.Nodes) {
+				return nil, formatError(d.r.off, -1, "header records %d nodes, found %d", hdr.Nodes, tree.nodes)
+			}
+			if prefixes != nil {
+				filterNode(tree.root, "", prefixes)
+				pruneNode(tree.root)
+				tree.recount()
+			}
+		} else if prefixes == nil {
+			decode := d.decodeNode
+			if d.workers > 1 {
+				decode = d.decodeChildrenParallel
+			}
+			if err := decode(tree.root, nil); err != nil {
+				return nil, err
+			}
+			if tree.nodes != int(hdr.Nodes) {
+				return nil, formatError(d.r.off, -1, "header records %d nodes, found %d", hdr.Nodes, tree.nodes)
+			}
+		} else {
+			if err := d.decodeFiltered(tree.root, nil, "", -1); err != nil {
+				return nil, err
+			}
+			pruneNode(tree.root)
+			tree.recount()
+		}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, hdr.Version)
+	}
+
+	computeLengths(tree.root, 0)
+	d.progress.report(d.r.off, true)
+	return tree, nil
+}
+
+// recount recomputes the node and word counts of t by walking the tree.
+func (t *Tree) recount() {
+	t.nodes, t.words = 0, 0
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		t.nodes++
+		if node.isWord {
+			t.words++
+		}
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	walk(t.root)
+}
+
+// selected reports whether some prefix in prefixes is a prefix of path, in which
+// case everything below path is wanted.
+func selected(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// leadsTo reports whether path is a proper prefix of some prefix in prefixes,
+// in which case part of what is below path may be wanted.
+func leadsTo(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if len(p) > len(path) && strings.HasPrefix(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterNode removes everything below node, whose path from the root is path,
+// that doesn't start with one of prefixes. It can leave behind empty or
+// mergeable nodes, see pruneNode.
+func filterNode(node *Node, path string, prefixes []string) {
+	if selected(path, prefixes) {
+		return
+	}
+	node.isWord = false
+	for k, child := range node.children {
+		childPath := path + child.label
+		if !selected(childPath, prefixes) && !leadsTo(childPath, prefixes) {
+			delete(node.children, k)
+			continue
+		}
+		filterNode(child, childPath, prefixes)
+	}
+}
+
+// pruneNode restores the compressed trie invariants below node after some of
+// its words have been removed: children that no longer hold any words are
+// dropped and children that aren't words and only have a single child are
+// merged with that child. It returns false if node itself holds no words.
+func pruneNode(node *Node) bool {
+	for k, child := range node.children {
+		if !pruneNode(child) {
+			delete(node.children, k)
+			continue
+		}
+		if !child.isWord && len(child.children) == 1 {
+			for _, grandchild := range child.children {
+				grandchild.label = child.label + grandchild.label
+				node.children[k] = grandchild
+			}
+		}
+	}
+	return node.isWord || len(node.children) > 0
+}
+
+// buildDictionary returns the labels below root that are worth putting in a
+// label dictionary, most valuable first so that they get the shortest
+// references.
+func buildDictionary(root *Node) []string {
+	counts := make(map[string]int)
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		for _, child := range node.children {
+			counts[child.label[1:]]++
+			walk(child)
+		}
+	}
+	walk(root)
+
+	// Assume a two byte reference, an entry is worth adding if writing it out
+	// once and referring to it is cheaper than repeating it.
+	savings := func(label string) int {
+		literal := uvarintLen(uint64(len(label))<<1) + len(label)
+		entry := uvarintLen(uint64(len(label))) + len(label)
+		return counts[label]*(literal-2) - entry
+	}
+	var dict []string
+	for label := range counts {
+		if savings(label) > 0 {
+			dict = append(dict, label)
+		}
+	}
+	slices.SortFunc(dict, func(a, b string) int {
+		if c := savings(b) - savings(a); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	if len(dict) > maxDictionaryEntries {
+		dict = dict[:maxDictionaryEntries]
+	}
+	return dict
+}
+
+// encoder writes the version 2 node format.
+type encoder struct {
+	w        *bufio.Writer
+	off      int64            // offset in the file of the next byte written
+	sizes    map[*Node]uint64 // encoded size of each node's subtree
+	dict     map[string]int   // index of each label in the dictionary, nil if there is none
+	values   map[*Node][]byte // encoded values of each node that has them
+	bounds   bool             // whether to write length bounds
+	counts   map[*Node]uint64 // words in each node's subtree, nil unless they are written
+	progress *progress        // nil unless SerializeOptions.Progress is set
+
+	subtreeNodes map[*Node]int // nodes in each node's subtree, filled in by measure if not nil
+	scratch      [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) write(b []byte) error {
+	n, err := e.w.Write(b)
+	e.off += int64(n)
+	return err
+}
+
+func (e *encoder) writeString(s string) error {
+	n, err := e.w.WriteString(s)
+	e.off += int64(n)
+	return err
+}
+
+func (e *encoder) writeByte(b byte) error {
+	if err := e.w.WriteByte(b); err != nil {
+		return err
+	}
+	e.off++
+	return nil
+}
+
+// writeStart writes the header of a file of nodes nodes with flags, followed
+// by dict if the flags say there is a label dictionary.
+func (e *encoder) writeStart(nodes int, flags uint32, dict []string) error {
+	hdr := SerializedTreeHeader{
+		Magic:   CtreeMagic,
+		Version: Version,
+		Nodes:   uint32(nodes),
+	}
+	if err := binary.Write(e.w, binary.BigEndian, hdr); err != nil {
+		return err
+	}
+	if err := binary.Write(e.w, binary.BigEndian, flags); err != nil {
+		return err
+	}
+	if flags&headerFlagLabelDictionary == 0 {
+		return nil
+	}
+	return e.writeDictionary(dict)
+}
+
+func (e *encoder) writeDictionary(dict []string) error {
+	e.useDictionary(dict)
+	if err := e.writeUvarint(uint64(len(dict))); err != nil {
+		return err
+	}
+	for _, label := range dict {
+		if err := e.writeUvarint(uint64(len(label))); err != nil {
+			return err
+		}
+		if err := e.writeString(label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useDictionary makes e refer to the labels in dict by index, returning the
+// encoded size of the dictionary.
+func (e *encoder) useDictionary(dict []string) int64 {
+	e.dict = make(map[string]int, len(dict))
+	n := int64(uvarintLen(uint64(len(dict))))
+	for i, label := range dict {
+		e.dict[label] = i
+		n += int64(uvarintLen(uint64(len(label))) + len(label))
+	}
+	return n
+}
+
+// labelRef returns the uvarint that starts the encoding of label, and whether
+// label is written out after it.
+func (e *encoder) labelRef(label string) (uint64, bool) {
+	if e.dict == nil {
+		return uint64(len(label)), true
+	}
+	if i, ok := e.dict[label]; ok {
+		return uint64(i)<<1 | 1, false
+	}
+	return uint64(len(label)) << 1, true
+}
+
+// headSize returns the encoded size of the start of node's record, which is
+// common to both layouts: the label, flags, child count and keys.
+func (e *encoder) headSize(node *Node) uint64 {
+	label := e.labelTail(node)
+	ref, literal := e.labelRef(label)
+	n := uint64(uvarintLen(ref) + 1 + uvarintLen(uint64(len(node.children))) + len(node.children))
+	if literal {
+		n += uint64(len(label))
+	}
+	if v, ok := e.values[node]; ok {
+		n += uint64(uvarintLen(uint64(len(v))) + len(v))
+	}
+	if lo, span, ok := e.lengthBounds(node); ok {
+		n += uint64(uvarintLen(lo) + uvarintLen(span))
+	}
+	if e.counts != nil {
+		n += uint64(uvarintLen(e.counts[node]))
+	}
+	return n
+}
+
+// lengthBounds returns the length bounds recorded for node, if there are any:
+// the length of the shortest word below it and how much longer the longest is.
+func (e *encoder) lengthBounds(node *Node) (lo, span uint64, ok bool) {
+	if !e.bounds || node.lenHi == 0 {
+		return 0, 0, false
+	}
+	return uint64(node.lenLo), uint64(node.lenHi - 1 - node.lenLo), true
+}
+
+// countWords returns the number of words in the subtree of each node below
+// root, including root.
+func countWords(root *Node) map[*Node]uint64 {
+	counts := make(map[*Node]uint64)
+	var walk func(node *Node) uint64
+	walk = func(node *Node) uint64 {
+		var n uint64
+		if node.isWord {
+			n++
+		}
+		for _, child := range node.children {
+			n += walk(child)
+		}
+		counts[node] = n
+		return n
+	}
+	walk(root)
+	return counts
+}
+
+// encodeValues returns the encoded values of the word nodes below node, whose
+// path from the root is path, that have them.
+func encodeValues(node *Node, path string, encode func(any) ([]byte, error)) (map[*Node][]byte, error) {
+	values := make(map[*Node][]byte)
+	var walk func(node *Node, path string) error
+	walk = func(node *Node, path string) error {
+		if node.isWord && node.value != nil {
+			v, err := encode(node.value)
+			if err != nil {
+				return fmt.Errorf("compressedtrie: encoding values of %q: %w", path, err)
+			}
+			values[node] = v
+		}
+		for _, child := range node.children {
+			if err := walk(child, path+child.label); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return values, walk(node, path)
+}
+
+// measure returns the encoded size of node's subtree in the depth first
+// layout, recording it and the sizes of all the subtrees below it in e.sizes.
+func (e *encoder) measure(node *Node) uint64 {
+	n := e.headSize(node)
+	for _, child := range node.children {
+		size := e.measure(child)
+		n += uint64(uvarintLen(size)) + size
+	}
+	e.sizes[node] = n
+	if e.subtreeNodes != nil {
+		nodes := 1
+		for _, child := range node.children {
+			nodes += e.subtreeNodes[child]
+		}
+		e.subtreeNodes[node] = nodes
+	}
+	return n
+}
+
+func (e *encoder) writeUvarint(v uint64) error {
+	n := binary.PutUvarint(e.scratch[:], v)
+	return e.write(e.scratch[:n])
+}
+
+// labelTail returns the part of node's label that is written out, which is all
+// of it except for the leading byte that the parent already stores as the key.
+// Only the root's label is empty.
+func (e *encoder) labelTail(node *Node) string {
+	if node.label == "" {
+		return ""
+	}
+	return node.label[1:]
+}
+
+// writeHead writes the start of node's record, see headSize, returning the
+// keys of node's children.
+func (e *encoder) writeHead(node *Node) ([]byte, error) {
+	label := e.labelTail(node)
+	ref, literal := e.labelRef(label)
+	if err := e.writeUvarint(ref); err != nil {
+		return nil, err
+	}
+	if literal {
+		if err := e.writeString(label); err != nil {
+			return nil, err
+		}
+	}
+
+	var flags byte
+	if node.isWord {
+		flags |= nodeFlagWord
+	}
+	v, hasValues := e.values[node]
+	if hasValues {
+		flags |= nodeFlagValues
+	}
+	lo, span, hasBounds := e.lengthBounds(node)
+	if hasBounds {
+		flags |= nodeFlagBounds
+	}
+	if e.counts != nil {
+		flags |= nodeFlagWordCount
+	}
+	if err := e.writeByte(flags); err != nil {
+		return nil, err
+	}
+	if hasValues {
+		if err := e.writeUvarint(uint64(len(v))); err != nil {
+			return nil, err
+		}
+		if err := e.write(v); err != nil {
+			return nil, err
+		}
+	}
+	if hasBounds {
+		if err := e.writeUvarint(lo); err != nil {
+			return nil, err
+		}
+		if err := e.writeUvarint(span); err != nil {
+			return nil, err
+		}
+	}
+	if e.counts != nil {
+		if err := e.writeUvarint(e.counts[node]); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(node.children))
+	if err := e.writeUvarint(uint64(len(keys))); err != nil {
+		return nil, err
+	}
+	if err := e.write(keys); err != nil {
+		return nil, err
+	}
+	e.progress.node(e.off)
+	return keys, nil
+}
+
+// writeNode writes node and its subtree in the depth first layout. e.sizes
+// must have been filled in by measure.
+func (e *encoder) writeNode(node *Node) error {
+	keys, err := e.writeRecord(node)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := e.writeNode(node.children[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeRecord writes node's record in the depth first layout, returning the
+// keys of its children.
+func (e *encoder) writeRecord(node *Node) ([]byte, error) {
+	keys, err := e.writeHead(node)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if err := e.writeUvarint(e.sizes[node.children[k]]); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// writeBreadthFirst writes the tree below root in the breadth first layout.
+func (e *encoder) writeBreadthFirst(root *Node) error {
+	// Put the nodes in level order, which is also the order their records
+	// are written in. The children of order[i] start at order[first[i]].
+	order := []*Node{root}
+	var first []int
+	for i := 0; i < len(order); i++ {
+		node := order[i]
+		first = append(first, len(order))
+		for _, k := range slices.Sorted(maps.Keys(node.children)) {
+			order = append(order, node.children[k])
+		}
+	}
+
+	// Work out where each record starts
+	offsets := make([]int64, len(order))
+	off := e.off
+	for i, node := range order {
+		offsets[i] = off
+		off += int64(e.headSize(node))
+		if len(node.children) > 0 {
+			off += 4
+		}
+	}
+	if off > math.MaxUint32 {
+		return ErrTooLarge
+	}
+
+	for i, node := range order {
+		if _, err := e.writeHead(node); err != nil {
+			return err
+		}
+		if len(node.children) > 0 {
+			var b [4]byte
+			binary.BigEndian.PutUint32(b[:], uint32(offsets[first[i]]))
+			if err := e.write(b[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// uvarintLen returns the number of bytes binary.PutUvarint uses to encode v.
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
+
+// reader keeps track of how far into the stream it is so that the decoder can
+// check, and skip over, subtrees by their encoded size.
+type reader struct {
+	r   *bufio.Reader
+	off int64
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.off += int64(n)
+	return n, err
+}
+
+func (r *reader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.off++
+	}
+	return b, err
+}
+
+func (r *reader) skip(n int64) error {
+	d, err := io.CopyN(io.Discard, r.r, n)
+	r.off += d
+	return err
+}
+
+// decoder reads the version 2 node format.
+type decoder struct {
+	r        *reader
+	tree     *Tree
+	dict     []string                  // the label dictionary, nil if there is none
+	prefixes []string                  // only used when filtering
+	values   func([]byte) (any, error) // decodes values, nil to skip them
+
+	node    int  // index of the record being read, for errors
+	skipped bool // whether records have been skipped, making node meaningless
+
+	free  []*Node   // nodes of an old tree to reuse, see DeserializeInto
+	alloc Allocator // where new nodes and labels come from, nil for the Go heap
+
+	data    []byte // the whole file, only when decoding in parallel
+	workers int    // goroutines decoding subtrees, see DeserializeOptions.Workers
+
+	progress *progress // nil unless DeserializeOptions.Progress is set
+}
+
+// newNode returns an empty node, reusing one from d.free if there are any.
+func (d *decoder) newNode() *Node {
+	n := len(d.free)
+	switch {
+	case n == 0 && d.alloc != nil:
+		return d.alloc.AllocNode()
+	case n == 0:
+		return &Node{}
+	}
+	node := d.free[n-1]
+	d.free = d.free[:n-1]
+	*node = Node{children: node.children}
+	return node
+}
+
+// makeChildren gives node an empty map for n children, reusing the map it has
+// if it came from d.free.
+func (d *decoder) makeChildren(node *Node, n int) {
+	if node.children != nil {
+		clear(node.children)
+		return
+	}
+	node.children = make(map[byte]*Node, n)
+}
+
+// index returns the index of the record being read, or -1 if it isn't known.
+func (d *decoder) index() int {
+	if d.skipped {
+		return -1
+	}
+	return d.node
+}
+
+// next returns the index of the record after the current one, or -1 if it
+// isn't known.
+func (d *decoder) next() int {
+	if d.skipped {
+		return -1
+	}
+	return d.node + 1
+}
+
+// errorf returns a FormatError for the current record.
+func (d *decoder) errorf(off int64, format string, args ...any) error {
+	return formatError(off, d.index(), format, args...)
+}
+
+// readError returns a FormatError for the failure err when reading what, which
+// started at off.
+func (d *decoder) readError(off int64, what string, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return &FormatError{Offset: off, Node: d.index(), Reason: "reading " + what, Err: err}
+}
+
+func (d *decoder) readUvarint(what string) (uint64, error) {
+	off := d.r.off
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, d.readError(off, what, err)
+	}
+	return v, nil
+}
+
+// skip skips the next n bytes without decoding them.
+func (d *decoder) skip(n int64) error {
+	if n < 0 {
+		return d.errorf(d.r.off, "subtree overruns its size by %d bytes", -n)
+	}
+	d.skipped = true
+	off := d.r.off
+	if err := d.r.skip(n); err != nil {
+		return d.readError(off, "skipped subtree", err)
+	}
+	return nil
+}
+
+func (d *decoder) readDictionary() error {
+	off := d.r.off
+	n, err := d.readUvarint("dictionary size")
+	if err != nil {
+		return err
+	}
+	if n > maxDictionaryEntries {
+		return d.errorf(off, "dictionary size %d", n)
+	}
+	d.dict = make([]string, n)
+	for i := range d.dict {
+		if d.dict[i], err = d.readString("dictionary entry"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readString reads a uvarint length prefixed string, what names it in errors.
+func (d *decoder) readString(what string) (string, error) {
+	n, err := d.readUvarint(what + " length")
+	if err != nil {
+		return "", err
+	}
+	return d.readBytes(nil, n, what)
+}
+
+// readBytes reads n bytes, returning them appended to lead as a string.
+func (d *decoder) readBytes(lead []byte, n uint64, what string) (string, error) {
+	off := d.r.off
+	if n > 1<<31 {
+		return "", d.errorf(off, "%s length %d", what, n)
+	}
+	b := make([]byte, len(lead)+int(n))
+	copy(b, lead)
+	if _, err := io.ReadFull(d.r, b[len(lead):]); err != nil {
+		return "", d.readError(off, what, err)
+	}
+	return string(b), nil
+}
+
+// readRecord reads a depth first layout record into node, returning the keys
+// of its children and the encoded sizes of their subtrees. lead is the key of
+// node in its parent, which is an empty slice for the root.
+func (d *decoder) readRecord(node *Node, lead []byte) ([]byte, []uint64, error) {
+	keys, err := d.readHead(node, lead)
+	if err != nil {
+		return nil, nil, err
+	}
+	sizes := make([]uint64, len(keys))
+	for i := range sizes {
+		if sizes[i], err = d.readUvarint("subtree size"); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return keys, sizes, nil
+}
+
+// readHead reads the label and flags of node, returning the keys of its
+// children. This is the part of the record common to both layouts.
+func (d *decoder) readHead(node *Node, lead []byte) ([]byte, error) {
+	d.node++
+
+	off := d.r.off
+	ref, err := d.readUvarint("label")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case d.dict == nil:
+		node.label, err = d.readBytes(lead, ref, "label")
+	case ref&1 == 0:
+		node.label, err = d.readBytes(lead, ref>>1, "label")
+	case ref>>1 < uint64(len(d.dict)):
+		node.label = string(lead) + d.dict[ref>>1]
+	default:
+		err = d.errorf(off, "dictionary reference %d", ref>>1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.alloc != nil {
+		node.label = d.alloc.AllocLabel(node.label)
+	}
+
+	off = d.r.off
+	flags, err := d.r.ReadByte()
+	if err != nil {
+		return nil, d.readError(off, "node flags", err)
+	}
+	if flags&^knownNodeFlags != 0 || flags&nodeFlagValues != 0 && flags&nodeFlagWord == 0 {
+		return nil, d.errorf(off, "node flags %#x", flags)
+	}
+	node.isWord = flags&nodeFlagWord != 0
+	if flags&nodeFlagValues != 0 {
+		off = d.r.off
+		v, err := d.readString("values")
+		if err != nil {
+			return nil, err
+		}
+		if d.values != nil {
+			if node.value, err = d.values([]byte(v)); err != nil {
+				return nil, &FormatError{Offset: off, Node: d.index(), Reason: "decoding values", Err: err}
+			}
+		}
+	}
+	if flags&nodeFlagBounds != 0 {
+		// The lengths are recomputed once the tree is decoded
+		for _, what := range []string{"shortest word length", "word length span"} {
+			if _, err := d.readUvarint(what); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if flags&nodeFlagWordCount != 0 {
+		// Only a FrozenTree uses the count
+		if _, err := d.readUvarint("word count"); err != nil {
+			return nil, err
+		}
+	}
+
+	off = d.r.off
+	n, err := d.readUvarint("child count")
+	if err != nil {
+		return nil, err
+	}
+	if n > 256 {
+		return nil, d.errorf(off, "child count %d", n)
+	}
+	off = d.r.off
+	keys := make([]byte, n)
+	if _, err := io.ReadFull(d.r, keys); err != nil {
+		return nil, d.readError(off, "child keys", err)
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			return nil, d.errorf(off+int64(i), "child key %#x out of order", keys[i])
+		}
+	}
+	d.progress.node(d.r.off)
+	return keys, nil
+}
+
+// decodeBreadthFirst reads the tree in the breadth first layout into root.
+func (d *decoder) decodeBreadthFirst(root *Node) error {
+	// The children whose records are still to be read, in the order they
+	// appear. first is the offset recorded for the first child of a node,
+	// -1 for the other children.
+	type pending struct {
+		parent *Node
+		key    byte
+		first  int64
+	}
+	var queue []pending
+
+	read := func(node *Node, lead []byte) error {
+		keys, err := d.readHead(node, lead)
+		if err != nil {
+			return err
+		}
+		d.tree.nodes++
+		if node.isWord {
+			d.tree.words++
+		}
+		d.makeChildren(node, len(keys))
+		if len(keys) == 0 {
+			return nil
+		}
+
+		var b [4]byte
+		off := d.r.off
+		if _, err := io.ReadFull(d.r, b[:]); err != nil {
+			return d.readError(off, "child offset", err)
+		}
+		first := int64(binary.BigEndian.Uint32(b[:]))
+		for _, k := range keys {
+			queue = append(queue, pending{node, k, first})
+			first = -1
+		}
+		return nil
+	}
+
+	if err := read(root, nil); err != nil {
+		return err
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p.first >= 0 && p.first != d.r.off {
+			return formatError(d.r.off, d.node+1, "first child recorded at offset %d", p.first)
+		}
+		child := d.newNode()
+		if err := read(child, []byte{p.key}); err != nil {
+			return err
+		}
+		p.parent.children[p.key] = child
+	}
+	return nil
+}
+
+// decodeNode reads node, whose key in its parent is lead, and everything below
+// it.
+func (d *decoder) decodeNode(node *Node, lead []byte) error {
+	keys, sizes, err := d.readRecord(node, lead)
+	if err != nil {
+		return err
+	}
+	d.tree.nodes++
+	if node.isWord {
+		d.tree.words++
+	}
+
+	d.makeChildren(node, len(keys))
+	for i, k := range keys {
+		child := d.newNode()
+		start, index := d.r.off, d.next()
+		if err := d.decodeNode(child, keys[i:i+1]); err != nil {
+			return err
+		}
+		if uint64(d.r.off-start) != sizes[i] {
+			return subtreeSizeError(start, index, sizes[i], d.r.off-start)
+		}
+		node.children[k] = child
+	}
+
+	return nil
+}
+
+// decodeChildrenParallel is decodeNode for the root, decoding the subtree of
+// each child with its own decoder on up to d.workers goroutines. The record
+// gives the offset of every subtree in d.data, so they can all start at once.
+// Errors in a subtree don't know the index of the node.
+func (d *decoder) decodeChildrenParallel(node *Node, lead []byte) error {
+	keys, sizes, err := d.readRecord(node, lead)
+	if err != nil {
+		return err
+	}
+	d.tree.nodes++
+	if node.isWord {
+		d.tree.words++
+	}
+	d.makeChildren(node, len(keys))
+
+	type subtree struct {
+		child *Node
+		tree  Tree // node and word counts
+		err   error
+	}
+	if d.progress != nil {
+		d.progress.off = d.r.off
+	}
+	subtrees := make([]subtree, len(keys))
+	sem := make(chan struct{}, d.workers)
+	var wg sync.WaitGroup
+
+	// The workers share the allocator, which needn't be safe for concurrent
+	// use. They don't report progress node by node, each subtree is added to
+	// it once done, see DeserializeOptions.Progress.
+	alloc := d.alloc
+	if alloc != nil {
+		alloc = &lockedAllocator{a: alloc}
+	}
+	start := d.r.off
+	for i := range keys {
+		size := sizes[i]
+		if size > uint64(int64(len(d.data))-start) {
+			// Let the workers already started finish before returning
+			err = &FormatError{Offset: start, Node: -1, Reason: "reading child subtree", Err: io.ErrUnexpectedEOF}
+			break
+		}
+		end := start + int64(size)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, start int64) {
+			defer func() { <-sem; wg.Done() }()
+			s := &subtrees[i]
+			sub := &decoder{
+				r:       &reader{r: bufio.NewReader(bytes.NewReader(d.data[start:end])), off: start},
+				tree:    &s.tree,
+				dict:    d.dict,
+				values:  d.values,
+				skipped: true,
+				alloc:   alloc,
+			}
+			s.child = sub.newNode()
+			if s.err = sub.decodeNode(s.child, keys[i:i+1]); s.err == nil && sub.r.off != end {
+				s.err = subtreeSizeError(start, -1, size, sub.r.off-start)
+			}
+			if s.err == nil {
+				d.progress.add(s.tree.nodes, int64(size))
+			}
+		}(i, start)
+		start = end
+	}
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+
+	for i, s := range subtrees {
+		if s.err != nil {
+			return s.err
+		}
+		node.children[keys[i]] = s.child
+		d.tree.nodes += s.tree.nodes
+		d.tree.words += s.tree.words
+	}
+	return d.skip(start - d.r.off)
+}
+
+// decodeFiltered reads node, whose key in its parent is lead and whose parent's
+// path from the root is parentPath, and the parts of the tree below it that
+// lead to d.prefixes. Subtrees that can't hold a selected word are skipped. end
+// is the offset at which node's subtree ends, or -1 for the root. Node and word
+// counts are not maintained.
+func (d *decoder) decodeFiltered(node *Node, lead []byte, parentPath string, end int64) error {
+	keys, sizes, err := d.readRecord(node, lead)
+	if err != nil {
+		return err
+	}
+	d.makeChildren(node, len(keys))
+
+	path := parentPath + node.label
+	if selected(path, d.prefixes) {
+		// Everything below here is wanted
+		for i, k := range keys {
+			child := d.newNode()
+			start, index := d.r.off, d.next()
+			if err := d.decodeNode(child, keys[i:i+1]); err != nil {
+				return err
+			}
+			if uint64(d.r.off-start) != sizes[i] {
+				return subtreeSizeError(start, index, sizes[i], d.r.off-start)
+			}
+			node.children[k] = child
+		}
+		return nil
+	}
+
+	node.isWord = false
+	node.value = nil
+	if end >= 0 && !leadsTo(path, d.prefixes) {
+		// The label took us away from all of the prefixes, skip the rest of the
+		// subtree. pruneNode will remove the now empty node.
+		node.children = nil
+		return d.skip(end - d.r.off)
+	}
+
+	for i, k := range keys {
+		start, index := d.r.off, d.next()
+		if !selectsKey(path, k, d.prefixes) {
+			if err := d.skip(int64(sizes[i])); err != nil {
+				return err
+			}
+			continue
+		}
+
+		child := d.newNode()
+		if err := d.decodeFiltered(child, keys[i:i+1], path, start+int64(sizes[i])); err != nil {
+			return err
+		}
+		if uint64(d.r.off-start) != sizes[i] {
+			return subtreeSizeError(start, index, sizes[i], d.r.off-start)
+		}
+		node.children[k] = child
+	}
+
+	return nil
+}
+
+// subtreeSizeError reports that the subtree of node index at off was recorded
+// as size bytes long but decoded from n bytes.
+func subtreeSizeError(off int64, index int, size uint64, n int64) error {
+	return formatError(off, index, "subtree size %d, decoded %d bytes", size, n)
+}
+
+// selectsKey reports whether a child with key k below path can hold words that
+// start with one of prefixes.
+func selectsKey(path string, k byte, prefixes []string) bool {
+	for _, p := range prefixes {
+		if len(p) > len(path) && strings.HasPrefix(p, path) && p[len(path)] == k {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeNodeV1 reads node and everything below it in the version 1 format.
+func (d *decoder) decodeNodeV1(node *Node) error {
+	var (
+		err       error
+		ncb, w, k byte
+	)
+	d.node++
+	d.progress.node(d.r.off)
+
+	node.label, err = d.readStringV1()
+	if err != nil {
+		return err
+	}
+	if d.alloc != nil {
+		node.label = d.alloc.AllocLabel(node.label)
+	}
+
+	off := d.r.off
+	if w, err = d.r.ReadByte(); err != nil {
+		return d.readError(off, "isWord", err)
+	}
+	node.isWord = w == 1
+	if node.isWord {
+		d.tree.words++
+	}
+
+	off = d.r.off
+	if ncb, err = d.r.ReadByte(); err != nil {
+		return d.readError(off, "child count", err)
+	}
+	d.makeChildren(node, int(ncb))
+	for range int(ncb) {
+		// Read key
+		off = d.r.off
+		if k, err = d.r.ReadByte(); err != nil {
+			return d.readError(off, "child key", err)
+		}
+		node.children[k] = d.newNode()
+		if err = d.decodeNodeV1(node.children[k]); err != nil {
+			return err
+		}
+
+	}
+	return err
+}
+
+// readStringV1 reads a version 1 label, a u16 length followed by the bytes.
+func (d *decoder) readStringV1() (string, error) {
+	// Read the length of the string
+	off := d.r.off
+	var blen [2]byte
+	if _, err := io.ReadFull(d.r, blen[:]); err != nil {
+		return "", d.readError(off, "label length", err)
+	}
+
+	return d.readBytes(nil, uint64(binary.BigEndian.Uint16(blen[:])), "label")
+}
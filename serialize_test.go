@@ -0,0 +1,484 @@
+package compressedtrie
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDeserializeTreeFiltered(t *testing.T) {
+	words := []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus", "test", "toaster", "toasting", "slow", "slowly"}
+	tree := NewTree()
+	for _, word := range words {
+		tree.Insert(word)
+	}
+	buf := &bytes.Buffer{}
+	if err := tree.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name     string
+		Prefixes []string
+		Expected []string
+	}{
+		{"Nothing", nil, nil},
+		{"Everything", []string{""}, words},
+		{"Ends on a node", []string{"rub"}, []string{"rubens", "ruber", "rubicon", "rubicundus"}},
+		{"Ends inside a label", []string{"toas", "slowl"}, []string{"slowly", "toaster", "toasting"}},
+		{"Overlapping", []string{"ro", "rom", "roma"}, []string{"romane", "romanus", "romulus"}},
+		{"Word and its extension", []string{"slow"}, []string{"slow", "slowly"}},
+		{"No match", []string{"x", "rox", "toastingly"}, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			filtered, err := DeserializeTreeFiltered(bytes.NewReader(buf.Bytes()), tc.Prefixes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Build the expected tree from scratch, the filtered tree must have
+			// exactly the same shape.
+			expected := NewTree()
+			for _, word := range tc.Expected {
+				expected.Insert(word)
+			}
+			if actual, expected := asDot(filtered), asDot(expected); actual != expected {
+				t.Errorf("Differing output\nActual=%q\nExpected=%q\n", actual, expected)
+			}
+			if filtered.NodeCount() != expected.NodeCount() || filtered.WordCount() != expected.WordCount() {
+				t.Errorf("Expected %d nodes and %d words, got %d and %d", expected.NodeCount(), expected.WordCount(), filtered.NodeCount(), filtered.WordCount())
+			}
+		})
+	}
+}
+
+func TestDeserializeTreeFilteredV1(t *testing.T) {
+	f, err := os.Open("testdata/serialize_v1.ctree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tree, err := DeserializeTreeFiltered(f, []string{"alp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := tree.FindWordsWithPrefix(""), []string{"alpha", "alphabet"}; !slices.Equal(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if expected, actual := 3, tree.NodeCount(); expected != actual {
+		t.Errorf("Expected tree to have %d nodes, got %d", expected, actual)
+	}
+}
+
+func TestSerializedSize(t *testing.T) {
+	for name, tree := range map[string]*Tree{"empty": NewTree(), "words": perfTree(3000)} {
+		for _, tc := range frozenOptions {
+			var buf bytes.Buffer
+			if err := tree.SerializeWithOptions(&buf, tc.Opts); err != nil {
+				t.Fatal(err)
+			}
+			size, err := tree.SerializedSizeWithOptions(tc.Opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != int64(buf.Len()) {
+				t.Errorf("%s, %s: expected %d bytes, measured %d", name, tc.Name, buf.Len(), size)
+			}
+		}
+	}
+	if size, err := perfTree(100).SerializedSize(); err != nil || size != int64(len(perfTree(100).Freeze())) {
+		t.Errorf("Expected the size written by Serialize, got %d %v", size, err)
+	}
+
+	// Measuring and writing agree on a tree with more nodes than the header
+	// can count
+	if strconv.IntSize == 64 {
+		huge := perfTree(100)
+		huge.nodes = 1 << 32
+		for _, tc := range frozenOptions {
+			var buf bytes.Buffer
+			if err := huge.SerializeWithOptions(&buf, tc.Opts); !errors.Is(err, ErrTooLarge) || buf.Len() != 0 {
+				t.Errorf("%s: expected ErrTooLarge and nothing written, got %v and %d bytes", tc.Name, err, buf.Len())
+			}
+			if _, err := huge.SerializedSizeWithOptions(tc.Opts); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("%s: expected SerializedSize to return ErrTooLarge, got %v", tc.Name, err)
+			}
+		}
+	}
+}
+
+func TestDeserializeInto(t *testing.T) {
+	source := perfTree(3000)
+	data := source.Freeze()
+
+	tree := NewTree(WithMaxWordLength(20), WithMissCache(16))
+	for _, word := range perfWords(500) {
+		tree.Insert(word + "x")
+	}
+	tree.Contains("missing")
+	if err := tree.DeserializeInto(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if asDot(tree) != asDot(source) || tree.WordCount() != source.WordCount() || tree.NodeCount() != source.NodeCount() {
+		t.Errorf("Tree differs from the one serialized")
+	}
+	if err := tree.Insert(strings.Repeat("x", 21)); !errors.Is(err, ErrWordTooLong) {
+		t.Errorf("Options were lost, got %v", err)
+	}
+
+	// Reloading the same file reuses the nodes
+	fresh := testing.AllocsPerRun(5, func() { DeserializeTree(bytes.NewReader(data)) })
+	reused := testing.AllocsPerRun(5, func() { tree.DeserializeInto(bytes.NewReader(data)) })
+	t.Logf("%.0f allocations for a new tree, %.0f reusing one", fresh, reused)
+	// At least the node and its child map for each node
+	if saved := fresh - reused; saved < float64(2*source.NodeCount()) {
+		t.Errorf("Expected reuse to save two allocations a node, saved %.0f for %d nodes", saved, source.NodeCount())
+	}
+	if asDot(tree) != asDot(source) {
+		t.Errorf("Tree differs after reloading")
+	}
+
+	// A failure leaves the tree empty
+	if err := tree.DeserializeInto(bytes.NewReader(data[:len(data)/2])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if tree.WordCount() != 0 || tree.NodeCount() != 1 || len(tree.FindWordsWithPrefix("")) != 0 {
+		t.Errorf("Expected an empty tree, got %d words", tree.WordCount())
+	}
+
+	// The limits apply to the words read, and the duplicate report is reset
+	limited := NewTree(WithMaxWordLength(5), WithDuplicateReport(1))
+	limited.Insert("alpha")
+	limited.Insert("alpha")
+	long := NewTree()
+	long.Insert("alphabet")
+	var formatErr *FormatError
+	if err := limited.DeserializeInto(bytes.NewReader(long.Freeze())); !errors.As(err, &formatErr) {
+		t.Errorf("Expected a *FormatError for a word over the length limit, got %v", err)
+	}
+	if report, _ := limited.Duplicates(); report.Count != 0 {
+		t.Errorf("Expected the duplicate report to be reset, got %+v", report)
+	}
+	deep := NewTree(WithMaxDepth(2))
+	if err := deep.DeserializeInto(bytes.NewReader(perfTree(100).Freeze())); !errors.As(err, &formatErr) {
+		t.Errorf("Expected a *FormatError for a node over the depth limit, got %v", err)
+	}
+	if err := deep.DeserializeInto(bytes.NewReader(long.Freeze())); err != nil {
+		t.Errorf("Expected a tree within the limits to load, got %v", err)
+	}
+
+	// Split counts and revisions' ownership of nodes don't carry over
+	versioned := NewTree(WithHistory(2))
+	for _, word := range []string{"alpha", "alps", "beta"} {
+		versioned.Insert(word)
+	}
+	v := versioned.Commit()
+	if err := versioned.DeserializeInto(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if s := versioned.Stats(); s.Splits != 0 {
+		t.Errorf("Expected no splits after reloading, got %d", s.Splits)
+	}
+	root := versioned.root
+	versioned.Insert("zzz")
+	if versioned.root != root {
+		t.Errorf("Insert copied a node that belongs to the tree alone")
+	}
+	old, _ := versioned.At(v)
+	if words := old.FindWordsWithPrefix(""); !slices.Equal(words, []string{"alpha", "alps", "beta"}) {
+		t.Errorf("Revision %d changed by reloading, got %v", v, words)
+	}
+}
+
+func TestLabelDictionary(t *testing.T) {
+	tree := perfTree(5000)
+
+	plain, compact := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := tree.Serialize(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.SerializeWithOptions(compact, SerializeOptions{LabelDictionary: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%d bytes without a label dictionary, %d with", plain.Len(), compact.Len())
+	if compact.Len() >= plain.Len() {
+		t.Errorf("Expected the label dictionary to shrink the output")
+	}
+
+	decoded, err := DeserializeTree(bytes.NewReader(compact.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asDot(decoded) != asDot(tree) {
+		t.Errorf("Tree does not survive a round trip through a label dictionary")
+	}
+
+	filtered, err := DeserializeTreeFiltered(bytes.NewReader(compact.Bytes()), []string{"ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := filtered.FindWordsWithPrefix(""), tree.FindWordsWithPrefix("ca"); !slices.Equal(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	frozen, err := AttachFrozen(compact.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := frozen.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := frozen.FindWordsWithPrefix(""), tree.FindWordsWithPrefix(""); !slices.Equal(actual, expected) {
+		t.Errorf("Frozen tree with a label dictionary returned different words")
+	}
+}
+
+func TestBreadthFirstLayout(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+
+	for _, tc := range frozenOptions {
+		t.Run(tc.Name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := tree.SerializeWithOptions(buf, tc.Opts); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := DeserializeTree(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if asDot(decoded) != asDot(tree) || decoded.NodeCount() != tree.NodeCount() || decoded.WordCount() != tree.WordCount() {
+				t.Errorf("Tree does not survive a round trip")
+			}
+
+			filtered, err := DeserializeTreeFiltered(bytes.NewReader(buf.Bytes()), []string{"rub", "slowl"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := []string{"rubens", "ruber", "rubicon", "rubicundus", "slowly"}
+			if actual := filtered.FindWordsWithPrefix(""); !slices.Equal(actual, expected) {
+				t.Errorf("Expected %v, got %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	data := tree.Freeze()
+
+	// The root record starts with a one byte label and the flags, replace its
+	// child count with 300.
+	corrupt := slices.Clone(data)
+	corrupt[headerSize+2], corrupt[headerSize+3] = 0xac, 0x02
+
+	_, err := DeserializeTree(bytes.NewReader(corrupt))
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("Expected ErrInvalidFormat, got %v", err)
+	}
+	var fe *FormatError
+	if !errors.As(err, &fe) || fe.Offset != headerSize+2 || fe.Node != 0 {
+		t.Fatalf("Expected a FormatError for the root's child count, got %#v", err)
+	}
+	if expected := "invalid file format: child count 300 at offset 18 (node 0)"; err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+
+	_, err = DeserializeTree(bytes.NewReader(data[:len(data)-1]))
+	if !errors.Is(err, ErrInvalidFormat) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected a truncation error, got %v", err)
+	}
+
+	corrupt = slices.Clone(data)
+	corrupt[7] = 9
+	if _, err = DeserializeTree(bytes.NewReader(corrupt)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+
+	frozen, err := AttachFrozen(data[:len(data)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = frozen.Verify(); !errors.As(err, &fe) || fe.Offset <= headerSize {
+		t.Errorf("Expected a FormatError from Verify, got %v", err)
+	}
+}
+
+func TestSerializerReuse(t *testing.T) {
+	trees := make([]*Tree, 3)
+	for i := range trees {
+		trees[i] = NewTree()
+		for _, word := range frozenWords[i:] {
+			trees[i].Insert(word)
+		}
+	}
+
+	s := NewSerializer(SerializeOptions{BufferSize: 64})
+	d := NewDeserializer(DeserializeOptions{BufferSize: 64, Sanitize: SanitizeReject})
+	for _, tree := range trees {
+		buf := &bytes.Buffer{}
+		if err := s.Serialize(buf, tree); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), tree.Freeze()) {
+			t.Errorf("Serializer output differs from Serialize")
+		}
+		decoded, err := d.Deserialize(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if asDot(decoded) != asDot(tree) {
+			t.Errorf("Tree does not survive a round trip")
+		}
+	}
+
+	// Reusing the Serializer saves allocating its buffer and map each time
+	tree := trees[0]
+	fresh := testing.AllocsPerRun(10, func() { tree.Serialize(io.Discard) })
+	reused := testing.AllocsPerRun(10, func() { s.Serialize(io.Discard, tree) })
+	if reused >= fresh {
+		t.Errorf("Expected fewer than %v allocations with reuse, got %v", fresh, reused)
+	}
+}
+
+func TestDeserializeTruncated(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	index := NewMultiMap[int]()
+	for i, word := range frozenWords {
+		index.Append(word, i)
+	}
+	values := &bytes.Buffer{}
+	index.Serialize(values, postingsCodec)
+
+	files := map[string][]byte{
+		"version 1":     v1File(v1Node{"", false, "ab", []v1Node{{"ab", true, "", nil}, {"bc", true, "c", []v1Node{{"cd", true, "", nil}}}}}, 4),
+		"depth first":   tree.Freeze(),
+		"dictionary":    tree.FreezeWithOptions(SerializeOptions{LabelDictionary: true}),
+		"breadth first": tree.FreezeWithOptions(SerializeOptions{Layout: BreadthFirst}),
+		"length bounds": tree.FreezeWithOptions(SerializeOptions{LengthBounds: true}),
+		"word counts":   tree.FreezeWithOptions(SerializeOptions{WordCounts: true}),
+		"values":        values.Bytes(),
+	}
+	injected := errors.New("injected")
+	for name, data := range files {
+		loads := map[string]func(r io.Reader) (any, error){
+			"DeserializeTree": func(r io.Reader) (any, error) {
+				tree, err := DeserializeTree(r)
+				return tree, err
+			},
+			"DeserializeTreeFiltered": func(r io.Reader) (any, error) {
+				tree, err := DeserializeTreeFiltered(r, []string{"ro"})
+				return tree, err
+			},
+			"DeserializeMultiMap": func(r io.Reader) (any, error) {
+				m, err := DeserializeMultiMap(r, postingsCodec)
+				return m, err
+			},
+			"Workers": func(r io.Reader) (any, error) {
+				tree, err := NewDeserializer(DeserializeOptions{Workers: 4}).Deserialize(r)
+				return tree, err
+			},
+		}
+		for load, fn := range loads {
+			// The file ending at every byte, or the reader failing there, is
+			// reported with the offset and nothing is returned
+			for n := range len(data) {
+				got, err := fn(bytes.NewReader(data[:n]))
+				var fe *FormatError
+				if !errors.As(err, &fe) || !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Fatalf("%s, %s: truncated to %d bytes: expected a truncation error, got %v", name, load, n, err)
+				}
+				if fe.Offset > int64(n) {
+					t.Errorf("%s, %s: truncated to %d bytes: error at offset %d", name, load, n, fe.Offset)
+				}
+				if !reflect.ValueOf(got).IsNil() {
+					t.Fatalf("%s, %s: truncated to %d bytes: returned a partial result", name, load, n)
+				}
+
+				_, err = fn(io.MultiReader(bytes.NewReader(data[:n]), iotest.ErrReader(injected)))
+				if !errors.Is(err, ErrInvalidFormat) || !errors.Is(err, injected) {
+					t.Fatalf("%s, %s: failing after %d bytes: expected the injected error, got %v", name, load, n, err)
+				}
+			}
+			if _, err := fn(bytes.NewReader(data)); err != nil {
+				t.Errorf("%s, %s: %v", name, load, err)
+			}
+		}
+	}
+}
+
+func TestDeserializeWorkers(t *testing.T) {
+	tree := perfTree(3000)
+	d := NewDeserializer(DeserializeOptions{Workers: 4})
+	for _, tc := range frozenOptions {
+		decoded, err := d.Deserialize(bytes.NewReader(tree.FreezeWithOptions(tc.Opts)))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.Name, err)
+		}
+		if !sameTrees(decoded, tree) {
+			t.Errorf("%s: tree does not survive a round trip", tc.Name)
+		}
+	}
+
+	index := NewMultiMap[int]()
+	for i, word := range perfWords(1000) {
+		index.Append(word, i)
+	}
+	buf := &bytes.Buffer{}
+	if err := index.Serialize(buf, postingsCodec); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DeserializeMultiMapWithOptions(buf, DeserializeOptions{Workers: 4}, postingsCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for word, values := range index.All("") {
+		if actual := decoded.Values(word); !slices.Equal(actual, values) {
+			t.Fatalf("%q: expected %v, got %v", word, values, actual)
+		}
+	}
+
+	// A truncated file runs out part way through the root's children, after
+	// some of them have been handed to workers
+	data := tree.Freeze()
+	var fe *FormatError
+	if _, err := d.Deserialize(bytes.NewReader(data[:len(data)*2/3])); !errors.As(err, &fe) {
+		t.Errorf("Expected a FormatError for a truncated file, got %v", err)
+	}
+
+	// The workers take their nodes and labels from the decoder's allocator
+	a := &slabAllocator{owned: make(map[*Node]bool)}
+	dec := &decoder{r: &reader{r: bufio.NewReader(bytes.NewReader(data))}, node: -1, data: data, workers: 4, alloc: a}
+	decoded2, err := dec.decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameTrees(decoded2, tree) {
+		t.Errorf("Tree decoded with an allocator differs")
+	}
+	for path, node := range decoded2.Nodes() {
+		if path != "" && (!a.owned[node] || !a.holds(node.label)) {
+			t.Fatalf("Node %q not from the allocator", path)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package compressedtrie
+
+import (
+	"maps"
+	"slices"
+)
+
+// Union returns a new tree holding the words that are in a, b or both.
+func Union(a, b *Tree) *Tree {
+	result := NewTree()
+	for _, tree := range []*Tree{a, b} {
+		var words []string
+		tree.gatherWords(tree.root, "", &words)
+		for _, word := range words {
+			result.InsertSortedNext(word)
+		}
+	}
+	return result
+}
+
+// Intersect returns a new tree holding the words that are in both a and b. The
+// two trees are walked together, so branches of a that diverge from b are
+// abandoned as soon as possible.
+func Intersect(a, b *Tree) *Tree {
+	result := NewTree()
+	a.walkAgainst(a.root, "", position{node: b.root}, func(word string, inB bool) {
+		if inB {
+			result.InsertSortedNext(word)
+		}
+	}, false)
+	return result
+}
+
+// Subtract returns a new tree holding the words that are in a but not in b,
+// e.g. a corpus with a blocklist removed. As with Intersect the two trees are
+// walked together, once a branch of a leaves b all of its words are kept
+// without further comparison.
+func Subtract(a, b *Tree) *Tree {
+	result := NewTree()
+	a.walkAgainst(a.root, "", position{node: b.root}, func(word string, inB bool) {
+		if !inB {
+			result.InsertSortedNext(word)
+		}
+	}, true)
+	return result
+}
+
+// position is a point in a tree that may be part way along a node's label. off
+// is the number of bytes of node's label that have been matched.
+type position struct {
+	node *Node
+	off  int
+}
+
+// advance moves p along the byte c, returning false if the tree has no such
+// path.
+func (p position) advance(c byte) (position, bool) {
+	if p.off < len(p.node.label) {
+		if p.node.label[p.off] != c {
+			return p, false
+		}
+		return position{p.node, p.off + 1}, true
+	}
+	child, exists := p.node.children[c]
+	if !exists {
+		return p, false
+	}
+	return position{child, 1}, true
+}
+
+// isWord reports whether p is at the end of a word.
+func (p position) isWord() bool {
+	return p.off == len(p.node.label) && p.node.isWord
+}
+
+// walkAgainst visits the words below node of t in order, whose path is path, reporting for each whether it is also a word in the other tree. pos is
+// the position of path in the other tree. When a branch of a leaves the other
+// tree its words are only visited if wantMissing is set.
+func (t *Tree) walkAgainst(node *Node, path string, pos position, visit func(word string, inB bool), wantMissing bool) {
+	if node.isWord {
+		visit(path, pos.isWord())
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(node.children)) {
+		child := node.children[k]
+		childPath := path + child.label
+		childPos, ok := pos, true
+		for i := 0; i < len(child.label) && ok; i++ {
+			childPos, ok = childPos.advance(child.label[i])
+		}
+		if ok {
+			t.walkAgainst(child, childPath, childPos, visit, wantMissing)
+		} else if wantMissing {
+			var words []string
+			t.gatherWords(child, childPath, &words)
+			for _, word := range words {
+				visit(word, false)
+			}
+		}
+	}
+}
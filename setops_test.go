@@ -0,0 +1,40 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetOperations(t *testing.T) {
+	a := NewTree()
+	for _, word := range []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "slow", "slowly"} {
+		a.Insert(word)
+	}
+	b := NewTree()
+	for _, word := range []string{"roman", "romanus", "rubicon", "rubicundus", "slow", "test"} {
+		b.Insert(word)
+	}
+
+	cases := []struct {
+		Name     string
+		Result   *Tree
+		Expected []string
+	}{
+		{"Union", Union(a, b), []string{"roman", "romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus", "slow", "slowly", "test"}},
+		{"Intersect", Intersect(a, b), []string{"romanus", "rubicon", "slow"}},
+		{"Subtract", Subtract(a, b), []string{"romane", "romulus", "rubens", "ruber", "slowly"}},
+		{"Subtract everything", Subtract(a, a), nil},
+		{"Intersect empty", Intersect(a, NewTree()), nil},
+		{"Subtract empty", Subtract(b, NewTree()), []string{"roman", "romanus", "rubicon", "rubicundus", "slow", "test"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if actual := tc.Result.FindWordsWithPrefix(""); !slices.Equal(actual, tc.Expected) {
+				t.Errorf("Expected %v, got %v", tc.Expected, actual)
+			}
+			if tc.Result.WordCount() != len(tc.Expected) {
+				t.Errorf("Expected %d words, got %d", len(tc.Expected), tc.Result.WordCount())
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package compressedtrie
+
+import (
+	"maps"
+	"slices"
+)
+
+// ShardBy is the measure Shards balances.
+type ShardBy int
+
+const (
+	// ShardByWords gives each shard roughly the same number of words.
+	ShardByWords ShardBy = iota
+	// ShardByBytes gives each shard roughly the same total word length.
+	ShardByBytes
+)
+
+// A Shard is a contiguous range of the words in a tree, as returned by Shards.
+type Shard struct {
+	// Start is the shortest prefix that separates the shard from the one
+	// before it. The shard holds the words w with Start <= w that are less than
+	// the next shard's Start. The first shard's Start is "".
+	Start string
+	Words int // number of words in the shard
+	Bytes int // total length of the words in the shard
+}
+
+// Shards suggests how to split the words of t into n roughly equal ranges, for
+// example to distribute them across servers. The size of every subtree is
+// measured once, after which only the paths to the boundaries are walked.
+// Fewer than n shards are returned if t has too few words, or single words that
+// are too large, to make up n.
+func (t *Tree) Shards(n int, by ShardBy) []Shard {
+	s := &sharder{sizes: make(map[*Node]shardSize)}
+	total := s.measure(t.root, 0)
+	if n <= 0 || total.words == 0 {
+		return nil
+	}
+
+	s.by = by
+	s.n = n
+	s.total = total.get(by)
+	s.cut = 1
+	s.shards = []Shard{{}}
+	s.walk(t.root, "")
+	return s.shards
+}
+
+type shardSize struct {
+	words, bytes int
+}
+
+func (z shardSize) get(by ShardBy) int {
+	if by == ShardByBytes {
+		return z.bytes
+	}
+	return z.words
+}
+
+type sharder struct {
+	sizes map[*Node]shardSize // size of each node's subtree
+
+	by     ShardBy
+	n      int
+	total  int
+	cut    int // index of the next boundary
+	done   int // measure of the words visited so far
+	shards []Shard
+
+	// The last word, or subtree if prevWhole, visited. Its largest word is the
+	// one before the next boundary.
+	prev      *Node
+	prevPath  string
+	prevWhole bool
+}
+
+// measure fills in s.sizes for the subtree at node, which is depth bytes below
+// the root.
+func (s *sharder) measure(node *Node, depth int) shardSize {
+	var z shardSize
+	if node.isWord {
+		z = shardSize{1, depth}
+	}
+	for _, child := range node.children {
+		c := s.measure(child, depth+len(child.label))
+		z.words += c.words
+		z.bytes += c.bytes
+	}
+	s.sizes[node] = z
+	return z
+}
+
+// boundary returns the measure at which the next shard should start.
+func (s *sharder) boundary() int {
+	return (s.cut*s.total + s.n - 1) / s.n
+}
+
+// walk visits the words below node, whose path from the root is path, in order.
+func (s *sharder) walk(node *Node, path string) {
+	if node.isWord {
+		s.add(node, path, shardSize{1, len(path)}, true)
+	}
+	for _, k := range slices.Sorted(maps.Keys(node.children)) {
+		child := node.children[k]
+		childPath := path + child.label
+		z := s.sizes[child]
+		if s.cut >= s.n || s.done+z.get(s.by) <= s.boundary() {
+			// The whole subtree fits in the current shard
+			s.add(child, childPath, z, false)
+			continue
+		}
+		s.walk(child, childPath)
+	}
+}
+
+// add appends z, which is a single word at node if word is true and otherwise
+// the subtree at node, to the current shard. A word can start a new shard.
+func (s *sharder) add(node *Node, path string, z shardSize, word bool) {
+	if word && s.cut < s.n && s.done >= s.boundary() && s.prev != nil {
+		prev := s.prevPath
+		if s.prevWhole {
+			prev = maxWord(s.prev, s.prevPath)
+		}
+		common := commonPrefixLen(prev, path)
+		s.shards = append(s.shards, Shard{Start: path[:common+1]})
+		for s.cut < s.n && s.done >= s.boundary() {
+			s.cut++
+		}
+	}
+
+	cur := &s.shards[len(s.shards)-1]
+	cur.Words += z.words
+	cur.Bytes += z.bytes
+	s.done += z.get(s.by)
+	if z.words > 0 {
+		s.prev, s.prevPath, s.prevWhole = node, path, !word
+	}
+}
+
+// maxWord returns the largest word in the non-empty subtree at node, whose path
+// from the root is path.
+func maxWord(node *Node, path string) string {
+	for len(node.children) > 0 {
+		node = node.children[slices.Max(slices.Collect(maps.Keys(node.children)))]
+		path += node.label
+	}
+	return path
+}
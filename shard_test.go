@@ -0,0 +1,65 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestShards(t *testing.T) {
+	tree := NewTree()
+	for i := range 1000 {
+		tree.Insert(fmt.Sprintf("%03d", i))
+	}
+
+	expected := []Shard{{"", 250, 750}, {"25", 250, 750}, {"5", 250, 750}, {"75", 250, 750}}
+	if actual := tree.Shards(4, ShardByWords); !slices.Equal(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if actual := tree.Shards(1, ShardByBytes); !slices.Equal(actual, []Shard{{"", 1000, 3000}}) {
+		t.Errorf("Expected a single shard, got %v", actual)
+	}
+	if actual := NewTree().Shards(4, ShardByWords); actual != nil {
+		t.Errorf("Expected no shards for an empty tree, got %v", actual)
+	}
+}
+
+func TestShardsCoverTree(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	tree.Insert("a")
+	tree.Insert("zzzzzzzzzzzzzzzzzzzzzzzzzzzzzz")
+	words := tree.FindWordsWithPrefix("")
+
+	for _, by := range []ShardBy{ShardByWords, ShardByBytes} {
+		for n := 1; n <= len(words)+1; n++ {
+			shards := tree.Shards(n, by)
+			if len(shards) == 0 || len(shards) > n || shards[0].Start != "" {
+				t.Fatalf("Shards(%d, %d): bad shards %v", n, by, shards)
+			}
+
+			// Every word belongs to the last shard whose Start is <= it
+			counts := make([]Shard, len(shards))
+			for _, word := range words {
+				i := len(shards) - 1
+				for strings.Compare(word, shards[i].Start) < 0 {
+					i--
+				}
+				counts[i].Words++
+				counts[i].Bytes += len(word)
+			}
+			for i := range shards {
+				if i > 0 && shards[i-1].Start >= shards[i].Start {
+					t.Errorf("Shards(%d, %d): boundaries out of order %v", n, by, shards)
+				}
+				counts[i].Start = shards[i].Start
+				if counts[i] != shards[i] || shards[i].Words == 0 {
+					t.Errorf("Shards(%d, %d): expected %v, got %v", n, by, counts[i], shards[i])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package compressedtrie
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SnapshotTree is a tree for read-mostly services with a single writer, whose
+// readers never wait, not even while a write changes the structure of the
+// tree. It is built on WithHistory: each write copies the nodes on the paths
+// it changes instead of changing them, then publishes the new root with an
+// atomic pointer swap. Readers Load the root that was current when they
+// started and walk nodes that are never written again, so they see each write
+// completely or not at all.
+//
+// In terms of the Go memory model, everything a write does happens before the
+// Store that publishes it, which is synchronized before any Load that returns
+// the new tree. No node reachable from a published tree is written after it
+// is published. A tree returned by Load stays valid, and unchanged, for as
+// long as the reader holds on to it.
+//
+// Writers are serialized by a mutex, so more than one writer is safe, but each
+// write costs a copy of its path, which is what makes the mode read-mostly.
+type SnapshotTree struct {
+	mu      sync.Mutex // held by the writer
+	tree    *Tree      // the writer's tree
+	current atomic.Pointer[Tree]
+}
+
+// NewSnapshotTree returns an empty SnapshotTree. The options apply to the
+// writer's tree, except that SnapshotTree sets up WithHistory itself.
+func NewSnapshotTree(opts ...Option) *SnapshotTree {
+	s := &SnapshotTree{tree: NewTree(append(opts, WithHistory(1))...)}
+	s.publish()
+	return s
+}
+
+// publish makes the writer's tree as it is now current. s.mu must be held, or
+// s not yet shared.
+func (s *SnapshotTree) publish() {
+	tree, _ := s.tree.At(s.tree.Commit())
+	s.current.Store(tree)
+}
+
+// Load returns the current tree for queries. It must not be changed.
+func (s *SnapshotTree) Load() *Tree {
+	return s.current.Load()
+}
+
+// Insert adds word to s and publishes the result, see Tree.Insert.
+func (s *SnapshotTree) Insert(word string) error {
+	return s.Update(func(t *Tree) error { return t.Insert(word) })
+}
+
+// Delete removes word from s, publishing the result, and reports whether it
+// was there.
+func (s *SnapshotTree) Delete(word string) bool {
+	deleted := false
+	s.Update(func(t *Tree) error {
+		deleted = t.Delete(word)
+		return nil
+	})
+	return deleted
+}
+
+// Update calls fn with the writer's tree and then publishes the tree, so that
+// readers see all of fn's changes at once. fn must not keep t. The tree is
+// published even if fn returns an error, which Update returns, use a Batch in
+// fn for changes that must all be made or none.
+func (s *SnapshotTree) Update(fn func(t *Tree) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := fn(s.tree)
+	s.publish()
+	return err
+}
+
+// Contains reports whether word is in the current tree.
+func (s *SnapshotTree) Contains(word string) bool {
+	return s.Load().Contains(word)
+}
+
+// FindWordsWithPrefix returns the words in the current tree that start with
+// prefix, see Tree.FindWordsWithPrefix.
+func (s *SnapshotTree) FindWordsWithPrefix(prefix string) []string {
+	return s.Load().FindWordsWithPrefix(prefix)
+}
@@ -0,0 +1,75 @@
+package compressedtrie
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotTree(t *testing.T) {
+	s := NewSnapshotTree()
+	s.Insert("toast")
+	before := s.Load()
+	s.Insert("toaster")
+	if before.Contains("toaster") || !s.Contains("toaster") {
+		t.Errorf("Insert changed a published tree")
+	}
+	if err := s.Update(func(t *Tree) error {
+		t.Delete("toast")
+		return t.Insert("tea")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if words := s.FindWordsWithPrefix("t"); !slices.Equal(words, []string{"tea", "toaster"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+	if !before.Contains("toast") || s.Delete("toast") || !s.Delete("tea") {
+		t.Errorf("Unexpected results after deleting")
+	}
+}
+
+func TestSnapshotTreeConcurrent(t *testing.T) {
+	// Run with -race: readers walk the tree while the writer inserts and
+	// deletes, and every tree they load is whole
+	words := perfWords(2000)
+	s := NewSnapshotTree()
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				tree := s.Load()
+				if n := len(tree.FindWordsWithPrefix("")); n != tree.WordCount() {
+					t.Errorf("Loaded a tree of %d words listing %d", tree.WordCount(), n)
+					return
+				}
+			}
+		}()
+	}
+	for i, word := range words {
+		s.Insert(word)
+		if i%3 == 0 {
+			s.Delete(words[i/2])
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	expected := NewTree()
+	for i, word := range words {
+		expected.Insert(word)
+		if i%3 == 0 {
+			expected.Delete(words[i/2])
+		}
+	}
+	if !slices.Equal(s.FindWordsWithPrefix(""), expected.FindWordsWithPrefix("")) {
+		t.Errorf("Snapshot tree differs from a plain tree")
+	}
+}
@@ -0,0 +1,70 @@
+package compressedtrie
+
+// A finger is the path from the root to the node of the last word added by
+// InsertSortedNext, which the next word resumes from.
+type finger struct {
+	word    string
+	nodes   []*Node // nodes[0] is the root
+	ends    []int   // length of the path of each node
+	covered []bool  // whether each node is inside a subtree marked dirty
+}
+
+// InsertSortedNext is Insert for words that arrive in sorted order, such as
+// when building a tree from a sorted file. Rather than descending from the root
+// it starts from the deepest node on the path of the previous word that the
+// new word shares, so the bytes consecutive words have in common are only
+// walked once. The words don't have to be sorted, but the fewer bytes a word
+// shares with the one before it the less there is to gain. The tree must not be
+// changed in between other than by InsertSortedNext, if it is the next word
+// simply starts from the root. Trees created with WithHistory always start
+// from the root.
+func (t *Tree) InsertSortedNext(word string) error {
+	if t.history != nil {
+		return t.Insert(word)
+	}
+	if t.maxWordLen > 0 && len(word) > t.maxWordLen {
+		return ErrWordTooLong
+	}
+	if err := t.checkDepth(word); err != nil {
+		return err
+	}
+	t.misses.invalidate(word)
+	t.changes++
+	word = t.copied(word)
+
+	f := t.finger
+	if f == nil {
+		f = &finger{nodes: []*Node{t.root}, ends: []int{0}, covered: []bool{t.dirtySubtree(t.root)}}
+		t.finger = f
+	}
+	// The deepest node whose path both words start with. Only the nodes below
+	// it can change.
+	common := commonPrefixLen(f.word, word)
+	n := len(f.nodes)
+	for f.ends[n-1] > common {
+		n--
+	}
+	f.nodes, f.ends, f.covered = f.nodes[:n], f.ends[:n], f.covered[:n]
+	start, consumed := f.nodes[n-1], f.ends[n-1]
+	if err := t.insertBelow(start, n-1, word, consumed, f.covered[n-1]); err != nil {
+		// Whatever was left of the path is still there
+		f.word = word[:consumed]
+		return err
+	}
+	for _, node := range f.nodes[:n-1] {
+		node.addLength(len(word))
+	}
+
+	// Extend the path down to the new word
+	cur, covered := start, f.covered[n-1]
+	for consumed < len(word) {
+		cur = cur.children[word[consumed]]
+		consumed += len(cur.label)
+		covered = covered || t.dirtySubtree(cur)
+		f.nodes = append(f.nodes, cur)
+		f.ends = append(f.ends, consumed)
+		f.covered = append(f.covered, covered)
+	}
+	f.word = word
+	return nil
+}
@@ -0,0 +1,128 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// sameTrees reports whether a and b have the same shape, words and word
+// lengths.
+func sameTrees(a, b *Tree) bool {
+	var walk func(x, y *Node) bool
+	walk = func(x, y *Node) bool {
+		if x.label != y.label || x.isWord != y.isWord || x.lenLo != y.lenLo || x.lenHi != y.lenHi || len(x.children) != len(y.children) {
+			return false
+		}
+		for k, child := range x.children {
+			if other, ok := y.children[k]; !ok || !walk(child, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.nodes == b.nodes && a.words == b.words && walk(a.root, b.root)
+}
+
+func TestInsertSortedNext(t *testing.T) {
+	words := perfWords(3000)
+	sorted := slices.Sorted(slices.Values(words))
+	shuffled := slices.Clone(words)
+	rand.New(rand.NewPCG(1, 2)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	expected := perfTree(3000)
+
+	for name, order := range map[string][]string{"sorted": sorted, "shuffled": shuffled, "generated": words} {
+		tree := NewTree()
+		for _, word := range order {
+			if err := tree.InsertSortedNext(word); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !sameTrees(tree, expected) {
+			t.Errorf("%s: tree differs from one built with Insert", name)
+		}
+	}
+
+	// Mixed with other changes
+	tree := NewTree()
+	for i, word := range sorted {
+		tree.InsertSortedNext(word)
+		if i%7 == 0 {
+			tree.Delete(sorted[i/2])
+			tree.Insert(sorted[i/2])
+		}
+	}
+	tree.InsertSortedNext("")
+	expected.Insert("")
+	if !sameTrees(tree, expected) {
+		t.Errorf("Tree differs after mixing InsertSortedNext with other changes")
+	}
+
+	// The same delta as Insert
+	base := NewTree(WithDirtyTracking())
+	other := NewTree(WithDirtyTracking())
+	for _, word := range sorted[:1000] {
+		base.Insert(word)
+		other.Insert(word)
+	}
+	base.MarkClean()
+	other.MarkClean()
+	for _, word := range sorted[1000:] {
+		base.Insert(word)
+		other.InsertSortedNext(word)
+	}
+	var a, b bytes.Buffer
+	if err := base.SerializeDirty(&a); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.SerializeDirty(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Errorf("InsertSortedNext recorded different changes than Insert")
+	}
+}
+
+func TestInsertSortedNextErrors(t *testing.T) {
+	tree := NewTree(WithMaxDepth(2), WithMaxWordLength(8))
+	for _, word := range []string{"abc", "abcd"} {
+		if err := tree.InsertSortedNext(word); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tree.InsertSortedNext("abcde"); !errors.Is(err, ErrTreeTooDeep) {
+		t.Errorf("Expected ErrTreeTooDeep, got %v", err)
+	}
+	if err := tree.InsertSortedNext("abcdefghi"); !errors.Is(err, ErrWordTooLong) {
+		t.Errorf("Expected ErrWordTooLong, got %v", err)
+	}
+	// Carries on after an error
+	if err := tree.InsertSortedNext("b"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"abc", "abcd", "b"}
+	if words := tree.FindWordsWithPrefix(""); !slices.Equal(words, expected) {
+		t.Errorf("Expected %v, got %v", expected, words)
+	}
+}
+
+func BenchmarkInsertSortedNext(b *testing.B) {
+	words := slices.Sorted(slices.Values(perfWords(10000)))
+	for _, insert := range []struct {
+		Name string
+		Fn   func(*Tree, string) error
+	}{{"Insert", (*Tree).Insert}, {"InsertSortedNext", (*Tree).InsertSortedNext}} {
+		b.Run(insert.Name, func(b *testing.B) {
+			for b.Loop() {
+				tree := NewTree()
+				for _, word := range words {
+					insert.Fn(tree, word)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package compressedtrie
+
+import "fmt"
+
+// QueryStats describes the work done by a query, for load testing tools that
+// want to relate latency to the shape of the tree.
+type QueryStats struct {
+	NodesVisited  int // nodes visited, including the root
+	BytesCompared int // bytes of labels compared against the prefix
+	Allocations   int // heap allocations made for the results and the walk
+}
+
+// FindWordsWithPrefixStats is FindWordsWithPrefix that also reports the work
+// the search did. The allocations are counted by the search rather than
+// measured, one for each path built, each list of children sorted and each
+// time the result slice grows, so that they don't include the allocations of
+// other goroutines. A prefix found in the miss cache visits no nodes.
+func (t *Tree) FindWordsWithPrefixStats(prefix string) ([]string, QueryStats) {
+	var stats QueryStats
+	var words []string
+	if t.misses.contains(prefix) {
+		return words, stats
+	}
+	query := prefix
+
+	cur := t.root
+	path := ""
+	stats.NodesVisited++
+	for prefix != "" {
+		t.visit(cur)
+		child, exists := cur.children[prefix[0]]
+		if !exists {
+			t.misses.add(query)
+			return words, stats
+		}
+		stats.NodesVisited++
+		label := child.label
+		n := min(len(label), len(prefix))
+		stats.BytesCompared += n
+		if label[:n] != prefix[:n] {
+			t.misses.add(query)
+			return words, stats
+		}
+		path += label
+		stats.Allocations++
+		prefix = prefix[n:]
+		cur = child
+	}
+	t.visit(cur)
+
+	t.traverse(cur, path, traversal{
+		enter: func(node *Node, _ []byte, _ int) step {
+			if node != cur {
+				stats.NodesVisited++
+			}
+			if len(node.children) > 0 {
+				stats.Allocations++
+			}
+			return stepInto
+		},
+		visit: func(word string, _ *Node, _ int) step {
+			stats.Allocations++
+			if len(words) == cap(words) {
+				stats.Allocations++
+			}
+			words = append(words, word)
+			return stepInto
+		},
+	})
+	return words, stats
+}
+
+// TreeStats describes the shape of a tree, to tell how well a dataset
+// compresses. See Warnings for what to make of it.
+type TreeStats struct {
+	Nodes int // nodes, including the root
+	Words int
+
+	// Splits is the number of labels that inserts have split in two since the
+	// tree was created. It is 0 for a tree that was deserialized.
+	Splits int
+
+	LabelBytes     int     // bytes in the labels of all nodes
+	WordBytes      int     // bytes in all the words, as if stored one by one
+	AvgLabelLength float64 // LabelBytes over the nodes other than the root
+
+	// SingleChildNodes is the number of nodes other than the root with
+	// exactly one child, which are all words since other nodes are merged with
+	// their only child.
+	// SingleChildChains is the number of runs of them one below the other
+	// and LongestChain the length of the longest, in nodes.
+	SingleChildNodes  int
+	SingleChildChains int
+	LongestChain      int
+}
+
+// Stats walks t and returns its TreeStats.
+func (t *Tree) Stats() TreeStats {
+	s := TreeStats{Nodes: t.nodes, Words: t.words, Splits: t.splits}
+	var walk func(node *Node, depth, chain int)
+	walk = func(node *Node, depth, chain int) {
+		s.LabelBytes += len(node.label)
+		depth += len(node.label)
+		if node.isWord {
+			s.WordBytes += depth
+		}
+		if len(node.children) == 1 && node != t.root {
+			s.SingleChildNodes++
+			if chain == 0 {
+				s.SingleChildChains++
+			}
+			chain++
+			s.LongestChain = max(s.LongestChain, chain)
+		} else {
+			chain = 0
+		}
+		for _, child := range node.children {
+			walk(child, depth, chain)
+		}
+	}
+	walk(t.root, 0, 0)
+	if t.nodes > 1 {
+		s.AvgLabelLength = float64(s.LabelBytes) / float64(t.nodes-1)
+	}
+	return s
+}
+
+// Thresholds for Warnings. A dataset needs minWarnWords words before it is
+// judged at all.
+const (
+	minWarnWords     = 1000
+	maxLabelFraction = 0.8
+	maxChainLength   = 32
+)
+
+// Warnings returns a description of each way in which the dataset described by
+// s suits a compressed trie badly, nil if there is none. The checks are
+// heuristics, meant for logging when a dictionary is loaded:
+//
+//   - the labels holding most of the bytes of the words, so that shared
+//     prefixes save little, as with random keys such as UUIDs or hashes
+//   - long chains of single-child nodes, words that each extend the one
+//     before, which make lookups visit a node for every word in the chain
+func (s TreeStats) Warnings() []string {
+	if s.Words < minWarnWords || s.WordBytes == 0 {
+		return nil
+	}
+	var warnings []string
+	if f := float64(s.LabelBytes) / float64(s.WordBytes); f > maxLabelFraction {
+		warnings = append(warnings, fmt.Sprintf("labels hold %.0f%% of the bytes of the words, shared prefixes save little; random keys such as UUIDs or hashes compress poorly", 100*f))
+	}
+	if s.LongestChain > maxChainLength {
+		warnings = append(warnings, fmt.Sprintf("a chain of %d single-child nodes, each word extending the one before; lookups along it visit every node", s.LongestChain))
+	}
+	return warnings
+}
@@ -0,0 +1,83 @@
+package compressedtrie
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindWordsWithPrefixStats(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"test", "toaster", "toasting", "slow", "slowly"} {
+		tree.Insert(word)
+	}
+
+	cases := []struct {
+		Prefix string
+		Stats  QueryStats
+	}{
+		// root, t, toast, toaster, toasting
+		{"toa", QueryStats{NodesVisited: 5, BytesCompared: 3, Allocations: 7}},
+		// The walk of the subtree builds the path of its word again
+		{"toaster", QueryStats{NodesVisited: 4, BytesCompared: 7, Allocations: 5}},
+		{"tx", QueryStats{NodesVisited: 2, BytesCompared: 1, Allocations: 1}},
+		{"toad", QueryStats{NodesVisited: 3, BytesCompared: 4, Allocations: 1}},
+		{"b", QueryStats{NodesVisited: 1}},
+	}
+	for _, tc := range cases {
+		words, stats := tree.FindWordsWithPrefixStats(tc.Prefix)
+		if expected := tree.FindWordsWithPrefix(tc.Prefix); !slices.Equal(words, expected) {
+			t.Errorf("%q: expected %v, got %v", tc.Prefix, expected, words)
+		}
+		if stats != tc.Stats {
+			t.Errorf("%q: expected %+v, got %+v", tc.Prefix, tc.Stats, stats)
+		}
+	}
+
+	// The whole tree visits every node
+	if _, stats := tree.FindWordsWithPrefixStats(""); stats.NodesVisited != tree.NodeCount() || stats.BytesCompared != 0 {
+		t.Errorf("Expected %d nodes visited, got %+v", tree.NodeCount(), stats)
+	}
+}
+
+func TestTreeStats(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"test", "toaster", "toasting", "slow", "slowly"} {
+		tree.Insert(word)
+	}
+	expected := TreeStats{
+		Nodes: 8, Words: 5, Splits: 2,
+		LabelBytes: 19, WordBytes: 29, AvgLabelLength: 19.0 / 7,
+		SingleChildNodes: 1, SingleChildChains: 1, LongestChain: 1,
+	}
+	if s := tree.Stats(); s != expected {
+		t.Errorf("Expected %+v, got %+v", expected, s)
+	}
+	if w := tree.Stats().Warnings(); w != nil {
+		t.Errorf("Unexpected warnings for a small tree %q", w)
+	}
+
+	if w := perfTree(3000).Stats().Warnings(); w != nil {
+		t.Errorf("Unexpected warnings for words %q", w)
+	}
+
+	r := rand.New(rand.NewPCG(1, 2))
+	ids := NewTree()
+	for range 2000 {
+		ids.Insert(fmt.Sprintf("%016x", r.Uint64()))
+	}
+	if w := ids.Stats().Warnings(); len(w) != 1 || !strings.Contains(w[0], "UUIDs") {
+		t.Errorf("Expected a warning about random keys, got %q", w)
+	}
+
+	chain := NewTree()
+	for i := 1; i <= 1000; i++ {
+		chain.Insert(strings.Repeat("a", i))
+	}
+	s := chain.Stats()
+	if w := s.Warnings(); len(w) != 1 || s.LongestChain != 999 || s.SingleChildChains != 1 {
+		t.Errorf("Expected a warning about a chain of 999 nodes, got %q for %+v", w, s)
+	}
+}
@@ -0,0 +1,32 @@
+package compressedtrie
+
+import "context"
+
+// StreamWordsWithPrefix sends the words in t that start with prefix on the
+// first channel, in the same order as FindWordsWithPrefix, for consumers built
+// around channel pipelines. The channel is unbuffered so the walk only runs as
+// fast as words are received. If ctx is cancelled before every word has been
+// sent the walk stops and ctx.Err() is sent on the second channel. Both
+// channels are closed when the walk is done, the error channel after the word
+// channel. As with the other queries t must not be changed until then.
+func (t *Tree) StreamWordsWithPrefix(ctx context.Context, prefix string) (<-chan string, <-chan error) {
+	words := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(words)
+		if err := ctx.Err(); err != nil {
+			errc <- err
+			return
+		}
+		for word := range t.WordsWithPrefix(prefix) {
+			select {
+			case words <- word:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return words, errc
+}
@@ -0,0 +1,65 @@
+package compressedtrie
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestStreamWordsWithPrefix(t *testing.T) {
+	tree := perfTree(500)
+	words, errc := tree.StreamWordsWithPrefix(context.Background(), "a")
+	var streamed []string
+	for word := range words {
+		streamed = append(streamed, word)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if expected := tree.FindWordsWithPrefix("a"); !slices.Equal(streamed, expected) {
+		t.Errorf("Expected %d words, got %d", len(expected), len(streamed))
+	}
+
+	// Several workers can share the stream
+	words, errc = tree.StreamWordsWithPrefix(context.Background(), "")
+	counts := make(chan int)
+	for range 4 {
+		go func() {
+			n := 0
+			for range words {
+				n++
+			}
+			counts <- n
+		}()
+	}
+	total := 0
+	for range 4 {
+		total += <-counts
+	}
+	if err := <-errc; err != nil || total != tree.WordCount() {
+		t.Errorf("Expected %d words, got %d and %v", tree.WordCount(), total, err)
+	}
+
+	// Cancelling stops the walk and closes both channels
+	ctx, cancel := context.WithCancel(context.Background())
+	words, errc = tree.StreamWordsWithPrefix(ctx, "")
+	<-words
+	cancel()
+	for range words {
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, ok := <-errc; ok {
+		t.Errorf("Error channel left open")
+	}
+
+	words, errc = tree.StreamWordsWithPrefix(ctx, "")
+	if _, ok := <-words; ok {
+		t.Errorf("Received a word after cancellation")
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
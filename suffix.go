@@ -0,0 +1,57 @@
+package compressedtrie
+
+import "slices"
+
+// SuffixTree stores words by their ending, so that the words that end with a
+// given suffix, or the longest stored suffix of a string, can be found as
+// quickly as a Tree finds prefixes. This suits matching host names against
+// domain suffixes. Words are reversed byte by byte internally, callers only
+// ever see them the right way round.
+type SuffixTree struct {
+	tree *Tree
+}
+
+// NewSuffixTree returns an empty SuffixTree, the options apply to the Tree that
+// holds the reversed words.
+func NewSuffixTree(opts ...Option) *SuffixTree {
+	return &SuffixTree{tree: NewTree(opts...)}
+}
+
+// reverse returns s with its bytes in the opposite order.
+func reverse(s string) string {
+	b := []byte(s)
+	slices.Reverse(b)
+	return string(b)
+}
+
+// Insert adds word to s.
+func (s *SuffixTree) Insert(word string) error {
+	return s.tree.Insert(reverse(word))
+}
+
+// Contains reports whether word has been inserted into s.
+func (s *SuffixTree) Contains(word string) bool {
+	return s.tree.Contains(reverse(word))
+}
+
+// EndsWith returns the words in s that end with suffix, in alphabetical order.
+func (s *SuffixTree) EndsWith(suffix string) []string {
+	words := s.tree.FindWordsWithPrefix(reverse(suffix))
+	for i, w := range words {
+		words[i] = reverse(w)
+	}
+	slices.Sort(words)
+	return words
+}
+
+// LongestSuffix returns the longest word in s that str ends with, and whether
+// there is one.
+func (s *SuffixTree) LongestSuffix(str string) (string, bool) {
+	n, ok := s.tree.longestPrefix(reverse(str))
+	return str[len(str)-n:], ok
+}
+
+// Len returns the number of words in s.
+func (s *SuffixTree) Len() int {
+	return s.tree.WordCount()
+}
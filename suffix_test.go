@@ -0,0 +1,83 @@
+package compressedtrie
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSuffixTree(t *testing.T) {
+	s := NewSuffixTree()
+	for _, word := range []string{"com", "example.com", "co.uk", "uk", "bbc.co.uk", "running", "jumping", "sing"} {
+		s.Insert(word)
+	}
+	if s.Len() != 8 {
+		t.Errorf("Expected 8 words, got %d", s.Len())
+	}
+	if !s.Contains("co.uk") || s.Contains("co") {
+		t.Errorf("Contains gave the wrong answer")
+	}
+
+	for suffix, expected := range map[string][]string{
+		"ing":  {"jumping", "running", "sing"},
+		".uk":  {"bbc.co.uk", "co.uk"},
+		"com":  {"com", "example.com"},
+		"xing": nil,
+	} {
+		if actual := s.EndsWith(suffix); !slices.Equal(actual, expected) {
+			t.Errorf("EndsWith(%q): expected %v, got %v", suffix, expected, actual)
+		}
+	}
+
+	for str, expected := range map[string]string{
+		"www.bbc.co.uk":   "bbc.co.uk",
+		"news.co.uk":      "co.uk",
+		"www.example.com": "example.com",
+		"example.org":     "",
+		"rising":          "sing",
+	} {
+		actual, ok := s.LongestSuffix(str)
+		if actual != expected || ok != (expected != "") {
+			t.Errorf("LongestSuffix(%q): expected %q, got %q, %v", str, expected, actual, ok)
+		}
+	}
+}
+
+func TestLongestPrefix(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	for s, expected := range map[string]string{"slowlyish": "slowly", "slowish": "slow", "slo": "", "toasters": "toaster", "": ""} {
+		actual, ok := tree.LongestPrefix(s)
+		if actual != expected || ok != (expected != "") {
+			t.Errorf("LongestPrefix(%q): expected %q, got %q, %v", s, expected, actual, ok)
+		}
+	}
+}
+
+func TestFirstCompletion(t *testing.T) {
+	if _, ok := NewTree().FirstCompletion(""); ok {
+		t.Errorf("Expected no completion in an empty tree")
+	}
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	for _, prefix := range []string{"", "r", "rub", "rubic", "slow", "to", "toasti", "x", "slowlyish"} {
+		words := tree.FindWordsWithPrefix(prefix)
+		actual, ok := tree.FirstCompletion(prefix)
+		if ok != (len(words) > 0) || ok && actual != words[0] {
+			t.Errorf("FirstCompletion(%q): expected %v, got %q, %v", prefix, words, actual, ok)
+		}
+	}
+
+	// The child order is followed
+	ordered := NewTree(WithChildOrder(func(a, b string) int { return strings.Compare(b, a) }))
+	for _, word := range frozenWords {
+		ordered.Insert(word)
+	}
+	if actual, _ := ordered.FirstCompletion("ro"); actual != ordered.FindWordsWithPrefix("ro")[0] {
+		t.Errorf("Expected %q, got %q", ordered.FindWordsWithPrefix("ro")[0], actual)
+	}
+}
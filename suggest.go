@@ -0,0 +1,167 @@
+package compressedtrie
+
+import (
+	"cmp"
+	"slices"
+)
+
+// A Suggestion is a correction for a misspelt word returned by Suggest.
+type Suggestion struct {
+	Word     string
+	Distance float64 // weighted edit distance between the query and Word
+	Weight   float64 // weight of Word, see SuggestOptions.Weight
+}
+
+// SuggestOptions configures SuggestWith. The zero value gives the behavior of
+// Suggest.
+type SuggestOptions struct {
+	// MaxDistance is the largest edit distance a suggestion can have, it
+	// defaults to 2.
+	MaxDistance float64
+
+	// SubstitutionCost returns the cost of replacing byte a in the query with
+	// byte b, it is never called with a == b. Insertions and deletions cost 1.
+	// Defaults to 1 for every substitution, see QWERTYSubstitutionCost for an
+	// alternative.
+	SubstitutionCost func(a, b byte) float64
+
+	// Weight returns the weight of word, e.g. how popular it is. Among
+	// suggestions with the same distance higher weights are ranked first.
+	// Defaults to 0 for every word.
+	Weight func(word string) float64
+}
+
+// Suggest returns up to k words from t that are within an edit distance of 2
+// of word, ranked by distance. If k <= 0 all of them are returned.
+func (t *Tree) Suggest(word string, k int) []Suggestion {
+	return t.SuggestWith(word, k, SuggestOptions{})
+}
+
+// SuggestWith is Suggest with configurable edit costs and word weights. The
+// suggestions are ordered by distance, then weight, then alphabetically.
+func (t *Tree) SuggestWith(word string, k int, opts SuggestOptions) []Suggestion {
+	if opts.MaxDistance == 0 {
+		opts.MaxDistance = 2
+	}
+	if opts.SubstitutionCost == nil {
+		opts.SubstitutionCost = func(a, b byte) float64 { return 1 }
+	}
+
+	// Walk the tree computing one row of the edit distance matrix for each
+	// byte of the path, abandoning a branch once every entry in the row is
+	// over the limit.
+	s := &suggester{word: word, opts: opts}
+	row := make([]float64, len(word)+1)
+	for i := range row {
+		row[i] = float64(i)
+	}
+	s.walk(t.root, nil, row)
+
+	slices.SortFunc(s.found, func(a, b Suggestion) int {
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Weight, a.Weight); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Word, b.Word)
+	})
+	if k > 0 && len(s.found) > k {
+		s.found = s.found[:k]
+	}
+	return s.found
+}
+
+type suggester struct {
+	word  string
+	opts  SuggestOptions
+	found []Suggestion
+}
+
+// walk continues the search below node, whose path from the root is path. row
+// is the edit distance row for the last byte of path.
+func (s *suggester) walk(node *Node, path []byte, row []float64) {
+	if node.isWord && row[len(s.word)] <= s.opts.MaxDistance {
+		candidate := Suggestion{Word: string(path), Distance: row[len(s.word)]}
+		if s.opts.Weight != nil {
+			candidate.Weight = s.opts.Weight(candidate.Word)
+		}
+		s.found = append(s.found, candidate)
+	}
+
+	for _, child := range node.children {
+		cur := row
+		childPath := path
+		alive := true
+		for i := 0; i < len(child.label) && alive; i++ {
+			c := child.label[i]
+			next := make([]float64, len(cur))
+			next[0] = cur[0] + 1
+			best := next[0]
+			for j := 1; j < len(next); j++ {
+				sub := cur[j-1]
+				if s.word[j-1] != c {
+					sub += s.opts.SubstitutionCost(s.word[j-1], c)
+				}
+				next[j] = min(sub, cur[j]+1, next[j-1]+1)
+				best = min(best, next[j])
+			}
+			cur = next
+			childPath = append(childPath, c)
+			alive = best <= s.opts.MaxDistance
+		}
+		if alive {
+			s.walk(child, childPath, cur)
+		}
+	}
+}
+
+// qwertyAdjacent[a] has bit b set if the keys for lower case letters a and b
+// are next to each other on a QWERTY keyboard.
+var qwertyAdjacent = func() (adj ['z' + 1]uint32) {
+	rows := []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"}
+	link := func(a, b byte) {
+		adj[a] |= 1 << (b - 'a')
+		adj[b] |= 1 << (a - 'a')
+	}
+	for r, row := range rows {
+		for i := range len(row) {
+			if i+1 < len(row) {
+				link(row[i], row[i+1])
+			}
+			// Each row is shifted right by half a key relative to the one
+			// above, so a key touches the keys at i-1 and i in the row below.
+			if r+1 < len(rows) {
+				below := rows[r+1]
+				for _, j := range []int{i - 1, i} {
+					if j >= 0 && j < len(below) {
+						link(row[i], below[j])
+					}
+				}
+			}
+		}
+	}
+	return adj
+}()
+
+// QWERTYSubstitutionCost is a SubstitutionCost for SuggestOptions that makes
+// substituting a letter with a neighboring key on a QWERTY keyboard, which is a
+// likely typo, cost 0.5. A change of case alone costs 0.25, any other
+// substitution costs 1.
+func QWERTYSubstitutionCost(a, b byte) float64 {
+	la, lb := lower(a), lower(b)
+	switch {
+	case la == lb:
+		return 0.25
+	case la >= 'a' && la <= 'z' && lb >= 'a' && lb <= 'z' && qwertyAdjacent[la]&(1<<(lb-'a')) != 0:
+		return 0.5
+	}
+	return 1
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,90 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func suggestedWords(suggestions []Suggestion) []string {
+	var words []string
+	for _, s := range suggestions {
+		words = append(words, s.Word)
+	}
+	return words
+}
+
+func TestSuggest(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"hello", "help", "helm", "hell", "yellow", "jello", "world"} {
+		tree.Insert(word)
+	}
+
+	cases := []struct {
+		Name     string
+		Word     string
+		K        int
+		Expected []string
+	}{
+		{"Exact match first", "hello", 3, []string{"hello", "hell", "jello"}},
+		{"Substitution", "helo", 0, []string{"hell", "hello", "helm", "help", "jello"}},
+		{"Nothing close", "xyzzy", 0, nil},
+		{"Limit", "wrld", 1, []string{"world"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual := suggestedWords(tree.Suggest(tc.Word, tc.K))
+			if !slices.Equal(actual, tc.Expected) {
+				t.Errorf("Expected %v, got %v", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestSuggestWith(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"cat", "cap", "car", "cab"} {
+		tree.Insert(word)
+	}
+
+	weights := map[string]float64{"cap": 10, "car": 5}
+	opts := SuggestOptions{
+		MaxDistance:      1,
+		SubstitutionCost: QWERTYSubstitutionCost,
+		Weight:           func(word string) float64 { return weights[word] },
+	}
+
+	// 'cay' is a single substitution away from every word, but only y and t
+	// are neighbors on the keyboard. The rest are ranked by weight.
+	actual := tree.SuggestWith("cay", 0, opts)
+	expected := []Suggestion{
+		{"cat", 0.5, 0},
+		{"cap", 1, 10},
+		{"car", 1, 5},
+		{"cab", 1, 0},
+	}
+	if !slices.Equal(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestQWERTYSubstitutionCost(t *testing.T) {
+	cases := []struct {
+		A, B     byte
+		Expected float64
+	}{
+		{'q', 'w', 0.5},
+		{'q', 'a', 0.5},
+		{'s', 'z', 0.5},
+		{'s', 'x', 0.5},
+		{'g', 'b', 0.5},
+		{'q', 'p', 1},
+		{'a', 'A', 0.25},
+		{'T', 'y', 0.5},
+		{'1', '2', 1},
+	}
+	for _, tc := range cases {
+		if actual := QWERTYSubstitutionCost(tc.A, tc.B); actual != tc.Expected {
+			t.Errorf("%c -> %c: expected %v, got %v", tc.A, tc.B, tc.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package compressedtrie
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// A ShardWriter is a shard written by SerializeWithShards: the words of the
+// tree that start with one of Prefixes, written to W. An empty string in
+// Prefixes selects every word, an empty Prefixes none.
+type ShardWriter struct {
+	Prefixes []string
+	W        io.Writer
+}
+
+// SerializeWithShards is SerializeWithOptions that also writes a file for each
+// of shards, holding the words that start with the shard's prefixes, as the
+// whole tree is written. A publishing pipeline that ships the whole dictionary
+// along with files for parts of it then walks the tree once, rather than once
+// for the whole and again for each shard. Each shard reads back as
+// DeserializeTreeFiltered would read it from the whole file, and is the file
+// SerializeWithOptions writes for that tree, except that with LabelDictionary
+// every shard carries the dictionary of the whole tree. Shards can only be
+// written in the DepthFirst layout.
+func (t *Tree) SerializeWithShards(w io.Writer, shards []ShardWriter, opts SerializeOptions) error {
+	return NewSerializer(opts).SerializeWithShards(w, t, shards)
+}
+
+// SerializeWithShards writes t to w and its shards to theirs, the same as
+// t.SerializeWithShards.
+func (s *Serializer) SerializeWithShards(w io.Writer, t *Tree, shards []ShardWriter) error {
+	if len(shards) > 0 && s.opts.Layout != DepthFirst {
+		return errors.New("compressedtrie: shards can only be written in the DepthFirst layout")
+	}
+	return s.serialize(w, t, shards)
+}
+
+// A teeShard is a shard being written alongside the whole tree. Below the
+// nodes at which the shard's prefixes end, its nodes, and their records, are
+// those of the whole tree. Above them the shard has nodes of its own, on the
+// paths to the shared subtrees, which are written as the walk of the whole
+// tree passes the node each stands for.
+type teeShard struct {
+	e    *encoder // writes the shard, sharing the maps of the whole tree's encoder
+	buf  *bufio.Writer
+	at   map[*Node]*Node // the shard's node written at each node of the whole tree, nil for nodes it passes through
+	orig map[*Node]*Node // the inverse of at
+	tops map[*Node]bool  // the shard's nodes whose children are those of the whole tree
+}
+
+// teeTarget is a shard taking part in the walk of a subtree. inside is set once
+// the walk is below a shared subtree's root, where the shard's records are the
+// same as the whole tree's.
+type teeTarget struct {
+	s      *teeShard
+	inside bool
+}
+
+// writeShards writes the tree below root, which has been measured with
+// e.subtreeNodes set, along with shards. The shards' files have flags and dict
+// like the whole tree's.
+func (e *encoder) writeShards(root *Node, shards []ShardWriter, flags uint32, dict []string, bufSize int) error {
+	targets := make([]teeTarget, len(shards))
+	for i, shard := range shards {
+		s := &teeShard{
+			buf:  bufio.NewWriterSize(shard.W, bufSize),
+			at:   make(map[*Node]*Node),
+			orig: make(map[*Node]*Node),
+			tops: make(map[*Node]bool),
+		}
+		s.e = &encoder{w: s.buf, off: headerSize, sizes: e.sizes, dict: e.dict, values: e.values, bounds: e.bounds, counts: e.counts}
+		top := s.view(e, root, "", shards[i].Prefixes, true)
+		e.measureShard(top)
+
+		nodes := 0
+		for v, node := range s.orig {
+			if s.tops[v] {
+				nodes += e.subtreeNodes[node] - 1
+			}
+			nodes++
+		}
+		if err := s.e.writeStart(nodes, flags, dict); err != nil {
+			return err
+		}
+		targets[i] = teeTarget{s: s}
+	}
+
+	if err := e.writeTee(root, targets); err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if err := target.s.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// view returns the shard's node for node, whose path from the root is path,
+// or nil if the shard has no words below it, recording where the shard's nodes
+// are written. The nodes are those DeserializeTreeFiltered gives after
+// pruneNode: a node of the shard that isn't a word and is left with a single
+// child is merged with it.
+func (s *teeShard) view(e *encoder, node *Node, path string, prefixes []string, root bool) *Node {
+	if selected(path, prefixes) {
+		s.place(node, node, true)
+		return node
+	}
+
+	v := &Node{label: node.label, children: make(map[byte]*Node)}
+	for k, child := range node.children {
+		childPath := path + child.label
+		if !selected(childPath, prefixes) && !leadsTo(childPath, prefixes) {
+			continue
+		}
+		if c := s.view(e, child, childPath, prefixes, false); c != nil {
+			v.children[k] = c
+		}
+	}
+
+	switch {
+	case root:
+	case len(v.children) == 0:
+		return nil
+	case len(v.children) == 1:
+		// node is passed through, its child takes its place
+		s.at[node] = nil
+		for _, c := range v.children {
+			m := *c
+			m.label = node.label + c.label
+			s.place(s.orig[c], &m, s.tops[c])
+			delete(s.orig, c)
+			delete(s.tops, c)
+			if e.counts != nil {
+				e.counts[&m] = e.counts[c]
+			}
+			if value, ok := e.values[c]; ok {
+				e.values[&m] = value
+			}
+			return &m
+		}
+	}
+
+	var words uint64
+	for _, c := range v.children {
+		if c.lenHi != 0 && (v.lenHi == 0 || c.lenLo < v.lenLo) {
+			v.lenLo = c.lenLo
+		}
+		v.lenHi = max(v.lenHi, c.lenHi)
+		if e.counts != nil {
+			words += e.counts[c]
+		}
+	}
+	if e.counts != nil {
+		e.counts[v] = words
+	}
+	s.place(node, v, false)
+	return v
+}
+
+// place records that v is written at node, and whether v's children are those
+// of the whole tree.
+func (s *teeShard) place(node, v *Node, top bool) {
+	s.at[node] = v
+	s.orig[v] = node
+	if top {
+		s.tops[v] = true
+	}
+}
+
+// measureShard is measure for the nodes of a shard that aren't in the whole
+// tree, whose subtrees include those that are, which have been measured.
+func (e *encoder) measureShard(node *Node) uint64 {
+	if size, ok := e.sizes[node]; ok {
+		return size
+	}
+	n := e.headSize(node)
+	for _, child := range node.children {
+		size := e.measureShard(child)
+		n += uint64(uvarintLen(size)) + size
+	}
+	e.sizes[node] = n
+	return n
+}
+
+// writeTee is writeNode that also writes the records of targets the nodes of
+// node's subtree stand for.
+func (e *encoder) writeTee(node *Node, targets []teeTarget) error {
+	if len(targets) == 0 {
+		return e.writeNode(node)
+	}
+	keys, err := e.writeRecord(node)
+	if err != nil {
+		return err
+	}
+	for i, target := range targets {
+		v := node
+		if !target.inside {
+			v = target.s.at[node]
+			targets[i].inside = target.s.tops[v]
+		}
+		if v != nil {
+			if _, err := target.s.e.writeRecord(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, k := range keys {
+		child := node.children[k]
+		var below []teeTarget
+		for _, target := range targets {
+			if _, ok := target.s.at[child]; ok || target.inside {
+				below = append(below, target)
+			}
+		}
+		if err := e.writeTee(child, below); err != nil {
+			return err
+		}
+	}
+	return nil
+}
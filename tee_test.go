@@ -0,0 +1,104 @@
+package compressedtrie
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestSerializeWithShards(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+	prefixSets := [][]string{
+		{"ro"}, {"rub", "t"}, {""}, {}, {"x"}, {"romanus"}, {"rom", "romulus"}, {"s", "slo"}, {"rubi", "te"}, {"toast"},
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Opts SerializeOptions
+	}{
+		{"Plain", SerializeOptions{}},
+		{"Length bounds", SerializeOptions{LengthBounds: true}},
+		{"Word counts", SerializeOptions{WordCounts: true, LengthBounds: true, BufferSize: 16}},
+		{"Dictionary", SerializeOptions{LabelDictionary: true}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			var whole bytes.Buffer
+			files := make([]bytes.Buffer, len(prefixSets))
+			shards := make([]ShardWriter, len(prefixSets))
+			for i, prefixes := range prefixSets {
+				shards[i] = ShardWriter{Prefixes: prefixes, W: &files[i]}
+			}
+			if err := tree.SerializeWithShards(&whole, shards, tc.Opts); err != nil {
+				t.Fatal(err)
+			}
+			if expected := tree.FreezeWithOptions(tc.Opts); !bytes.Equal(whole.Bytes(), expected) {
+				t.Errorf("The whole tree differs from SerializeWithOptions")
+			}
+
+			for i, prefixes := range prefixSets {
+				expected, err := DeserializeTreeFiltered(bytes.NewReader(whole.Bytes()), prefixes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				shard, err := DeserializeTree(bytes.NewReader(files[i].Bytes()))
+				if err != nil {
+					t.Fatalf("%q: %v", prefixes, err)
+				}
+				if got, want := shard.FindWordsWithPrefix(""), expected.FindWordsWithPrefix(""); !slices.Equal(got, want) {
+					t.Errorf("%q: expected %v, got %v", prefixes, want, got)
+				}
+				if shard.NodeCount() != expected.NodeCount() {
+					t.Errorf("%q: expected %d nodes, got %d", prefixes, expected.NodeCount(), shard.NodeCount())
+				}
+				frozen, err := AttachFrozen(files[i].Bytes())
+				if err == nil {
+					err = frozen.Verify()
+				}
+				if err != nil {
+					t.Errorf("%q: %v", prefixes, err)
+				}
+				if tc.Opts.LabelDictionary {
+					// Each shard has the whole tree's dictionary
+					continue
+				}
+				if want := expected.FreezeWithOptions(tc.Opts); !bytes.Equal(files[i].Bytes(), want) {
+					t.Errorf("%q: the shard differs from SerializeWithOptions of its words", prefixes)
+				}
+			}
+		})
+	}
+
+	// Shards of a larger tree, at prefixes that end inside labels and at
+	// nodes
+	big := perfTree(2000)
+	words := big.FindWordsWithPrefix("")
+	var shards []ShardWriter
+	var files []*bytes.Buffer
+	for i := 0; i+40 < len(words); i += 97 {
+		file := &bytes.Buffer{}
+		files = append(files, file)
+		shards = append(shards, ShardWriter{Prefixes: []string{words[i][:len(words[i])/2], words[i+40][:2]}, W: file})
+	}
+	var whole bytes.Buffer
+	opts := SerializeOptions{LengthBounds: true, WordCounts: true}
+	if err := big.SerializeWithShards(&whole, shards, opts); err != nil {
+		t.Fatal(err)
+	}
+	for i, shard := range shards {
+		expected, err := DeserializeTreeFiltered(bytes.NewReader(whole.Bytes()), shard.Prefixes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := expected.FreezeWithOptions(opts); !bytes.Equal(files[i].Bytes(), want) {
+			t.Errorf("%q: the shard differs from SerializeWithOptions of its words", shard.Prefixes)
+		}
+	}
+
+	err := tree.SerializeWithShards(&bytes.Buffer{}, []ShardWriter{{Prefixes: []string{"r"}, W: &bytes.Buffer{}}}, SerializeOptions{Layout: BreadthFirst})
+	if err == nil {
+		t.Error("Expected an error for shards in the BreadthFirst layout")
+	}
+}
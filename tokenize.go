@@ -0,0 +1,41 @@
+package compressedtrie
+
+import "unicode/utf8"
+
+// A Token is a span s[Start:End] of a string given to Tokenize. Known tokens
+// are words of the tree, the rest are runs of input between them.
+type Token struct {
+	Start, End int
+	Known      bool
+}
+
+// Tokenize segments s into words of t and the unknown runs between them, in
+// order and covering all of s. From each position it takes the longest word of
+// t that starts there, as LongestPrefix does, and where no word does it skips
+// a rune and extends the unknown run. The empty word never matches. Greedy
+// matching can miss a segmentation; with "a", "ab" and "bc", "abc" gives "ab"
+// and an unknown "c".
+func (t *Tree) Tokenize(s string) []Token {
+	var tokens []Token
+	unknown := -1 // start of the current unknown run
+	for i := 0; i < len(s); {
+		if n, _ := t.longestPrefix(s[i:]); n > 0 {
+			if unknown >= 0 {
+				tokens = append(tokens, Token{Start: unknown, End: i})
+				unknown = -1
+			}
+			tokens = append(tokens, Token{Start: i, End: i + n, Known: true})
+			i += n
+			continue
+		}
+		if unknown < 0 {
+			unknown = i
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	if unknown >= 0 {
+		tokens = append(tokens, Token{Start: unknown, End: len(s)})
+	}
+	return tokens
+}
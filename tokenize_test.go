@@ -0,0 +1,44 @@
+package compressedtrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"", "new", "newyork", "york", "city", "a", "ab", "bc"} {
+		tree.Insert(word)
+	}
+	cases := []struct {
+		S        string
+		Expected []string // unknown runs in brackets
+	}{
+		{"newyorkcity", []string{"newyork", "city"}},
+		{"newyorxcity", []string{"new", "[yorx]", "city"}},
+		{"thenewcity!", []string{"[the]", "new", "city", "[!]"}},
+		{"zürichcity", []string{"[zürich]", "city"}},
+		// Greedy, not the best segmentation
+		{"abc", []string{"ab", "[c]"}},
+		{"xyz", []string{"[xyz]"}},
+		{"", nil},
+	}
+	for _, tc := range cases {
+		var actual []string
+		end := 0
+		for _, token := range tree.Tokenize(tc.S) {
+			if token.Start != end {
+				t.Errorf("%q: token %+v does not follow %d", tc.S, token, end)
+			}
+			end = token.End
+			if token.Known {
+				actual = append(actual, tc.S[token.Start:token.End])
+			} else {
+				actual = append(actual, "["+tc.S[token.Start:token.End]+"]")
+			}
+		}
+		if end != len(tc.S) || !slices.Equal(actual, tc.Expected) {
+			t.Errorf("%q: expected %q, got %q", tc.S, tc.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package compressedtrie
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// TokenTree is a compressed trie over sequences of tokens, such as the words of
+// phrases, rather than bytes. Labels are runs of whole tokens, so prefixes
+// never split a token and completions follow token boundaries: "new york ci"
+// completes to "new york city" without "new yorker" getting in the way.
+// Tokenizing phrases is left to the caller.
+type TokenTree struct {
+	root  *tokenNode
+	nodes int
+	seqs  int
+}
+
+type tokenNode struct {
+	label    []string // tokens on the edge from the parent, empty for the root
+	children map[string]*tokenNode
+	isSeq    bool // whether the node marks the end of a sequence
+}
+
+// NewTokenTree returns an empty TokenTree.
+func NewTokenTree() *TokenTree {
+	return &TokenTree{root: &tokenNode{children: make(map[string]*tokenNode)}, nodes: 1}
+}
+
+// Insert adds the sequence tokens to t.
+func (t *TokenTree) Insert(tokens []string) {
+	cur := t.root
+	for {
+		if len(tokens) == 0 {
+			if !cur.isSeq {
+				cur.isSeq = true
+				t.seqs++
+			}
+			return
+		}
+
+		child, exists := cur.children[tokens[0]]
+		if !exists {
+			cur.children[tokens[0]] = &tokenNode{
+				label:    slices.Clone(tokens),
+				children: make(map[string]*tokenNode),
+				isSeq:    true,
+			}
+			t.nodes++
+			t.seqs++
+			return
+		}
+
+		common := commonTokens(tokens, child.label)
+		if common == len(child.label) {
+			tokens = tokens[common:]
+			cur = child
+			continue
+		}
+
+		// Split the label where tokens leaves it, as Tree.Insert does
+		split := &tokenNode{
+			label:    child.label[:common:common],
+			children: map[string]*tokenNode{child.label[common]: child},
+		}
+		child.label = child.label[common:]
+		cur.children[tokens[0]] = split
+		t.nodes++
+	}
+}
+
+// commonTokens returns the number of tokens at the start of a and b that are
+// the same.
+func commonTokens(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// Contains reports whether the sequence tokens has been inserted into t.
+func (t *TokenTree) Contains(tokens []string) bool {
+	node, _, inLabel := t.descend(tokens)
+	return node != nil && inLabel == 0 && node.isSeq
+}
+
+// descend follows prefix down from the root. It returns the highest node
+// whose sequence starts with prefix along with that sequence, and how many
+// tokens of the node's label prefix ends before, 0 if it ends at the node. The
+// node is nil if no sequence starts with prefix.
+func (t *TokenTree) descend(prefix []string) (*tokenNode, []string, int) {
+	cur := t.root
+	var path []string
+	for len(prefix) > 0 {
+		child, exists := cur.children[prefix[0]]
+		if !exists {
+			return nil, nil, 0
+		}
+		common := commonTokens(prefix, child.label)
+		path = append(path, child.label...)
+		if common < len(child.label) {
+			if common < len(prefix) {
+				return nil, nil, 0
+			}
+			return child, path, len(child.label) - common
+		}
+		prefix = prefix[common:]
+		cur = child
+	}
+	return cur, path, 0
+}
+
+// FindWithPrefix returns the sequences in t that start with the tokens of
+// prefix, in ascending order token by token.
+func (t *TokenTree) FindWithPrefix(prefix []string) [][]string {
+	var seqs [][]string
+	if node, path, _ := t.descend(prefix); node != nil {
+		t.gather(node, path, &seqs)
+	}
+	return seqs
+}
+
+// Complete returns the sequences in t that start with the tokens of prefix
+// followed by a token that starts with partial, the token being typed, in the
+// same order as FindWithPrefix. Complete([]string{"new", "york"}, "ci") finds
+// "new york city" and "new york city hall".
+func (t *TokenTree) Complete(prefix []string, partial string) [][]string {
+	var seqs [][]string
+	node, path, inLabel := t.descend(prefix)
+	switch {
+	case node == nil:
+	case inLabel > 0:
+		// The next token is in the node's label
+		if strings.HasPrefix(node.label[len(node.label)-inLabel], partial) {
+			t.gather(node, path, &seqs)
+		}
+	default:
+		for _, k := range slices.Sorted(maps.Keys(node.children)) {
+			if strings.HasPrefix(k, partial) {
+				child := node.children[k]
+				t.gather(child, append(slices.Clip(path), child.label...), &seqs)
+			}
+		}
+	}
+	return seqs
+}
+
+// gather appends the sequences in the subtree at node, whose sequence is path,
+// to seqs.
+func (t *TokenTree) gather(node *tokenNode, path []string, seqs *[][]string) {
+	if node.isSeq {
+		*seqs = append(*seqs, slices.Clone(path))
+	}
+	for _, k := range slices.Sorted(maps.Keys(node.children)) {
+		child := node.children[k]
+		t.gather(child, append(slices.Clip(path), child.label...), seqs)
+	}
+}
+
+// Len returns the number of sequences in t.
+func (t *TokenTree) Len() int {
+	return t.seqs
+}
+
+// NodeCount returns the number of nodes in t.
+func (t *TokenTree) NodeCount() int {
+	return t.nodes
+}
@@ -0,0 +1,69 @@
+package compressedtrie
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenTree(t *testing.T) {
+	tree := NewTokenTree()
+	for _, phrase := range []string{"new york city", "new york city hall", "new yorker", "new york", "new jersey", "newark", "new york times"} {
+		tree.Insert(strings.Fields(phrase))
+	}
+	tree.Insert([]string{"new", "york"})
+	if tree.Len() != 7 {
+		t.Errorf("Expected 7 sequences, got %d", tree.Len())
+	}
+	// root, new, york, city, hall, times, yorker, jersey, newark
+	if tree.NodeCount() != 9 {
+		t.Errorf("Expected 9 nodes, got %d", tree.NodeCount())
+	}
+
+	phrases := func(seqs [][]string) []string {
+		var out []string
+		for _, seq := range seqs {
+			out = append(out, strings.Join(seq, " "))
+		}
+		return out
+	}
+	completions := []struct {
+		Prefix   string
+		Partial  string
+		Expected []string
+	}{
+		{"new york", "ci", []string{"new york city", "new york city hall"}},
+		{"new york", "", []string{"new york city", "new york city hall", "new york times"}},
+		{"new", "york", []string{"new york", "new york city", "new york city hall", "new york times", "new yorker"}},
+		{"new york city", "h", []string{"new york city hall"}},
+		{"", "new", []string{"new jersey", "new york", "new york city", "new york city hall", "new york times", "new yorker", "newark"}},
+		{"new york", "x", nil},
+		{"old", "", nil},
+	}
+	for _, tc := range completions {
+		if actual := phrases(tree.Complete(strings.Fields(tc.Prefix), tc.Partial)); !reflect.DeepEqual(actual, tc.Expected) {
+			t.Errorf("Complete(%q, %q): expected %q, got %q", tc.Prefix, tc.Partial, tc.Expected, actual)
+		}
+	}
+
+	// Tokens are never split
+	if actual := phrases(tree.FindWithPrefix([]string{"new", "york"})); !reflect.DeepEqual(actual, []string{"new york", "new york city", "new york city hall", "new york times"}) {
+		t.Errorf("Unexpected sequences %q", actual)
+	}
+	if actual := tree.FindWithPrefix([]string{"new", "yor"}); actual != nil {
+		t.Errorf("Expected no sequences, got %q", actual)
+	}
+
+	for phrase, expected := range map[string]bool{"new york": true, "new york city": true, "new": false, "new york city hall": true, "new york ci": false, "": false} {
+		if tree.Contains(strings.Fields(phrase)) != expected {
+			t.Errorf("Contains(%q): expected %v", phrase, expected)
+		}
+	}
+
+	// Sequences don't share storage with the tree or each other
+	seqs := tree.FindWithPrefix([]string{"new", "york"})
+	seqs[0][0] = "old"
+	if !tree.Contains([]string{"new", "york"}) {
+		t.Errorf("Changing a result changed the tree")
+	}
+}
@@ -0,0 +1,186 @@
+package compressedtrie
+
+import (
+	"container/heap"
+	"maps"
+	"slices"
+)
+
+// A step tells a traversal how to go on from a node.
+type step int
+
+const (
+	stepInto step = iota // go on into the node's subtree
+	stepOver             // skip the node's subtree
+	stepStop             // end the traversal
+)
+
+// A traversal is a walk over the words of a subtree, the core shared by the
+// queries that collect words so that each only says what it wants rather than
+// how to get there. Limits on the number of words are up to visit, which stops
+// the walk once it has enough. The walk is iterative, so deep trees don't grow
+// the call stack.
+type traversal struct {
+	// enter, if set, is called for each node before its word and children
+	// and decides whether they are visited. In a depth first walk path is
+	// in the walk's buffer, only valid until enter returns.
+	enter func(node *Node, path []byte, state int) step
+
+	// visit is called for each word reached. stepOver skips the subtree
+	// below the word, in depth first order only.
+	visit func(word string, node *Node, state int) step
+
+	// visitBytes, if set, is called in place of visit with the word in a
+	// buffer the walk reuses, only valid until it returns, for walks that
+	// don't keep the words. Depth first only.
+	visitBytes func(word []byte, node *Node, state int) step
+
+	// keyOrder makes a depth first walk take children in ascending order of
+	// key, whatever the child order of the tree.
+	keyOrder bool
+
+	// buf, if set, is the buffer a depth first walk builds paths in.
+	buf []byte
+
+	// unprofiled keeps a depth first walk out of the tree's profile, for the
+	// walks that report on it.
+	unprofiled bool
+
+	// advance, if set, returns the state of a child's path from the state
+	// of its parent's and its label, for walks that track a matcher along
+	// the path. state is the state of the first node's path.
+	advance func(state int, label string) int
+	state   int
+
+	// rank, if set, makes the walk best first rather than depth first in
+	// the child order of the tree. A node's subtree comes out by its key,
+	// which must be at least the key of every word in it, and its word by
+	// the word's key, highest first, then in ascending order of path.
+	rank func(node *Node) (subtree, word float64)
+}
+
+// walkEntry is a node waiting to be walked by a best first traversal.
+type walkEntry struct {
+	node  *Node
+	path  string
+	state int
+}
+
+// stackEntry is a node waiting to be walked by a depth first traversal. The
+// node's path is its label after the first at bytes of the walk's buffer, or
+// the whole buffer for the node the walk starts at.
+type stackEntry struct {
+	node  *Node
+	at    int
+	state int
+}
+
+// traverse walks the subtree of node, whose path is path, as tr describes. It
+// returns false if the walk was stopped by enter or visit.
+func (t *Tree) traverse(node *Node, path string, tr traversal) bool {
+	if tr.rank != nil {
+		return t.traverseBest(node, path, tr)
+	}
+
+	// The stack comes off in depth first order, so the path of the node
+	// taken off it always extends the path of its parent in buf, and paths
+	// are only made into strings for the calls that need them.
+	buf := append(tr.buf[:0], path...)
+	stack := []stackEntry{{node, -1, tr.state}}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e.at >= 0 {
+			buf = append(buf[:e.at], e.node.label...)
+		}
+		if !tr.unprofiled {
+			t.visit(e.node)
+		}
+		if tr.enter != nil {
+			switch tr.enter(e.node, buf, e.state) {
+			case stepOver:
+				continue
+			case stepStop:
+				return false
+			}
+		}
+		if e.node.isWord {
+			var s step
+			if tr.visitBytes != nil {
+				s = tr.visitBytes(buf, e.node, e.state)
+			} else {
+				s = tr.visit(string(buf), e.node, e.state)
+			}
+			switch s {
+			case stepOver:
+				continue
+			case stepStop:
+				return false
+			}
+		}
+
+		var keys []byte
+		switch {
+		case len(e.node.children) == 0:
+		case tr.keyOrder:
+			keys = slices.Sorted(maps.Keys(e.node.children))
+		case t.order != nil:
+			keys = t.childKeys(e.node, string(buf))
+		default:
+			keys = t.childKeys(e.node, "")
+		}
+		// Pushed last first so that they come off the stack in order
+		for _, k := range slices.Backward(keys) {
+			child := e.node.children[k]
+			state := e.state
+			if tr.advance != nil {
+				state = tr.advance(e.state, child.label)
+			}
+			stack = append(stack, stackEntry{child, len(buf), state})
+		}
+	}
+	return true
+}
+
+// traverseBest is traverse in the order of tr.rank.
+func (t *Tree) traverseBest(node *Node, path string, tr traversal) bool {
+	key, _ := tr.rank(node)
+	q := &rankQueue{{node: node, path: path, key: key, state: tr.state}}
+	for q.Len() > 0 {
+		e := heap.Pop(q).(rankEntry)
+		if e.word {
+			if tr.visit(e.path, e.node, e.state) == stepStop {
+				return false
+			}
+			continue
+		}
+		t.visit(e.node)
+		if tr.enter != nil {
+			switch tr.enter(e.node, []byte(e.path), e.state) {
+			case stepOver:
+				continue
+			case stepStop:
+				return false
+			}
+		}
+		if e.node.isWord {
+			_, key := tr.rank(e.node)
+			heap.Push(q, rankEntry{node: e.node, path: e.path, word: true, key: key, state: e.state})
+		}
+		for _, child := range e.node.children {
+			c := tr.child(walkEntry{e.node, e.path, e.state}, child)
+			key, _ := tr.rank(child)
+			heap.Push(q, rankEntry{node: child, path: c.path, key: key, state: c.state})
+		}
+	}
+	return true
+}
+
+// child returns the entry for child, a child of the node of e.
+func (tr *traversal) child(e walkEntry, child *Node) walkEntry {
+	c := walkEntry{child, e.path + child.label, e.state}
+	if tr.advance != nil {
+		c.state = tr.advance(e.state, child.label)
+	}
+	return c
+}
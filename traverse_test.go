@@ -0,0 +1,152 @@
+package compressedtrie
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// collect returns the words visited by tr over the whole of tree, and whether
+// the walk finished.
+func collect(tree *Tree, tr traversal) ([]string, bool) {
+	var words []string
+	visit := tr.visit
+	tr.visit = func(word string, node *Node, state int) step {
+		words = append(words, word)
+		if visit == nil {
+			return stepInto
+		}
+		return visit(word, node, state)
+	}
+	return words, tree.traverse(tree.root, "", tr)
+}
+
+func TestTraverse(t *testing.T) {
+	words := []string{"", "a", "ab", "abc", "abd", "b", "ba", "bab", "c"}
+	tree := NewTree()
+	for _, word := range words {
+		tree.Insert(word)
+	}
+
+	if got, ok := collect(tree, traversal{}); !ok || !slices.Equal(got, words) {
+		t.Errorf("Expected %v in order, got %v, %v", words, got, ok)
+	}
+
+	reversed := NewTree(WithChildOrder(func(a, b string) int { return cmp.Compare(b, a) }))
+	for _, word := range words {
+		reversed.Insert(word)
+	}
+	got, _ := collect(reversed, traversal{})
+	if expected := []string{"", "c", "b", "ba", "bab", "a", "ab", "abd", "abc"}; !slices.Equal(got, expected) {
+		t.Errorf("Expected the child order %v, got %v", expected, got)
+	}
+
+	// keyOrder ignores the child order, and visitBytes sees the same words
+	var raw []string
+	reversed.traverse(reversed.root, "", traversal{
+		visitBytes: func(word []byte, _ *Node, _ int) step {
+			raw = append(raw, string(word))
+			return stepInto
+		},
+		keyOrder: true,
+	})
+	if !slices.Equal(raw, words) {
+		t.Errorf("Expected %v in key order, got %v", words, raw)
+	}
+
+	// enter skips subtrees and stops the walk
+	got, ok := collect(tree, traversal{enter: func(node *Node, path []byte, _ int) step {
+		switch string(path) {
+		case "ab":
+			return stepOver
+		case "c":
+			return stepStop
+		}
+		return stepInto
+	}})
+	if expected := []string{"", "a", "b", "ba", "bab"}; ok || !slices.Equal(got, expected) {
+		t.Errorf("Expected %v and a stopped walk, got %v, %v", expected, got, ok)
+	}
+
+	// visit skips the words below a word
+	got, _ = collect(tree, traversal{visit: func(word string, _ *Node, _ int) step {
+		if word == "" {
+			return stepInto
+		}
+		return stepOver
+	}})
+	if expected := []string{"", "a", "b", "c"}; !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// The state follows the path, here the number of b's in it
+	var counts []int
+	tree.traverse(tree.root, "", traversal{
+		visit: func(word string, _ *Node, state int) step {
+			if state != strings.Count(word, "b") {
+				t.Errorf("%q: expected state %d, got %d", word, strings.Count(word, "b"), state)
+			}
+			counts = append(counts, state)
+			return stepInto
+		},
+		advance: func(state int, label string) int { return state + strings.Count(label, "b") },
+	})
+	if len(counts) != len(words) {
+		t.Errorf("Expected %d words visited, got %d", len(words), len(counts))
+	}
+}
+
+func TestTraverseBest(t *testing.T) {
+	weights := map[string]float64{"a": 1, "ab": 5, "abc": 2, "b": 5, "ba": 3, "c": 4}
+	tree := NewTree()
+	for word := range weights {
+		tree.Insert(word)
+	}
+	// A subtree's key is the largest weight in it
+	var rank func(node *Node, path string) float64
+	rank = func(node *Node, path string) float64 {
+		key := weights[path]
+		for _, child := range node.children {
+			key = max(key, rank(child, path+child.label))
+		}
+		return key
+	}
+	paths := map[*Node]string{}
+	tree.traverse(tree.root, "", traversal{
+		enter: func(node *Node, path []byte, _ int) step {
+			paths[node] = string(path)
+			return stepInto
+		},
+		visit: func(string, *Node, int) step { return stepInto },
+	})
+
+	got, ok := collect(tree, traversal{
+		rank: func(node *Node) (float64, float64) {
+			return rank(node, paths[node]), weights[paths[node]]
+		},
+		visit: func(word string, _ *Node, _ int) step {
+			if word == "ba" {
+				return stepStop
+			}
+			return stepInto
+		},
+	})
+	// Equal weights come out in ascending order
+	if expected := []string{"ab", "b", "c", "ba"}; ok || !slices.Equal(got, expected) {
+		t.Errorf("Expected %v and a stopped walk, got %v, %v", expected, got, ok)
+	}
+}
+
+func TestTraverseDeep(t *testing.T) {
+	// A chain of nodes one below the other
+	tree := NewTree()
+	var word strings.Builder
+	for range 3000 {
+		word.WriteString("ab")
+		tree.Insert(word.String())
+	}
+	if words := tree.FindWordsWithPrefix(""); len(words) != 3000 {
+		t.Errorf("Expected 3000 words, got %d", len(words))
+	}
+}
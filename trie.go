@@ -35,10 +35,8 @@ package compressedtrie
 // characters sequentially in memory.
 
 import (
-	"bufio"
-	"encoding/binary"
 	"errors"
-	"io"
+	"iter"
 	"maps"
 	"slices"
 	"strings"
@@ -47,47 +45,172 @@ import (
 var (
 	ErrUnsupportedVersion = errors.New("unsupported version of the file format")
 	ErrInvalidFormat      = errors.New("invalid file format")
+	ErrWordTooLong        = errors.New("word exceeds maximum length")
+	ErrTreeTooDeep        = errors.New("word exceeds maximum tree depth")
+	ErrTooLarge           = errors.New("tree is too large for the file format")
+	ErrNotCanonical       = errors.New("tree is not in canonical form")
+	ErrDeltaMismatch      = errors.New("delta does not apply to this tree")
+	ErrDuplicateKey       = errors.New("key already has a value")
+	ErrStaleCursor        = errors.New("cursor used after its tree changed")
 )
 
 type Node struct {
 	label    string
 	children map[byte]*Node
 	isWord   bool
+
+	// The lengths of the shortest and longest words in the subtree, see
+	// addLength. lenHi is one more than the longest, 0 if there are no words.
+	lenLo, lenHi uint32
+
+	value any // the values of a word node in a MultiMap, the weights in a WeightedTree
+
+	gen uint64 // the tree generation that created the node, see WithHistory
 }
 
 type Tree struct {
-	root *Node
-	N    int // The number of nodes in the tree
+	root  *Node
+	nodes int // The number of nodes in the tree, including the root
+	words int // The number of words stored in the tree
+
+	maxWordLen int // 0 means unlimited
+	maxDepth   int // 0 means unlimited
+
+	misses *missCache            // nil unless WithMissCache was used
+	order  func(a, b string) int // child order, nil for byte order
+
+	dirty map[*Node]dirtyEntry // changed nodes, nil unless WithDirtyTracking was used
+	dups  *DuplicateReport     // nil unless WithDuplicateReport was used
+
+	duplicates DuplicatePolicy // see WithDuplicatePolicy
+
+	history *history // committed versions, nil unless WithHistory was used
+	gen     uint64   // generation of the nodes that belong to t alone
+
+	finger *finger // path of the last word added by InsertSortedNext
+
+	changes uint64 // incremented by every change to t, see Cursor
+	splits  int    // labels split by inserts, see Stats
+
+	alloc       Allocator // nil for the Go heap, see WithAllocator
+	copyStrings bool      // see WithCopyOnInsert
+
+	profile *visitCounts // nil unless built with profiling, see Profile
 }
 
-type SerializedTreeHeader struct {
-	Magic   uint32 // magic number (CtreeMagic)
-	Version uint32 // file format version
-	Nodes   uint32 // number of nodes in the tree
+// An Option configures a Tree created by NewTree.
+type Option func(*Tree)
+
+// WithMaxWordLength makes Insert reject words longer than n bytes with
+// ErrWordTooLong. A value of 0 disables the limit.
+func WithMaxWordLength(n int) Option {
+	return func(t *Tree) { t.maxWordLen = n }
 }
 
-const (
-	// 32-bit magic number for the serialized tree binary format
-	CtreeMagic uint32 = 'C'<<24 | 'T'<<16 | 'R'<<8 | 'E'
-	// File format version
-	Version uint32 = 1
-)
+// WithMaxDepth makes Insert reject words that would cause any node to sit more
+// than n edges below the root with ErrTreeTooDeep. A value of 0 disables the
+// limit. Note that splitting a label pushes the whole subtree below it down a
+// level, so enforcing this limit costs a walk of that subtree on every split.
+func WithMaxDepth(n int) Option {
+	return func(t *Tree) { t.maxDepth = n }
+}
+
+// WithChildOrder changes the order in which FindWordsWithPrefix and
+// WordsWithPrefix visit the children of a node. cmp is given the paths from the
+// root of two children of the same node and returns a negative number if the
+// first should come first, a positive number if the second should, and 0 to
+// keep them in byte order. A word always comes before the words it is a prefix
+// of. For example to list the most popular words first cmp can compare the
+// popularity of the best word below each path.
+func WithChildOrder(cmp func(a, b string) int) Option {
+	return func(t *Tree) { t.order = cmp }
+}
 
 // NewTree creates an empty instance of Tree, ready for word insertion.
-func NewTree() *Tree {
-	return &Tree{root: &Node{children: make(map[byte]*Node)}, N: 1}
+func NewTree(opts ...Option) *Tree {
+	t := &Tree{root: &Node{children: make(map[byte]*Node)}, nodes: 1}
+	if profiling {
+		t.profile = newVisitCounts()
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
 }
 
-// Insert adds a word into t.
-func (t *Tree) Insert(word string) {
+// Insert adds a word into t. It returns ErrWordTooLong or ErrTreeTooDeep if the
+// word violates the limits t was created with, in which case t is unchanged.
+// The empty word is a word like any other: it is marked on the root, so adds
+// no node, and is found by Contains(""), the queries for the prefix "" and
+// LongestPrefix of any string, and is kept by serialization.
+func (t *Tree) Insert(word string) error {
+	if t.maxWordLen > 0 && len(word) > t.maxWordLen {
+		return ErrWordTooLong
+	}
+	if err := t.checkDepth(word); err != nil {
+		return err
+	}
+	t.misses.invalidate(word)
+	t.own(word)
+	t.finger = nil
+	t.changes++
+	return t.insertBelow(t.root, 0, t.copied(word), 0, false)
+}
+
+// checkDepth returns ErrTreeTooDeep if inserting word would put a node deeper
+// than t's depth limit, without changing t, so that a rejected insert leaves
+// cursors, revisions and caches alone. insertBelow makes the same checks.
+func (t *Tree) checkDepth(word string) error {
+	if t.maxDepth <= 0 {
+		return nil
+	}
 	cur := t.root
+	for depth := 0; word != ""; depth++ {
+		child, exists := cur.children[word[0]]
+		if !exists {
+			if depth+1 > t.maxDepth {
+				return ErrTreeTooDeep
+			}
+			return nil
+		}
+		if n := commonPrefixLen(word, child.label); n < len(child.label) {
+			// A split, which pushes child and its subtree down a level
+			if depth+2+height(child) > t.maxDepth {
+				return ErrTreeTooDeep
+			}
+			return nil
+		}
+		word = word[len(child.label):]
+		cur = child
+	}
+	return nil
+}
+
+// insertBelow inserts full, whose first consumed bytes are the path of start,
+// into the subtree at start. depth is the number of edges between the root and
+// start, covered whether start is inside a subtree already marked dirty. Only
+// the nodes from start down are changed, adding the length of full to those
+// above start is left to the caller.
+func (t *Tree) insertBelow(start *Node, depth int, full string, consumed int, covered bool) error {
+	cur := start
+	word := full[consumed:]
 
 	for {
+		if t.dirty != nil && !covered {
+			covered = t.dirtySubtree(cur)
+		}
 		if word == "" {
 			// Trivial case, we have reached the end of the word so mark the
 			// current node as a word (by definition) and return.
-			cur.isWord = true
-			return
+			if !cur.isWord {
+				cur.isWord = true
+				t.words++
+				t.markDirty(cur, full, deltaWord, covered)
+				t.noteLengthBelow(start, consumed, full)
+			} else {
+				t.dups.add(full)
+			}
+			return nil
 		}
 
 		// Check if the current node has a child that starts with the first
@@ -98,29 +221,36 @@ func (t *Tree) Insert(word string) {
 		if !exists {
 			// No child exists, add a child with the word as the label. From the
 			// definition this also means that the child is a word.
-			cur.children[firstChar] = &Node{
+			if t.maxDepth > 0 && depth+1 > t.maxDepth {
+				return ErrTreeTooDeep
+			}
+			newNode := t.newNode()
+			*newNode = Node{
 				children: make(map[byte]*Node),
-				label:    word,
+				label:    t.newLabel(word),
 				isWord:   true,
+				gen:      t.gen,
 			}
-			t.N++
+			cur.children[firstChar] = newNode
+			t.nodes++
+			t.words++
+			t.markAdded(newNode, full, covered)
+			t.noteLengthBelow(start, consumed, full)
 
-			return
+			return nil
 		}
 
 		// A child does exist, find the common prefix between the child's label
 		// and the word
 		label := child.label
-		commonLen := 0
-		for commonLen < len(word) && commonLen < len(label) && word[commonLen] == label[commonLen] {
-			commonLen++
-		}
+		commonLen := commonPrefixLen(word, label)
 
 		if commonLen == len(label) {
 			// The word fully contains the label as a prefix. Discard the common
 			// part and descend into the child.
 			word = word[commonLen:]
 			cur = child
+			depth++
 			continue
 		}
 
@@ -132,40 +262,345 @@ func (t *Tree) Insert(word string) {
 		// In either case we need to create a new node between the current and child nodes that holds the common prefix,
 		// 'octo' and 'alpha' from the two examples. The new node will replace child in the current node (the parent).
 		// In the parial match case the child's label is updated to the remainder, 'naut'.
+		//
+		// The split moves child and everything below it one level further from
+		// the root, so check the depth limit before touching the tree.
+		if t.maxDepth > 0 && depth+2+height(child) > t.maxDepth {
+			return ErrTreeTooDeep
+		}
+		// Both parts share the memory of the label
 		commonPrefix := label[:commonLen]
 		remainder := label[commonLen:]
-		newNode := &Node{
+		newNode := t.newNode()
+		*newNode = Node{
 			label:    commonPrefix,
 			children: make(map[byte]*Node),
 			isWord:   remainder == "",
+			lenLo:    child.lenLo,
+			lenHi:    child.lenHi,
+			gen:      t.gen,
 		}
-		t.N++
+		t.nodes++
+		t.splits++
 		newNode.children[remainder[0]] = child
 		child.label = remainder
 
 		cur.children[firstChar] = newNode
+		// The split node takes child's place, which may itself be new
+		if t.dirty[child].added {
+			t.markAdded(newNode, full[:len(full)-len(word)+commonLen], covered)
+		} else {
+			t.markDirty(newNode, full[:len(full)-len(word)+commonLen], deltaSubtree, covered)
+		}
+	}
+}
+
+// InsertAll inserts every word produced by seq into t. It stops at the first
+// word that Insert rejects and returns the error, the words before it remain
+// in t. The words are inserted with InsertSortedNext, so sorted input is
+// inserted faster.
+func (t *Tree) InsertAll(seq iter.Seq[string]) error {
+	for word := range seq {
+		if err := t.InsertSortedNext(word); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// InsertAllErr is InsertAll for sources that can fail, such as a database
+// cursor, which yield an error alongside each word. It stops at the first
+// non-nil error from seq or from Insert and returns it.
+func (t *Tree) InsertAllErr(seq iter.Seq2[string, error]) error {
+	for word, err := range seq {
+		if err != nil {
+			return err
+		}
+		if err := t.InsertSortedNext(word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes word from t and reports whether it was there. Nodes that no
+// longer lead to a word are removed and a node left with a single child is
+// merged with it, so the tree is the same as if word had never been inserted.
+func (t *Tree) Delete(word string) bool {
+	t.finger = nil
+	if t.history != nil {
+		// Don't copy the path of a word that isn't there
+		if !t.Contains(word) {
+			return false
+		}
+		t.own(word)
+	}
+
+	// Record the nodes on the path to word, the fixups below need the parent
+	// and grandparent of the word node.
+	path := []*Node{t.root}
+	covered := false       // whether the word node is below a subtree already marked dirty
+	parentCovered := false // the same for the word node's parent
+	cur := t.root
+	for rest := word; rest != ""; {
+		parentCovered = covered
+		if t.dirty != nil && !covered {
+			covered = t.dirtySubtree(cur)
+		}
+		child, exists := cur.children[rest[0]]
+		if !exists || !strings.HasPrefix(rest, child.label) {
+			return false
+		}
+		rest = rest[len(child.label):]
+		cur = child
+		path = append(path, cur)
+	}
+	if !cur.isWord {
+		return false
+	}
+	cur.isWord = false
+	cur.value = nil
+	t.words--
+	t.changes++
+
+	switch {
+	case cur == t.root || len(cur.children) > 1:
+		// The node is still needed to hold its children apart
+		t.markDirty(cur, word, deltaUnword, covered)
+		t.refreshLengths(word)
+	case len(cur.children) == 1:
+		t.refreshLengths(t.mergeChild(cur, word, covered))
+	default:
+		// A leaf, remove it. Its parent may be left with a single child and no
+		// word of its own, in which case it is merged with that child.
+		parent := path[len(path)-2]
+		parentPath := word[:len(word)-len(cur.label)]
+		delete(parent.children, cur.label[0])
+		t.nodes--
+
+		// Removals below the top level are recorded as a new subtree at the
+		// parent, and there is nothing to record for a leaf that is itself new
+		// since the tree was marked clean. A leaf recorded as a subtree for
+		// other reasons, such as removals below it or a merge, replaces one
+		// that was there before.
+		isNew := t.dirty[cur].added
+		delete(t.dirty, cur)
+		switch {
+		case parent != t.root && !parent.isWord && len(parent.children) == 1:
+			// The merge removes the parent's own entry, only its ancestors cover it
+			parentPath = t.mergeChild(parent, parentPath, parentCovered)
+		case parent == t.root:
+			t.markDirty(cur, word, deltaRemove, covered || isNew)
+		default:
+			t.markDirty(parent, parentPath, deltaSubtree, covered || isNew)
+		}
+		t.refreshLengths(parentPath)
+	}
+	return true
+}
+
+// mergeChild merges the only child of node, whose path from the root is path,
+// into node and returns the new path of node.
+func (t *Tree) mergeChild(node *Node, path string, covered bool) string {
+	var child *Node
+	for _, c := range node.children {
+		child = c
+	}
+	node.label = t.newLabel(node.label + child.label)
+	node.children = child.children
+	if child.gen != t.gen {
+		// child still belongs to a committed version
+		node.children = maps.Clone(child.children)
+	}
+	node.isWord = child.isWord
+	node.value = child.value
+	node.lenLo, node.lenHi = child.lenLo, child.lenHi
+	t.nodes--
+
+	// The merged node replaces the old one, so record it as a new subtree, in
+	// a new place if the old one's was. Entries for the two nodes are stale,
+	// node's because its path has changed.
+	path += child.label
+	added := t.dirty[node].added
+	delete(t.dirty, child)
+	delete(t.dirty, node)
+	if added {
+		t.markAdded(node, path, covered)
+	} else {
+		t.markDirty(node, path, deltaSubtree, covered)
+	}
+	return path
+}
+
+// NodeCount returns the number of nodes in t, including the root. This is a
+// measure of the size of the tree and is not the number of words it holds, see
+// WordCount for that.
+func (t *Tree) NodeCount() int {
+	return t.nodes
+}
+
+// WordCount returns the number of distinct words in t.
+func (t *Tree) WordCount() int {
+	return t.words
 }
 
 // FindWordsWithPrefix returns all the words in the tree that start with prefix.
+// The words are in ascending order unless t was created with WithChildOrder.
+// Only the prefix "" finds the empty word, which always comes first. If there
+// are no words it returns nil, as do the other functions that return words;
+// Query with NonNil set returns an empty slice instead.
 func (t *Tree) FindWordsWithPrefix(prefix string) []string {
 	var words []string
+	if node, path := t.descend(prefix); node != nil {
+		t.gatherWords(node, path, &words)
+	}
+	return words
+}
+
+// FindWordsWithAnyPrefix returns up to limit words in the tree that start with
+// at least one of prefixes, each word once. If limit <= 0 all of them are
+// returned. A prefix that starts with another of prefixes adds nothing and is
+// dropped, the subtrees of those left don't overlap and are walked in
+// ascending order of prefix. The words are in ascending order unless t was
+// created with WithChildOrder, in which case the words under each prefix are
+// in that order.
+func (t *Tree) FindWordsWithAnyPrefix(prefixes []string, limit int) []string {
+	sorted := slices.Sorted(slices.Values(prefixes))
+	var words []string
+	for i, prefix := range sorted {
+		// Sorting puts a prefix right after the shortest prefix of it kept
+		if i > 0 && strings.HasPrefix(prefix, sorted[i-1]) {
+			sorted[i] = sorted[i-1]
+			continue
+		}
+		node, path := t.descend(prefix)
+		if node == nil {
+			continue
+		}
+		done := t.yieldWords(node, path, func(word string, _ *Node) bool {
+			words = append(words, word)
+			return limit <= 0 || len(words) < limit
+		})
+		if !done {
+			break
+		}
+	}
+	return words
+}
+
+// FindShallowestWords returns the words in t that start with prefix and have
+// no proper prefix that is itself a word in t, prefixes shorter than prefix
+// included, in the same order as FindWordsWithPrefix. These are the minimal
+// covering set of the words under prefix: blocking "foo" makes blocking
+// "foobar" redundant, so with both in t only "foo" is returned. The walk stops
+// at the first word on each path.
+func (t *Tree) FindShallowestWords(prefix string) []string {
+	var words []string
+	node, path := t.descend(prefix)
+	if node == nil {
+		return words
+	}
+	if path != "" {
+		if _, ok := t.longestPrefix(path[:len(path)-1]); ok {
+			// A word above node covers everything below it
+			return words
+		}
+	}
+	t.traverse(node, path, traversal{visit: func(word string, _ *Node, _ int) step {
+		words = append(words, word)
+		return stepOver
+	}})
+	return words
+}
+
+// WordsWithPrefix returns an iterator over the words in the tree that start
+// with prefix, in the same order as FindWordsWithPrefix. The words are found as
+// the iteration proceeds, so stopping early avoids visiting the rest of the
+// subtree.
+func (t *Tree) WordsWithPrefix(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if node, path := t.descend(prefix); node != nil {
+			t.yieldWords(node, path, func(word string, _ *Node) bool { return yield(word) })
+		}
+	}
+}
+
+// FrontCodedWords is WordsWithPrefix with each word front coded against the
+// one before it: it yields the number of leading bytes the word shares with
+// the previous word, 0 for the first, and the rest of the word. The shared
+// length is found as the walk goes, so consumers that build front coded lists
+// or FSTs don't have to rebuild or rescan whole words.
+func (t *Tree) FrontCodedWords(prefix string) iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		node, path := t.descend(prefix)
+		if node == nil {
+			return
+		}
+		// Siblings differ in the first byte of their labels, so the bytes
+		// a word shares with the one before are those of the node where the
+		// walk turned off the way to it.
+		var last []byte
+		t.traverse(node, path, traversal{visitBytes: func(word []byte, _ *Node, _ int) step {
+			shared := 0
+			for shared < min(len(last), len(word)) && last[shared] == word[shared] {
+				shared++
+			}
+			if !yield(shared, string(word[shared:])) {
+				return stepStop
+			}
+			last = append(last[:0], word...)
+			return stepInto
+		}})
+	}
+}
+
+// AppendWords returns an iterator over the words in the tree that start with
+// prefix, in the same order as FindWordsWithPrefix, each appended to buf[:0].
+// The words are built in place in one buffer instead of allocating a string
+// for each, for consumers such as hashes that don't keep them. A yielded slice
+// is only valid until the next one, copy it to keep it.
+func (t *Tree) AppendWords(buf []byte, prefix string) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		node, path := t.descend(prefix)
+		if node == nil {
+			return
+		}
+		t.traverse(node, path, traversal{
+			visitBytes: func(word []byte, _ *Node, _ int) step {
+				if !yield(word) {
+					return stepStop
+				}
+				return stepInto
+			},
+			buf: buf,
+		})
+	}
+}
+
+// descend returns the highest node whose path from the root starts with
+// prefix, along with that path, or nil if no word starts with prefix.
+func (t *Tree) descend(prefix string) (*Node, string) {
+	if t.misses.contains(prefix) {
+		return nil, ""
+	}
+	query := prefix
 
 	// Starting at the root, descend by prefix
 	cur := t.root
 	currentPath := ""
 	for {
+		t.visit(cur)
 		if prefix == "" {
-			// Search prefix exhausted. At this point we traverse the tree below
-			// this to recover the words
-			t.gatherWords(cur, currentPath, &words)
-			return words
+			// Search prefix exhausted. The words below here are the ones
+			// wanted.
+			return cur, currentPath
 		}
 		firstChar := prefix[0]
 		child, exists := cur.children[firstChar]
 		if !exists {
 			// Cannot go any further, nothing to return
-			return nil
+			t.misses.add(query)
+			return nil, ""
 		}
 
 		// Check if the remaining prefix entirely covers the child's label, e.g.
@@ -180,172 +615,192 @@ func (t *Tree) FindWordsWithPrefix(prefix string) []string {
 			continue
 		}
 
-		// Next case: the label is longer than the path prefix. Gather all words
-		// under the child and we are finished.
+		// Next case: the label is longer than the path prefix. All the words
+		// under the child are wanted.
 		if strings.HasPrefix(label, prefix) {
-			t.gatherWords(child, currentPath+label, &words)
-			return words
+			t.visit(child)
+			return child, currentPath + label
 		}
-	}
-}
-
-// Serialize a tree into an io.Writer. The serialized format is binary.
-func (t *Tree) Serialize(w io.Writer) error {
-	if int(uint32(t.N)) != t.N {
-		panic("node count exceeds file format")
-	}
 
-	buf := bufio.NewWriter(w)
-	hdr := SerializedTreeHeader{
-		Magic:   CtreeMagic,
-		Version: Version,
-		Nodes:   uint32(t.N),
-	}
-	if err := binary.Write(buf, binary.BigEndian, hdr); err != nil {
-		return err
+		// Otherwise the prefix and label differ part way through, nothing to
+		// return
+		t.misses.add(query)
+		return nil, ""
 	}
-
-	t.serializeNode(t.root, buf)
-	return buf.Flush()
 }
 
-// DeserializeTree returns a *Tree from an io.Reader. Returns ErrUnsupportedVersion
-// if the serialize format is an unsupported version, ErrInvalidFormat if the
-// file is unrecognized.
-func DeserializeTree(r io.Reader) (*Tree, error) {
-	tree := NewTree()
-
-	buf := bufio.NewReader(r)
-
-	// Read the header in
-	hdr := SerializedTreeHeader{}
-	if err := binary.Read(buf, binary.BigEndian, &hdr); err != nil {
-		return nil, err
+// Contains reports whether word has been inserted into t.
+func (t *Tree) Contains(word string) bool {
+	if t.misses.contains(word) {
+		return false
 	}
-	if hdr.Magic != CtreeMagic {
-		return nil, ErrInvalidFormat
-	}
-	if hdr.Version != Version {
-		return nil, ErrUnsupportedVersion
-	}
-
-	tree.N = int(hdr.Nodes)
-
-	if err := deserializeNode(tree.root, buf); err != nil {
-		return nil, err
+	query := word
+	cur := t.root
+	for word != "" {
+		t.visit(cur)
+		child, exists := cur.children[word[0]]
+		if !exists || !strings.HasPrefix(word, child.label) {
+			// Only a miss if no word continues from here
+			if !exists || !strings.HasPrefix(child.label, word) {
+				t.misses.add(query)
+			}
+			return false
+		}
+		word = word[len(child.label):]
+		cur = child
 	}
-
-	return tree, nil
+	t.visit(cur)
+	return cur.isWord
 }
 
-func (t *Tree) gatherWords(node *Node, currentPath string, words *[]string) {
-	// If this node marks a word then add it
-	if node.isWord {
-		*words = append(*words, currentPath)
+// FirstCompletion returns the first word FindWordsWithPrefix(prefix) would
+// return, the smallest word that starts with prefix unless t was created with
+// WithChildOrder, and whether there is one. It follows a single path down the
+// tree instead of gathering words, which suits showing one suggestion per
+// keystroke.
+func (t *Tree) FirstCompletion(prefix string) (string, bool) {
+	node, path := t.descend(prefix)
+	if node == nil {
+		return "", false
 	}
-
-	// Iterate over the children
-	for _, k := range slices.Sorted(maps.Keys(node.children)) {
-		child := node.children[k]
-		t.gatherWords(child, currentPath+child.label, words)
+	for !node.isWord {
+		if len(node.children) == 0 {
+			// Only the root of an empty tree is neither a word nor leads to
+			// one
+			return "", false
+		}
+		var first *Node
+		if t.order != nil {
+			first = node.children[t.childKeys(node, path)[0]]
+		} else {
+			for k, child := range node.children {
+				if first == nil || k < first.label[0] {
+					first = child
+				}
+			}
+		}
+		node = first
+		path += node.label
 	}
+	return path, true
 }
 
-func (t *Tree) serializeNode(node *Node, buf *bufio.Writer) error {
-	// Each node starts with the node label (u16 length, bytes of label string)
-	if _, err := buf.Write(serializeString(node.label)); err != nil {
-		return err
-	}
-
-	// Followed by u8 for isWord and then u8 for the number of children the node has
-	var err error
-	switch node.isWord {
-	case false:
-		err = buf.WriteByte(0)
-	case true:
-		err = buf.WriteByte(1)
-	}
-	if err != nil {
-		return err
-	}
-	if err := buf.WriteByte(byte(len(node.children))); err != nil {
-		return err
-	}
+// LongestPrefix returns the longest word in t that is a prefix of s, and
+// whether there is one.
+func (t *Tree) LongestPrefix(s string) (string, bool) {
+	n, ok := t.longestPrefix(s)
+	return s[:n], ok
+}
 
-	// Then we iterate over the keys in the node, write out the child key
-	// and then recurse into the child.
-	for _, k := range slices.Sorted(maps.Keys(node.children)) {
-		if err := buf.WriteByte(k); err != nil {
-			return err
+// longestPrefix returns the length of the longest word in t that is a prefix of
+// s.
+func (t *Tree) longestPrefix(s string) (int, bool) {
+	cur := t.root
+	n, found := 0, cur.isWord
+	t.visit(cur)
+	for consumed := 0; consumed < len(s); {
+		child, exists := cur.children[s[consumed]]
+		if !exists || !strings.HasPrefix(s[consumed:], child.label) {
+			break
 		}
-		if err := t.serializeNode(node.children[k], buf); err != nil {
-			return err
+		consumed += len(child.label)
+		cur = child
+		t.visit(cur)
+		if cur.isWord {
+			n, found = consumed, true
 		}
 	}
-
-	return nil
+	return n, found
 }
 
-func deserializeNode(node *Node, buf *bufio.Reader) error {
-	var (
-		err       error
-		ncb, w, k byte
-	)
-
-	node.label, err = deserializeString(buf)
-	if err != nil {
-		return err
-	}
-
-	if w, err = buf.ReadByte(); err != nil {
-		return err
+// MatchLength returns the length of the longest prefix of s that is also a
+// prefix of some word in t, whether or not a word ends there. Tokenizers use it
+// to find how far input can follow the dictionary before it must split.
+func (t *Tree) MatchLength(s string) int {
+	cur := t.root
+	t.visit(cur)
+	for consumed := 0; consumed < len(s); {
+		child, exists := cur.children[s[consumed]]
+		if !exists {
+			return consumed
+		}
+		if n := commonPrefixLen(s[consumed:], child.label); n < len(child.label) {
+			return consumed + n
+		}
+		consumed += len(child.label)
+		cur = child
+		t.visit(cur)
 	}
-	node.isWord = w == 1
+	return len(s)
+}
 
-	if ncb, err = buf.ReadByte(); err != nil {
-		return err
+// commonPrefixLen returns the length of the longest common prefix of a and b.
+// Labels such as URLs can be long and usually match in full, so rather than
+// comparing byte by byte it compares whole strings, which the runtime does
+// many bytes at a time, bisecting to find where they differ.
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	if a[:n] == b[:n] {
+		return n
 	}
-	node.children = make(map[byte]*Node, int(ncb))
-	for range int(ncb) {
-		// Read key
-		if k, err = buf.ReadByte(); err != nil {
-			return err
+	lo, hi := 0, n // a[:lo] == b[:lo] and a[:hi] != b[:hi]
+	for hi-lo > 16 {
+		mid := lo + (hi-lo)/2
+		if a[lo:mid] == b[lo:mid] {
+			lo = mid
+		} else {
+			hi = mid
 		}
-		node.children[k] = &Node{}
-		if err = deserializeNode(node.children[k], buf); err != nil {
-			return err
-		}
-
 	}
-	return err
+	for a[lo] == b[lo] {
+		lo++
+	}
+	return lo
 }
 
-func serializeString(s string) []byte {
-	ls := uint16(len(s))
-	if int(ls) != len(s) {
-		panic("string length exceeds file format")
+// height returns the number of edges on the longest path from node down to a
+// leaf.
+func height(node *Node) int {
+	h := 0
+	for _, child := range node.children {
+		h = max(h, 1+height(child))
 	}
+	return h
+}
 
-	out := make([]byte, 2+len(s))
-	binary.BigEndian.PutUint16(out, ls)
-	copy(out[2:], s)
+// gatherWords appends the words in the subtree of node, whose path is
+// currentPath, to words.
+func (t *Tree) gatherWords(node *Node, currentPath string, words *[]string) {
+	t.traverse(node, currentPath, traversal{visit: func(word string, _ *Node, _ int) step {
+		*words = append(*words, word)
+		return stepInto
+	}})
+}
 
-	return out
+// yieldWords is gatherWords for iterators, it returns false once yield does.
+// yield is also given the word's node.
+func (t *Tree) yieldWords(node *Node, currentPath string, yield func(string, *Node) bool) bool {
+	return t.traverse(node, currentPath, traversal{visit: func(word string, node *Node, _ int) step {
+		if !yield(word, node) {
+			return stepStop
+		}
+		return stepInto
+	}})
 }
 
-func deserializeString(r io.Reader) (string, error) {
-	// Read the length of the string
-	var blen [2]byte
-	if _, err := io.ReadFull(r, blen[:]); err != nil {
-		return "", err
+// childKeys returns the keys of node's children in the order they should be
+// visited. path is node's path from the root.
+func (t *Tree) childKeys(node *Node, path string) []byte {
+	keys := slices.Sorted(maps.Keys(node.children))
+	if t.order == nil || len(keys) < 2 {
+		return keys
 	}
-
-	slen := int(binary.BigEndian.Uint16(blen[:]))
-	scratch := make([]byte, slen)
-
-	if _, err := io.ReadFull(r, scratch); err != nil {
-		return "", err
+	paths := make(map[byte]string, len(keys))
+	for _, k := range keys {
+		paths[k] = path + node.children[k].label
 	}
-
-	return string(scratch), nil
+	slices.SortStableFunc(keys, func(a, b byte) int {
+		return t.order(paths[a], paths[b])
+	})
+	return keys
 }
@@ -4,11 +4,12 @@ package compressedtrie
 
 import (
 	"bytes"
+	"errors"
 	"flag"
-	"fmt"
+	"math/rand/v2"
 	"os"
-	"path"
 	"slices"
+	"strings"
 	"testing"
 )
 
@@ -53,6 +54,333 @@ func TestInsertWord(t *testing.T) {
 	}
 }
 
+func TestInsertLimits(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Opts     []Option
+		Words    []string
+		Word     string
+		Expected error
+	}{
+		{"No limits", nil, []string{"alpha"}, "alphabet", nil},
+		{"Word length ok", []Option{WithMaxWordLength(5)}, nil, "alpha", nil},
+		{"Word too long", []Option{WithMaxWordLength(5)}, nil, "alphabet", ErrWordTooLong},
+		{"Depth ok", []Option{WithMaxDepth(2)}, []string{"alpha"}, "alphabet", nil},
+		{"New child too deep", []Option{WithMaxDepth(2)}, []string{"a", "ab"}, "abc", ErrTreeTooDeep},
+		{"Split too deep", []Option{WithMaxDepth(2)}, []string{"alpha", "alphabet"}, "al", ErrTreeTooDeep},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tree := NewTree(tc.Opts...)
+			for _, word := range tc.Words {
+				if err := tree.Insert(word); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			before := asDot(tree)
+			if err := tree.Insert(tc.Word); err != tc.Expected {
+				t.Fatalf("Expected error %v, got %v", tc.Expected, err)
+			}
+			if tc.Expected != nil && asDot(tree) != before {
+				t.Errorf("Tree was modified by a rejected insert")
+			}
+		})
+	}
+}
+
+func TestInsertAll(t *testing.T) {
+	words := []string{"romane", "romanus", "romulus", "rubens"}
+	tree := NewTree()
+	if err := tree.InsertAll(slices.Values(words)); err != nil {
+		t.Fatal(err)
+	}
+	if actual := tree.FindWordsWithPrefix(""); !slices.Equal(actual, words) {
+		t.Errorf("Expected %v, got %v", words, actual)
+	}
+
+	tree = NewTree(WithMaxWordLength(6))
+	if err := tree.InsertAll(slices.Values(words)); err != ErrWordTooLong {
+		t.Errorf("Expected ErrWordTooLong, got %v", err)
+	}
+	if tree.WordCount() != 1 {
+		t.Errorf("Expected the words before the error to be inserted, got %d", tree.WordCount())
+	}
+
+	errCursor := errors.New("cursor failed")
+	seq := func(yield func(string, error) bool) {
+		for _, word := range words[:2] {
+			if !yield(word, nil) {
+				return
+			}
+		}
+		yield("", errCursor)
+	}
+	tree = NewTree()
+	if err := tree.InsertAllErr(seq); err != errCursor {
+		t.Errorf("Expected %v, got %v", errCursor, err)
+	}
+	if tree.WordCount() != 2 || tree.Contains("") {
+		t.Errorf("Expected the 2 words before the error, got %v", tree.FindWordsWithPrefix(""))
+	}
+}
+
+func TestWordsWithPrefix(t *testing.T) {
+	tree := NewTree()
+	for _, word := range frozenWords {
+		tree.Insert(word)
+	}
+
+	for _, prefix := range []string{"", "r", "rub", "rubx", "toast", "x"} {
+		expected := tree.FindWordsWithPrefix(prefix)
+		if actual := slices.Collect(tree.WordsWithPrefix(prefix)); !slices.Equal(actual, expected) {
+			t.Errorf("WordsWithPrefix(%q): expected %v, got %v", prefix, expected, actual)
+		}
+	}
+
+	var first []string
+	for word := range tree.WordsWithPrefix("r") {
+		first = append(first, word)
+		if len(first) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(first, []string{"romane", "romanus"}) {
+		t.Errorf("Expected [romane romanus], got %v", first)
+	}
+}
+
+func TestFindWordsWithAnyPrefix(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"car", "cart", "carton", "cat", "dog", "door", "zebra"} {
+		tree.Insert(word)
+	}
+	cases := []struct {
+		Prefixes []string
+		Limit    int
+		Expected []string
+	}{
+		{[]string{"do", "ca"}, 0, []string{"car", "cart", "carton", "cat", "dog", "door"}},
+		// Overlapping prefixes
+		{[]string{"cart", "car", "c", "ca"}, 0, []string{"car", "cart", "carton", "cat"}},
+		{[]string{"doo", "doo", "x", "cat"}, 0, []string{"cat", "door"}},
+		{[]string{"z", "ca", "do"}, 3, []string{"car", "cart", "carton"}},
+		{[]string{"z", "ca", "do"}, 5, []string{"car", "cart", "carton", "cat", "dog"}},
+		{[]string{"", "dog"}, 2, []string{"car", "cart"}},
+		{nil, 0, nil},
+	}
+	for _, tc := range cases {
+		prefixes := slices.Clone(tc.Prefixes)
+		if actual := tree.FindWordsWithAnyPrefix(tc.Prefixes, tc.Limit); !slices.Equal(actual, tc.Expected) {
+			t.Errorf("%q, %d: expected %v, got %v", tc.Prefixes, tc.Limit, tc.Expected, actual)
+		}
+		if !slices.Equal(prefixes, tc.Prefixes) {
+			t.Errorf("Prefixes changed to %q", tc.Prefixes)
+		}
+	}
+}
+
+func TestMatchLength(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"international", "internet", "in", "zebra"} {
+		tree.Insert(word)
+	}
+	cases := []struct {
+		S        string
+		Expected int
+	}{
+		{"interstellar", 5},
+		{"internetwork", 8},
+		{"intern", 6},
+		{"inn", 2},
+		{"zeal", 2},
+		{"apple", 0},
+		{"", 0},
+	}
+	for _, tc := range cases {
+		if actual := tree.MatchLength(tc.S); actual != tc.Expected {
+			t.Errorf("%q: expected %d, got %d", tc.S, tc.Expected, actual)
+		}
+	}
+}
+
+func TestFindShallowestWords(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"foo", "foobar", "foobaz", "fob", "fobs", "bar", "barn", "baz"} {
+		tree.Insert(word)
+	}
+	cases := []struct {
+		Prefix   string
+		Expected []string
+	}{
+		{"", []string{"bar", "baz", "fob", "foo"}},
+		{"f", []string{"fob", "foo"}},
+		{"foo", []string{"foo"}},
+		// Covered by foo
+		{"foob", nil},
+		{"fobs", nil},
+		{"ba", []string{"bar", "baz"}},
+		{"x", nil},
+	}
+	for _, tc := range cases {
+		if actual := tree.FindShallowestWords(tc.Prefix); !slices.Equal(actual, tc.Expected) {
+			t.Errorf("%q: expected %q, got %q", tc.Prefix, tc.Expected, actual)
+		}
+	}
+	tree.Insert("")
+	if actual := tree.FindShallowestWords(""); !slices.Equal(actual, []string{""}) {
+		t.Errorf("Expected the empty word to cover everything, got %q", actual)
+	}
+	if actual := tree.FindShallowestWords("f"); actual != nil {
+		t.Errorf("Expected the empty word to cover everything, got %q", actual)
+	}
+}
+
+func TestFrontCodedWords(t *testing.T) {
+	tree := perfTree(2000)
+	tree.Insert("")
+	for _, prefix := range []string{"", "a", "ba", "zzzz"} {
+		expected := tree.FindWordsWithPrefix(prefix)
+		var words []string
+		prev := ""
+		for shared, suffix := range tree.FrontCodedWords(prefix) {
+			word := prev[:shared] + suffix
+			// The shared length is the longest possible
+			if suffix != "" && shared < len(prev) && prev[shared] == suffix[0] {
+				t.Fatalf("%q after %q: shared length %d is too short", word, prev, shared)
+			}
+			words = append(words, word)
+			prev = word
+		}
+		if !slices.Equal(words, expected) {
+			t.Errorf("%q: expected %d words, decoded %d", prefix, len(expected), len(words))
+		}
+	}
+
+	// Stopping early
+	n := 0
+	for range tree.FrontCodedWords("") {
+		if n++; n == 3 {
+			break
+		}
+	}
+}
+
+func TestAppendWords(t *testing.T) {
+	tree := perfTree(2000)
+	for _, prefix := range []string{"", "a", "ba", "zzzz"} {
+		expected := tree.FindWordsWithPrefix(prefix)
+		var words []string
+		for word := range tree.AppendWords(nil, prefix) {
+			words = append(words, string(word))
+		}
+		if !slices.Equal(words, expected) {
+			t.Errorf("%q: expected %d words, got %d", prefix, len(expected), len(words))
+		}
+	}
+
+	// Words share the caller's buffer
+	buf := make([]byte, 0, 64)
+	for word := range tree.AppendWords(buf, "b") {
+		if &word[0] != &buf[:1][0] {
+			t.Fatalf("%q is not in the buffer", word)
+		}
+	}
+	n := 0
+	for range tree.AppendWords(buf, "") {
+		if n++; n == 3 {
+			break
+		}
+	}
+}
+
+func TestChildOrder(t *testing.T) {
+	popularity := map[string]int{"slow": 5, "slowly": 1, "test": 9, "toaster": 2, "toasting": 7}
+	best := func(path string) int {
+		b := 0
+		for word, p := range popularity {
+			if strings.HasPrefix(word, path) {
+				b = max(b, p)
+			}
+		}
+		return b
+	}
+	tree := NewTree(WithChildOrder(func(a, b string) int { return best(b) - best(a) }))
+	for word := range popularity {
+		tree.Insert(word)
+	}
+
+	expected := []string{"test", "toasting", "toaster", "slow", "slowly"}
+	if actual := tree.FindWordsWithPrefix(""); !slices.Equal(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if actual := slices.Collect(tree.WordsWithPrefix("to")); !slices.Equal(actual, expected[1:3]) {
+		t.Errorf("Expected %v, got %v", expected[1:3], actual)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	tree := NewTree()
+	if n, w := tree.NodeCount(), tree.WordCount(); n != 1 || w != 0 {
+		t.Errorf("Empty tree: expected 1 node and 0 words, got %d and %d", n, w)
+	}
+
+	// alpha and alphabet share a node, inserting the same word twice must not
+	// count it twice.
+	for _, word := range []string{"alphabet", "elephant", "alpha", "alpha"} {
+		tree.Insert(word)
+	}
+	if n, w := tree.NodeCount(), tree.WordCount(); n != 4 || w != 3 {
+		t.Errorf("Expected 4 nodes and 3 words, got %d and %d", n, w)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus"} {
+		tree.Insert(word)
+	}
+	if tree.Delete("rom") || tree.Delete("rubicons") || tree.Delete("") {
+		t.Errorf("Deleted a word that isn't in the tree")
+	}
+	if !tree.Delete("romulus") || tree.Delete("romulus") {
+		t.Errorf("Expected romulus to be deleted exactly once")
+	}
+
+	// Deleting words in any order leaves the tree that holds the rest
+	words := perfWords(2000)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for round := range 3 {
+		tree := NewTree()
+		for _, word := range words {
+			tree.Insert(word)
+		}
+		rng.Shuffle(len(words), func(i, j int) { words[i], words[j] = words[j], words[i] })
+		kept := words[:len(words)/(round+2)]
+		for _, word := range words[len(kept):] {
+			if !tree.Delete(word) {
+				t.Fatalf("Delete(%q) failed", word)
+			}
+		}
+
+		expected := NewTree()
+		for _, word := range kept {
+			expected.Insert(word)
+		}
+		if asDot(tree) != asDot(expected) {
+			t.Fatalf("Round %d: tree differs from one holding the remaining words", round)
+		}
+		if tree.NodeCount() != expected.NodeCount() || tree.WordCount() != expected.WordCount() {
+			t.Errorf("Round %d: expected %d nodes and %d words, got %d and %d", round, expected.NodeCount(), expected.WordCount(), tree.NodeCount(), tree.WordCount())
+		}
+		if err := tree.checkCanonical(); err != nil {
+			t.Errorf("Round %d: %v", round, err)
+		}
+		checkLengths(t, tree)
+	}
+}
+
 func TestFindWordsWithPrefix(t *testing.T) {
 	cases := []struct {
 		Name     string
@@ -65,6 +393,7 @@ func TestFindWordsWithPrefix(t *testing.T) {
 		{"No match", []string{"test", "toaster", "toasting"}, "a", []string{}},
 		{"Prefix too long", []string{"test", "toaster", "toasting"}, "toastinger", []string{}},
 		{"Everything", []string{"test", "toaster", "toasting"}, "", []string{"test", "toaster", "toasting"}},
+		{"Differs inside label", []string{"test", "toaster", "toasting"}, "tx", []string{}},
 	}
 
 	for _, tc := range cases {
@@ -83,6 +412,76 @@ func TestFindWordsWithPrefix(t *testing.T) {
 	}
 }
 
+func TestContains(t *testing.T) {
+	tree := NewTree()
+	for _, word := range []string{"test", "toaster", "toasting"} {
+		tree.Insert(word)
+	}
+
+	cases := []struct {
+		Word     string
+		Expected bool
+	}{
+		{"test", true},
+		{"toaster", true},
+		{"toast", false},
+		{"tester", false},
+		{"tx", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if actual := tree.Contains(tc.Word); actual != tc.Expected {
+			t.Errorf("Contains(%q): expected %v, got %v", tc.Word, tc.Expected, actual)
+		}
+	}
+}
+
+func TestEmptyWord(t *testing.T) {
+	tree := NewTree(WithChildOrder(func(a, b string) int { return strings.Compare(b, a) }))
+	for _, word := range []string{"a", "", "ab", "b", ""} {
+		tree.Insert(word)
+	}
+	if !tree.Contains("") || tree.WordCount() != 4 || tree.NodeCount() != 4 {
+		t.Errorf("Expected the empty word on the root, got %d words in %d nodes", tree.WordCount(), tree.NodeCount())
+	}
+	// First whatever the child order, and only for the empty prefix
+	if words := tree.FindWordsWithPrefix(""); !slices.Equal(words, []string{"", "b", "a", "ab"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+	if words := tree.FindWordsWithPrefix("a"); !slices.Equal(words, []string{"a", "ab"}) {
+		t.Errorf("Unexpected words %q", words)
+	}
+	if prefix, ok := tree.LongestPrefix("xyz"); !ok || prefix != "" {
+		t.Errorf("Expected the empty word as the longest prefix, got %q %v", prefix, ok)
+	}
+
+	// Kept by every serialization and frozen trees
+	for _, opts := range []SerializeOptions{{}, {Layout: BreadthFirst}, {LabelDictionary: true, LengthBounds: true}} {
+		var b bytes.Buffer
+		if err := tree.SerializeWithOptions(&b, opts); err != nil {
+			t.Fatal(err)
+		}
+		frozen, err := AttachFrozen(b.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := DeserializeTree(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !read.Contains("") || read.WordCount() != 4 || !frozen.Contains("") || frozen.FindWordsWithPrefix("")[0] != "" {
+			t.Errorf("%+v: lost the empty word", opts)
+		}
+	}
+
+	if !tree.Delete("") || tree.Delete("") || tree.Contains("") || tree.WordCount() != 3 || tree.NodeCount() != 4 {
+		t.Errorf("Deleting the empty word left %d words in %d nodes", tree.WordCount(), tree.NodeCount())
+	}
+	if _, ok := tree.LongestPrefix("xyz"); ok {
+		t.Errorf("Found a prefix after deleting the empty word")
+	}
+}
+
 func TestSerialize(t *testing.T) {
 	words := []string{"alphabet", "elephant", "alpha"}
 	tree := NewTree()
@@ -117,41 +516,121 @@ func TestSerialize(t *testing.T) {
 }
 
 func TestDeserialize(t *testing.T) {
-	f, err := os.Open("testdata/serialize.ctree")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer f.Close()
+	// The current format and the previous version of it must decode to the same tree
+	for _, filename := range []string{"testdata/serialize.ctree", "testdata/serialize_v1.ctree"} {
+		t.Run(filename, func(t *testing.T) {
+			f, err := os.Open(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
 
-	tree, err := DeserializeTree(f)
-	if err != nil {
-		t.Fatal(err)
-	}
+			tree, err := DeserializeTree(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected, actual := 4, tree.NodeCount(); expected != actual {
+				t.Errorf("Expected tree to have %d nodes, got %d", expected, actual)
+			}
+			if expected, actual := 3, tree.WordCount(); expected != actual {
+				t.Errorf("Expected tree to have %d words, got %d", expected, actual)
+			}
 
-	if expected, actual := 4, tree.N; expected != actual {
-		t.Errorf("Expected tree to have %d nodes, got %d", expected, actual)
+			actual := asDot(tree)
+			expected, err := os.ReadFile("testdata/serialize.dot")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != string(expected) {
+				t.Errorf("Differing output\nActual=%q\nExpected=%q\n", actual, expected)
+			}
+		})
 	}
+}
 
-	actual := asDot(tree)
-	expected, err := os.ReadFile("testdata/serialize.dot")
-	if err != nil {
-		t.Fatal(err)
+func TestCommonPrefixLen(t *testing.T) {
+	long := strings.Repeat("https://example.com/path/", 10)
+	cases := []struct {
+		A, B     string
+		Expected int
+	}{
+		{"", "", 0},
+		{"", "abc", 0},
+		{"abc", "abd", 2},
+		{"alpha", "alphabet", 5},
+		{"octopus", "octonaut", 4},
+		{"x", "y", 0},
+		{long, long, len(long)},
+		{long + "a", long + "b", len(long)},
+		{long[:100] + "!" + long[101:], long, 100},
+		{long[:17] + "!", long, 17},
+	}
+	for _, tc := range cases {
+		if actual := commonPrefixLen(tc.A, tc.B); actual != tc.Expected {
+			t.Errorf("(%q, %q): expected %d, got %d", tc.A, tc.B, tc.Expected, actual)
+		}
+		if actual := commonPrefixLen(tc.B, tc.A); actual != tc.Expected {
+			t.Errorf("(%q, %q): expected %d, got %d", tc.B, tc.A, tc.Expected, actual)
+		}
 	}
-	if actual != string(expected) {
-		t.Errorf("Differing output\nActual=%q\nExpected=%q\n", actual, expected)
+	// Every position of the first difference
+	for i := range len(long) {
+		b := []byte(long)
+		b[i]++
+		if actual := commonPrefixLen(long, string(b)); actual != i {
+			t.Fatalf("Difference at %d: got %d", i, actual)
+		}
 	}
 }
 
 func TestPerf(t *testing.T) {
 	t.Skip("Disabled") // For performance measurements
 
-	file_suffixes := []string{"10", "100", "200", "500", "1000", "5000", "10000", "20000", "50000", "100000", "all"}
-	for _, suffix := range file_suffixes {
-		filepath := path.Join("perf", fmt.Sprintf("words_%s.sid", suffix))
-		ctree, err := treeFromSID(filepath)
-		if err != nil {
-			t.Fatal(err)
+	for _, n := range []int{10, 100, 200, 500, 1000, 5000, 10000, 20000, 50000, 100000, 500000} {
+		ctree := perfTree(n)
+		t.Logf("%d generated words in %d nodes", ctree.WordCount(), ctree.NodeCount())
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	words := perfWords(10000)
+	for b.Loop() {
+		tree := NewTree()
+		for _, word := range words {
+			tree.Insert(word)
+		}
+	}
+}
+
+func BenchmarkFindWordsWithPrefix(b *testing.B) {
+	tree := perfTree(10000)
+	prefixes := perfWords(100)
+	for b.Loop() {
+		for _, prefix := range prefixes {
+			tree.FindWordsWithPrefix(prefix[:2])
+		}
+	}
+}
+
+func BenchmarkInsertLongLabels(b *testing.B) {
+	// URL like keys whose labels are long and share most of their bytes
+	var words []string
+	for _, word := range perfWords(10000) {
+		words = append(words, "https://www.example.com/catalogue/products/"+word+"/reviews?sort=newest&page=1")
+	}
+	for b.Loop() {
+		tree := NewTree()
+		for _, word := range words {
+			tree.Insert(word)
 		}
-		t.Logf("file %s has %d nodes", filepath, ctree.N)
+	}
+}
+
+func BenchmarkCommonPrefixLen(b *testing.B) {
+	a := strings.Repeat("https://example.com/path/", 8)
+	c := a[:len(a)-1] + "!"
+	for b.Loop() {
+		commonPrefixLen(a, c)
 	}
 }
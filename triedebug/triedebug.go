@@ -0,0 +1,185 @@
+// Package triedebug provides a console for looking into a compressedtrie tree
+// while the program using it runs. The console reads commands a line at a
+// time and writes their results back, over any io.ReadWriter, so it can be
+// attached to a unix socket and reached with a tool such as nc or socat:
+//
+//	console := triedebug.Shell(tree, triedebug.ShellOptions{Locker: &mu})
+//	l, err := net.Listen("unix", "/run/dictionary/debug.sock")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	go console.Serve(l)
+//
+// and then
+//
+//	$ socat - UNIX-CONNECT:/run/dictionary/debug.sock
+//	> explain toast
+//	prefix "toast": prefix found after matching 5 of 5 bytes, 3 words
+//	  "t": matched 1 of label "t"
+//	  "toast": matched 4 of label "oast" (word)
+//
+// Type help for the commands.
+package triedebug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chriskillpack/compressedtrie"
+)
+
+// ShellOptions configures a Console.
+type ShellOptions struct {
+	// Locker, if set, is held while each command runs, for trees that are
+	// changed by the program as well, such as the mutex that guards them.
+	Locker sync.Locker
+
+	// ReadOnly rejects the commands that change the tree.
+	ReadOnly bool
+
+	// MaxResults is the largest number of words find writes, 50 by default.
+	MaxResults int
+}
+
+// A Console runs debug commands against a tree, see Shell.
+type Console struct {
+	tree *compressedtrie.Tree
+	opts ShellOptions
+}
+
+// Shell returns a Console for tree.
+func Shell(tree *compressedtrie.Tree, opts ShellOptions) *Console {
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = 50
+	}
+	return &Console{tree: tree, opts: opts}
+}
+
+// help lists the commands.
+const help = `commands:
+  find PREFIX      list the words starting with PREFIX
+  contains WORD    report whether WORD is in the tree
+  insert WORD      add WORD
+  delete WORD      remove WORD
+  explain PREFIX   trace the search for PREFIX
+  stats            show the size and shape of the tree
+  dot [PREFIX]     write the tree in Graphviz DOT, the search for PREFIX in red
+  help             show this list
+  quit             end the session
+Arguments run to the end of the line, quote them as in Go for other bytes.
+Words are written quoted.
+`
+
+// Run reads commands from rw and writes their output to it until the input
+// ends or a quit command. It returns nil then, or the error reading or writing
+// rw.
+func (c *Console) Run(rw io.ReadWriter) error {
+	w := bufio.NewWriter(rw)
+	lines := bufio.NewScanner(rw)
+	for {
+		w.WriteString("> ")
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if !lines.Scan() {
+			return lines.Err()
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(lines.Text()), " ")
+		if cmd == "quit" {
+			return w.Flush()
+		}
+		if cmd != "" {
+			c.command(w, cmd, arg)
+		}
+	}
+}
+
+// Serve runs a session of the console for each connection accepted by l,
+// closing the connection at the end, until Accept fails. It returns that
+// error.
+func (c *Console) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			c.Run(conn)
+		}()
+	}
+}
+
+// command writes to w the output of cmd with argument arg.
+func (c *Console) command(w io.Writer, cmd, arg string) {
+	arg = strings.TrimSpace(arg)
+	if strings.HasPrefix(arg, `"`) {
+		unquoted, err := strconv.Unquote(arg)
+		if err != nil {
+			fmt.Fprintf(w, "bad argument %s: %v\n", arg, err)
+			return
+		}
+		arg = unquoted
+	}
+	if (cmd == "insert" || cmd == "delete") && c.opts.ReadOnly {
+		fmt.Fprintf(w, "%s: the console is read only\n", cmd)
+		return
+	}
+	if c.opts.Locker != nil {
+		c.opts.Locker.Lock()
+		defer c.opts.Locker.Unlock()
+	}
+
+	tree := c.tree
+	switch cmd {
+	case "find":
+		words, truncated := tree.Query(arg, compressedtrie.QueryOptions{Limit: c.opts.MaxResults})
+		for _, word := range words {
+			fmt.Fprintf(w, "%q\n", word)
+		}
+		more := ""
+		if truncated {
+			more = " and more"
+		}
+		fmt.Fprintf(w, "%d words%s\n", len(words), more)
+	case "contains":
+		fmt.Fprintln(w, tree.Contains(arg))
+	case "insert":
+		if err := tree.Insert(arg); err != nil {
+			fmt.Fprintf(w, "insert: %v\n", err)
+			return
+		}
+		fmt.Fprintf(w, "inserted %q, %d words\n", arg, tree.WordCount())
+	case "delete":
+		if !tree.Delete(arg) {
+			fmt.Fprintf(w, "%q not found\n", arg)
+			return
+		}
+		fmt.Fprintf(w, "deleted %q, %d words\n", arg, tree.WordCount())
+	case "explain":
+		io.WriteString(w, tree.ExplainPrefix(arg).String())
+	case "stats":
+		s := tree.Stats()
+		fmt.Fprintf(w, "%d nodes, %d words, %d splits\n", s.Nodes, s.Words, s.Splits)
+		fmt.Fprintf(w, "%d label bytes, %d word bytes, %.2f bytes a label\n", s.LabelBytes, s.WordBytes, s.AvgLabelLength)
+		fmt.Fprintf(w, "%d single child nodes in %d chains, the longest %d\n", s.SingleChildNodes, s.SingleChildChains, s.LongestChain)
+		for _, warning := range s.Warnings() {
+			fmt.Fprintf(w, "warning: %s\n", warning)
+		}
+	case "dot":
+		if arg == "" {
+			io.WriteString(w, tree.DOT())
+		} else {
+			io.WriteString(w, tree.ExplainPrefix(arg).DOT())
+		}
+	case "help":
+		io.WriteString(w, help)
+	default:
+		fmt.Fprintf(w, "unknown command %q, type help for the commands\n", cmd)
+	}
+}
@@ -0,0 +1,104 @@
+package triedebug
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chriskillpack/compressedtrie"
+)
+
+// session is an io.ReadWriter of the commands and the output.
+type session struct {
+	io.Reader
+	strings.Builder
+}
+
+func newTree() *compressedtrie.Tree {
+	tree := compressedtrie.NewTree()
+	for _, word := range []string{"test", "toaster", "toasting", "toast"} {
+		tree.Insert(word)
+	}
+	return tree
+}
+
+func TestShell(t *testing.T) {
+	tree := newTree()
+	var mu sync.Mutex
+	commands := []string{
+		"find toa",
+		"contains toast",
+		`insert "tab\tbed"`,
+		"delete toaster",
+		"delete toaster",
+		"find",
+		"explain toast",
+		"stats",
+		"bogus",
+		"",
+		"quit",
+		"find t",
+	}
+	s := &session{Reader: strings.NewReader(strings.Join(commands, "\n"))}
+	if err := Shell(tree, ShellOptions{Locker: &mu, MaxResults: 3}).Run(s); err != nil {
+		t.Fatal(err)
+	}
+	expected := `> "toast"
+"toaster"
+"toasting"
+3 words
+> true
+> inserted "tab\tbed", 5 words
+> deleted "toaster", 4 words
+> "toaster" not found
+> "tab\tbed"
+"test"
+"toast"
+3 words and more
+> prefix "toast": prefix found after matching 5 of 5 bytes, 2 words
+  "t": matched 1 of label "t"
+  "toast": matched 4 of label "oast" (word)
+> 6 nodes, 4 words, 2 splits
+17 label bytes, 24 word bytes, 3.40 bytes a label
+1 single child nodes in 1 chains, the longest 1
+> unknown command "bogus", type help for the commands
+> > `
+	if s.String() != expected {
+		t.Errorf("Unexpected output\n%s\nExpected\n%s", s.String(), expected)
+	}
+	if tree.Contains("toaster") || !tree.Contains("tab\tbed") {
+		t.Errorf("The commands didn't change the tree")
+	}
+
+	s = &session{Reader: strings.NewReader("insert x\ndot t\n")}
+	if err := Shell(tree, ShellOptions{ReadOnly: true}).Run(s); err != nil {
+		t.Fatal(err)
+	}
+	if out := s.String(); !strings.HasPrefix(out, "> insert: the console is read only\n> digraph Trie {") || !strings.Contains(out, "color=red") {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "debug.sock"))
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	go Shell(newTree(), ShellOptions{}).Serve(l)
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "contains test\nquit\n")
+	out, err := io.ReadAll(bufio.NewReader(conn))
+	if err != nil || string(out) != "> true\n> " {
+		t.Errorf("Unexpected output %q, %v", out, err)
+	}
+}
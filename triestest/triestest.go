@@ -0,0 +1,89 @@
+// Package triestest provides golden file helpers for regression tests of code
+// that builds compressedtrie trees, in the style of compressedtrie's own tests:
+// a tree is rendered, as DOT or in the serialized format, and compared with a
+// file under testdata that is rewritten when the test is run with an update
+// flag.
+//
+// The package doesn't register a flag of its own, which would clash with a
+// test that already has one, so tests pass theirs in:
+//
+//	var update = flag.Bool("update", false, "rewrite testdata files")
+//
+//	func TestBuild(t *testing.T) {
+//		tree := build()
+//		triestest.GoldenDOT(t, "testdata/build.dot", tree, *update)
+//	}
+package triestest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chriskillpack/compressedtrie"
+)
+
+// DOT returns the Graphviz DOT description of tree that golden DOT files hold.
+func DOT(tree *compressedtrie.Tree) string {
+	return tree.DOT()
+}
+
+// Golden compares actual with the contents of filename, failing t if they
+// differ. If update is true filename is instead rewritten with actual, and any
+// missing directories created.
+func Golden(t testing.TB, filename string, actual []byte, update bool) {
+	t.Helper()
+	if update {
+		t.Logf("rewriting %s", filename)
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, actual, 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("%s: differing output\nActual=%q\nExpected=%q\n", filename, actual, expected)
+	}
+}
+
+// GoldenDOT is Golden for the DOT description of tree.
+func GoldenDOT(t testing.TB, filename string, tree *compressedtrie.Tree, update bool) {
+	t.Helper()
+	Golden(t, filename, []byte(DOT(tree)), update)
+}
+
+// GoldenSerialized is Golden for tree serialized with opts. Serialized files
+// are binary, so a mismatch reports the sizes and the offset of the first
+// differing byte rather than the contents.
+func GoldenSerialized(t testing.TB, filename string, tree *compressedtrie.Tree, opts compressedtrie.SerializeOptions, update bool) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tree.SerializeWithOptions(&buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	actual := buf.Bytes()
+	if update {
+		Golden(t, filename, actual, true)
+		return
+	}
+
+	expected, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		i := 0
+		for i < len(actual) && i < len(expected) && actual[i] == expected[i] {
+			i++
+		}
+		t.Errorf("%s: serialized tree of %d bytes differs from the %d expected at offset %d", filename, len(actual), len(expected), i)
+	}
+}
@@ -0,0 +1,60 @@
+package triestest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chriskillpack/compressedtrie"
+)
+
+// recorder is a testing.TB that records failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (r *recorder) Helper()                         {}
+func (r *recorder) Logf(format string, args ...any) {}
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+func (r *recorder) Fatal(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+	r.fatal = true
+}
+
+func TestGolden(t *testing.T) {
+	tree := compressedtrie.NewTree()
+	for _, word := range []string{"alphabet", "elephant", "alpha"} {
+		tree.Insert(word)
+	}
+	dir := t.TempDir()
+	dot := filepath.Join(dir, "testdata", "simple.dot")
+	ctree := filepath.Join(dir, "testdata", "simple.ctree")
+
+	// Missing files fail until written with update
+	r := &recorder{}
+	GoldenDOT(r, dot, tree, false)
+	if !r.fatal {
+		t.Errorf("Expected a missing golden file to fail")
+	}
+	for _, update := range []bool{true, false} {
+		r := &recorder{}
+		GoldenDOT(r, dot, tree, update)
+		GoldenSerialized(r, ctree, tree, compressedtrie.SerializeOptions{}, update)
+		if len(r.errors) > 0 {
+			t.Errorf("update %v: unexpected failures %q", update, r.errors)
+		}
+	}
+
+	tree.Insert("elephants")
+	r = &recorder{}
+	GoldenDOT(r, dot, tree, false)
+	GoldenSerialized(r, ctree, tree, compressedtrie.SerializeOptions{}, false)
+	if len(r.errors) != 2 || !strings.Contains(r.errors[0], "differing output") || !strings.Contains(r.errors[1], "differs from the") {
+		t.Errorf("Expected both comparisons to fail, got %q", r.errors)
+	}
+}
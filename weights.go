@@ -0,0 +1,243 @@
+package compressedtrie
+
+import "math"
+
+// WeightedTree stores a weight for each word, such as how often it has been
+// searched for, along with the total weight and number of the words below every
+// node. Aggregates over the words that start with a prefix then take time
+// proportional to the length of the prefix instead of the number of words.
+type WeightedTree struct {
+	tree *Tree
+}
+
+// weights is the value of every node of a WeightedTree. A node's weights are
+// replaced rather than changed, so that revisions kept by WithHistory don't
+// see later changes.
+type weights struct {
+	weight float64 // weight of the node's word, 0 if it isn't one
+	sum    float64 // total weight of the words in the subtree
+	max    float64 // largest weight of a word in the subtree, -Inf if none
+	words  int     // number of words in the subtree
+}
+
+// NewWeightedTree returns an empty WeightedTree, the options apply to the Tree
+// that holds the words.
+func NewWeightedTree(opts ...Option) *WeightedTree {
+	return &WeightedTree{tree: NewTree(opts...)}
+}
+
+// nodeWeights returns the weights of node, zero if it has none yet.
+func nodeWeights(node *Node) weights {
+	if w, ok := node.value.(*weights); ok {
+		return *w
+	}
+	return weights{max: math.Inf(-1)}
+}
+
+// Add adds weight to the weight of word, inserting word with that weight if it
+// isn't in w. If word is already in w the DuplicatePolicy of w's tree can
+// instead have the weight replace the old one or be dropped, see
+// WithDuplicatePolicy, and WithDuplicateReport counts the add whatever the
+// policy. It returns the same errors as Tree.Insert, and one matching
+// ErrDuplicateKey for DuplicateError.
+func (w *WeightedTree) Add(word string, weight float64) error {
+	replace := false
+	if node := w.tree.nodeAt(word); node != nil && node.isWord {
+		// The policy may drop weight, the duplicate is reported either way
+		w.tree.dups.add(word)
+		keep, r, err := w.tree.duplicate(word)
+		if !keep {
+			return err
+		}
+		replace = r
+		// word is already in the tree, only its weight changes
+		w.tree.own(word)
+	} else if err := w.tree.Insert(word); err != nil {
+		return err
+	}
+	path := w.path(word)
+	node := path[len(path)-1]
+	nw := nodeWeights(node)
+	if replace {
+		nw.weight = 0
+	}
+	nw.weight += weight
+	node.value = &nw
+	w.refresh(path)
+	return nil
+}
+
+// IncrementWeight adds delta, which may be negative, to the weight of word and
+// returns the new weight, or false if word isn't in w. Unlike Add it never
+// inserts word and ignores the DuplicatePolicy, so feedback such as clicks can
+// tune the ranking of TopK as it arrives, without rebuilding w.
+func (w *WeightedTree) IncrementWeight(word string, delta float64) (float64, bool) {
+	if node := w.tree.nodeAt(word); node == nil || !node.isWord {
+		return 0, false
+	}
+	w.tree.own(word)
+	path := w.path(word)
+	node := path[len(path)-1]
+	nw := nodeWeights(node)
+	nw.weight += delta
+	node.value = &nw
+	w.refresh(path)
+	return nw.weight, true
+}
+
+// Weight returns the weight of word and whether it is in w.
+func (w *WeightedTree) Weight(word string) (float64, bool) {
+	node := w.tree.nodeAt(word)
+	if node == nil || !node.isWord {
+		return 0, false
+	}
+	return nodeWeights(node).weight, true
+}
+
+// Delete removes word and its weight from w, and reports whether it was there.
+func (w *WeightedTree) Delete(word string) bool {
+	if !w.tree.Delete(word) {
+		return false
+	}
+	// A node merged with its child took the child's weights, which are still
+	// right. The nodes left on the path need their totals redone.
+	w.refresh(w.path(word))
+	return true
+}
+
+// path returns the nodes on the path of word from the root, stopping at the
+// last one whose path is a prefix of word.
+func (w *WeightedTree) path(word string) []*Node {
+	cur := w.tree.root
+	path := []*Node{cur}
+	for word != "" {
+		child, exists := cur.children[word[0]]
+		if !exists || len(word) < len(child.label) || word[:len(child.label)] != child.label {
+			break
+		}
+		word = word[len(child.label):]
+		cur = child
+		path = append(path, cur)
+	}
+	return path
+}
+
+// refresh recomputes the totals of the nodes on path, deepest first. The
+// children off the path are up to date.
+func (w *WeightedTree) refresh(path []*Node) {
+	for i := len(path) - 1; i >= 0; i-- {
+		node := path[i]
+		nw := weights{weight: nodeWeights(node).weight, max: math.Inf(-1)}
+		if node.isWord {
+			nw.sum, nw.max, nw.words = nw.weight, nw.weight, 1
+		} else {
+			nw.weight = 0
+		}
+		for _, child := range node.children {
+			cw := nodeWeights(child)
+			nw.sum += cw.sum
+			nw.max = max(nw.max, cw.max)
+			nw.words += cw.words
+		}
+		node.value = &nw
+	}
+}
+
+// SumWeightsWithPrefix returns the total weight of the words in w that start
+// with prefix.
+func (w *WeightedTree) SumWeightsWithPrefix(prefix string) float64 {
+	if node, _ := w.tree.descend(prefix); node != nil {
+		return nodeWeights(node).sum
+	}
+	return 0
+}
+
+// CountWithPrefix returns the number of words in w that start with prefix.
+func (w *WeightedTree) CountWithPrefix(prefix string) int {
+	if node, _ := w.tree.descend(prefix); node != nil {
+		return nodeWeights(node).words
+	}
+	return 0
+}
+
+// A WeightedWord is a word returned by TopK and its weight.
+type WeightedWord struct {
+	Word   string
+	Weight float64
+}
+
+// TopK returns the k words in w with the highest weights that start with
+// prefix, highest first and words of equal weight in ascending order. If k <= 0
+// all of them are returned. The search is best first on the largest weight
+// below each node, so only the subtrees that can hold one of the k words are
+// visited.
+func (w *WeightedTree) TopK(prefix string, k int) []WeightedWord {
+	var top []WeightedWord
+	node, path := w.tree.descend(prefix)
+	if node == nil {
+		return top
+	}
+	w.tree.traverse(node, path, traversal{
+		rank: func(node *Node) (float64, float64) {
+			weights := nodeWeights(node)
+			return weights.max, weights.weight
+		},
+		visit: func(word string, node *Node, _ int) step {
+			top = append(top, WeightedWord{word, nodeWeights(node).weight})
+			if len(top) == k {
+				return stepStop
+			}
+			return stepInto
+		},
+	})
+	return top
+}
+
+// A rankEntry is a word, or a subtree with the largest weight in it, waiting
+// in a rankQueue.
+type rankEntry struct {
+	node *Node
+	path string
+	word bool    // whether the entry is the node's word rather than its subtree
+	key  float64 // the word's weight, or the largest weight in the subtree
+
+	state int // the state of path in the traversal, see traversal.advance
+}
+
+// rankQueue orders entries by key, highest first, then by path. A subtree's
+// words all follow its path and no other entry's path falls between them, so
+// words of equal weight come out in ascending order.
+type rankQueue []rankEntry
+
+func (q rankQueue) Len() int { return len(q) }
+func (q rankQueue) Less(i, j int) bool {
+	if q[i].key != q[j].key {
+		return q[i].key > q[j].key
+	}
+	return q[i].path < q[j].path
+}
+func (q rankQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *rankQueue) Push(x any)   { *q = append(*q, x.(rankEntry)) }
+func (q *rankQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// TotalWeight returns the total weight of the words in w.
+func (w *WeightedTree) TotalWeight() float64 {
+	return nodeWeights(w.tree.root).sum
+}
+
+// Len returns the number of words in w.
+func (w *WeightedTree) Len() int {
+	return w.tree.WordCount()
+}
+
+// Tree returns the tree that holds the words of w, for queries that
+// WeightedTree doesn't provide. It must not be changed directly, that would
+// leave the totals out of date.
+func (w *WeightedTree) Tree() *Tree {
+	return w.tree
+}
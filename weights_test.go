@@ -0,0 +1,206 @@
+package compressedtrie
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWeightedTree(t *testing.T) {
+	w := NewWeightedTree()
+	for word, weight := range map[string]float64{"how to cook": 5, "how to code": 3, "how tall": 2, "hot": 1, "how to": 4} {
+		w.Add(word, weight)
+	}
+	w.Add("hot", 1)
+
+	cases := []struct {
+		Prefix string
+		Sum    float64
+		Count  int
+	}{
+		{"", 16, 5},
+		{"how to", 12, 3},
+		{"how to co", 8, 2},
+		{"ho", 16, 5},
+		{"hot", 2, 1},
+		{"how t", 14, 4},
+		{"why", 0, 0},
+	}
+	for _, tc := range cases {
+		if sum, count := w.SumWeightsWithPrefix(tc.Prefix), w.CountWithPrefix(tc.Prefix); sum != tc.Sum || count != tc.Count {
+			t.Errorf("%q: expected %v over %d words, got %v over %d", tc.Prefix, tc.Sum, tc.Count, sum, count)
+		}
+	}
+	if weight, ok := w.Weight("hot"); !ok || weight != 2 {
+		t.Errorf("Expected hot to weigh 2, got %v %v", weight, ok)
+	}
+	if _, ok := w.Weight("how t"); ok {
+		t.Errorf("Unexpected weight for a prefix")
+	}
+
+	if !w.Delete("how to") || w.Delete("how to") {
+		t.Errorf("Expected to delete how to once")
+	}
+	w.Delete("hot")
+	if sum, count := w.SumWeightsWithPrefix("how to"), w.CountWithPrefix("how to"); sum != 8 || count != 2 {
+		t.Errorf("Expected 8 over 2 words after deleting, got %v over %d", sum, count)
+	}
+	if w.TotalWeight() != 10 || w.Len() != 3 {
+		t.Errorf("Expected a total of 10 over 3 words, got %v over %d", w.TotalWeight(), w.Len())
+	}
+}
+
+func TestWeightedTreeAgrees(t *testing.T) {
+	// The totals agree with adding up the words
+	words := perfWords(2000)
+	r := rand.New(rand.NewPCG(1, 2))
+	w := NewWeightedTree()
+	weight := make(map[string]float64)
+	for _, word := range words {
+		n := float64(r.IntN(100))
+		w.Add(word, n)
+		weight[word] += n
+		if r.IntN(4) == 0 {
+			victim := words[r.IntN(len(words))]
+			if w.Delete(victim) {
+				delete(weight, victim)
+			}
+		}
+	}
+	for _, prefix := range []string{"", "a", "b", "ca", "st", "zz"} {
+		var sum float64
+		count := 0
+		for word, n := range weight {
+			if strings.HasPrefix(word, prefix) {
+				sum += n
+				count++
+			}
+		}
+		if w.SumWeightsWithPrefix(prefix) != sum || w.CountWithPrefix(prefix) != count {
+			t.Errorf("%q: expected %v over %d words, got %v over %d", prefix, sum, count, w.SumWeightsWithPrefix(prefix), w.CountWithPrefix(prefix))
+		}
+	}
+}
+
+func TestWeightedTopK(t *testing.T) {
+	w := NewWeightedTree()
+	for word, weight := range map[string]float64{"how to cook": 5, "how to code": 3, "how tall": 2, "hot": 1, "how to": 4, "hotel": 3} {
+		w.Add(word, weight)
+	}
+	expected := []WeightedWord{{"how to cook", 5}, {"how to", 4}, {"hotel", 3}}
+	if top := w.TopK("ho", 3); !slices.Equal(top, expected) {
+		t.Errorf("Expected %v, got %v", expected, top)
+	}
+
+	// Feedback reorders the completions without a rebuild
+	if weight, ok := w.IncrementWeight("how tall", 4); !ok || weight != 6 {
+		t.Errorf("Expected how tall to weigh 6, got %v %v", weight, ok)
+	}
+	if _, ok := w.IncrementWeight("how t", 1); ok {
+		t.Errorf("Incremented a word that isn't in the tree")
+	}
+	w.IncrementWeight("how to cook", -4)
+	expected = []WeightedWord{{"how tall", 6}, {"how to", 4}, {"how to code", 3}, {"how to cook", 1}}
+	if top := w.TopK("how", 0); !slices.Equal(top, expected) {
+		t.Errorf("Expected %v, got %v", expected, top)
+	}
+	// Equal weights in ascending order
+	expected = []WeightedWord{{"how tall", 6}, {"how to", 4}, {"hotel", 3}, {"how to code", 3}, {"hot", 1}}
+	if top := w.TopK("", 5); !slices.Equal(top, expected) {
+		t.Errorf("Expected %v, got %v", expected, top)
+	}
+	if w.TotalWeight() != 18 || w.Len() != 6 {
+		t.Errorf("Expected a total of 18 over 6 words, got %v over %d", w.TotalWeight(), w.Len())
+	}
+	if top := w.TopK("x", 3); len(top) != 0 {
+		t.Errorf("Unexpected top words %v", top)
+	}
+
+	// Revisions keep their weights
+	h := NewWeightedTree(WithHistory(2))
+	h.Add("alpha", 1)
+	h.Add("beta", 2)
+	r := h.Tree().Commit()
+	h.IncrementWeight("alpha", 5)
+	old, _ := h.Tree().At(r)
+	if w := nodeWeights(old.nodeAt("alpha")).weight; w != 1 {
+		t.Errorf("Revision saw the increment, alpha weighs %v", w)
+	}
+	if top := h.TopK("", 1); top[0].Word != "alpha" {
+		t.Errorf("Expected alpha on top, got %v", top)
+	}
+}
+
+func TestWeightedTopKAgrees(t *testing.T) {
+	words := perfWords(2000)
+	r := rand.New(rand.NewPCG(3, 4))
+	w := NewWeightedTree()
+	weight := make(map[string]float64)
+	for _, word := range words {
+		n := float64(r.IntN(50))
+		w.Add(word, n)
+		weight[word] += n
+	}
+	for range 3000 {
+		word := words[r.IntN(len(words))]
+		delta := float64(r.IntN(21) - 10)
+		w.IncrementWeight(word, delta)
+		weight[word] += delta
+	}
+	for _, prefix := range []string{"", "a", "st", "zz"} {
+		var all []WeightedWord
+		for word, n := range weight {
+			if strings.HasPrefix(word, prefix) {
+				all = append(all, WeightedWord{word, n})
+			}
+		}
+		slices.SortFunc(all, func(a, b WeightedWord) int {
+			if c := cmp.Compare(b.Weight, a.Weight); c != 0 {
+				return c
+			}
+			return strings.Compare(a.Word, b.Word)
+		})
+		if top := w.TopK(prefix, 10); !slices.Equal(top, all[:min(10, len(all))]) {
+			t.Errorf("%q: expected %v, got %v", prefix, all[:min(10, len(all))], top)
+		}
+	}
+}
+
+func TestWeightedTopKStopsEarly(t *testing.T) {
+	// The max weight hints lead straight to the heaviest words, so a small k
+	// visits a sliver of a popular prefix
+	w := NewWeightedTree()
+	for i, word := range perfWords(10000) {
+		w.Add(word, float64(i%97))
+	}
+	nodes := w.Tree().NodeCount()
+	allocs := testing.AllocsPerRun(10, func() { w.TopK("", 3) })
+	if allocs > float64(nodes)/20 {
+		t.Errorf("TopK of 3 made %v allocations in a tree of %d nodes", allocs, nodes)
+	}
+}
+
+func BenchmarkWeightedTopK(b *testing.B) {
+	w := NewWeightedTree()
+	for i, word := range perfWords(10000) {
+		w.Add(word, float64(i%97))
+	}
+	b.Run("TopK", func(b *testing.B) {
+		for b.Loop() {
+			w.TopK("", 10)
+		}
+	})
+	b.Run("Gather", func(b *testing.B) {
+		for b.Loop() {
+			var all []WeightedWord
+			for _, word := range w.Tree().FindWordsWithPrefix("") {
+				weight, _ := w.Weight(word)
+				all = append(all, WeightedWord{word, weight})
+			}
+			slices.SortFunc(all, func(a, b WeightedWord) int { return cmp.Compare(b.Weight, a.Weight) })
+			_ = all[:10]
+		}
+	})
+}